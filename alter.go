@@ -0,0 +1,273 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+
+	"github.com/kelindar/column/commit"
+)
+
+// RenameColumn renames a column (or an index) of the collection, along with the computed
+// columns which depend on it. The data of the column is kept as is. Reserved columns such
+// as the primary key can not be renamed.
+//
+// The rename is not written into the commit log, so the collections which replay the log
+// (e.g. replicas) must be renamed the same way before replaying the subsequent commits.
+func (c *Collection) RenameColumn(oldName, newName string) error {
+	if oldName == "" || newName == "" {
+		return fmt.Errorf("column: rename column must specify both names")
+	}
+
+	if err := c.beginWrite(); err != nil {
+		return err
+	}
+
+	// Block every commit while the registry is being updated. The spilled chunks must be
+	// reloaded first, since their files refer to the columns by name.
+	defer c.endWrite()
+	defer c.writeUnlockAll()
+	if err := c.writeLockAll(); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	source, ok := c.cols.Load(oldName)
+	switch {
+	case !ok:
+		return fmt.Errorf("column: unable to rename column '%s', does not exist", oldName)
+	case c.isReserved(oldName):
+		return fmt.Errorf("column: unable to rename column '%s', column is reserved", oldName)
+	}
+
+	if _, exists := c.cols.Load(newName); exists {
+		return fmt.Errorf("column: unable to rename column '%s', '%s' already exists", oldName, newName)
+	}
+
+	// Point the computed columns and the views to the new name of their source
+	if indexes, ok := c.cols.LoadWithIndex(oldName); ok {
+		for _, index := range indexes[1:] {
+			retarget(index.Column, oldName, newName)
+		}
+	}
+
+	c.cols.Range(func(view *column) {
+		if _, ok := view.Column.(*columnView); ok {
+			retarget(view.Column, oldName, newName)
+		}
+	})
+
+	c.cols.Rename(oldName, newName)
+	c.cols.Replace(source, columnFor(newName, source.Column))
+	return nil
+}
+
+// AlterColumn changes the type of a column, converting every existing value of the column
+// with the convert function and storing the result in the new column. If the function
+// returns nil, the row is left without a value. The indexes built on the column are then
+// rebuilt from the converted values, the triggers are kept but not invoked, and the views
+// are recomputed.
+//
+// Like BulkLoad, the converted values bypass the commit logger, so a snapshot should be
+// taken afterwards if durability is required, and the collections which replay the log
+// must be altered the same way before replaying the subsequent commits.
+func (c *Collection) AlterColumn(columnName string, newColumn Column, convert func(value any) any) error {
+	if newColumn == nil || convert == nil {
+		return fmt.Errorf("column: alter column must specify the column and conversion function")
+	}
+
+	// Block every commit while the data is being migrated
+	if err := c.beginWrite(); err != nil {
+		return err
+	}
+
+	defer c.endWrite()
+	defer c.writeUnlockAll()
+	if err := c.writeLockAll(); err != nil {
+		return err
+	}
+
+	columns, ok := c.cols.LoadWithIndex(columnName)
+	switch {
+	case !ok:
+		return fmt.Errorf("column: unable to alter column '%s', does not exist", columnName)
+	case isComputed(columns[0]):
+		return fmt.Errorf("column: unable to alter column '%s', column is computed", columnName)
+	case c.isReserved(columnName):
+		return fmt.Errorf("column: unable to alter column '%s', column is reserved", columnName)
+	}
+
+	switch newColumn.(type) {
+	case primaryKey, computed:
+		return fmt.Errorf("column: unable to alter column '%s' into %s", columnName, typeName(newColumn))
+	}
+
+	target := columnFor(columnName, newColumn)
+	for _, index := range columns[1:] {
+		if _, ok := index.Column.(*columnTrigram); ok && !target.IsTextual() {
+			return fmt.Errorf("column: unable to alter column '%s', trigram index '%s' requires a textual column",
+				columnName, index.name)
+		}
+	}
+
+	capacity := c.capacity()
+	c.lock.Lock()
+	fill := c.fill.Clone(nil)
+	c.lock.Unlock()
+
+	// Convert every value of the existing column and write it into the new one
+	target.Grow(capacity)
+	chunks := c.chunks()
+	buffer := commit.NewBuffer(c.Count())
+	reader := commit.NewReader()
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		var err error
+		buffer.Reset(columnName)
+		chunk.Range(fill, func(idx uint32) {
+			if err == nil {
+				err = convertAt(columns[0], target, buffer, idx, convert)
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+
+		reader.Seek(buffer)
+		target.Apply(chunk, reader)
+	}
+
+	// Rebuild the indexes from the converted values
+	rebuilt := make([]*column, 0, len(columns))
+	rebuilt = append(rebuilt, target)
+	for _, index := range columns[1:] {
+		rebuilt = append(rebuilt, rebuildIndex(index, target, chunks, capacity))
+	}
+
+	c.lock.Lock()
+	for i, prev := range columns {
+		c.cols.Replace(prev, rebuilt[i])
+	}
+	c.lock.Unlock()
+
+	// Recompute the views, since their queries may read the converted values
+	c.refreshViews()
+	return nil
+}
+
+// RebuildIndex discards the content of an index (bitmap, sorted or trigram) and builds it
+// again from the values of its source column. Every commit is blocked while the index is
+// being rebuilt. Nothing is written into the commit log, since the indexes are derived
+// from the values which were already logged.
+func (c *Collection) RebuildIndex(indexName string) error {
+	if err := c.beginWrite(); err != nil {
+		return err
+	}
+
+	defer c.endWrite()
+	defer c.writeUnlockAll()
+	if err := c.writeLockAll(); err != nil {
+		return err
+	}
+
+	index, ok := c.cols.Load(indexName)
+	switch {
+	case !ok:
+		return fmt.Errorf("column: unable to rebuild index '%s', does not exist", indexName)
+	case !isComputed(index):
+		return fmt.Errorf("column: unable to rebuild index '%s', column is not an index", indexName)
+	}
+
+	source, ok := c.cols.Load(index.Column.(computed).Column())
+	if !ok {
+		return fmt.Errorf("column: unable to rebuild index '%s', source column does not exist", indexName)
+	}
+
+	fresh := rebuildIndex(index, source, c.chunks(), c.capacity())
+	if fresh == index {
+		return fmt.Errorf("column: unable to rebuild index '%s', column is not an index", indexName)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cols.Replace(index, fresh)
+	return nil
+}
+
+// convertAt converts the value of a row and writes it into the buffer of the target column
+func convertAt(source, target *column, buffer *commit.Buffer, idx uint32, convert func(any) any) error {
+	value, ok := source.Value(idx)
+	if !ok {
+		return nil
+	}
+
+	converted := convert(value)
+	switch {
+	case converted == nil:
+		return nil
+	case !target.Accepts(converted):
+		return fmt.Errorf("column: unable to alter column '%s', value %v of row %d is not accepted by %s",
+			source.name, converted, idx, typeName(target.Column))
+	}
+
+	encoded, err := encodeValue(target, converted)
+	if err != nil {
+		return err
+	}
+
+	return buffer.PutAny(commit.Put, idx, encoded)
+}
+
+// rebuildIndex creates a fresh copy of an index and fills it with the values of the source
+// column. The triggers are copied without the values they captured from the previous
+// column, and the other computed columns are returned as is.
+func rebuildIndex(index, source *column, chunks int, capacity uint32) *column {
+	var fresh *column
+	switch v := index.Column.(type) {
+	case *columnIndex:
+		fresh = newIndex(index.name, v.name, v.rule)
+	case *columnSortIndex:
+		fresh = newSortIndex(index.name, v.name, source.Column)
+	case *columnTrigram:
+		fresh = newTrigramIndex(index.name, v.name)
+	case *columnTrigger:
+		return columnFor(index.name, &columnTrigger{name: v.name, clbk: v.clbk})
+	case *columnAudit:
+		return columnFor(index.name, &columnAudit{name: v.name, before: make(map[uint32]any), clbk: v.clbk})
+	default:
+		return index
+	}
+
+	fresh.Grow(capacity)
+	buffer := commit.NewBuffer(int(capacity))
+	reader := commit.NewReader()
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		if source.Snapshot(chunk, buffer) {
+			reader.Seek(buffer)
+			fresh.Apply(chunk, reader)
+		}
+	}
+	return fresh
+}
+
+// retarget changes the name of the source column of a computed column, or of a column
+// read by the query of a view
+func retarget(index Column, oldName, newName string) {
+	switch v := index.(type) {
+	case *columnIndex:
+		v.name = newName
+	case *columnTrigger:
+		v.name = newName
+	case *columnSortIndex:
+		v.name = newName
+	case *columnTrigram:
+		v.name = newName
+	case *columnAudit:
+		v.name = newName
+	case *columnView:
+		v.rename(oldName, newName)
+	}
+}
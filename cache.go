@@ -0,0 +1,274 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- Packed Row ----------------------------
+
+// PackedRow represents a copy of the values of a single row, packed in the order of the
+// columns of the collection. It remains valid once it has been loaded, and is not
+// modified by subsequent commits.
+type PackedRow struct {
+	Index  uint32     // The index of the row
+	schema *rowSchema // The layout of the values
+	values []any      // The values of the row, in the order of the schema
+}
+
+// Get returns the value of the specified column, if present in the row.
+func (r PackedRow) Get(columnName string) (any, bool) {
+	if r.schema == nil {
+		return nil, false
+	}
+
+	if i, ok := r.schema.names[columnName]; ok && r.values[i] != nil {
+		return r.values[i], true
+	}
+	return nil, false
+}
+
+// rowSchema represents the layout of a packed row.
+type rowSchema struct {
+	version uint64         // The generation of the columns when the schema was built
+	columns []*column      // The non-computed columns of the collection
+	names   map[string]int // The position of each column in the row
+}
+
+// newRowSchema creates a schema for the current columns of the collection.
+func newRowSchema(c *Collection) *rowSchema {
+	schema := &rowSchema{
+		version: c.cols.Generation(),
+		columns: make([]*column, 0, 8),
+		names:   make(map[string]int, 8),
+	}
+
+	c.cols.Range(func(column *column) {
+		if !isComputed(column) {
+			schema.names[column.name] = len(schema.columns)
+			schema.columns = append(schema.columns, column)
+		}
+	})
+	return schema
+}
+
+// pack copies the values of a row. The binary values are copied as well, since they
+// are shared with the column. The caller must hold the lock of the chunk.
+func (s *rowSchema) pack(idx uint32) PackedRow {
+	values := make([]any, len(s.columns))
+	for i, c := range s.columns {
+		if v, ok := c.Value(idx); ok {
+			values[i] = copyValue(v)
+		}
+	}
+
+	return PackedRow{
+		Index:  idx,
+		schema: s,
+		values: values,
+	}
+}
+
+// copyValue copies a value loaded from a column, so that it does not share the memory of
+// the column. Only the binary values are shared with the column.
+func copyValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return append([]byte(nil), b...)
+	}
+	return v
+}
+
+// --------------------------- Row Cache ----------------------------
+
+// cacheShards is the maximum number of shards of the row cache
+const cacheShards = 16
+
+// rowCache represents a bounded cache of packed rows, which evicts the least recently
+// used rows first. The rows are spread across shards by index, each with its own lock,
+// and the cached rows of a chunk are invalidated when the chunk is committed.
+type rowCache struct {
+	schema atomic.Pointer[rowSchema] // The current layout of the rows
+	shards []rowShard                // The shards of the cache
+}
+
+// rowShard represents a shard of the row cache.
+type rowShard struct {
+	lock     sync.Mutex
+	capacity int                                       // The maximum number of rows to keep
+	recent   *list.List                                // The cached rows, most recently used first
+	chunks   map[commit.Chunk]map[uint32]*list.Element // The cached rows, by chunk and index
+}
+
+// newRowCache creates a new row cache with a specified capacity.
+func newRowCache(capacity int) *rowCache {
+	count := cacheShards
+	if capacity < count {
+		count = capacity
+	}
+
+	cache := &rowCache{
+		shards: make([]rowShard, count),
+	}
+	for i := range cache.shards {
+		shard := &cache.shards[i]
+		shard.capacity = (capacity + count - 1) / count
+		shard.recent = list.New()
+		shard.chunks = make(map[commit.Chunk]map[uint32]*list.Element, 8)
+	}
+	return cache
+}
+
+// schemaOf returns the current schema, and builds a new one if the columns of the
+// collection have changed since it was built. The rows packed with a previous schema
+// are discarded once they are loaded.
+func (c *rowCache) schemaOf(owner *Collection) *rowSchema {
+	if schema := c.schema.Load(); schema != nil && schema.version == owner.cols.Generation() {
+		return schema
+	}
+
+	schema := newRowSchema(owner)
+	c.schema.Store(schema)
+	return schema
+}
+
+// shardOf returns the shard which contains the row at a specified index
+func (c *rowCache) shardOf(idx uint32) *rowShard {
+	return &c.shards[idx%uint32(len(c.shards))]
+}
+
+// load attempts to load a row from the cache.
+func (c *rowCache) load(owner *Collection, idx uint32) (PackedRow, bool) {
+	schema := c.schemaOf(owner)
+	shard := c.shardOf(idx)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	e, ok := shard.chunks[commit.ChunkAt(idx)][idx]
+	switch {
+	case !ok:
+		return PackedRow{}, false
+	case e.Value.(PackedRow).schema != schema:
+		shard.remove(e)
+		return PackedRow{}, false
+	default:
+		shard.recent.MoveToFront(e)
+		return e.Value.(PackedRow), true
+	}
+}
+
+// store packs a row and adds it to the cache, evicting the least recently used row of
+// its shard if the shard is full. The caller must hold the lock of the chunk.
+func (c *rowCache) store(owner *Collection, idx uint32) PackedRow {
+	row := c.schemaOf(owner).pack(idx)
+	shard := c.shardOf(idx)
+	shard.lock.Lock()
+	defer shard.lock.Unlock()
+
+	chunk := commit.ChunkAt(idx)
+	if e, ok := shard.chunks[chunk][idx]; ok {
+		e.Value = row
+		shard.recent.MoveToFront(e)
+		return row
+	}
+
+	if shard.recent.Len() >= shard.capacity {
+		shard.remove(shard.recent.Back())
+	}
+
+	rows, ok := shard.chunks[chunk]
+	if !ok {
+		rows = make(map[uint32]*list.Element, 8)
+		shard.chunks[chunk] = rows
+	}
+
+	rows[idx] = shard.recent.PushFront(row)
+	return row
+}
+
+// invalidate removes the cached rows of a chunk, so they are packed again on their next
+// load. The caller must hold the write lock of the chunk.
+func (c *rowCache) invalidate(chunk commit.Chunk) {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.lock.Lock()
+		for _, e := range shard.chunks[chunk] {
+			shard.recent.Remove(e)
+		}
+		delete(shard.chunks, chunk)
+		shard.lock.Unlock()
+	}
+}
+
+// len returns the number of cached rows
+func (c *rowCache) len() (n int) {
+	for i := range c.shards {
+		shard := &c.shards[i]
+		shard.lock.Lock()
+		n += shard.recent.Len()
+		shard.lock.Unlock()
+	}
+	return
+}
+
+// remove removes a cached row. The caller must hold the lock of the shard.
+func (s *rowShard) remove(e *list.Element) {
+	row := s.recent.Remove(e).(PackedRow)
+	chunk := commit.ChunkAt(row.Index)
+	if rows := s.chunks[chunk]; len(rows) > 1 {
+		delete(rows, row.Index)
+	} else {
+		delete(s.chunks, chunk)
+	}
+}
+
+// --------------------------- Collection ----------------------------
+
+// LoadRow loads a copy of all of the values of the row at a specified index. If the
+// collection was created with a HotRows option, the rows are served from a cache whose
+// rows are invalidated when their chunk is committed, so the hottest rows do not touch
+// the column chunks.
+func (c *Collection) LoadRow(idx uint32) (PackedRow, bool) {
+	if c.hot != nil {
+		if row, ok := c.hot.load(c, idx); ok {
+			return row, true
+		}
+	}
+
+	chunk := commit.ChunkAt(idx)
+	err := c.readLockChunk(chunk)
+	defer c.slock.RUnlock(uint(chunk))
+	if err != nil {
+		return PackedRow{}, false
+	}
+
+	c.lock.RLock()
+	exists := c.fill.Contains(idx)
+	c.lock.RUnlock()
+	switch {
+	case !exists:
+		return PackedRow{}, false
+	case c.hot != nil:
+		return c.hot.store(c, idx), true
+	default:
+		return newRowSchema(c).pack(idx), true
+	}
+}
+
+// LoadRowByKey loads a copy of all of the values of the row with a specified primary
+// key, using the row cache if enabled.
+func (c *Collection) LoadRowByKey(key string) (PackedRow, bool) {
+	if c.pk == nil {
+		return PackedRow{}, false
+	}
+
+	if idx, ok := c.pk.OffsetOf(key); ok {
+		return c.LoadRow(idx)
+	}
+	return PackedRow{}, false
+}
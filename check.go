@@ -0,0 +1,222 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// checkSample is the maximum number of chunks sampled when verifying index predicates
+const checkSample = 8
+
+// Report represents the result of an integrity check of the collection.
+type Report struct {
+	Chunks int     // The number of chunks checked
+	Rows   int     // The number of rows checked
+	Issues []Issue // The list of violated invariants
+	Err    error   // The error which interrupted the check, if any
+}
+
+// OK returns whether the check has completed without finding any issue.
+func (r Report) OK() bool {
+	return r.Err == nil && len(r.Issues) == 0
+}
+
+// Issue represents a single violated invariant found during the check.
+type Issue struct {
+	Column  string       // The column (or index) in which the issue was found
+	Chunk   commit.Chunk // The chunk in which the issue was found
+	Message string       // The description of the issue
+}
+
+// String returns a human-readable representation of the issue
+func (i Issue) String() string {
+	return fmt.Sprintf("column '%s' (chunk %d): %s", i.Column, i.Chunk, i.Message)
+}
+
+// Check validates the internal invariants of the collection and returns a structured
+// report. It verifies that the values of every column are only present for rows which
+// exist in the collection, that the primary key lookup table agrees with the key column,
+// that the bitmap indexes agree with their predicates on a sample of chunks, and that
+// the commit IDs are tracked for every chunk. The check is done chunk by chunk, holding
+// the appropriate read locks, and can be interrupted by cancelling the context.
+func (c *Collection) Check(ctx context.Context) (report Report) {
+	chunks := c.chunks()
+	report.Chunks = chunks
+
+	// Every chunk with data must have a corresponding last commit ID
+	c.lock.RLock()
+	if commits := len(c.commits); commits < chunks {
+		report.add("", commit.Chunk(commits), "missing commit IDs for %d chunk(s)", chunks-commits)
+	}
+	c.lock.RUnlock()
+
+	buffer := commit.NewBuffer(chunkSize)
+	reader := commit.NewReader()
+	sample := chunks/checkSample + 1
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		if err := ctx.Err(); err != nil {
+			report.Err = err
+			return
+		}
+
+		if err := c.readLockChunk(chunk); err != nil {
+			c.slock.RUnlock(uint(chunk))
+			report.Err = err
+			return
+		}
+
+		c.lock.RLock()
+		fill := chunk.OfBitmap(c.fill)
+		report.Rows += fill.Count()
+		c.checkFill(&report, chunk, fill)
+		c.checkKey(&report, chunk, fill)
+		c.lock.RUnlock()
+
+		if int(chunk)%sample == 0 {
+			c.checkIndexes(&report, chunk, buffer, reader)
+		}
+		c.slock.RUnlock(uint(chunk))
+	}
+	return
+}
+
+// checkFill checks that each column only contains values for rows which exist.
+func (c *Collection) checkFill(report *Report, chunk commit.Chunk, fill bitmap.Bitmap) {
+	var orphans bitmap.Bitmap
+	c.cols.Range(func(column *column) {
+		index := column.Index(chunk)
+		if len(index) == 0 {
+			return
+		}
+
+		index.Clone(&orphans)
+		orphans.AndNot(fill)
+		if count := orphans.Count(); count > 0 {
+			report.add(column.name, chunk, "%d value(s) present for rows which do not exist", count)
+		}
+	})
+}
+
+// checkKey checks that the primary key lookup table agrees with the key column.
+func (c *Collection) checkKey(report *Report, chunk commit.Chunk, fill bitmap.Bitmap) {
+	if c.pk == nil {
+		return
+	}
+
+	chunk.Range(fill, func(idx uint32) {
+		key, ok := c.pk.LoadString(idx)
+		if !ok {
+			return // Row might be reserved by an insert in progress
+		}
+
+		if at, ok := c.pk.OffsetOf(key); !ok || at != idx {
+			report.add(c.pkName, chunk, "key '%s' of row %d is not found in the lookup table", key, idx)
+		}
+	})
+}
+
+// checkIndexes checks that the bitmap indexes agree with their predicates.
+func (c *Collection) checkIndexes(report *Report, chunk commit.Chunk, buffer *commit.Buffer, reader *commit.Reader) {
+	c.cols.Range(func(column *column) {
+		index, ok := column.Column.(*columnIndex)
+		if !ok {
+			return
+		}
+
+		source, ok := c.cols.Load(index.Column())
+		if !ok {
+			report.add(column.name, chunk, "source column '%s' does not exist", index.Column())
+			return
+		}
+
+		if !source.Snapshot(chunk, buffer) {
+			return
+		}
+
+		// Re-evaluate the predicate on every stored value of the source column
+		reader.Seek(buffer)
+		for reader.Next() {
+			if reader.Type != commit.Put {
+				continue
+			}
+
+			if want, has := index.rule(reader), index.fill.Contains(reader.Index()); want != has {
+				report.add(column.name, chunk, "row %d is expected to be indexed=%v", reader.Index(), want)
+			}
+		}
+	})
+}
+
+// VerifyIndexes re-evaluates the predicates of every bitmap index on all of the values of
+// their source columns, and returns the number of rows whose indexed state is wrong for
+// each index which has at least one. An empty result means that all of the indexes are
+// consistent, while the inconsistent ones can be repaired with RebuildIndex. An error is
+// returned if a spilled chunk can not be reloaded, since its values can not be verified.
+func (c *Collection) VerifyIndexes() (map[string]int, error) {
+	chunks := c.chunks()
+	buffer := commit.NewBuffer(chunkSize)
+	reader := commit.NewReader()
+	result := make(map[string]int)
+	var expect bitmap.Bitmap
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		if err := c.readLockChunk(chunk); err != nil {
+			c.slock.RUnlock(uint(chunk))
+			return nil, err
+		}
+
+		c.cols.Range(func(column *column) {
+			index, ok := column.Column.(*columnIndex)
+			if !ok {
+				return
+			}
+
+			// Compute the expected index of the chunk from the stored values
+			expect.Clear()
+			if source, ok := c.cols.Load(index.Column()); ok && source.Snapshot(chunk, buffer) {
+				reader.Seek(buffer)
+				for reader.Next() {
+					if reader.Type == commit.Put && index.rule(reader) {
+						expect.Set(reader.IndexAtChunk())
+					}
+				}
+			}
+
+			if count := countXor(expect, index.Index(chunk)); count > 0 {
+				result[column.name] += count
+			}
+		})
+		c.slock.RUnlock(uint(chunk))
+	}
+	return result, nil
+}
+
+// countXor counts the number of bits which are set in only one of the bitmaps
+func countXor(a, b bitmap.Bitmap) (count int) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y uint64
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		count += bits.OnesCount64(x ^ y)
+	}
+	return
+}
+
+// add adds an issue to the report
+func (r *Report) add(column string, chunk commit.Chunk, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Column:  column,
+		Chunk:   chunk,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
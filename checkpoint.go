@@ -0,0 +1,307 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+const (
+	checkpointSnapshot = "snapshot-" // The prefix of the snapshot files
+	checkpointLog      = "commits-"  // The prefix of the commit log files
+)
+
+// CheckpointPolicy represents the policy of the periodic snapshots of a collection. When
+// enabled, the commits are also written into a commit log in the directory, which is
+// rotated at every checkpoint, so that RestoreCheckpoint can recover the commits made
+// after the last snapshot.
+type CheckpointPolicy struct {
+	Interval time.Duration // The interval between the snapshots, disabled if zero
+	Dir      string        // The directory of the snapshots and the commit logs
+	Keep     int           // The number of snapshots to keep, all of them if zero
+	OnError  func(error)   // The callback receiving the errors of the checkpoints (optional)
+}
+
+// checkpointer represents a commit logger which writes the commits into a commit log,
+// rotated at every checkpoint, and forwards them to the next logger.
+type checkpointer struct {
+	lock   sync.Mutex       // The lock to protect the commit log
+	policy CheckpointPolicy // The checkpoint policy
+	log    *commit.Log      // The current commit log
+	seq    int64            // The sequence number of the current commit log
+	next   commit.Logger    // The logger configured in the options (optional)
+	muted  bool             // Whether the commits are not written, while restoring
+}
+
+// newCheckpointer creates a new checkpointer and opens its first commit log
+func newCheckpointer(policy CheckpointPolicy, next commit.Logger) (*checkpointer, error) {
+	if err := os.MkdirAll(policy.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	c := &checkpointer{policy: policy, next: next}
+	if _, err := c.rotate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Append appends the commit into the current commit log and forwards it to the next logger
+func (c *checkpointer) Append(change commit.Commit) error {
+	c.lock.Lock()
+	var err error
+	if !c.muted {
+		err = c.log.Append(change)
+	}
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if c.next != nil {
+		return c.next.Append(change)
+	}
+	return nil
+}
+
+// rotate closes the current commit log and opens a new one. It returns the sequence number
+// of the new log, which is also used to name the snapshot taken right after.
+func (c *checkpointer) rotate() (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Keep the sequence numbers strictly increasing, even if the clock is not
+	seq := time.Now().UnixNano()
+	if seq <= c.seq {
+		seq = c.seq + 1
+	}
+
+	log, err := commit.OpenFile(c.pathOf(checkpointLog, seq, ".log"))
+	if err != nil {
+		return 0, err
+	}
+
+	prev := c.log
+	c.log, c.seq = log, seq
+	if prev != nil {
+		return seq, prev.Close()
+	}
+	return seq, nil
+}
+
+// mute sets whether the commits are written into the commit log
+func (c *checkpointer) mute(muted bool) {
+	c.lock.Lock()
+	c.muted = muted
+	c.lock.Unlock()
+}
+
+// Close closes the current commit log
+func (c *checkpointer) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.log.Close()
+}
+
+// pathOf returns the path of a file of the checkpoint directory
+func (c *checkpointer) pathOf(prefix string, seq int64, ext string) string {
+	return filepath.Join(c.policy.Dir, fmt.Sprintf("%s%020d%s", prefix, seq, ext))
+}
+
+// --------------------------- Checkpoints ----------------------------
+
+// checkpoints writes the periodic snapshots, until the context is cancelled
+func (c *Collection) checkpoints(ctx context.Context, cp *checkpointer) {
+	ticker := time.NewTicker(cp.policy.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			if err := c.Checkpoint(); err != nil && cp.policy.OnError != nil {
+				cp.policy.OnError(err)
+			}
+		}
+	}
+}
+
+// Checkpoint rotates the commit log and writes a snapshot into the checkpoint directory,
+// then deletes the snapshots and commit logs which are no longer retained. This is done
+// periodically when a checkpoint policy is configured, but can also be called manually.
+func (c *Collection) Checkpoint() error {
+	cp := c.checkpoint
+	if cp == nil {
+		return fmt.Errorf("column: unable to checkpoint, no checkpoint policy is configured")
+	}
+
+	seq, err := cp.rotate()
+	if err != nil {
+		return err
+	}
+
+	// Write the snapshot into a temporary file first, so it is never partially written
+	name := cp.pathOf(checkpointSnapshot, seq, ".bin")
+	if err := c.snapshotFile(name + ".tmp"); err != nil {
+		os.Remove(name + ".tmp")
+		return err
+	}
+
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	return cp.prune()
+}
+
+// snapshotFile writes a snapshot into a file and syncs it to the disk
+func (c *Collection) snapshotFile(name string) error {
+	dst, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer dst.Close()
+	if err := c.Snapshot(dst); err != nil {
+		return err
+	}
+	return dst.Sync()
+}
+
+// prune deletes the snapshots beyond the retention, along with the commit logs which are
+// older than the oldest snapshot retained.
+func (c *checkpointer) prune() error {
+	snapshots, err := c.list(checkpointSnapshot, ".bin")
+	if err != nil || c.policy.Keep <= 0 || len(snapshots) <= c.policy.Keep {
+		return err
+	}
+
+	oldest := snapshots[len(snapshots)-c.policy.Keep]
+	for _, seq := range snapshots[:len(snapshots)-c.policy.Keep] {
+		if err := os.Remove(c.pathOf(checkpointSnapshot, seq, ".bin")); err != nil {
+			return err
+		}
+	}
+
+	logs, err := c.list(checkpointLog, ".log")
+	for _, seq := range logs {
+		if seq < oldest {
+			if err := os.Remove(c.pathOf(checkpointLog, seq, ".log")); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// list returns the sorted sequence numbers of the files with the prefix and extension
+func (c *checkpointer) list(prefix, ext string) ([]int64, error) {
+	files, err := os.ReadDir(c.policy.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]int64, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		seq, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), 10, 64)
+		if err == nil {
+			out = append(out, seq)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out, nil
+}
+
+// RestoreCheckpoint restores the collection from the latest snapshot of the checkpoint
+// directory, then replays the commit logs written since that snapshot. A new checkpoint
+// is written once restored. This should be called right after the collection is created,
+// before any transaction.
+func (c *Collection) RestoreCheckpoint() error {
+	cp := c.checkpoint
+	if cp == nil {
+		return fmt.Errorf("column: unable to restore, no checkpoint policy is configured")
+	}
+
+	// The restored commits are already in the directory, so do not log them twice
+	cp.mute(true)
+	err := c.restoreCheckpoint(cp)
+	cp.mute(false)
+	if err != nil {
+		return err
+	}
+	return c.Checkpoint()
+}
+
+// restoreCheckpoint restores the latest snapshot and replays the commit logs
+func (c *Collection) restoreCheckpoint(cp *checkpointer) error {
+	snapshots, err := cp.list(checkpointSnapshot, ".bin")
+	if err != nil {
+		return err
+	}
+
+	// Restore the latest snapshot, if any
+	var since int64
+	var commits map[commit.Chunk]uint64
+	if len(snapshots) > 0 {
+		since = snapshots[len(snapshots)-1]
+		src, err := os.Open(cp.pathOf(checkpointSnapshot, since, ".bin"))
+		if err != nil {
+			return err
+		}
+
+		commits, err = c.restore(src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	logs, err := cp.list(checkpointLog, ".log")
+	if err != nil {
+		return err
+	}
+
+	// Replay the commit logs written since, except the one currently written
+	cp.lock.Lock()
+	current := cp.seq
+	cp.lock.Unlock()
+	for _, seq := range logs {
+		if seq < since || seq >= current {
+			continue
+		}
+
+		log, err := commit.OpenFile(cp.pathOf(checkpointLog, seq, ".log"))
+		if err != nil {
+			return err
+		}
+
+		// Skip the commits made while the snapshot was written, as they are part of it
+		err = log.Range(func(change commit.Commit) error {
+			if change.ID > commits[change.Chunk] {
+				return c.Replay(change)
+			}
+			return nil
+		})
+		log.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
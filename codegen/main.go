@@ -1,20 +1,75 @@
 package main
 
 import (
+	"bytes"
 	_ "embed"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
 //go:embed numbers.tpl
 var numbers string
 
+//go:embed wrapper.tpl
+var wrapper string
+
 type Type struct {
-	Name string
-	Type string
+	Name   string
+	Type   string
+	Kernel string // The vectorized range filter for the type
+}
+
+// Wrapper represents a strongly-typed collection wrapper for a struct
+type Wrapper struct {
+	Package    string  // The package of the generated file
+	Type       string  // The name of the struct, e.g. "Player"
+	Collection string  // The name of the collection, e.g. "Players"
+	Fields     []Field // The fields of the struct mapped to columns
+	Time       bool    // Whether the "time" package needs to be imported
+}
+
+// Field represents a struct field mapped to a column
+type Field struct {
+	Name   string // The name of the struct field
+	Column string // The name of the column
+	Type   string // The Go type of the field
+	Getter string // The results of the accessor's getter
+	Setter string // The results of the accessor's setter
+	Access string // The expression creating the accessor on a transaction
 }
 
 func main() {
+	typeName := flag.String("type", "", "the struct to generate a typed collection wrapper for")
+	collection := flag.String("name", "", "the name of the collection, defaults to the plural of the type")
+	input := flag.String("in", "", "the file containing the struct definition")
+	output := flag.String("out", "", "the file to write the wrapper to")
+	flag.Parse()
+
+	switch {
+	case *typeName == "":
+		generateNumbers()
+	case *input == "" || *output == "":
+		panic("codegen: both -in and -out must be specified along with -type")
+	default:
+		if *collection == "" {
+			*collection = *typeName + "s"
+		}
+
+		generateWrapper(*typeName, *collection, *input, *output)
+	}
+}
+
+// generateNumbers generates the numeric columns of the package
+func generateNumbers() {
 	t, err := template.New("numbers").Parse(numbers)
 	if err != nil {
 		panic(err)
@@ -27,17 +82,162 @@ func main() {
 	}
 
 	if err := t.Execute(dst, []Type{
-		{Name: "Int", Type: "int"},
-		{Name: "Int16", Type: "int16"},
-		{Name: "Int32", Type: "int32"},
-		{Name: "Int64", Type: "int64"},
-		{Name: "Uint", Type: "uint"},
-		{Name: "Uint16", Type: "uint16"},
-		{Name: "Uint32", Type: "uint32"},
-		{Name: "Uint64", Type: "uint64"},
-		{Name: "Float32", Type: "float32"},
-		{Name: "Float64", Type: "float64"},
+		{Name: "Int", Type: "int", Kernel: "rangeInt64s"},
+		{Name: "Int16", Type: "int16", Kernel: "rangeInt16s"},
+		{Name: "Int32", Type: "int32", Kernel: "rangeInt32s"},
+		{Name: "Int64", Type: "int64", Kernel: "rangeInt64s"},
+		{Name: "Uint", Type: "uint", Kernel: "rangeUint64s"},
+		{Name: "Uint16", Type: "uint16", Kernel: "rangeUint16s"},
+		{Name: "Uint32", Type: "uint32", Kernel: "rangeUint32s"},
+		{Name: "Uint64", Type: "uint64", Kernel: "rangeUint64s"},
+		{Name: "Float32", Type: "float32", Kernel: "rangeFloat32s"},
+		{Name: "Float64", Type: "float64", Kernel: "rangeFloat64s"},
 	}); err != nil {
 		panic(err)
 	}
 }
+
+// generateWrapper generates a strongly-typed collection wrapper for a struct
+func generateWrapper(typeName, collection, input, output string) {
+	t, err := template.New("wrapper").Parse(wrapper)
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := parseWrapper(input, typeName)
+	if err != nil {
+		panic(err)
+	}
+
+	w.Collection = collection
+	var src bytes.Buffer
+	if err := t.Execute(&src, w); err != nil {
+		panic(err)
+	}
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		panic(err)
+	}
+
+	if err := os.WriteFile(output, out, os.ModePerm); err != nil {
+		panic(err)
+	}
+}
+
+// parseWrapper parses the struct definition from the input file
+func parseWrapper(input, typeName string) (*Wrapper, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), input, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec *ast.StructType
+	ast.Inspect(file, func(n ast.Node) bool {
+		if t, ok := n.(*ast.TypeSpec); ok && t.Name.Name == typeName {
+			spec, _ = t.Type.(*ast.StructType)
+		}
+		return spec == nil
+	})
+
+	if spec == nil {
+		return nil, fmt.Errorf("codegen: struct %s not found in %s", typeName, input)
+	}
+
+	w := &Wrapper{
+		Package: file.Name.Name,
+		Type:    typeName,
+	}
+
+	for _, f := range spec.Fields.List {
+		var tag string
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(tag).Get("column")
+		}
+
+		for _, ident := range f.Names {
+			if !ident.IsExported() || tag == "-" {
+				continue
+			}
+
+			if ident.Name == "Index" || ident.Name == typeName {
+				return nil, fmt.Errorf("codegen: field %s conflicts with a cursor method", ident.Name)
+			}
+
+			field, err := fieldOf(ident.Name, f.Type, tag)
+			if err != nil {
+				return nil, err
+			}
+
+			w.Time = w.Time || field.Type == "time.Time"
+			w.Fields = append(w.Fields, field)
+		}
+	}
+	return w, nil
+}
+
+// fieldOf maps a struct field to its column accessor, following the same conventions
+// as the struct mapping of the column package.
+func fieldOf(name string, expr ast.Expr, tag string) (Field, error) {
+	column, kind, _ := strings.Cut(tag, ",")
+	if column == "" {
+		column = name
+	}
+
+	field := Field{
+		Name:   name,
+		Column: column,
+		Type:   typeOf(expr),
+		Getter: "(" + typeOf(expr) + ", bool)",
+	}
+
+	switch field.Type {
+	case "string":
+		switch kind {
+		case "":
+			field.Access = fmt.Sprintf("String(%q)", column)
+		case "enum":
+			field.Access = fmt.Sprintf("Enum(%q)", column)
+		case "key":
+			field.Access = "Key()"
+			field.Setter = "error"
+		}
+	case "bool":
+		field.Getter = "bool"
+		field.Access = fmt.Sprintf("Bool(%q)", column)
+	case "time.Time":
+		field.Access = fmt.Sprintf("Time(%q)", column)
+	case "[]byte":
+		field.Access = fmt.Sprintf("Bytes(%q)", column)
+		field.Setter = "error"
+	case "int", "int16", "int32", "int64", "uint", "uint16", "uint32", "uint64", "float32", "float64":
+		field.Access = fmt.Sprintf("%s%s(%q)", strings.ToUpper(field.Type[:1]), field.Type[1:], column)
+	}
+
+	switch {
+	case field.Access == "":
+		return field, fmt.Errorf("codegen: unsupported field %s of type %s", name, field.Type)
+	case kind != "" && field.Type != "string":
+		return field, fmt.Errorf("codegen: unsupported column kind '%s' for field %s", kind, name)
+	default:
+		return field, nil
+	}
+}
+
+// typeOf returns the source representation of a type expression
+func typeOf(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeOf(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + typeOf(t.Elt)
+		}
+	case *ast.StarExpr:
+		return "*" + typeOf(t.X)
+	}
+	return fmt.Sprintf("%T", expr)
+}
@@ -29,7 +29,7 @@ func make{{.Name}}s(opts ...func(*option[{{.Type}}])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, {{.Kernel}}[{{.Type}}], opts,
 	)
 }
 
@@ -49,6 +49,28 @@ func (s rw{{.Name}}) Merge(delta {{.Type}}) {
 	s.writer.Put{{.Name}}(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rw{{.Name}}) UpdateAll(fn func(old {{.Type}}) {{.Type}}) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rw{{.Name}}) AddAll(delta {{.Type}}) {
+	s.rangeAll(func(idx uint32, _ {{.Type}}) {
+		s.writer.Put{{.Name}}(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rw{{.Name}}) ScaleAll(factor {{.Type}}) {
+	s.updateAll(s.writer, func(v {{.Type}}) {{.Type}} { return v * factor })
+}
+
 // {{.Name}} returns a read-write accessor for {{.Type}} column
 func (txn *Txn) {{.Name}}(columnName string) rw{{.Name}} {
 	return rw{{.Name}}{
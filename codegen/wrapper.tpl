@@ -0,0 +1,94 @@
+// This code was generated, DO NOT EDIT.
+// Any changes will be lost if this file is regenerated.
+
+package {{.Package}}
+
+import (
+{{- if .Time }}
+	"time"
+{{ end }}
+	"github.com/kelindar/column"
+)
+
+// {{.Collection}}Collection represents a strongly-typed collection of {{.Type}}
+type {{.Collection}}Collection struct {
+	*column.Collection
+}
+
+// New{{.Collection}}Collection creates a new collection with a column for every field of {{.Type}}
+func New{{.Collection}}Collection(opts ...column.Options) (*{{.Collection}}Collection, error) {
+	c := column.NewCollection(opts...)
+	if err := column.CreateColumnsOfStruct[{{.Type}}](c); err != nil {
+		return nil, err
+	}
+
+	return &{{.Collection}}Collection{Collection: c}, nil
+}
+
+// Insert{{.Type}} inserts a {{.Type}} into the collection and returns its index
+func (c *{{.Collection}}Collection) Insert{{.Type}}(v {{.Type}}) (index uint32, err error) {
+	err = c.Query(func(txn *column.Txn) (err error) {
+		index, err = txn.InsertStruct(&v)
+		return
+	})
+	return
+}
+
+// Query{{.Collection}} runs a strongly-typed transaction on the collection
+func (c *{{.Collection}}Collection) Query{{.Collection}}(fn func({{.Type}}Txn) error, opts ...column.QueryOption) error {
+	return c.Query(func(txn *column.Txn) error {
+		return fn({{.Type}}Txn{Txn: txn})
+	}, opts...)
+}
+
+// {{.Type}}Txn represents a strongly-typed transaction on the collection
+type {{.Type}}Txn struct {
+	*column.Txn
+}
+
+// Range iterates over the selected rows, with a typed cursor pointing to the current row
+func (txn {{.Type}}Txn) Range(fn func({{.Type}}Cursor)) error {
+	cursor := {{.Type}}Cursor{
+		txn: txn.Txn,
+{{- range .Fields }}
+		col{{.Name}}: txn.{{.Access}},
+{{- end }}
+	}
+
+	return txn.Txn.Range(func(uint32) {
+		fn(cursor)
+	})
+}
+
+// {{.Type}}Cursor represents a strongly-typed cursor pointing to the current row
+type {{.Type}}Cursor struct {
+	txn *column.Txn
+{{- range .Fields }}
+	col{{.Name}} interface {
+		Get() {{.Getter}}
+		Set({{.Type}}) {{.Setter}}
+	}
+{{- end }}
+}
+
+// Index returns the index of the current row
+func (c {{.Type}}Cursor) Index() uint32 {
+	return c.txn.Index()
+}
+
+// {{.Type}} scans the current row into a {{.Type}}
+func (c {{.Type}}Cursor) {{.Type}}() (v {{.Type}}, err error) {
+	err = c.txn.ScanStruct(c.txn.Index(), &v)
+	return
+}
+{{ range .Fields }}
+// {{.Name}} returns the value of the "{{.Column}}" column at the current row
+func (c {{$.Type}}Cursor) {{.Name}}() {{.Getter}} {
+	return c.col{{.Name}}.Get()
+}
+
+// Set{{.Name}} sets the value of the "{{.Column}}" column at the current row
+func (c {{$.Type}}Cursor) Set{{.Name}}(v {{.Type}}) {{.Setter}} {
+	{{if .Setter}}return {{end}}c.col{{.Name}}.Set(v)
+}
+{{ end }}
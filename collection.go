@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/bits"
 	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,31 +19,71 @@ import (
 )
 
 const (
-	expireColumn = "expire"
-	rowColumn    = "row"
+	expireColumn     = "expire"
+	expireIndex      = "expire_index"
+	rowColumn        = commit.RowColumn
+	versionColumn    = "version"
+	generationColumn = "generation"
 )
 
 // Collection represents a collection of objects in a columnar format
 type Collection struct {
-	count   uint64             // The current count of elements
-	txns    *txnPool           // The transaction pool
-	lock    sync.RWMutex       // The mutex to guard the fill-list
-	slock   *smutex.SMutex128  // The sharded mutex for the collection
-	cols    columns            // The map of columns
-	fill    bitmap.Bitmap      // The fill-list
-	opts    Options            // The options configured
-	logger  commit.Logger      // The commit logger for CDC
-	record  *commit.Log        // The commit logger for snapshot
-	pk      *columnKey         // The primary key column
-	cancel  context.CancelFunc // The cancellation function for the context
-	commits []uint64           // The array of commit IDs for corresponding chunk
+	count      uint64              // The current count of elements
+	syncID     uint64              // The commit ID to catch up from, for replicas
+	writers    int64               // The number of writes in progress
+	frozen     uint32              // The state of the collection, once it is frozen
+	txns       *txnPool            // The transaction pool
+	lock       sync.RWMutex        // The mutex to guard the fill-list
+	slock      *smutex.SMutex128   // The sharded mutex for the collection
+	rlock      *smutex.SMutex128   // The sharded mutex serializing the replays of each chunk
+	cols       columns             // The map of columns
+	fill       bitmap.Bitmap       // The fill-list
+	opts       Options             // The options configured
+	logger     commit.Logger       // The commit logger for CDC
+	record     *commit.Log         // The commit logger for snapshot
+	pk         primaryKey          // The primary key column
+	pkName     string              // The name of the primary key column
+	ctx        context.Context     // The root context, cancelled when the collection is closed
+	cancel     context.CancelFunc  // The cancellation function for the context
+	commits    []uint64            // The array of commit IDs for corresponding chunk
+	hot        *rowCache           // The cache of hot rows (optional)
+	subs       subscribers         // The subscriptions to the changes
+	vacuums    sync.Once           // Starts the vacuum once the first expiration is set
+	synced     []uint64            // The last commit ID of each chunk, for replicas
+	logged     []uint64            // The last commit ID of each chunk written into the commit log
+	replayed   map[uint64][]uint64 // The last commit ID of each chunk replayed, per source
+	source     uint64              // The identifier of the collection in the commits
+	stats      *writeStats         // The write statistics (optional)
+	hooks      rowHooks            // The hooks observing the inserted and deleted rows
+	batch      *commitBatch        // The batch of commits being coalesced (optional)
+	checkpoint *checkpointer       // The writer of the periodic checkpoints (optional)
+	prefetch   chan prefetchTask   // The queue of the chunks to prefetch (optional)
+	evictor    *evictor            // The access tracking of a size-bounded collection (optional)
+	tier       *tiering            // The hot/cold tiering of the chunks (optional)
 }
 
 // Options represents the options for a collection.
 type Options struct {
-	Capacity int           // The initial capacity when creating columns
-	Writer   commit.Logger // The writer for the commit log (optional)
-	Vacuum   time.Duration // The interval at which the vacuum of expired entries will be done
+	Capacity       int              // The initial capacity when creating columns
+	Writer         commit.Logger    // The writer for the commit log (optional)
+	Vacuum         time.Duration    // The interval at which the vacuum of expired entries will be done
+	Prefetch       bool             // Whether Range prefetches the next chunk while processing one
+	HotRows        int              // The number of rows to keep in the row cache, disabled if zero
+	NoTTL          bool             // Whether the expiration column and its vacuum are disabled
+	Stats          time.Duration    // The sliding window of the write statistics, disabled if zero
+	Versioned      bool             // Whether a version is maintained for every row
+	Generations    bool             // Whether a generation is maintained for every index, for the row IDs
+	Strict         bool             // Whether filtering on unknown columns fails the query
+	QueryLogger    func(Plan)       // The logger receiving the plan of the slow queries (optional)
+	SlowQuery      time.Duration    // The duration above which a query is logged, all of them if zero
+	Metrics        MetricsSink      // The receiver of the metrics (optional)
+	Tracer         Tracer           // The tracer of the queries, snapshots and restores (optional)
+	CommitCoalesce time.Duration    // The window during which small commits are grouped, disabled if zero
+	Checkpoint     CheckpointPolicy // The policy of the periodic snapshots, disabled if no interval
+	MaxRows        int              // The maximum number of rows, beyond which rows are evicted
+	MaxBytes       int64            // The approximate maximum size of the rows, beyond which rows are evicted
+	Eviction       EvictionPolicy   // The order in which the rows are evicted, least recently used by default
+	Tiering        TieringPolicy    // The policy of spilling the idle chunks to disk, disabled if no duration
 }
 
 // NewCollection creates a new columnar collection.
@@ -64,6 +105,55 @@ func NewCollection(opts ...Options) *Collection {
 		if o.Writer != nil {
 			options.Writer = o.Writer
 		}
+		if o.Prefetch {
+			options.Prefetch = true
+		}
+		if o.HotRows > 0 {
+			options.HotRows = o.HotRows
+		}
+		if o.NoTTL {
+			options.NoTTL = true
+		}
+		if o.Stats > 0 {
+			options.Stats = o.Stats
+		}
+		if o.Versioned {
+			options.Versioned = true
+		}
+		if o.Generations {
+			options.Generations = true
+		}
+		if o.Strict {
+			options.Strict = true
+		}
+		if o.Metrics != nil {
+			options.Metrics = o.Metrics
+		}
+		if o.Tracer != nil {
+			options.Tracer = o.Tracer
+		}
+		if o.CommitCoalesce > 0 {
+			options.CommitCoalesce = o.CommitCoalesce
+		}
+		if o.Checkpoint.Interval > 0 {
+			options.Checkpoint = o.Checkpoint
+		}
+		if o.MaxRows > 0 {
+			options.MaxRows = o.MaxRows
+		}
+		if o.MaxBytes > 0 {
+			options.MaxBytes = o.MaxBytes
+		}
+		if o.Eviction != EvictLRU {
+			options.Eviction = o.Eviction
+		}
+		if o.Tiering.After > 0 {
+			options.Tiering = o.Tiering
+		}
+		if o.QueryLogger != nil {
+			options.QueryLogger = o.QueryLogger
+			options.SlowQuery = o.SlowQuery
+		}
 	}
 
 	// Create a new collection
@@ -73,14 +163,69 @@ func NewCollection(opts ...Options) *Collection {
 		txns:   newTxnPool(),
 		opts:   options,
 		slock:  new(smutex.SMutex128),
+		rlock:  new(smutex.SMutex128),
 		fill:   make(bitmap.Bitmap, 0, options.Capacity>>6),
 		logger: options.Writer,
+		source: commit.Next(),
+		ctx:    ctx,
 		cancel: cancel,
 	}
 
-	// Create an expiration column and start the cleanup goroutine
-	store.CreateColumn(expireColumn, ForInt64())
-	go store.vacuum(ctx, options.Vacuum)
+	// Create the row cache and write statistics, if required
+	if options.HotRows > 0 {
+		store.hot = newRowCache(options.HotRows)
+	}
+	if options.Stats > 0 {
+		store.stats = newWriteStats(options.Stats)
+	}
+	if options.CommitCoalesce > 0 {
+		store.batch = &commitBatch{window: options.CommitCoalesce}
+	}
+	if options.MaxRows > 0 || options.MaxBytes > 0 {
+		store.evictor = new(evictor)
+	}
+
+	// Create an expiration column and its sorted index, the cleanup goroutine is started
+	// on first use
+	if !options.NoTTL {
+		store.CreateColumn(expireColumn, ForInt64())
+		store.CreateSortIndex(expireIndex, expireColumn)
+	}
+
+	// Create a version column, incremented every time a row is modified
+	if options.Versioned {
+		store.CreateColumn(versionColumn, ForUint64())
+	}
+
+	// Create a generation column, incremented every time a row is deleted
+	if options.Generations {
+		store.CreateColumn(generationColumn, makeGenerations())
+	}
+
+	// Start writing the periodic checkpoints, if required
+	if policy := options.Checkpoint; policy.Interval > 0 && policy.Dir != "" {
+		cp, err := newCheckpointer(policy, options.Writer)
+		switch {
+		case err != nil && policy.OnError != nil:
+			policy.OnError(err)
+		case err == nil:
+			store.logger = cp
+			store.checkpoint = cp
+			go store.checkpoints(ctx, cp)
+		}
+	}
+
+	// Start prefetching the chunks read by the transactions, if required
+	if options.Prefetch {
+		store.prefetch = make(chan prefetchTask, maxPrefetches)
+		go store.prefetches(ctx)
+	}
+
+	// Start spilling the idle chunks to disk, if required
+	if policy := options.Tiering; policy.After > 0 && policy.Dir != "" {
+		store.tier = newTiering(policy)
+		go store.tiers(ctx)
+	}
 	return store
 }
 
@@ -133,6 +278,44 @@ func (c *Collection) Insert(fn func(Row) error) (index uint32, err error) {
 	return
 }
 
+// InsertObject adds an object to the collection and returns the allocated index.
+func (c *Collection) InsertObject(obj map[string]any) (index uint32, err error) {
+	err = c.Query(func(txn *Txn) (innerErr error) {
+		index, innerErr = txn.InsertObject(obj)
+		return
+	})
+	return
+}
+
+// UpsertObject inserts or updates an object, keyed by the value of the specified primary
+// key column, in a single transaction.
+func (c *Collection) UpsertObject(obj map[string]any, keyColumn string) error {
+	return c.Query(func(txn *Txn) error {
+		return txn.UpsertObject(obj, keyColumn)
+	})
+}
+
+// InsertManyObjects adds a set of objects to the collection in a single transaction.
+// Unlike a regular transaction, it continues past objects which can not be inserted
+// (e.g. unknown column or mismatched type) and reports an error for each of them,
+// so a single bad record does not abort the whole batch. The indexes of successfully
+// inserted objects are returned in the same order as the input.
+func (c *Collection) InsertManyObjects(objs []map[string]any) (inserted []uint32, errs []error) {
+	c.Query(func(txn *Txn) error {
+		for i, obj := range objs {
+			idx, err := txn.InsertObject(obj)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("column: unable to insert object #%d: %w", i, err))
+				continue
+			}
+
+			inserted = append(inserted, idx)
+		}
+		return nil
+	})
+	return
+}
+
 // DeleteAt attempts to delete an item at the specified index for this collection. If the item
 // exists, it marks at as deleted and returns true, otherwise it returns false.
 func (c *Collection) DeleteAt(idx uint32) (deleted bool) {
@@ -148,17 +331,176 @@ func (c *Collection) Count() (count int) {
 	return int(atomic.LoadUint64(&c.count))
 }
 
+// CountConsistent returns the total number of elements in the collection, computed
+// from the fill list while no commit can be applied. Unlike Count, which reads an
+// atomic counter that may briefly lag behind the fill list while a commit is being
+// applied, this always agrees with the rows visible to a transaction started at the
+// same point in time. Rows reserved by an insert which is still in progress are
+// counted, same as they are present in the fill list.
+func (c *Collection) CountConsistent() (count int) {
+	c.readLockAll()
+	c.lock.RLock()
+	count = c.fill.Count()
+	c.lock.RUnlock()
+	c.readUnlockAll()
+	return
+}
+
+// ChunkSize is the number of rows in a chunk. The rows of a collection are partitioned in
+// chunks, which are locked independently, so they can be processed in parallel.
+const ChunkSize = commit.ChunkSize
+
+// Chunks returns the number of chunks of the collection, which is the number of chunks
+// up to the one containing the last row.
+func (c *Collection) Chunks() int {
+	return c.chunks()
+}
+
+// ChunkCount returns the number of rows in a specified chunk of the collection
+func (c *Collection) ChunkCount(chunk int) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return commit.Chunk(chunk).OfBitmap(c.fill).Count()
+}
+
+// ChunkRange executes a query over the rows of a specified chunk of the collection only.
+// Since the chunks are independent, the queries of different chunks can be executed in
+// parallel to partition the work.
+func (c *Collection) ChunkRange(chunk int, fn func(txn *Txn) error) error {
+	return c.Query(func(txn *Txn) error {
+		txn.initialize()
+		txn.index.Filter(func(idx uint32) bool {
+			return commit.ChunkAt(idx) == commit.Chunk(chunk)
+		})
+		return fn(txn)
+	})
+}
+
+// CacheStats returns the predicate cache statistics of an enum column. If the column
+// does not exist or is not an enum, this returns false.
+func (c *Collection) CacheStats(columnName string) (CacheStats, bool) {
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return CacheStats{}, false
+	}
+
+	enum, ok := column.Column.(*columnEnum)
+	if !ok {
+		return CacheStats{}, false
+	}
+
+	return enum.Stats(), true
+}
+
+// Vocabulary returns the distinct values of an enum column, which for a column created
+// with ForEnumOf() is its fixed vocabulary. If the column does not exist or is not an
+// enum, this returns false.
+func (c *Collection) Vocabulary(columnName string) ([]string, bool) {
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return nil, false
+	}
+
+	enum, ok := column.Column.(*columnEnum)
+	if !ok {
+		return nil, false
+	}
+
+	return enum.Vocabulary(), true
+}
+
+// ColumnInfo describes a column registered in the collection.
+type ColumnInfo struct {
+	Name     string `json:"name"`     // The name of the column
+	Type     string `json:"type"`     // The type of the column, such as "float64" or "index"
+	Computed bool   `json:"computed"` // Whether the column is computed (e.g. index or trigger)
+}
+
+// Columns returns the description of the columns registered in the collection, including
+// the computed ones, in the order they were created.
+func (c *Collection) Columns() []ColumnInfo {
+	out := make([]ColumnInfo, 0, 8)
+	c.cols.Range(func(column *column) {
+		out = append(out, ColumnInfo{
+			Name:     column.name,
+			Type:     typeName(column.Column),
+			Computed: isComputed(column),
+		})
+	})
+	return out
+}
+
+// typeName returns a short name of the column implementation
+func typeName(column Column) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", column), "*column.")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		return strings.TrimSuffix(name[i+1:], "]")
+	}
+	return strings.ToLower(strings.TrimPrefix(name, "column"))
+}
+
 // createColumnKey attempts to create a primary key column
-func (c *Collection) createColumnKey(columnName string, column *columnKey) error {
+func (c *Collection) createColumnKey(columnName string, column primaryKey) error {
 	if c.pk != nil {
 		return fmt.Errorf("column: unable to create key column '%s', another one exists", columnName)
 	}
 
 	c.pk = column
-	c.pk.name = columnName
+	c.pkName = columnName
 	return nil
 }
 
+// Reserve grows the fill list and all of the columns up-front, so that the collection can
+// hold the specified number of rows without growing during the commits. This avoids the
+// incremental growth pauses when loading a large number of rows.
+func (c *Collection) Reserve(n int) {
+	if n > 0 {
+		c.grow(commit.ChunkAt(uint32(n - 1)))
+	}
+}
+
+// grow grows the commits array, the fill list and all of the columns until they can hold
+// every row of the last chunk specified.
+func (c *Collection) grow(last commit.Chunk) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.commits) >= int(last+1) {
+		return
+	}
+
+	// Grow the commits array
+	for len(c.commits) < int(last+1) {
+		c.commits = append(c.commits, 0)
+	}
+
+	// Grow the fill list and all of the columns
+	max := last.Max()
+	c.fill.Grow(max)
+	c.cols.Range(func(column *column) {
+		column.Grow(max)
+	})
+}
+
+// capacity returns the capacity required for a column to cover every chunk of the
+// collection, since rows may be located past the number of rows after deletions, or
+// the capacity may have been reserved up-front.
+func (c *Collection) capacity() uint32 {
+	c.lock.RLock()
+	reserved := len(c.commits)
+	c.lock.RUnlock()
+
+	chunks := c.chunks()
+	if reserved > chunks {
+		chunks = reserved
+	}
+
+	capacity := commit.Chunk(chunks).Min()
+	if c.opts.Capacity > int(capacity) {
+		capacity = uint32(c.opts.Capacity)
+	}
+	return capacity
+}
+
 // CreateColumnsOf registers a set of columns that are present in the target map.
 func (c *Collection) CreateColumnsOf(value map[string]any) error {
 	for k, v := range value {
@@ -181,21 +523,42 @@ func (c *Collection) CreateColumn(columnName string, column Column) error {
 	}
 
 	// Grow the column to the current capacity
-	capacity := uint32(atomic.LoadUint64(&c.count))
-	if c.opts.Capacity > int(capacity) {
-		capacity = uint32(c.opts.Capacity)
-	}
-
-	column.Grow(capacity)
+	column.Grow(c.capacity())
 	c.cols.Store(columnName, columnFor(columnName, column))
 
 	// If necessary, create a primary key column
-	if pk, ok := column.(*columnKey); ok {
+	if pk, ok := column.(primaryKey); ok {
 		return c.createColumnKey(columnName, pk)
 	}
 	return nil
 }
 
+// CreateColumnWith creates a column similarly to CreateColumn, and populates it with the
+// values returned by the backfill function for every existing row, in a single pass over
+// the chunks of the collection. If the function returns nil, the row is left without a
+// value. If the backfill fails, the column is dropped and the error is returned.
+func (c *Collection) CreateColumnWith(columnName string, column Column, backfill func(r Row) any) error {
+	if backfill == nil {
+		return fmt.Errorf("column: create column must specify a backfill function")
+	}
+
+	if _, ok := column.(primaryKey); ok {
+		return fmt.Errorf("column: unable to backfill key column '%s'", columnName)
+	}
+
+	if err := c.CreateColumn(columnName, column); err != nil {
+		return err
+	}
+
+	if err := c.Query(func(txn *Txn) error {
+		return txn.backfill(columnName, backfill)
+	}); err != nil {
+		c.DropColumn(columnName)
+		return err
+	}
+	return nil
+}
+
 // DropColumn removes the column (or an index) with the specified name. If the column with this
 // name does not exist, this operation is a no-op.
 func (c *Collection) DropColumn(columnName string) {
@@ -210,6 +573,22 @@ func (c *Collection) CreateTrigger(triggerName, columnName string, fn func(r Rea
 		return fmt.Errorf("column: create trigger must specify name, column and function")
 	}
 
+	return c.createTrigger(columnName, newTrigger(triggerName, columnName, fn))
+}
+
+// CreateTriggerContext creates a trigger similar to CreateTrigger, but the trigger function
+// also receives the context of the transaction which is being committed. This is the
+// context given to QueryContext, or the root context of the collection otherwise.
+func (c *Collection) CreateTriggerContext(triggerName, columnName string, fn func(ctx context.Context, r Reader)) error {
+	if fn == nil || columnName == "" || triggerName == "" {
+		return fmt.Errorf("column: create trigger must specify name, column and function")
+	}
+
+	return c.createTrigger(columnName, newTriggerContext(triggerName, columnName, fn))
+}
+
+// createTrigger adds the trigger column for the specified column
+func (c *Collection) createTrigger(columnName string, trigger *column) error {
 	// Prior to creating an index, we should have a column
 	column, ok := c.cols.Load(columnName)
 	if !ok {
@@ -217,9 +596,8 @@ func (c *Collection) CreateTrigger(triggerName, columnName string, fn func(r Rea
 	}
 
 	// Create and add the trigger column
-	trigger := newTrigger(triggerName, columnName, fn)
 	c.lock.Lock()
-	c.cols.Store(triggerName, trigger)
+	c.cols.Store(trigger.name, trigger)
 	c.cols.Store(columnName, column, trigger)
 	c.lock.Unlock()
 	return nil
@@ -258,6 +636,10 @@ func (c *Collection) CreateIndex(indexName, columnName string, fn func(r Reader)
 		return fmt.Errorf("column: unable to create index, column '%v' does not exist", columnName)
 	}
 
+	if isCounter(column) {
+		return fmt.Errorf("column: unable to create index, column '%v' is a counter", columnName)
+	}
+
 	// Create and add the index column,
 	index := newIndex(indexName, columnName, fn)
 	c.lock.Lock()
@@ -272,10 +654,12 @@ func (c *Collection) CreateIndex(indexName, columnName string, fn func(r Reader)
 	buffer := commit.NewBuffer(c.Count())
 	reader := commit.NewReader()
 	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		c.readLockChunk(chunk)
 		if column.Snapshot(chunk, buffer) {
 			reader.Seek(buffer)
 			index.Apply(chunk, reader)
 		}
+		c.slock.RUnlock(uint(chunk))
 	}
 
 	return nil
@@ -294,6 +678,10 @@ func (c *Collection) CreateSortIndex(indexName, columnName string) error {
 		return fmt.Errorf("column: unable to create index, column '%v' does not exist", columnName)
 	}
 
+	if isCounter(column) {
+		return fmt.Errorf("column: unable to create index, column '%v' is a counter", columnName)
+	}
+
 	// Check to make sure index does not already exist
 	_, ok = c.cols.Load(indexName)
 	if ok {
@@ -301,7 +689,68 @@ func (c *Collection) CreateSortIndex(indexName, columnName string) error {
 	}
 
 	// Create and add the index column,
-	index := newSortIndex(indexName, columnName)
+	index := newSortIndex(indexName, columnName, column.Column)
+	c.lock.Lock()
+	c.cols.Store(indexName, index)
+	c.cols.Store(columnName, column, index)
+	c.lock.Unlock()
+
+	// Fill the index with all of the values of the target column
+	c.reindexSorted(index.Column.(*columnSortIndex), column)
+	return nil
+}
+
+// reindexSorted fills a sorted index with the values of its source column, chunk by chunk.
+// Each chunk is locked while being indexed, so that the index remains consistent with the
+// commits happening concurrently.
+func (c *Collection) reindexSorted(index *columnSortIndex, source *column) {
+	chunks := c.chunks()
+	buffer := commit.NewBuffer(c.Count())
+	reader := commit.NewReader()
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		c.readLockChunk(chunk)
+		if source.Snapshot(chunk, buffer) {
+			reader.Seek(buffer)
+			index.reindex(chunk, reader)
+		} else {
+			index.reindex(chunk, nil)
+		}
+		c.slock.RUnlock(uint(chunk))
+	}
+}
+
+// rebuildSorted rebuilds every sorted index of the collection from its source column
+func (c *Collection) rebuildSorted() {
+	c.cols.Range(func(column *column) {
+		if index, ok := column.Column.(*columnSortIndex); ok {
+			if source, ok := c.cols.Load(index.name); ok {
+				c.reindexSorted(index, source)
+			}
+		}
+	})
+}
+
+// CreateTrigramIndex creates a trigram index column with a specified name which depends
+// on a given string column. This index is used by WithPrefix and WithContains in order
+// to avoid scanning every value of the column.
+func (c *Collection) CreateTrigramIndex(indexName, columnName string) error {
+	if columnName == "" || indexName == "" {
+		return fmt.Errorf("column: create index must specify name & column")
+	}
+
+	// Prior to creating an index, we should have a textual column
+	column, ok := c.cols.Load(columnName)
+	if !ok || !column.IsTextual() {
+		return fmt.Errorf("column: unable to create index, column '%v' does not exist or is not textual", columnName)
+	}
+
+	// Check to make sure index does not already exist
+	if _, ok := c.cols.Load(indexName); ok {
+		return fmt.Errorf("column: unable to create index, index '%v' already exist", indexName)
+	}
+
+	// Create and add the index column,
+	index := newTrigramIndex(indexName, columnName)
 	c.lock.Lock()
 	c.cols.Store(indexName, index)
 	c.cols.Store(columnName, column, index)
@@ -352,27 +801,103 @@ func (c *Collection) QueryAt(idx uint32, fn func(Row) error) error {
 // Query creates a transaction which allows for filtering and iteration over the
 // columns in this collection. It also allows for individual rows to be modified or
 // deleted during iteration (range), but the actual operations will be queued and
-// executed after the iteration.
-func (c *Collection) Query(fn func(txn *Txn) error) error {
+// executed after the iteration. The options can limit the duration of the query and
+// the number of rows it scans.
+func (c *Collection) Query(fn func(txn *Txn) error, opts ...QueryOption) error {
+	return c.query(nil, fn, opts)
+}
+
+// query executes the query with the specified context, or the root context if nil.
+func (c *Collection) query(ctx context.Context, fn func(txn *Txn) error, opts []QueryOption) error {
 	txn := c.txns.acquire(c)
+	if ctx != nil {
+		txn.ctx = ctx
+	}
+
+	span := txn.startSpan("column.Query")
+	for _, opt := range opts {
+		opt(txn)
+	}
+	var start time.Time
+	if c.opts.QueryLogger != nil || c.opts.Metrics != nil {
+		start = time.Now()
+	}
 
 	// Execute the query and keep the error for later
-	if err := fn(txn); err != nil {
+	err := fn(txn)
+	if err == nil && txn.limited {
+		err = txn.expired()
+	}
+	if err == nil {
+		err = txn.err
+	}
+
+	if err != nil {
+		txn.freeInserts()
 		txn.rollback()
-		c.txns.release(txn)
+		txn.endSpan(span, err)
+		c.release(txn, start)
 		return err
 	}
 
 	// Now that the iteration has finished, we can range over the pending action
 	// queue and apply all of the actions that were requested by the Selector.
-	txn.commit()
-	c.txns.release(txn)
-	return nil
+	err = txn.commit()
+	txn.endSpan(span, err)
+	c.release(txn, start)
+	if err == nil {
+		c.evictPending()
+	}
+	return err
+}
+
+// QueryContext creates a transaction similar to Query, but carries the specified context
+// through the write path: it is available via txn.Context() and is passed to the triggers
+// created with CreateTriggerContext when the transaction is committed. If the context is
+// cancelled by the time fn returns, the transaction is rolled back and the context error
+// is returned.
+func (c *Collection) QueryContext(ctx context.Context, fn func(txn *Txn) error, opts ...QueryOption) error {
+	return c.query(ctx, func(txn *Txn) error {
+		if err := fn(txn); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}, opts)
+}
+
+// QueryConsistent creates a transaction similar to Query, but the entire callback is
+// executed against a consistent view of the collection: no commit can be applied
+// while fn runs, so filters, iteration and aggregates (Sum, Avg, Min, Max...) all
+// observe the same state instead of possibly straddling a concurrent commit.
+//
+// The guarantee is per chunk commit. Since a transaction touching several chunks is
+// committed chunk by chunk, the view may still contain such a transaction partially
+// applied if it was in the middle of committing when the view was taken. Updates made
+// within fn are committed after the view has been released. This blocks all writers
+// for the duration of fn, so it should be kept short.
+func (c *Collection) QueryConsistent(fn func(txn *Txn) error) error {
+	return c.Query(func(txn *Txn) error {
+		err := c.readLockAll()
+		txn.consistent = true
+		defer func() {
+			txn.consistent = false
+			c.readUnlockAll()
+		}()
+
+		if err != nil {
+			return err
+		}
+		return fn(txn)
+	})
 }
 
 // Close closes the collection and clears up all of the resources.
 func (c *Collection) Close() error {
 	c.cancel()
+	c.removeSpilled()
+	if c.checkpoint != nil {
+		return c.checkpoint.Close()
+	}
 	return nil
 }
 
@@ -392,6 +917,27 @@ func (c *Collection) UpsertKey(key string, fn func(Row) error) error {
 	})
 }
 
+// UpsertKeyMany inserts or updates a row with the specified primary key, merging the
+// values into the existing row. See Row.MergeMany for more details.
+func (c *Collection) UpsertKeyMany(key string, values map[string]any) error {
+	return c.Query(func(txn *Txn) error {
+		return txn.UpsertKeyMany(key, values)
+	})
+}
+
+// UpsertKeysMany inserts or updates many rows, keyed by their primary keys, in a single
+// transaction. If any of the rows fails, none of them are committed.
+func (c *Collection) UpsertKeysMany(rows map[string]map[string]any) error {
+	return c.Query(func(txn *Txn) error {
+		for _, key := range sortedKeys(rows) {
+			if err := txn.UpsertKeyMany(key, rows[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // QueryKey queries/updates a row given its corresponding primary key.
 func (c *Collection) QueryKey(key string, fn func(Row) error) error {
 	return c.Query(func(txn *Txn) error {
@@ -411,11 +957,13 @@ func (c *Collection) DeleteKey(key string) error {
 // columns represents a concurrent column registry.
 type columns struct {
 	cols *atomic.Value
+	gen  *uint64 // The generation, incremented every time the registry changes
 }
 
 func makeColumns(capacity int) columns {
 	data := columns{
 		cols: &atomic.Value{},
+		gen:  new(uint64),
 	}
 
 	data.cols.Store(make([]columnEntry, 0, capacity))
@@ -439,6 +987,12 @@ func (c *columns) Count() (count int) {
 	return
 }
 
+// Generation returns the generation of the registry, which changes every time a column is
+// added, removed, renamed or replaced.
+func (c *columns) Generation() uint64 {
+	return atomic.LoadUint64(c.gen)
+}
+
 // Range iterates over columns in the registry. This is faster than RangeUntil
 // method.
 func (c *columns) Range(fn func(column *column)) {
@@ -484,6 +1038,7 @@ func (c *columns) LoadWithIndex(columnName string) ([]*column, bool) {
 
 // Store stores a column into the registry.
 func (c *columns) Store(columnName string, main *column, index ...*column) {
+	defer atomic.AddUint64(c.gen, 1)
 
 	// Try to update an existing entry
 	columns := c.cols.Load().([]columnEntry)
@@ -516,6 +1071,7 @@ func (c *columns) Store(columnName string, main *column, index ...*column) {
 
 // DeleteColumn deletes a column from the registry.
 func (c *columns) DeleteColumn(columnName string) {
+	defer atomic.AddUint64(c.gen, 1)
 	columns := c.cols.Load().([]columnEntry)
 	filtered := make([]columnEntry, 0, cap(columns))
 	for _, v := range columns {
@@ -528,6 +1084,7 @@ func (c *columns) DeleteColumn(columnName string) {
 
 // Delete deletes a column from the registry.
 func (c *columns) DeleteIndex(columnName, indexName string) {
+	defer atomic.AddUint64(c.gen, 1)
 	index, _ := c.Load(indexName)
 	columns := c.cols.Load().([]columnEntry)
 	for i, v := range columns {
@@ -548,3 +1105,36 @@ func (c *columns) DeleteIndex(columnName, indexName string) {
 
 	c.cols.Store(columns)
 }
+
+// Rename changes the name of an entry in the registry.
+func (c *columns) Rename(oldName, newName string) {
+	defer atomic.AddUint64(c.gen, 1)
+	columns := c.cols.Load().([]columnEntry)
+	renamed := make([]columnEntry, 0, cap(columns))
+	for _, v := range columns {
+		if v.name == oldName {
+			v.name = newName
+		}
+		renamed = append(renamed, v)
+	}
+	c.cols.Store(renamed)
+}
+
+// Replace replaces every occurrence of a column in the registry, both as a main column
+// and as a computed one, by another column.
+func (c *columns) Replace(prev, next *column) {
+	defer atomic.AddUint64(c.gen, 1)
+	columns := c.cols.Load().([]columnEntry)
+	replaced := make([]columnEntry, 0, cap(columns))
+	for _, v := range columns {
+		cols := make([]*column, 0, len(v.cols))
+		for _, col := range v.cols {
+			if col == prev {
+				col = next
+			}
+			cols = append(cols, col)
+		}
+		replaced = append(replaced, columnEntry{name: v.name, cols: cols})
+	}
+	c.cols.Store(replaced)
+}
@@ -4,9 +4,15 @@
 package column
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"io"
+	"math"
+	"os"
+	"reflect"
 	"runtime"
 	"strconv"
 	"sync"
@@ -51,6 +57,16 @@ func BenchmarkCollection(b *testing.B) {
 		assert.NotEmpty(b, name)
 	})
 
+	b.Run("read-at", func(b *testing.B) {
+		balance := 0.0
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			balance, _ = ReadNumber[float64](players, 20, "balance")
+		}
+		assert.NotZero(b, balance)
+	})
+
 	b.Run("scan", func(b *testing.B) {
 		b.ReportAllocs()
 		b.ResetTimer()
@@ -68,6 +84,31 @@ func BenchmarkCollection(b *testing.B) {
 		}
 	})
 
+	b.Run("scan-range", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			players.Query(func(txn *Txn) error {
+				txn.WithStringEqual("race", "human").
+					WithStringEqual("class", "mage").
+					WithFloat64Range("age", 30, math.Inf(1)).
+					Count()
+				return nil
+			})
+		}
+	})
+
+	b.Run("scan-between", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			players.Query(func(txn *Txn) error {
+				txn.WithFloat64Range("balance", 1000, 3000).Count()
+				return nil
+			})
+		}
+	})
+
 	b.Run("count", func(b *testing.B) {
 		b.ReportAllocs()
 		b.ResetTimer()
@@ -766,6 +807,50 @@ func TestInsertWithTTL(t *testing.T) {
 	}))
 }
 
+func TestWithExpiringBefore(t *testing.T) {
+	c := NewCollection(Options{Vacuum: time.Hour})
+	c.CreateColumn("name", ForKey())
+	for i, ttl := range []time.Duration{time.Minute, time.Hour, 0, 2 * time.Minute} {
+		assert.NoError(t, c.InsertKey(fmt.Sprintf("key-%d", i), func(r Row) error {
+			r.SetTTL(ttl)
+			return nil
+		}))
+	}
+
+	expiring := func(d time.Duration) (keys []string) {
+		c.Query(func(txn *Txn) error {
+			key := txn.Key()
+			return txn.WithExpiringBefore(time.Now().Add(d)).Range(func(idx uint32) {
+				v, _ := key.Get()
+				keys = append(keys, v)
+			})
+		})
+		return
+	}
+
+	assert.Empty(t, expiring(0))
+	assert.ElementsMatch(t, []string{"key-0", "key-3"}, expiring(5*time.Minute))
+	assert.ElementsMatch(t, []string{"key-0", "key-1", "key-3"}, expiring(24*time.Hour))
+
+	// Update the expiration of the keys
+	assert.NoError(t, c.ExpireKey("key-0", 0))
+	assert.NoError(t, c.ExpireKey("key-2", time.Minute))
+	assert.Error(t, c.ExpireKey("key-9", time.Minute))
+	assert.ElementsMatch(t, []string{"key-2", "key-3"}, expiring(5*time.Minute))
+
+	// Deleted rows are removed from the index
+	assert.NoError(t, c.DeleteKey("key-3"))
+	assert.ElementsMatch(t, []string{"key-2"}, expiring(5*time.Minute))
+
+	// Without a time-to-live, nothing is expiring
+	noTTL := NewCollection(Options{NoTTL: true})
+	assert.ErrorIs(t, noTTL.ExpireKey("key-0", time.Minute), errNoTTL)
+	assert.NoError(t, noTTL.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithExpiringBefore(time.Now()).Count())
+		return nil
+	}))
+}
+
 func TestCreateColumnsOfInvalidKind(t *testing.T) {
 	obj := map[string]interface{}{
 		"name": complex64(1),
@@ -831,6 +916,54 @@ func TestReplica(t *testing.T) {
 	})
 }
 
+func TestReplayCompactedLog(t *testing.T) {
+	log := commit.Open(bytes.NewBuffer(nil))
+	newCollection := func(opts ...Options) *Collection {
+		c := NewCollection(opts...)
+		c.CreateColumn("name", ForString())
+		c.CreateColumn("cnt", ForInt())
+		return c
+	}
+
+	// Insert, update and delete some rows while writing the commit log
+	source := newCollection(Options{Writer: log})
+	for i := 0; i < 100; i++ {
+		source.Insert(func(r Row) error {
+			r.SetString("name", fmt.Sprintf("row %d", i))
+			r.SetInt("cnt", i)
+			return nil
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		source.Query(func(txn *Txn) error {
+			cnt := txn.Int("cnt")
+			return txn.Range(func(idx uint32) {
+				cnt.Merge(1)
+			})
+		})
+	}
+
+	source.Query(func(txn *Txn) error {
+		txn.WithValue("cnt", func(v any) bool {
+			return v.(int) >= 53
+		}).DeleteAll()
+		return nil
+	})
+
+	// Replay the compacted log into a replica
+	compacted := bytes.NewBuffer(nil)
+	assert.NoError(t, log.Compact(compacted))
+
+	target := newCollection()
+	assert.NoError(t, commit.Open(compacted).Range(target.Replay))
+	assert.Equal(t, 50, target.Count())
+	target.Query(func(txn *Txn) error {
+		assert.Equal(t, 50*49/2+50*3, txn.Int("cnt").Sum())
+		return nil
+	})
+}
+
 // --------------------------- Create/Drop Trigger ----------------------------
 
 func TestTriggerCreate(t *testing.T) {
@@ -868,6 +1001,46 @@ func TestTriggerCreate(t *testing.T) {
 	assert.Len(t, updates, 6)
 }
 
+func TestTriggerContext(t *testing.T) {
+	type ctxKey struct{}
+	players := newEmpty(10)
+
+	var seen []any
+	assert.NoError(t, players.CreateTriggerContext("on_balance", "balance", func(ctx context.Context, r Reader) {
+		seen = append(seen, ctx.Value(ctxKey{}))
+	}))
+	assert.Error(t, players.CreateTriggerContext("on_balance", "invalid", func(ctx context.Context, r Reader) {}))
+	assert.Error(t, players.CreateTriggerContext("", "", nil))
+
+	// The context of the query is passed to the trigger
+	var idx uint32
+	ctx := context.WithValue(context.Background(), ctxKey{}, "traced")
+	assert.NoError(t, players.QueryContext(ctx, func(txn *Txn) (err error) {
+		assert.Equal(t, ctx, txn.Context())
+		idx, err = txn.Insert(func(r Row) error {
+			r.SetFloat64("balance", 10.0)
+			return nil
+		})
+		return err
+	}))
+
+	// Without a query context, the root context of the collection is used
+	assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+		r.SetFloat64("balance", 20.0)
+		return nil
+	}))
+	assert.Equal(t, []any{"traced", nil}, seen)
+
+	// A cancelled context rolls back the transaction
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, players.QueryContext(cancelled, func(txn *Txn) error {
+		txn.DeleteAt(idx)
+		return nil
+	}), context.Canceled)
+	assert.Len(t, seen, 2)
+}
+
 func TestTriggerInvalid(t *testing.T) {
 	players := newEmpty(10)
 	assert.Error(t, players.CreateTrigger("on_balance", "invalid", func(r Reader) {}))
@@ -1002,3 +1175,2411 @@ func (r mockRecord) UnmarshalBinary(b []byte) error {
 	}
 	return nil
 }
+
+func TestCountConsistent(t *testing.T) {
+	players := loadPlayers(500)
+	assert.Equal(t, 500, players.CountConsistent())
+	assert.Equal(t, players.Count(), players.CountConsistent())
+
+	assert.True(t, players.DeleteAt(0))
+	assert.Equal(t, 499, players.CountConsistent())
+}
+
+func TestChunks(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("value", ForInt())
+	for i := 0; i < ChunkSize+100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetInt("value", 1)
+			return nil
+		})
+	}
+
+	assert.Equal(t, 2, c.Chunks())
+	assert.Equal(t, ChunkSize, c.ChunkCount(0))
+	assert.Equal(t, 100, c.ChunkCount(1))
+	assert.Equal(t, 0, c.ChunkCount(2))
+
+	// Partition the work by chunk, in parallel
+	var wg sync.WaitGroup
+	var sum int64
+	for chunk := 0; chunk < c.Chunks(); chunk++ {
+		wg.Add(1)
+		go func(chunk int) {
+			defer wg.Done()
+			assert.NoError(t, c.ChunkRange(chunk, func(txn *Txn) error {
+				value := txn.Int("value")
+				return txn.Range(func(idx uint32) {
+					assert.Equal(t, chunk, int(idx/ChunkSize))
+					v, _ := value.Get()
+					atomic.AddInt64(&sum, int64(v))
+					value.Set(v + chunk)
+				})
+			}))
+		}(chunk)
+	}
+
+	wg.Wait()
+	assert.Equal(t, int64(ChunkSize+100), sum)
+	assert.NoError(t, c.ChunkRange(1, func(txn *Txn) error {
+		assert.Equal(t, 100, txn.Count())
+		assert.Equal(t, 200, txn.Int("value").Sum())
+		return nil
+	}))
+}
+
+func TestReserve(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.Reserve(3*ChunkSize + 1)
+	c.Reserve(10)
+	assert.Equal(t, 4, len(c.commits))
+
+	// The columns created afterwards are also grown
+	c.CreateColumn("value", ForInt())
+	column, _ := c.cols.Load("value")
+	assert.GreaterOrEqual(t, len(column.Column.(*numericColumn[int]).chunks), 4)
+
+	// The rows are inserted without growing the collection
+	for i := 0; i < 3*ChunkSize+1; i++ {
+		c.Insert(func(r Row) error {
+			r.SetInt("value", i)
+			return nil
+		})
+	}
+
+	assert.Equal(t, 4, len(c.commits))
+	assert.Equal(t, 4, c.Chunks())
+	assert.Equal(t, 3*ChunkSize+1, c.Count())
+	assert.NoError(t, c.QueryAt(3*ChunkSize, func(r Row) error {
+		v, _ := r.Int("value")
+		assert.Equal(t, 3*ChunkSize, v)
+		return nil
+	}))
+}
+
+func TestEvictLRU(t *testing.T) {
+	c := NewCollection(Options{MaxRows: 10})
+	c.CreateColumn("name", ForKey())
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, c.InsertKey(fmt.Sprintf("key-%d", i), func(r Row) error {
+			return nil
+		}))
+	}
+
+	// Access the first row, so that the second one is the least recently used. Once over
+	// the limit, the collection is brought back to 90% of it.
+	assert.NoError(t, c.QueryKey("key-0", func(r Row) error { return nil }))
+	assert.NoError(t, c.InsertKey("key-10", func(r Row) error { return nil }))
+	assert.Equal(t, 9, c.Count())
+	assert.NoError(t, c.QueryKey("key-0", func(r Row) error { return nil }))
+	assert.Error(t, c.QueryKey("key-1", func(r Row) error { return nil }))
+	assert.Error(t, c.QueryKey("key-2", func(r Row) error { return nil }))
+	assert.NoError(t, c.QueryKey("key-3", func(r Row) error { return nil }))
+
+	// Rows deleted by a transaction are dropped from the access list
+	assert.NoError(t, c.DeleteKey("key-3"))
+	assert.NoError(t, c.InsertKey("key-a", func(r Row) error { return nil }))
+	assert.Equal(t, 9, c.Count())
+
+	// Insert many rows in a single transaction
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		for i := 11; i < 30; i++ {
+			txn.InsertKey(fmt.Sprintf("key-%d", i), func(r Row) error { return nil })
+		}
+		return nil
+	}))
+	assert.Equal(t, 9, c.Count())
+	assert.NoError(t, c.QueryKey("key-29", func(r Row) error { return nil }))
+	assert.NoError(t, c.QueryKey("key-21", func(r Row) error { return nil }))
+	assert.Error(t, c.QueryKey("key-20", func(r Row) error { return nil }))
+	assert.Error(t, c.QueryKey("key-0", func(r Row) error { return nil }))
+}
+
+func TestEvictTTL(t *testing.T) {
+	c := NewCollection(Options{MaxRows: 3, Eviction: EvictTTL})
+	c.CreateColumn("name", ForString())
+	insert := func(name string, ttl time.Duration) {
+		_, err := c.Insert(func(r Row) error {
+			r.SetString("name", name)
+			r.SetTTL(ttl)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	insert("a", time.Hour)
+	insert("b", time.Minute)
+	insert("c", 2*time.Hour)
+	insert("d", 3*time.Hour)
+
+	var names []string
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		name := txn.String("name")
+		return txn.Range(func(idx uint32) {
+			v, _ := name.Get()
+			names = append(names, v)
+		})
+	}))
+	assert.ElementsMatch(t, []string{"a", "c", "d"}, names)
+}
+
+func TestEvictBytes(t *testing.T) {
+	sink := &metricsSink{
+		counters: make(map[Metric]int64),
+		observed: make(map[Metric]int),
+	}
+
+	c := NewCollection(Options{MaxBytes: 1000, Metrics: sink, NoTTL: true})
+	c.CreateColumn("data", ForBytes())
+	for i := 0; i < 20; i++ {
+		_, err := c.Insert(func(r Row) error {
+			r.SetBytes("data", make([]byte, 100))
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 10, c.Count())
+	assert.Equal(t, int64(10), sink.counters[MetricEvictions])
+}
+
+func TestTiering(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCollection(Options{Tiering: TieringPolicy{
+		After:    time.Nanosecond,
+		Interval: time.Hour,
+		Dir:      dir,
+	}})
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("age", ForInt())
+	c.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 30
+	})
+
+	for i := 0; i < 100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetString("name", fmt.Sprintf("player-%d", i))
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	// Spill the chunk, which releases the memory of the columns
+	n, err := c.Spill()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+	column, _ := c.cols.Load("age")
+	assert.Nil(t, column.Column.(*numericColumn[int]).chunks[0].data)
+
+	// The queries reload the chunk transparently
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		assert.Equal(t, 4950, txn.Int("age").Sum())
+		assert.Equal(t, 70, txn.With("old").Count())
+		return nil
+	}))
+	files, _ = os.ReadDir(dir)
+	assert.Len(t, files, 0)
+
+	// The writes reload the chunk as well
+	n, _ = c.Spill()
+	assert.Equal(t, 1, n)
+	assert.NoError(t, c.QueryAt(5, func(r Row) error {
+		r.SetInt("age", 50)
+		return nil
+	}))
+	assert.NoError(t, c.QueryAt(5, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "player-5", name)
+		return nil
+	}))
+
+	// The snapshots contain the spilled chunks
+	n, _ = c.Spill()
+	assert.Equal(t, 1, n)
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, c.Snapshot(buffer))
+	other := NewCollection()
+	other.CreateColumn("name", ForString())
+	other.CreateColumn("age", ForInt())
+	assert.NoError(t, other.Restore(buffer))
+	assert.NoError(t, other.Query(func(txn *Txn) error {
+		assert.Equal(t, 4995, txn.Int("age").Sum())
+		return nil
+	}))
+
+	// Closing the collection removes the spilled chunks
+	n, _ = c.Spill()
+	assert.Equal(t, 1, n)
+	assert.NoError(t, c.Close())
+	files, _ = os.ReadDir(dir)
+	assert.Len(t, files, 0)
+
+	_, err = NewCollection().Spill()
+	assert.Error(t, err)
+}
+
+func TestTieringReloadError(t *testing.T) {
+	var errs int32
+	dir := t.TempDir()
+	c := NewCollection(Options{Tiering: TieringPolicy{
+		After:    time.Nanosecond,
+		Interval: time.Hour,
+		Dir:      dir,
+		OnError: func(error) {
+			atomic.AddInt32(&errs, 1)
+		},
+	}})
+	c.CreateColumn("age", ForInt())
+	for i := 0; i < 100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	// Corrupt the file of the spilled chunk
+	n, err := c.Spill()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	path := c.spillPath(0)
+	original, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, original[:len(original)/2], 0644))
+
+	// The reads fail, while the chunk remains spilled and its file is kept
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		txn.Int("age").Sum()
+		return nil
+	}))
+	assert.Error(t, c.QueryConsistent(func(txn *Txn) error {
+		return nil
+	}))
+	_, err = c.VerifyIndexes()
+	assert.Error(t, err)
+	assert.Equal(t, []commit.Chunk{0}, c.tier.spilled())
+	assert.FileExists(t, path)
+	assert.Greater(t, atomic.LoadInt32(&errs), int32(0))
+
+	// The writes into the chunk are rolled back, rather than discarding its values
+	_, err = c.Insert(func(r Row) error {
+		r.SetInt("age", 100)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		txn.DeleteAt(5)
+		return nil
+	}))
+	assert.Error(t, c.BulkLoad(func(loader *Loader) error {
+		_, err := loader.Insert(func(r Row) error {
+			r.SetInt("age", 100)
+			return nil
+		})
+		return err
+	}))
+	assert.Error(t, c.RebuildIndex("age"))
+	assert.Error(t, c.Freeze())
+	assert.False(t, c.IsFrozen())
+	assert.Equal(t, 100, c.Count())
+	assert.Equal(t, []commit.Chunk{0}, c.tier.spilled())
+
+	// Once the file is repaired, the chunk is reloaded
+	assert.NoError(t, os.WriteFile(path, original, 0644))
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		assert.Equal(t, 4950, txn.Int("age").Sum())
+		return nil
+	}))
+	assert.Empty(t, c.tier.spilled())
+	assert.NoFileExists(t, path)
+
+	// The chunks written by a commit in progress are not spilled
+	c.tier.pin(0)
+	n, _ = c.Spill()
+	assert.Equal(t, 0, n)
+	c.tier.unpin(0)
+	n, _ = c.Spill()
+	assert.Equal(t, 1, n)
+}
+
+func TestTieringConsistent(t *testing.T) {
+	c := NewCollection(Options{Tiering: TieringPolicy{
+		After:    time.Nanosecond,
+		Interval: time.Hour,
+		Dir:      t.TempDir(),
+	}})
+	c.CreateColumn("age", ForInt())
+	for i := 0; i < 100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	// Spill the chunks continuously, while the consistent queries read them
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				c.Spill()
+			}
+		}
+	}()
+
+	for i := 0; i < 5000; i++ {
+		assert.NoError(t, c.QueryConsistent(func(txn *Txn) error {
+			assert.Equal(t, 4950, txn.Int("age").Sum())
+			return nil
+		}))
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestQueryConsistent(t *testing.T) {
+	players := loadPlayers(500)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			players.Query(func(txn *Txn) error {
+				balance := txn.Float64("balance")
+				return txn.Range(func(idx uint32) {
+					balance.Merge(1)
+				})
+			})
+		}
+	}()
+
+	// The sum and the average must be computed on the same state
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, players.QueryConsistent(func(txn *Txn) error {
+			balance := txn.Float64("balance")
+			sum, avg := balance.Sum(), balance.Avg()
+			assert.InDelta(t, sum/float64(txn.Count()), avg, 0.0001)
+			return nil
+		}))
+	}
+
+	// Writes are allowed and committed once the view is released
+	assert.NoError(t, players.QueryConsistent(func(txn *Txn) error {
+		return txn.QueryAt(0, func(r Row) error {
+			r.SetString("name", "Roman")
+			return nil
+		})
+	}))
+
+	wg.Wait()
+	players.QueryAt(0, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Roman", name)
+		return nil
+	})
+}
+
+func TestInsertManyObjects(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("age", ForInt())
+	col.CreateColumn("active", ForBool())
+	col.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 30
+	})
+
+	inserted, errs := col.InsertManyObjects([]map[string]any{
+		{"name": "Roman", "age": 35, "active": true},
+		{"name": "Merlin", "age": "old"},
+		{"name": "Jane", "wallet": 10.5},
+		{"name": "Alice", "old": true},
+		{"name": "Bob", "age": 20},
+	})
+
+	assert.Len(t, errs, 3)
+	assert.Equal(t, []uint32{0, 1}, inserted)
+	assert.Equal(t, 2, col.Count())
+
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.With("old").Count())
+		return nil
+	})
+
+	idx, err := col.InsertObject(map[string]any{"name": "Carol"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), idx)
+}
+
+func TestCheck(t *testing.T) {
+	players := loadPlayers(500)
+	report := players.Check(context.Background())
+	assert.True(t, report.OK())
+	assert.Equal(t, 500, report.Rows)
+	assert.Equal(t, 1, report.Chunks)
+
+	// Corrupt the index
+	index, _ := players.cols.Load("human")
+	index.Column.(*columnIndex).fill.Remove(0)
+	index.Column.(*columnIndex).fill.Set(1)
+	report = players.Check(context.Background())
+	assert.False(t, report.OK())
+	assert.Len(t, report.Issues, 1)
+	assert.Contains(t, report.Issues[0].String(), "human")
+
+	// Corrupt the fill list
+	players.lock.Lock()
+	players.fill.Remove(5)
+	players.lock.Unlock()
+	report = players.Check(context.Background())
+	assert.False(t, report.OK())
+	assert.Greater(t, len(report.Issues), 1)
+
+	// Cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report = players.Check(ctx)
+	assert.Error(t, report.Err)
+	assert.False(t, report.OK())
+}
+
+func TestVerifyIndexes(t *testing.T) {
+	players := loadPlayers(500)
+	mismatched, err := players.VerifyIndexes()
+	assert.NoError(t, err)
+	assert.Empty(t, mismatched)
+
+	// Corrupt the index by flipping a couple of rows
+	index, _ := players.cols.Load("human")
+	fill := &index.Column.(*columnIndex).fill
+	for _, idx := range []uint32{0, 1} {
+		if fill.Contains(idx) {
+			fill.Remove(idx)
+		} else {
+			fill.Set(idx)
+		}
+	}
+	mismatched, err = players.VerifyIndexes()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"human": 2}, mismatched)
+
+	// Rebuild the index and check it again
+	assert.NoError(t, players.RebuildIndex("human"))
+	mismatched, err = players.VerifyIndexes()
+	assert.NoError(t, err)
+	assert.Empty(t, mismatched)
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, txn.WithValue("race", func(v any) bool {
+			return v == "human"
+		}).Count(), txn.With("human").Count())
+		return nil
+	})
+
+	// The rebuilt index is maintained by the subsequent commits
+	idx, _ := players.Insert(func(r Row) error {
+		r.SetEnum("race", "human")
+		return nil
+	})
+	players.QueryAt(idx, func(r Row) error {
+		assert.True(t, r.Bool("human"))
+		return nil
+	})
+
+	// Only the existing indexes can be rebuilt
+	assert.NoError(t, players.CreateSortIndex("by_balance", "balance"))
+	assert.NoError(t, players.RebuildIndex("by_balance"))
+	assert.Error(t, players.RebuildIndex("race"))
+	assert.Error(t, players.RebuildIndex("unknown"))
+}
+
+func TestCheckKey(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("key", ForKey())
+	col.InsertKey("a", func(r Row) error { return nil })
+	col.InsertKey("b", func(r Row) error { return nil })
+	assert.True(t, col.Check(context.Background()).OK())
+
+	// Corrupt the lookup table
+	pk := col.pk.(*columnKey)
+	delete(pk.seek, "a")
+	report := col.Check(context.Background())
+	assert.Len(t, report.Issues, 1)
+	assert.Equal(t, "key", report.Issues[0].Column)
+}
+
+func TestBulkLoad(t *testing.T) {
+	const amount = 50000
+	expect := loadPlayers(amount)
+	actual := newEmpty(amount)
+	data := fixtures.Players()
+
+	assert.NoError(t, actual.BulkLoad(func(loader *Loader) error {
+		for i := 0; i < amount/len(data); i++ {
+			for _, v := range data {
+				_, err := loader.Insert(func(r Row) error {
+					r.SetString("name", v.Name)
+					r.SetEnum("race", v.Race)
+					r.SetEnum("class", v.Class)
+					r.SetInt("age", v.Age)
+					r.SetFloat64("balance", v.Balance)
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+		}
+
+		// Failed inserts should not leave anything behind
+		_, err := loader.Insert(func(r Row) error {
+			r.SetString("name", "Roman")
+			return io.ErrUnexpectedEOF
+		})
+		assert.Error(t, err)
+		_, err = loader.InsertObject(map[string]any{"invalid": 1})
+		assert.Error(t, err)
+		_, err = loader.InsertKey("x", nil)
+		assert.Error(t, err)
+		return nil
+	}))
+
+	assert.Equal(t, amount, actual.Count())
+	assert.True(t, actual.Check(context.Background()).OK())
+	for _, query := range [][]string{{"human"}, {"human", "mage"}, {"old"}} {
+		var want, have int
+		expect.Query(func(txn *Txn) error {
+			want = txn.With(query...).Count()
+			return nil
+		})
+		actual.Query(func(txn *Txn) error {
+			have = txn.With(query...).Count()
+			assert.Equal(t, 0, txn.WithValue("name", func(v any) bool {
+				return v == "Roman"
+			}).Count())
+			return nil
+		})
+		assert.Equal(t, want, have)
+	}
+
+	// Regular transactions should work as usual afterwards
+	actual.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		return txn.With("human").Range(func(idx uint32) {
+			balance.Set(0)
+		})
+	})
+	actual.Query(func(txn *Txn) error {
+		assert.Equal(t, float64(0), txn.With("human").Float64("balance").Sum())
+		return nil
+	})
+}
+
+func TestBulkLoadRollback(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("name", ForString())
+	col.CreateColumn("active", ForBool())
+
+	var failed, reused uint32
+	assert.NoError(t, col.BulkLoad(func(loader *Loader) error {
+		_, err := loader.Insert(func(r Row) error {
+			r.SetString("name", "first")
+			r.SetBool("active", false)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		// A failed insert should only discard its own values
+		failed, err = loader.Insert(func(r Row) error {
+			r.SetBool("active", true)
+			return io.ErrUnexpectedEOF
+		})
+		assert.Error(t, err)
+
+		reused, err = loader.Insert(func(r Row) error {
+			r.SetString("name", "second")
+			return nil
+		})
+		return err
+	}))
+
+	assert.Equal(t, failed, reused)
+	assert.Equal(t, 2, col.Count())
+	assert.NoError(t, col.QueryAt(0, func(r Row) error {
+		active, ok := r.Any("active")
+		assert.Equal(t, false, active)
+		assert.True(t, ok)
+		return nil
+	}))
+
+	// The reused row must not have a phantom false value
+	assert.NoError(t, col.QueryAt(reused, func(r Row) error {
+		_, ok := r.Any("active")
+		assert.False(t, ok)
+		return nil
+	}))
+}
+
+func TestBulkLoadView(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("age", ForInt())
+	col.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 30
+	})
+	assert.NoError(t, col.CreateView("older", func(txn *Txn) *Txn {
+		return txn.With("old")
+	}))
+
+	assert.NoError(t, col.BulkLoad(func(loader *Loader) error {
+		for i := 0; i < 20; i++ {
+			if _, err := loader.Insert(func(r Row) error {
+				r.SetInt("age", 20+i)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	col.Query(func(txn *Txn) error {
+		assert.Equal(t, 10, txn.With("older").Count())
+		return nil
+	})
+}
+
+func TestBulkLoadKey(t *testing.T) {
+	col := NewCollection()
+	col.CreateColumn("key", ForKey())
+	col.CreateColumn("value", ForInt())
+	assert.NoError(t, col.InsertKey("existing", func(r Row) error { return nil }))
+
+	errFailed := fmt.Errorf("failed")
+	assert.Equal(t, errFailed, col.BulkLoad(func(loader *Loader) error {
+		_, err := loader.InsertKey("existing", func(r Row) error { return nil })
+		assert.Error(t, err)
+
+		for i := 0; i < 20000; i++ {
+			_, err := loader.InsertKey(strconv.Itoa(i), func(r Row) error {
+				r.SetInt("value", i)
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+
+		_, err = loader.InsertKey("1", func(r Row) error { return nil })
+		assert.Error(t, err)
+		_, err = loader.InsertKey("19999", func(r Row) error { return nil })
+		assert.Error(t, err)
+		_, err = loader.Insert(func(r Row) error { return nil })
+		assert.Error(t, err)
+		return errFailed
+	}))
+
+	assert.Equal(t, 20001, col.Count())
+	assert.NoError(t, col.QueryKey("12345", func(r Row) error {
+		v, _ := r.Int("value")
+		assert.Equal(t, 12345, v)
+		return nil
+	}))
+}
+
+func TestPrefetch(t *testing.T) {
+	coll := NewCollection(Options{Prefetch: true})
+	coll.CreateColumn("name", ForString())
+	coll.CreateColumn("balance", ForFloat64())
+	for i := 0; i < 3*chunkSize; i++ {
+		coll.Insert(func(r Row) error {
+			r.SetString("name", "Roman")
+			r.SetFloat64("balance", 1)
+			return nil
+		})
+	}
+
+	// Results must not be affected by prefetching
+	coll.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		total := 0.0
+		assert.NoError(t, txn.Range(func(idx uint32) {
+			v, _ := balance.Get()
+			total += v
+		}))
+		assert.Equal(t, float64(3*chunkSize), total)
+		return nil
+	})
+
+	// Touching the pages must not fail for empty or missing chunks
+	column, _ := coll.cols.Load("balance")
+	data := column.Column.(*numericColumn[float64]).chunks
+	assert.Equal(t, byte(0), data.prefetch(10))
+	assert.Equal(t, byte(0), chunks[struct{}]{{data: make([]struct{}, 10)}}.prefetch(0))
+	assert.NotPanics(t, func() { data.prefetch(0) })
+
+	// The columns can grow while the chunks are prefetched
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2*chunkSize; i++ {
+			coll.Insert(func(r Row) error {
+				r.SetFloat64("balance", 1)
+				return nil
+			})
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		coll.Query(func(txn *Txn) error {
+			txn.Float64("balance") // Prefetch the column, without reading it
+			return txn.Range(func(idx uint32) {})
+		})
+	}
+
+	wg.Wait()
+	assert.Eventually(t, func() bool {
+		return len(coll.prefetch) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestCreateView(t *testing.T) {
+	players := loadPlayers(50000)
+	defer players.Close()
+
+	query := func(txn *Txn) *Txn {
+		return txn.With("mage").WithFloat("balance", func(v float64) bool {
+			return v >= 3000
+		})
+	}
+
+	// countBoth counts the rows in the view as well as the rows matching the query
+	countBoth := func() (view, scan int) {
+		players.Query(func(txn *Txn) error {
+			view = txn.With("rich_mages").Count()
+			return nil
+		})
+		players.Query(func(txn *Txn) error {
+			scan = query(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	assert.Error(t, players.CreateView("", query))
+	assert.Error(t, players.CreateView("mage", query))
+	assert.NoError(t, players.CreateView("rich_mages", query))
+
+	view, scan := countBoth()
+	assert.NotZero(t, view)
+	assert.Equal(t, scan, view)
+
+	// Make every mage poor, the view should be emptied
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		return txn.With("mage").Range(func(idx uint32) {
+			balance.Set(1)
+		})
+	}))
+
+	view, scan = countBoth()
+	assert.Zero(t, view)
+	assert.Equal(t, scan, view)
+
+	// Turn some of the rows into rich mages and delete a few
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		class := txn.Enum("class")
+		balance := txn.Float64("balance")
+		return txn.With("human").Range(func(idx uint32) {
+			class.Set("mage")
+			balance.Set(5000)
+		})
+	}))
+	players.DeleteAt(0)
+	players.DeleteAt(1)
+
+	view, scan = countBoth()
+	assert.NotZero(t, view)
+	assert.Equal(t, scan, view)
+
+	// Inserted rows are added to the view
+	players.Insert(func(r Row) error {
+		r.SetEnum("class", "mage")
+		r.SetFloat64("balance", 9000)
+		return nil
+	})
+
+	view2, scan2 := countBoth()
+	assert.Equal(t, view+1, view2)
+	assert.Equal(t, scan2, view2)
+
+	// Dropped views no longer select any rows
+	assert.NoError(t, players.DropIndex("rich_mages"))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Zero(t, txn.With("rich_mages").Count())
+		return nil
+	}))
+}
+
+func TestHotRows(t *testing.T) {
+	players := NewCollection(Options{HotRows: 2})
+	defer players.Close()
+
+	assert.NoError(t, players.CreateColumn("name", ForKey()))
+	assert.NoError(t, players.CreateColumn("age", ForInt()))
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, players.InsertKey(name, func(r Row) error {
+			r.SetInt("age", 30)
+			return nil
+		}))
+	}
+
+	// Load the rows through the cache, the oldest one is evicted
+	for _, name := range []string{"a", "b", "c"} {
+		row, ok := players.LoadRowByKey(name)
+		assert.True(t, ok)
+		age, ok := row.Get("age")
+		assert.True(t, ok)
+		assert.Equal(t, 30, age)
+	}
+	assert.Equal(t, 2, players.hot.len())
+
+	// Updates are reflected in the cached rows
+	assert.NoError(t, players.QueryKey("c", func(r Row) error {
+		r.SetInt("age", 40)
+		return nil
+	}))
+	row, ok := players.LoadRowByKey("c")
+	assert.True(t, ok)
+	age, _ := row.Get("age")
+	assert.Equal(t, 40, age)
+
+	// Deleted rows are removed from the cache
+	assert.NoError(t, players.DeleteKey("c"))
+	_, ok = players.LoadRowByKey("c")
+	assert.False(t, ok)
+	_, ok = players.LoadRow(100)
+	assert.False(t, ok)
+
+	// New columns are visible once the schema changes
+	assert.NoError(t, players.CreateColumn("hp", ForInt()))
+	assert.NoError(t, players.QueryKey("b", func(r Row) error {
+		r.SetInt("hp", 10)
+		return nil
+	}))
+	row, ok = players.LoadRowByKey("b")
+	assert.True(t, ok)
+	hp, ok := row.Get("hp")
+	assert.True(t, ok)
+	assert.Equal(t, 10, hp)
+	_, ok = row.Get("invalid")
+	assert.False(t, ok)
+
+	// Renamed columns are visible, even if the number of columns is unchanged
+	assert.NoError(t, players.RenameColumn("hp", "health"))
+	row, ok = players.LoadRowByKey("b")
+	assert.True(t, ok)
+	_, ok = row.Get("hp")
+	assert.False(t, ok)
+	hp, ok = row.Get("health")
+	assert.True(t, ok)
+	assert.Equal(t, 10, hp)
+}
+
+func TestHotRowsBytes(t *testing.T) {
+	players := NewCollection(Options{HotRows: 100})
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("name", ForKey()))
+	assert.NoError(t, players.CreateColumn("data", ForBytes()))
+	assert.NoError(t, players.InsertKey("a", func(r Row) error {
+		r.SetBytes("data", []byte("hello"))
+		return nil
+	}))
+
+	// The packed row does not share the value stored in the column
+	row, ok := players.LoadRowByKey("a")
+	assert.True(t, ok)
+	data, _ := row.Get("data")
+	data.([]byte)[0] = 'j'
+
+	assert.NoError(t, players.QueryKey("a", func(r Row) error {
+		v, _ := r.Bytes("data")
+		assert.Equal(t, []byte("hello"), v)
+		return nil
+	}))
+}
+
+func TestLoadRowWithoutCache(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	row, ok := players.LoadRow(0)
+	assert.True(t, ok)
+	race, ok := row.Get("race")
+	assert.True(t, ok)
+	assert.NotEmpty(t, race)
+
+	_, ok = players.LoadRowByKey("a")
+	assert.False(t, ok)
+}
+
+func TestSubscribe(t *testing.T) {
+	players := NewCollection()
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("name", ForString()))
+	assert.NoError(t, players.CreateColumn("age", ForInt()))
+	assert.NoError(t, players.CreateIndex("old", "age", func(r Reader) bool {
+		return r.Int() >= 30
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	all, err := players.Subscribe(ctx, SubscribeOptions{})
+	assert.NoError(t, err)
+	old, err := players.Subscribe(ctx, SubscribeOptions{
+		Columns: []string{"age"},
+		Index:   "old",
+	})
+	assert.NoError(t, err)
+
+	// Invalid subscriptions
+	_, err = players.Subscribe(ctx, SubscribeOptions{Columns: []string{"invalid"}})
+	assert.Error(t, err)
+	_, err = players.Subscribe(ctx, SubscribeOptions{Index: "name"})
+	assert.Error(t, err)
+
+	idx, _ := players.Insert(func(r Row) error {
+		r.SetString("name", "Roman")
+		r.SetInt("age", 35)
+		return nil
+	})
+	players.QueryAt(idx, func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+	players.QueryAt(idx, func(r Row) error {
+		r.SetInt("age", 36)
+		return nil
+	})
+	players.DeleteAt(idx)
+
+	// Every change is reported to the first subscriber
+	e := <-all
+	assert.Equal(t, EventInsert, e.Type)
+	assert.Equal(t, idx, e.Index)
+	assert.Equal(t, "Roman", e.Values["name"])
+	assert.Equal(t, 35, e.Values["age"])
+	e = <-all
+	assert.Equal(t, EventUpdate, e.Type)
+	assert.Equal(t, map[string]any{"name": "Merlin"}, e.Values)
+	e = <-all
+	assert.Equal(t, EventUpdate, e.Type)
+	assert.Equal(t, map[string]any{"age": 36}, e.Values)
+	e = <-all
+	assert.Equal(t, EventDelete, e.Type)
+	assert.Equal(t, "delete", e.Type.String())
+
+	// Only the age changes are reported to the second subscriber
+	e = <-old
+	assert.Equal(t, EventInsert, e.Type)
+	assert.Equal(t, map[string]any{"age": 35}, e.Values)
+	e = <-old
+	assert.Equal(t, EventUpdate, e.Type)
+	assert.Equal(t, map[string]any{"age": 36}, e.Values)
+	e = <-old
+	assert.Equal(t, EventDelete, e.Type)
+
+	// Channels are closed on cancellation
+	cancel()
+	for range all {
+	}
+	for range old {
+	}
+}
+
+func TestSubscribeDrop(t *testing.T) {
+	players := NewCollection()
+	assert.NoError(t, players.CreateColumn("age", ForInt()))
+
+	events, err := players.Subscribe(context.Background(), SubscribeOptions{
+		Buffer: 2,
+		Policy: OverflowDrop,
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		players.Insert(func(r Row) error {
+			r.SetInt("age", i)
+			return nil
+		})
+	}
+
+	// Closing the collection closes the subscription
+	players.Close()
+	count := 0
+	for range events {
+		count++
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestSubscribeSlow(t *testing.T) {
+	players := NewCollection()
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("age", ForInt()))
+
+	// A slow subscriber blocks the committer once its buffer is full
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := players.Subscribe(ctx, SubscribeOptions{Buffer: 1})
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2; i++ {
+			players.Insert(func(r Row) error {
+				r.SetInt("age", i)
+				return nil
+			})
+		}
+	}()
+
+	// Others can still subscribe while the committer is blocked
+	time.Sleep(50 * time.Millisecond)
+	subscribed := make(chan error)
+	go func() {
+		_, err := players.Subscribe(context.Background(), SubscribeOptions{})
+		subscribed <- err
+	}()
+
+	select {
+	case err := <-subscribed:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "subscribe is blocked by a slow subscriber")
+	}
+
+	// Cancelling the slow subscription unblocks the committer
+	cancel()
+	<-done
+}
+
+func TestExpireLazyVacuum(t *testing.T) {
+	col := NewCollection(Options{Vacuum: time.Millisecond})
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+
+	// Inserting without a TTL does not require a vacuum
+	col.Insert(func(r Row) error {
+		r.SetString("name", "Roman")
+		return nil
+	})
+
+	// The vacuum is started once a TTL is set
+	col.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		r.SetTTL(time.Microsecond)
+		return nil
+	})
+
+	assert.Eventually(t, func() bool {
+		return col.Count() == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestExpireVacuumReplica(t *testing.T) {
+	w := make(commit.Channel, 16)
+	source := NewCollection(Options{Writer: w})
+	defer source.Close()
+	assert.NoError(t, source.CreateColumn("name", ForString()))
+	_, err := source.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		r.SetTTL(50 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// The vacuum of a restored collection is started by the expirations of the snapshot
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, source.Snapshot(buffer))
+	restored := NewCollection(Options{Vacuum: time.Millisecond})
+	defer restored.Close()
+	assert.NoError(t, restored.CreateColumn("name", ForString()))
+	assert.NoError(t, restored.Restore(buffer))
+	assert.Equal(t, 1, restored.Count())
+
+	// The vacuum of a replica is started by the expirations replayed
+	replica := NewCollection(Options{Vacuum: time.Millisecond})
+	defer replica.Close()
+	assert.NoError(t, replica.CreateColumn("name", ForString()))
+	assert.NoError(t, replica.Replay(<-w))
+	assert.Equal(t, 1, replica.Count())
+
+	assert.Eventually(t, func() bool {
+		return restored.Count() == 0 && replica.Count() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestNoTTL(t *testing.T) {
+	col := NewCollection(Options{NoTTL: true})
+	defer col.Close()
+	assert.NoError(t, col.CreateColumn("name", ForString()))
+	assert.Equal(t, []ColumnInfo{{Name: "name", Type: "string"}}, col.Columns())
+
+	idx, err := col.Insert(func(r Row) error {
+		r.SetString("name", "Roman")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, col.QueryAt(idx, func(r Row) error {
+		_, ok := r.TTL()
+		assert.False(t, ok)
+		return nil
+	}))
+
+	assert.ErrorIs(t, col.QueryAt(idx, func(r Row) error {
+		r.SetTTL(time.Second)
+		return nil
+	}), errNoTTL)
+
+	assert.ErrorIs(t, col.Query(func(txn *Txn) error {
+		ttl := txn.TTL()
+		ttl.Set(time.Second)
+		_, ok := ttl.TTL()
+		assert.False(t, ok)
+		return nil
+	}), errNoTTL)
+}
+
+func TestWriteStats(t *testing.T) {
+	players := NewCollection(Options{Stats: time.Minute})
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("name", ForString()))
+	assert.NoError(t, players.CreateColumn("age", ForInt()))
+
+	for i := 0; i < 20000; i++ {
+		players.Insert(func(r Row) error {
+			r.SetString("name", "Roman")
+			return nil
+		})
+	}
+
+	// Update the age of the rows in the second chunk only
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		age := txn.Int("age")
+		return txn.Range(func(idx uint32) {
+			if idx >= chunkSize {
+				age.Set(10)
+			}
+		})
+	}))
+
+	stats, ok := players.WriteStats(1)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, stats.Window)
+	assert.Equal(t, uint64(20000), stats.Columns["name"])
+	assert.Equal(t, uint64(20000-chunkSize), stats.Columns["age"])
+	assert.Equal(t, []ChunkWrites{{Chunk: 0, Writes: chunkSize}}, stats.Chunks)
+
+	// Statistics are disabled by default
+	_, ok = NewCollection().WriteStats(1)
+	assert.False(t, ok)
+}
+
+func TestWriteStatsWindow(t *testing.T) {
+	stats := newWriteStats(time.Second)
+	now := time.Now()
+	stats.bucketAt(now.Add(-2 * time.Second)).columns["old"] = 1
+	stats.record("new", 0, 1)
+
+	out := stats.load(10)
+	assert.Equal(t, map[string]uint64{"new": 1}, out.Columns)
+}
+
+func TestIndexStats(t *testing.T) {
+	players := loadPlayers(500)
+	stats := players.IndexStats()
+	assert.NotEmpty(t, stats)
+
+	var human IndexStats
+	for _, v := range stats {
+		if v.Name == "human" {
+			human = v
+		}
+	}
+
+	assert.Equal(t, "race", human.Column)
+	assert.False(t, human.Updated.IsZero())
+	assert.Greater(t, human.Memory, 0)
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, txn.With("human").Count(), human.Cardinality)
+		return nil
+	})
+
+	// The cardinality is maintained on deletion
+	players.Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	})
+	for _, v := range players.IndexStats() {
+		if v.Name == "human" {
+			assert.Equal(t, 0, v.Cardinality)
+		}
+	}
+}
+
+func TestWithSelectivity(t *testing.T) {
+	players := loadPlayers(500)
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, []string{"elf", "human", "name"}, txn.bySelectivity([]string{"name", "human", "elf"}))
+		return nil
+	})
+
+	// The order of the indexes does not change the result
+	var count int
+	players.Query(func(txn *Txn) error {
+		count = txn.With("old").With("human").Count()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		assert.NotZero(t, count)
+		assert.Equal(t, count, txn.With("old", "human").Count())
+		return nil
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	players := loadPlayers(500)
+
+	var human []uint32
+	var elf int
+	players.Query(func(txn *Txn) error {
+		elf = txn.With("elf").Count()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {
+			human = append(human, idx)
+		})
+	})
+
+	humans := len(human)
+	summary, err := players.DryRun(func(txn *Txn) error {
+		for _, idx := range human {
+			txn.QueryAt(idx, func(r Row) error {
+				r.SetFloat64("balance", 100)
+				return nil
+			})
+		}
+
+		txn.With("elf").DeleteAll()
+		for i := 0; i < 2; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetString("name", "Merlin")
+				return nil
+			})
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, summary.IsEmpty())
+	assert.Equal(t, 2, summary.Inserted)
+	assert.Equal(t, humans, summary.Updated)
+	assert.Equal(t, elf, summary.Deleted)
+	assert.Equal(t, ColumnChanges{Inserted: 2, Deleted: elf}, summary.Columns["name"])
+	assert.Equal(t, ColumnChanges{Updated: humans, Deleted: elf}, summary.Columns["balance"])
+
+	// Nothing should have been changed
+	assert.Equal(t, 500, players.Count())
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, elf, txn.With("elf").Count())
+		assert.Equal(t, 0, txn.WithValue("balance", func(v interface{}) bool {
+			return v.(float64) == 100
+		}).Count())
+		return nil
+	})
+
+	// Errors are returned as-is
+	_, err = players.DryRun(func(txn *Txn) error {
+		txn.DeleteAt(0)
+		return io.EOF
+	})
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 500, players.Count())
+}
+
+func TestApplySchema(t *testing.T) {
+	schema := Schema{
+		Columns: map[string]Column{
+			"name": ForString(),
+			"age":  ForInt(),
+		},
+		Indexes: map[string]SchemaIndex{
+			"old": {Column: "age", Filter: func(r Reader) bool {
+				return r.Int() >= 30
+			}},
+		},
+	}
+
+	// Create the schema from scratch
+	c := NewCollection()
+	assert.NoError(t, c.CreateColumn("extra", ForFloat64()))
+	assert.NoError(t, ApplySchema(c, schema))
+	m, err := DiffSchema(c, schema)
+	assert.NoError(t, err)
+	assert.True(t, m.IsEmpty())
+
+	c.Insert(func(r Row) error {
+		r.SetInt("age", 50)
+		return nil
+	})
+	c.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.With("old").Count())
+		return nil
+	})
+
+	// Move the index to another column and prune the extra ones
+	schema.Prune = true
+	schema.Columns["level"] = ForInt()
+	schema.Indexes["old"] = SchemaIndex{Column: "level", Filter: func(r Reader) bool {
+		return r.Int() >= 10
+	}}
+
+	m, err = DiffSchema(c, schema)
+	assert.NoError(t, err)
+	assert.Equal(t, Migration{
+		CreateColumns: []string{"level"},
+		CreateIndexes: []string{"old"},
+		DropColumns:   []string{"extra"},
+		DropIndexes:   []string{"old"},
+	}, m)
+
+	assert.NoError(t, ApplySchema(c, schema))
+	_, ok := c.cols.Load("extra")
+	assert.False(t, ok)
+	_, ok = c.cols.Load(expireColumn)
+	assert.True(t, ok)
+	c.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("old").Count())
+		return nil
+	})
+
+	// Changing the type of a column is not supported
+	schema.Columns["age"] = ForString()
+	assert.Error(t, ApplySchema(c, schema))
+}
+
+func TestCompact(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("id", ForKey())
+	c.CreateColumn("score", ForInt())
+	c.CreateColumn("active", ForBool())
+	c.CreateIndex("high", "score", func(r Reader) bool {
+		return r.Int() >= 30000
+	})
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		for i := 0; i < 40000; i++ {
+			txn.InsertKey(strconv.Itoa(i), func(r Row) error {
+				r.SetInt("score", i)
+				r.SetBool("active", i%2 == 0)
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	// Delete most of the rows, except a few in every chunk
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			if idx%1000 != 0 {
+				txn.DeleteAt(idx)
+			}
+		})
+	}))
+	assert.Equal(t, 40, c.Count())
+
+	freed := c.Compact()
+	assert.Greater(t, freed, 0)
+	assert.Equal(t, 40, c.Count())
+	assert.True(t, c.Check(context.Background()).OK())
+
+	// Rows are contiguous and can still be found by their key
+	max, _ := c.fill.Max()
+	assert.Equal(t, uint32(39), max)
+	c.Query(func(txn *Txn) error {
+		assert.Equal(t, 10, txn.With("high").Count())
+		return nil
+	})
+
+	for i := 0; i < 40000; i += 1000 {
+		assert.NoError(t, c.QueryKey(strconv.Itoa(i), func(r Row) error {
+			score, _ := r.Int("score")
+			active := r.Bool("active")
+			assert.Equal(t, i, score)
+			assert.Equal(t, i%2 == 0, active)
+			return nil
+		}))
+	}
+
+	// The collection keeps growing after the compaction
+	assert.NoError(t, c.InsertKey("new", func(r Row) error {
+		r.SetInt("score", 1)
+		return nil
+	}))
+	assert.Equal(t, 41, c.Count())
+	assert.Equal(t, 0, c.Compact())
+}
+
+func TestCompactReloadError(t *testing.T) {
+	c := NewCollection(Options{Tiering: TieringPolicy{
+		After:    time.Nanosecond,
+		Interval: time.Hour,
+		Dir:      t.TempDir(),
+	}})
+	c.CreateColumn("score", ForInt())
+	for i := 0; i < 100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetInt("score", i)
+			return nil
+		})
+	}
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			if idx < 50 {
+				txn.DeleteAt(idx)
+			}
+		})
+	}))
+
+	// Corrupt the file of the spilled chunk, so the rows can not be moved
+	n, err := c.Spill()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, os.WriteFile(c.spillPath(0), []byte{1, 2, 3}, 0644))
+
+	// The indexes reserved for the moved rows are released
+	assert.Equal(t, 0, c.Compact())
+	assert.Equal(t, 50, c.Count())
+	c.lock.Lock()
+	assert.Equal(t, 50, c.fill.Count())
+	min, _ := c.fill.Min()
+	c.lock.Unlock()
+	assert.Equal(t, uint32(50), min)
+}
+
+func TestAuditTrigger(t *testing.T) {
+	type change struct {
+		idx           uint32
+		before, after any
+	}
+
+	var changes []change
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("balance", ForInt())
+	assert.Error(t, c.CreateAuditTrigger("audit", "balance", nil))
+	assert.NoError(t, c.CreateAuditTrigger("audit", "balance", func(idx uint32, before, after any) {
+		changes = append(changes, change{idx, before, after})
+	}))
+
+	idx, _ := c.Insert(func(r Row) error {
+		r.SetInt("balance", 10)
+		return nil
+	})
+	c.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+
+	c.QueryAt(idx, func(r Row) error {
+		r.SetInt("balance", 20)
+		return nil
+	})
+	c.QueryAt(idx, func(r Row) error {
+		r.MergeInt("balance", 5)
+		return nil
+	})
+	c.DeleteAt(idx)
+	c.DeleteAt(1) // no balance, ignored
+
+	assert.Equal(t, []change{
+		{idx, nil, 10},
+		{idx, 10, 20},
+		{idx, 20, 25},
+		{idx, 25, nil},
+	}, changes)
+
+	// Dropped triggers are no longer invoked
+	assert.NoError(t, c.DropTrigger("audit"))
+	c.Insert(func(r Row) error {
+		r.SetInt("balance", 10)
+		return nil
+	})
+	assert.Len(t, changes, 4)
+}
+
+func TestRowHooks(t *testing.T) {
+	var inserted, deleted []uint32
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.OnInsert(func(idx uint32) {
+		inserted = append(inserted, idx)
+	})
+	c.OnDelete(func(idx uint32) {
+		deleted = append(deleted, idx)
+	})
+
+	for i := 0; i < 3; i++ {
+		c.Insert(func(r Row) error {
+			r.SetString("name", "Merlin")
+			return nil
+		})
+	}
+
+	c.QueryAt(1, func(r Row) error {
+		r.SetString("name", "Gandalf")
+		return nil
+	})
+	c.DeleteAt(1)
+	c.Query(func(txn *Txn) error {
+		txn.DeleteAll()
+		return nil
+	})
+
+	assert.Equal(t, []uint32{0, 1, 2}, inserted)
+	assert.Equal(t, []uint32{1, 0, 2}, deleted)
+}
+
+func TestOnDirty(t *testing.T) {
+	var dirty []commit.Chunk
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.OnDirty(func(chunk commit.Chunk) {
+		dirty = append(dirty, chunk)
+
+		// The chunk is unlocked, so the collection can be queried
+		assert.NoError(t, c.QueryAt(chunk.Min(), func(r Row) error {
+			return nil
+		}))
+	})
+
+	for i := 0; i < ChunkSize+1; i++ {
+		c.Insert(func(r Row) error {
+			r.SetString("name", "Merlin")
+			return nil
+		})
+	}
+
+	assert.Len(t, dirty, ChunkSize+1)
+	assert.Equal(t, commit.Chunk(1), dirty[ChunkSize])
+
+	// A single commit spanning both chunks reports each of them once
+	dirty = dirty[:0]
+	c.Query(func(txn *Txn) error {
+		name := txn.String("name")
+		return txn.Range(func(idx uint32) {
+			name.Set("Gandalf")
+		})
+	})
+	assert.Equal(t, []commit.Chunk{0, 1}, dirty)
+
+	// Nothing is reported by a read-only transaction
+	dirty = dirty[:0]
+	c.Query(func(txn *Txn) error {
+		return nil
+	})
+	assert.Empty(t, dirty)
+}
+
+// metricsSink records the metrics in memory
+type metricsSink struct {
+	sync.Mutex
+	counters map[Metric]int64
+	observed map[Metric]int
+}
+
+func (m *metricsSink) Add(metric Metric, delta int64) {
+	m.Lock()
+	defer m.Unlock()
+	m.counters[metric] += delta
+}
+
+func (m *metricsSink) Observe(metric Metric, value float64) {
+	m.Lock()
+	defer m.Unlock()
+	m.observed[metric]++
+}
+
+func TestMetrics(t *testing.T) {
+	sink := &metricsSink{
+		counters: make(map[Metric]int64),
+		observed: make(map[Metric]int),
+	}
+
+	c := NewCollection(Options{Metrics: sink})
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("age", ForInt())
+	for i := 0; i < 3; i++ {
+		c.Insert(func(r Row) error {
+			r.SetString("name", "Merlin")
+			return nil
+		})
+	}
+
+	c.QueryAt(0, func(r Row) error {
+		r.SetInt("age", 30)
+		r.SetString("name", "Gandalf")
+		return nil
+	})
+	c.DeleteAt(1)
+	assert.NoError(t, c.Snapshot(bytes.NewBuffer(nil)))
+
+	assert.Equal(t, int64(3), sink.counters[MetricInserts])
+	assert.Equal(t, int64(5), sink.counters[MetricUpdates])
+	assert.Equal(t, int64(1), sink.counters[MetricDeletes])
+	assert.Equal(t, 5, sink.observed[MetricQueryDuration])
+	assert.Equal(t, 5, sink.observed[MetricCommitSize])
+	assert.Equal(t, 1, sink.observed[MetricSnapshotSeconds])
+}
+
+// testTracer records the spans in memory
+type testTracer struct {
+	spans []*testSpan
+}
+
+type testSpan struct {
+	name   string
+	parent context.Context
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+type spanKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, parent: ctx, attrs: make(map[string]any)}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)              { s.err = err }
+func (s *testSpan) End()                               { s.ended = true }
+
+func TestTracing(t *testing.T) {
+	tracer := new(testTracer)
+	c := NewCollection(Options{Tracer: tracer})
+	c.CreateColumn("name", ForString())
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	assert.NoError(t, c.QueryContext(ctx, func(txn *Txn) error {
+		assert.Equal(t, "request", txn.Context().Value(ctxKey{}))
+		assert.NotNil(t, txn.Context().Value(spanKey{}))
+		for i := 0; i < 3; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetString("name", "Merlin")
+				return nil
+			})
+		}
+		return nil
+	}))
+
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		txn.With("name").Count()
+		return io.EOF
+	}))
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, c.SnapshotContext(ctx, buffer))
+	assert.NoError(t, NewCollection(Options{Tracer: tracer}).RestoreContext(ctx, buffer))
+
+	assert.Len(t, tracer.spans, 5) // including the replayed commit
+	assert.Equal(t, "column.Query", tracer.spans[0].name)
+	assert.Equal(t, "request", tracer.spans[0].parent.Value(ctxKey{}))
+	assert.Equal(t, 1, tracer.spans[0].attrs["column.chunks_touched"])
+	assert.NotZero(t, tracer.spans[0].attrs["column.commit_id"])
+	assert.Equal(t, 3, tracer.spans[1].attrs["column.rows_matched"])
+	assert.Equal(t, io.EOF, tracer.spans[1].err)
+	assert.Equal(t, "column.Snapshot", tracer.spans[2].name)
+	assert.Equal(t, "column.Restore", tracer.spans[3].name)
+	assert.Equal(t, 3, tracer.spans[3].attrs["column.rows"])
+	for _, span := range tracer.spans {
+		assert.True(t, span.ended)
+	}
+}
+
+func TestStructMapping(t *testing.T) {
+	type class string
+	type player struct {
+		Serial   string    `column:"serial,key"`
+		Name     string    `column:"name"`
+		Class    class     `column:"class,enum"`
+		Age      int16     `column:"age"`
+		Balance  float64   `column:"balance"`
+		Active   bool      `column:"active"`
+		Joined   time.Time `column:"joined"`
+		Ignored  string    `column:"-"`
+		Location string
+		internal int
+	}
+
+	c := NewCollection()
+	assert.NoError(t, CreateColumnsOfStruct[player](c))
+	assert.NoError(t, CreateColumnsOfStruct[player](c))
+	assert.Error(t, CreateColumnsOfStruct[int](c))
+
+	columns := make(map[string]string)
+	for _, v := range c.Columns() {
+		columns[v.Name] = v.Type
+	}
+	assert.Equal(t, "key", columns["serial"])
+	assert.Equal(t, "enum", columns["class"])
+	assert.Equal(t, "int16", columns["age"])
+	assert.Equal(t, "time", columns["joined"])
+	assert.Contains(t, columns, "Location")
+	assert.NotContains(t, columns, "Ignored")
+
+	joined := time.Unix(1700000000, 0)
+	expect := player{
+		Serial:   "a1",
+		Name:     "Merlin",
+		Class:    "mage",
+		Age:      300,
+		Balance:  10.5,
+		Active:   true,
+		Joined:   joined,
+		Location: "Camelot",
+	}
+
+	var idx uint32
+	assert.NoError(t, c.Query(func(txn *Txn) (err error) {
+		idx, err = txn.InsertStruct(&expect)
+		return
+	}))
+
+	var actual player
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		return txn.ScanStruct(idx, &actual)
+	}))
+	assert.Equal(t, expect.Name, actual.Name)
+	assert.Equal(t, expect.Class, actual.Class)
+	assert.Equal(t, expect.Age, actual.Age)
+	assert.True(t, expect.Joined.Equal(actual.Joined))
+	actual.Joined = expect.Joined
+	assert.Equal(t, expect, actual)
+
+	// Scanning a missing row or into a non-pointer fails
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		return txn.ScanStruct(idx+1, &actual)
+	}))
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		return txn.ScanStruct(idx, actual)
+	}))
+}
+
+func TestInsertStructKinds(t *testing.T) {
+	type level int8
+	type flags uint8
+	type item struct {
+		Level level
+		Flags flags
+	}
+	type tagged struct {
+		Tags map[string]int
+	}
+
+	c := NewCollection()
+	c.CreateColumn("Level", ForInt16())
+	c.CreateColumn("Flags", ForUint16())
+	c.CreateColumn("Tags", ForBytes())
+
+	// The named 8-bit integers are stored in the wider columns
+	var idx uint32
+	assert.NoError(t, c.Query(func(txn *Txn) (err error) {
+		idx, err = txn.InsertStruct(item{Level: -5, Flags: 3})
+		return
+	}))
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		level, _ := r.Int16("Level")
+		flags, _ := r.Uint16("Flags")
+		assert.Equal(t, int16(-5), level)
+		assert.Equal(t, uint16(3), flags)
+		return nil
+	}))
+
+	// The unsupported values are reported, and the row is not inserted
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		_, err := txn.InsertStruct(tagged{Tags: map[string]int{"a": 1}})
+		return err
+	}))
+	assert.Equal(t, 1, c.Count())
+}
+
+func TestSharded(t *testing.T) {
+	players := NewSharded(4)
+	defer players.Close()
+	assert.NoError(t, players.Each(func(c *Collection) error {
+		c.CreateColumn("name", ForKey())
+		c.CreateColumn("class", ForEnum())
+		c.CreateColumn("balance", ForFloat64())
+		return c.CreateIndex("mage", "class", func(r Reader) bool {
+			return r.String() == "mage"
+		})
+	}))
+
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, players.InsertKey(fmt.Sprintf("player-%d", i), func(r Row) error {
+			r.SetEnum("class", []string{"mage", "rogue"}[i%2])
+			r.SetFloat64("balance", 10)
+			return nil
+		}))
+	}
+
+	// Every shard should contain some of the rows
+	assert.Equal(t, 1000, players.Count())
+	assert.NoError(t, players.Each(func(c *Collection) error {
+		assert.NotZero(t, c.Count())
+		return nil
+	}))
+
+	// Point queries are routed to the right shard
+	assert.NoError(t, players.QueryKey("player-42", func(r Row) error {
+		class, _ := r.Enum("class")
+		assert.Equal(t, "mage", class)
+		r.SetFloat64("balance", 100)
+		return nil
+	}))
+	assert.Error(t, players.QueryKey("invalid", func(r Row) error { return nil }))
+
+	// Fan out a query and merge the results
+	sum, err := QueryMerge(players, func(txn *Txn) (float64, error) {
+		return txn.With("mage").Float64("balance").Sum(), nil
+	}, func(a, b float64) float64 {
+		return a + b
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 499*10.0+100, sum)
+
+	// Fan out a delete
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.With("mage").DeleteAll()
+		return nil
+	}))
+	assert.Equal(t, 500, players.Count())
+	assert.NoError(t, players.DeleteKey("player-1"))
+	assert.Equal(t, 499, players.Count())
+
+	// Insert without a key
+	assert.Error(t, players.Insert(func(r Row) error {
+		return fmt.Errorf("error")
+	}))
+}
+
+func TestReadAt(t *testing.T) {
+	players := loadPlayers(500)
+
+	var name string
+	var balance float64
+	assert.NoError(t, players.QueryAt(20, func(r Row) error {
+		name, _ = r.String("name")
+		balance, _ = r.Float64("balance")
+		return nil
+	}))
+
+	values, ok := players.ReadAt(20, "name", "balance")
+	assert.True(t, ok)
+	assert.Equal(t, []any{name, balance}, values)
+
+	values, ok = players.ReadAt(20, "name", "invalid")
+	assert.False(t, ok)
+	assert.Equal(t, []any{name, nil}, values)
+
+	// Rows which do not exist have no values
+	values, ok = players.ReadAt(100000, "name", "balance")
+	assert.False(t, ok)
+	assert.Nil(t, values)
+
+	assert.True(t, players.DeleteAt(30))
+	values, ok = players.ReadAt(30, "name", "balance")
+	assert.False(t, ok)
+	assert.Nil(t, values)
+
+	// Typed reads of numbers
+	v, ok := ReadNumber[float64](players, 20, "balance")
+	assert.True(t, ok)
+	assert.Equal(t, balance, v)
+
+	_, ok = ReadNumber[int](players, 20, "balance")
+	assert.False(t, ok)
+	_, ok = ReadNumber[float64](players, 20, "invalid")
+	assert.False(t, ok)
+	_, ok = players.ReadKey("key", "name")
+	assert.False(t, ok)
+
+	// Reads by primary key
+	c := NewCollection()
+	c.CreateColumn("name", ForKey())
+	c.CreateColumn("age", ForInt())
+	c.InsertKey("merlin", func(r Row) error {
+		r.SetInt("age", 500)
+		return nil
+	})
+
+	values, ok = c.ReadKey("merlin", "age")
+	assert.True(t, ok)
+	assert.Equal(t, []any{500}, values)
+	_, ok = c.ReadKey("arthur", "age")
+	assert.False(t, ok)
+}
+
+func TestUpsertKeyMany(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("key", ForKey())
+	c.CreateColumn("clicks", ForInt64())
+	c.CreateColumn("tags", ForString(WithMerge(func(value, delta string) string {
+		if len(value) > 0 {
+			value += ","
+		}
+		return value + delta
+	})))
+	c.CreateColumn("country", ForEnum())
+
+	assert.NoError(t, c.UpsertKeyMany("a", map[string]any{
+		"clicks":  int64(1),
+		"tags":    "x",
+		"country": "fr",
+	}))
+	assert.NoError(t, c.UpsertKeysMany(map[string]map[string]any{
+		"a": {"clicks": int64(2), "tags": "y", "country": "de"},
+		"b": {"clicks": int64(5)},
+	}))
+
+	assert.Equal(t, 2, c.Count())
+	assert.NoError(t, c.QueryKey("a", func(r Row) error {
+		clicks, _ := r.Int64("clicks")
+		tags, _ := r.String("tags")
+		country, _ := r.Enum("country")
+		assert.Equal(t, int64(3), clicks)
+		assert.Equal(t, "x,y", tags)
+		assert.Equal(t, "de", country)
+		return nil
+	}))
+
+	// Invalid rows are not committed
+	assert.Error(t, c.UpsertKeysMany(map[string]map[string]any{
+		"c": {"clicks": int64(1)},
+		"d": {"invalid": 1},
+	}))
+	assert.Error(t, c.UpsertKeyMany("a", map[string]any{"clicks": "x"}))
+	assert.NoError(t, c.QueryKey("b", func(r Row) error {
+		clicks, _ := r.Int64("clicks")
+		assert.Equal(t, int64(5), clicks)
+		return nil
+	}))
+}
+
+func TestRenameAndAlterColumn(t *testing.T) {
+	players := loadPlayers(500)
+	mages := countIndex(players, "mage")
+	old := countIndex(players, "old")
+
+	// Rename a column along with its index
+	assert.NoError(t, players.RenameColumn("class", "role"))
+	assert.NoError(t, players.RenameColumn("mage", "wizard"))
+	assert.Error(t, players.RenameColumn("class", "other"))
+	assert.Error(t, players.RenameColumn("role", "race"))
+	assert.Equal(t, mages, countIndex(players, "wizard"))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, mages, txn.WithValue("role", func(v any) bool {
+			return v == "mage"
+		}).Count())
+		return nil
+	}))
+
+	// The indexes keep following the renamed column
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		r.SetEnum("role", "mage")
+		return nil
+	}))
+	assert.Equal(t, mages+1, countIndex(players, "wizard"))
+	mages++
+
+	// Change the type of the columns, and rebuild their indexes
+	assert.NoError(t, players.AlterColumn("age", ForInt64(), func(v any) any {
+		return int64(v.(int))
+	}))
+	assert.NoError(t, players.AlterColumn("role", ForString(), func(v any) any {
+		return v
+	}))
+	assert.Equal(t, old, countIndex(players, "old"))
+	assert.Equal(t, mages, countIndex(players, "wizard"))
+
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		age, ok := r.Int64("age")
+		assert.True(t, ok)
+		assert.Greater(t, age, int64(0))
+		role, _ := r.String("role")
+		assert.Equal(t, "mage", role)
+		return nil
+	}))
+
+	// Values which are not accepted abort the migration
+	assert.Error(t, players.AlterColumn("hp", ForInt64(), func(v any) any {
+		return v
+	}))
+	assert.Error(t, players.AlterColumn("old", ForInt(), func(v any) any {
+		return v
+	}))
+	assert.Error(t, players.AlterColumn("missing", ForInt(), func(v any) any {
+		return v
+	}))
+}
+
+func TestRenameAndAlterWithView(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+	assert.NoError(t, players.CreateView("rich", func(txn *Txn) *Txn {
+		return txn.WithFloat("balance", func(v float64) bool {
+			return v >= 3000
+		})
+	}))
+
+	// countRich counts the rows in the view as well as the rows matching the query
+	countRich := func(columnName string) (view, scan int) {
+		players.Query(func(txn *Txn) error {
+			view = txn.With("rich").Count()
+			scan = txn.WithFloat(columnName, func(v float64) bool {
+				return v >= 3000
+			}).Count()
+			return nil
+		})
+		return
+	}
+
+	rich, _ := countRich("balance")
+	assert.NotZero(t, rich)
+
+	// The view keeps reading the renamed column
+	assert.NoError(t, players.RenameColumn("balance", "wealth"))
+	assert.NoError(t, players.RenameColumn("wealth", "gold"))
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		r.SetFloat64("gold", 5000)
+		return nil
+	}))
+	view, scan := countRich("gold")
+	assert.Equal(t, scan, view)
+	assert.GreaterOrEqual(t, view, rich)
+
+	// The view is recomputed from the converted values
+	assert.NoError(t, players.AlterColumn("gold", ForFloat64(), func(v any) any {
+		return v.(float64) * 2
+	}))
+	view, scan = countRich("gold")
+	assert.Equal(t, scan, view)
+	assert.Greater(t, view, rich)
+}
+
+func TestAlterWithAuditTrigger(t *testing.T) {
+	players := loadPlayers(500)
+	defer players.Close()
+
+	var changes int
+	assert.NoError(t, players.CreateAuditTrigger("audit", "age", func(idx uint32, before, after any) {
+		changes++
+	}))
+	assert.NoError(t, players.AlterColumn("age", ForInt64(), func(v any) any {
+		return int64(v.(int))
+	}))
+
+	// The trigger follows the converted column
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		r.SetInt64("age", 99)
+		return nil
+	}))
+	assert.Equal(t, 1, changes)
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		age, _ := r.Int64("age")
+		assert.Equal(t, int64(99), age)
+		return nil
+	}))
+}
+
+// countIndex counts the rows of the collection which are part of the index
+func countIndex(c *Collection, indexName string) (count int) {
+	c.Query(func(txn *Txn) error {
+		count = txn.With(indexName).Count()
+		return nil
+	})
+	return
+}
+
+func TestCreateColumnWith(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.WithValue("age", func(v any) bool {
+			return v.(int) < 25
+		}).DeleteAll()
+		return nil
+	}))
+
+	// Backfill a column computed from the existing values of each row
+	assert.NoError(t, players.CreateColumnWith("power", ForInt(), func(r Row) any {
+		hp, _ := r.Int("hp")
+		mp, _ := r.Int("mp")
+		if hp+mp > 1000 {
+			return nil
+		}
+		return hp + mp
+	}))
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		hp, mp, power := txn.Int("hp"), txn.Int("mp"), txn.Int("power")
+		return txn.Range(func(idx uint32) {
+			h, _ := hp.Get()
+			m, _ := mp.Get()
+			v, ok := power.Get()
+			assert.Equal(t, h+m <= 1000, ok)
+			if ok {
+				assert.Equal(t, h+m, v)
+			}
+		})
+	}))
+
+	// New rows can be written to the column as usual
+	idx, err := players.Insert(func(r Row) error {
+		r.SetInt("power", 42)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+		v, ok := r.Int("power")
+		assert.True(t, ok)
+		assert.Equal(t, 42, v)
+		return nil
+	}))
+
+	// Invalid values drop the column
+	assert.Error(t, players.CreateColumnWith("label", ForString(), func(r Row) any {
+		return 1
+	}))
+	assert.Error(t, players.CreateColumnWith("power", ForInt(), func(r Row) any {
+		return 1
+	}))
+	_, exists := players.cols.Load("label")
+	assert.False(t, exists)
+}
+
+func TestFreeze(t *testing.T) {
+	players := loadPlayers(500)
+	count := players.Count()
+	assert.False(t, players.IsFrozen())
+	assert.NoError(t, players.Freeze())
+	assert.NoError(t, players.Freeze())
+	assert.True(t, players.IsFrozen())
+
+	// Writes are rejected and rolled back
+	_, err := players.Insert(func(r Row) error {
+		r.SetString("name", "merlin")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrFrozen)
+	assert.ErrorIs(t, players.QueryAt(0, func(r Row) error {
+		r.SetInt("age", 1)
+		return nil
+	}), ErrFrozen)
+	assert.ErrorIs(t, Atomic(func(tx *MultiTxn) error {
+		tx.On(players).DeleteAll()
+		return nil
+	}), ErrFrozen)
+	assert.ErrorIs(t, players.BulkLoad(func(*Loader) error {
+		return nil
+	}), ErrFrozen)
+	assert.ErrorIs(t, players.RenameColumn("name", "title"), ErrFrozen)
+	assert.Equal(t, count, players.Count())
+
+	// Reads are allowed concurrently, without locking the chunks
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, players.Query(func(txn *Txn) error {
+				assert.Equal(t, count, txn.Count())
+				assert.Greater(t, txn.With("human").Count(), 0)
+				return nil
+			}))
+
+			values, ok := players.ReadAt(0, "name", "age")
+			assert.True(t, ok)
+			assert.Len(t, values, 2)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestImportSQL(t *testing.T) {
+	db, err := sql.Open("column-test", "")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Import into a collection without any columns
+	players := NewCollection()
+	rows, err := db.Query("players")
+	assert.NoError(t, err)
+
+	var progress []int
+	n, err := players.ImportSQL(rows, WithImportBatch(2), WithImportProgress(func(rows int) {
+		progress = append(progress, rows)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []int{2, 3}, progress)
+	assert.Equal(t, 3, players.Count())
+	assert.Contains(t, players.Columns(), ColumnInfo{Name: "age", Type: "int64"})
+	assert.Contains(t, players.Columns(), ColumnInfo{Name: "joined", Type: "time"})
+
+	values, ok := players.ReadAt(1, "name", "age", "balance", "active")
+	assert.True(t, ok)
+	assert.Equal(t, []any{"bob", int64(30), 20.5, false}, values)
+
+	// Import into a keyed collection with existing columns, from textual values
+	keyed := NewCollection()
+	keyed.CreateColumn("name", ForKey())
+	keyed.CreateColumn("age", ForInt())
+	keyed.CreateColumn("balance", ForFloat32())
+	for i := 0; i < 2; i++ {
+		rows, err = db.Query("players_text")
+		assert.NoError(t, err)
+		n, err = keyed.ImportSQL(rows)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, 3, keyed.Count())
+	}
+
+	assert.NoError(t, keyed.QueryKey("carol", func(r Row) error {
+		age, _ := r.Int("age")
+		assert.Equal(t, 40, age)
+		balance, _ := r.Float32("balance")
+		assert.Equal(t, float32(30.5), balance)
+		_, ok := r.Any("active")
+		assert.False(t, ok)
+		return nil
+	}))
+
+	// Invalid values are reported
+	keyed.CreateColumn("joined", ForInt())
+	rows, err = db.Query("players")
+	assert.NoError(t, err)
+	_, err = keyed.ImportSQL(rows)
+	assert.Error(t, err)
+
+	// The numbers which do not fit into the column are reported, rather than truncated
+	for _, column := range []string{"score", "text", "ratio"} {
+		small := NewCollection()
+		small.CreateColumn(column, ForInt16())
+		rows, err = db.Query("scores")
+		assert.NoError(t, err)
+		_, err = small.ImportSQL(rows)
+		assert.Error(t, err)
+		assert.Equal(t, 0, small.Count())
+	}
+
+	// The batch size defaults to 1000 rows if it is not positive
+	rows, err = db.Query("players")
+	assert.NoError(t, err)
+	n, err = NewCollection().ImportSQL(rows, WithImportBatch(0))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+// --------------------------- SQL Driver ----------------------------
+
+func init() {
+	sql.Register("column-test", testDriver{})
+}
+
+// testDriver represents a database driver returning a fixed set of rows for each query
+type testDriver struct{}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return testStmt(query), nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type testStmt string
+
+func (testStmt) Close() error                               { return nil }
+func (testStmt) NumInput() int                              { return 0 }
+func (testStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s testStmt) Query([]driver.Value) (driver.Rows, error) {
+	joined := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	switch s {
+	case "players":
+		return &testRows{
+			names: []string{"name", "age", "balance", "active", "joined", "comment"},
+			types: []reflect.Type{
+				reflect.TypeOf(""), reflect.TypeOf(int64(0)), reflect.TypeOf(float64(0)),
+				reflect.TypeOf(false), reflect.TypeOf(sql.NullTime{}), reflect.TypeOf(sql.NullString{}),
+			},
+			rows: [][]driver.Value{
+				{"alice", int64(20), 10.5, true, joined, "first"},
+				{"bob", int64(30), 20.5, false, joined, nil},
+				{"carol", int64(40), 30.5, true, nil, []byte("last")},
+			},
+		}, nil
+	case "scores":
+		return &testRows{
+			names: []string{"score", "text", "ratio"},
+			types: []reflect.Type{
+				reflect.TypeOf(int64(0)), reflect.TypeOf(sql.RawBytes{}), reflect.TypeOf(float64(0)),
+			},
+			rows: [][]driver.Value{
+				{int64(70000), []byte("70000"), 0.5},
+			},
+		}, nil
+	default:
+		return &testRows{
+			names: []string{"name", "age", "balance", "active"},
+			rows: [][]driver.Value{
+				{[]byte("alice"), []byte("20"), []byte("10.5"), []byte("1")},
+				{[]byte("bob"), []byte("30"), []byte("20.5"), []byte("0")},
+				{[]byte("carol"), []byte("40"), []byte("30.5"), nil},
+			},
+		}, nil
+	}
+}
+
+type testRows struct {
+	names []string
+	types []reflect.Type
+	rows  [][]driver.Value
+}
+
+func (r *testRows) Columns() []string { return r.names }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dst []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dst, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *testRows) ColumnTypeScanType(i int) reflect.Type {
+	if r.types == nil {
+		return reflect.TypeOf(sql.RawBytes{})
+	}
+	return r.types[i]
+}
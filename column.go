@@ -4,9 +4,11 @@
 package column
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -53,6 +55,9 @@ type Numeric interface {
 	FilterFloat64(commit.Chunk, bitmap.Bitmap, func(v float64) bool)
 	FilterUint64(commit.Chunk, bitmap.Bitmap, func(v uint64) bool)
 	FilterInt64(commit.Chunk, bitmap.Bitmap, func(v int64) bool)
+	FilterRangeFloat64(chunk commit.Chunk, index bitmap.Bitmap, min, max float64)
+	FilterRangeUint64(chunk commit.Chunk, index bitmap.Bitmap, min, max uint64)
+	FilterRangeInt64(chunk commit.Chunk, index bitmap.Bitmap, min, max int64)
 }
 
 // Textual represents a column that stores strings.
@@ -62,6 +67,12 @@ type Textual interface {
 	FilterString(commit.Chunk, bitmap.Bitmap, func(v string) bool)
 }
 
+// typed represents a column which is able to validate the type of a value prior to
+// it being written into the commit buffer.
+type typed interface {
+	accepts(value any) bool
+}
+
 // --------------------------- Constructors ----------------------------
 
 // Various column constructor functions for a specific types.
@@ -118,7 +129,11 @@ func ForKind(kind reflect.Kind) (Column, error) {
 
 // option represents options for variouos columns.
 type option[T any] struct {
-	Merge func(value, delta T) T
+	Merge    func(value, delta T) T
+	MaxSize  int                        // The maximum size of a value, for variable-size columns
+	Clock    func() int64               // The clock for the last-writer-wins columns
+	Validate func(T) error              // The validation function of the values
+	Check    func(value, delta T) error // The check of the deltas merged into the values
 }
 
 // configure applies options
@@ -162,6 +177,12 @@ func (c *column) IsIndex() bool {
 	return ok
 }
 
+// IsTime checks whether the column stores time values.
+func (c *column) IsTime() bool {
+	_, ok := c.Column.(*columnTime)
+	return ok
+}
+
 // IsNumeric checks whether a column type supports certain numerical operations.
 func (c *column) IsNumeric() bool {
 	return (c.kind & typeNumeric) == typeNumeric
@@ -172,6 +193,15 @@ func (c *column) IsTextual() bool {
 	return (c.kind & typeTextual) == typeTextual
 }
 
+// Accepts checks whether a value can be stored in the column. Columns which do not
+// validate types accept any value supported by the commit buffer.
+func (c *column) Accepts(value any) bool {
+	if v, ok := c.Column.(typed); ok {
+		return v.accepts(value)
+	}
+	return true
+}
+
 // Grow grows the size of the column
 func (c *column) Grow(idx uint32) {
 	c.lock.Lock()
@@ -189,6 +219,22 @@ func (c *column) Apply(chunk commit.Chunk, r *commit.Reader) {
 	c.Column.Apply(chunk, r)
 }
 
+// ApplyContext performs a series of operations on a column, passing the context down
+// to the columns which require it, such as triggers.
+func (c *column) ApplyContext(ctx context.Context, chunk commit.Chunk, r *commit.Reader) {
+	applier, ok := c.Column.(contextual)
+	if !ok {
+		c.Apply(chunk, r)
+		return
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	r.Rewind()
+	applier.ApplyContext(ctx, chunk, r)
+}
+
 // Index loads the appropriate column index for a given chunk
 func (c *column) Index(chunk commit.Chunk) bitmap.Bitmap {
 	c.lock.RLock()
@@ -241,6 +287,12 @@ func readerFor[T any](txn *Txn, columnName string) reader[T] {
 	}
 }
 
+// encoder represents a column which encodes its values before they are written
+// into the commit buffer.
+type encoder interface {
+	encode(value any) (any, error)
+}
+
 // --------------------------- Any Writer ----------------------------
 
 // rwAny represents read-write accessor for any column type
@@ -313,3 +365,46 @@ func (s chunks[T]) Index(chunk commit.Chunk) (fill bitmap.Bitmap) {
 	}
 	return
 }
+
+// shrink releases the chunks starting from the specified one and returns the approximate
+// number of bytes freed.
+func (s *chunks[T]) shrink(from commit.Chunk) (freed int) {
+	if int(from) >= len(*s) {
+		return 0
+	}
+
+	var zero T
+	for i := int(from); i < len(*s); i++ {
+		freed += len((*s)[i].fill)*8 + len((*s)[i].data)*int(unsafe.Sizeof(zero))
+		(*s)[i].fill = nil
+		(*s)[i].data = nil
+	}
+
+	*s = (*s)[:from]
+	return
+}
+
+// prefetch touches every memory page of the chunk so that they are resident by the
+// time the chunk is read, and returns a checksum which prevents the reads from being
+// optimized away.
+func (s chunks[T]) prefetch(chunk commit.Chunk) (sum byte) {
+	if int(chunk) >= len(s) || len(s[chunk].data) == 0 {
+		return 0
+	}
+
+	var zero T
+	data := s[chunk].data
+	size := int(unsafe.Sizeof(zero)) * len(data)
+	if size == 0 {
+		return 0
+	}
+
+	memory := unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), size)
+	for i := 0; i < len(memory); i += pageSize {
+		sum += memory[i]
+	}
+	for i := 0; i < len(s[chunk].fill); i += pageSize / 8 {
+		sum += byte(s[chunk].fill[i])
+	}
+	return sum
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// auditor represents a computed column which observes the values of its source column
+// before they are modified.
+type auditor interface {
+	computed
+	capture(source *column, r *commit.Reader)
+}
+
+// --------------------------- Audit Trigger ----------------------------
+
+// columnAudit represents a trigger which receives the previous and the new values
+type columnAudit struct {
+	lock   sync.Mutex                          // The lock to protect the captured values
+	name   string                              // The name of the target column
+	source *column                             // The source column, set during the capture
+	before map[uint32]any                      // The captured values, until they are applied
+	clbk   func(idx uint32, before, after any) // The trigger callback
+}
+
+// CreateAuditTrigger creates a trigger with a specified name which depends on a given column.
+// The trigger function receives the index of the row, along with the value stored before
+// and after the change, whenever a row is added, updated or deleted. A missing value is
+// given as nil.
+func (c *Collection) CreateAuditTrigger(triggerName, columnName string, fn func(idx uint32, before, after any)) error {
+	if fn == nil || columnName == "" || triggerName == "" {
+		return fmt.Errorf("column: create trigger must specify name, column and function")
+	}
+
+	return c.createTrigger(columnName, columnFor(triggerName, &columnAudit{
+		name:   columnName,
+		before: make(map[uint32]any),
+		clbk:   fn,
+	}))
+}
+
+// Column returns the target name of the column on which this trigger should apply.
+func (c *columnAudit) Column() string {
+	return c.name
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnAudit) Grow(idx uint32) {
+	// Noop
+}
+
+// capture captures the values of the source column, before the operations are applied.
+func (c *columnAudit) capture(source *column, r *commit.Reader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.source = source
+	for r.Next() {
+		switch r.Type {
+		case commit.Put, commit.Merge, commit.Delete:
+			c.before[r.Index()] = valueAt(source, r.Index())
+		}
+	}
+}
+
+// valueAt returns the value stored in the column, or nil if there is none
+func valueAt(source *column, idx uint32) any {
+	if v, ok := source.Column.Value(idx); ok {
+		return v
+	}
+	return nil
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnAudit) Apply(chunk commit.Chunk, r *commit.Reader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for r.Next() {
+		before, ok := c.before[r.Index()]
+		if !ok {
+			continue
+		}
+
+		delete(c.before, r.Index())
+		after := valueAt(c.source, r.Index())
+		if before != nil || after != nil {
+			c.clbk(r.Index(), before, after)
+		}
+	}
+}
+
+// ApplyContext applies a set of operations to the column.
+func (c *columnAudit) ApplyContext(_ context.Context, chunk commit.Chunk, r *commit.Reader) {
+	c.Apply(chunk, r)
+}
+
+// Value retrieves a value at a specified index.
+func (c *columnAudit) Value(idx uint32) (v any, ok bool) {
+	return nil, false
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnAudit) Contains(idx uint32) bool {
+	return false
+}
+
+// Index returns the fill list for the column
+func (c *columnAudit) Index(chunk commit.Chunk) bitmap.Bitmap {
+	return nil
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnAudit) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	// Noop
+}
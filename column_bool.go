@@ -10,43 +10,61 @@ import (
 
 // columnBool represents a boolean column
 type columnBool struct {
-	data bitmap.Bitmap
+	data bitmap.Bitmap // The rows which are true
+	fill bitmap.Bitmap // The rows which have a value, true or false
 }
 
 // makeBools creates a new boolean column
 func makeBools() Column {
 	return &columnBool{
 		data: make(bitmap.Bitmap, 0, 4),
+		fill: make(bitmap.Bitmap, 0, 4),
 	}
 }
 
 // Grow grows the size of the column until we have enough to store
 func (c *columnBool) Grow(idx uint32) {
 	c.data.Grow(idx)
+	c.fill.Grow(idx)
 }
 
-// Apply applies a set of operations to the column.
+// Apply applies a set of operations to the column. Since a false value is encoded the same
+// way as a deletion, the deletions are only those of the rows themselves.
 func (c *columnBool) Apply(chunk commit.Chunk, r *commit.Reader) {
+	rows := r.Column() == rowColumn
 	for r.Next() {
 		v := uint64(1) << (r.Offset & 0x3f)
-		switch r.Type {
-		case commit.PutTrue:
+		switch {
+		case rows && r.Type == commit.Delete:
+			c.data[r.Offset>>6] &^= v
+			c.fill[r.Offset>>6] &^= v
+		case rows:
+			continue
+		case r.Type == commit.PutTrue:
 			c.data[r.Offset>>6] |= v
-		case commit.PutFalse: // also "delete"
+			c.fill[r.Offset>>6] |= v
+		case r.Type == commit.PutFalse:
 			c.data[r.Offset>>6] &^= v
+			c.fill[r.Offset>>6] |= v
 		}
 	}
 }
 
 // Value retrieves a value at a specified index
 func (c *columnBool) Value(idx uint32) (interface{}, bool) {
-	value := c.data.Contains(idx)
-	return value, value
+	return c.data.Contains(idx), c.fill.Contains(idx)
 }
 
 // Contains checks whether the column has a value at a specified index.
 func (c *columnBool) Contains(idx uint32) bool {
-	return c.data.Contains(idx)
+	return c.fill.Contains(idx)
+}
+
+// falses returns the rows of a chunk which are explicitly set to false
+func (c *columnBool) falses(chunk commit.Chunk) bitmap.Bitmap {
+	out := chunk.OfBitmap(c.fill).Clone(nil)
+	out.AndNot(chunk.OfBitmap(c.data))
+	return out
 }
 
 // Index returns the fill list for the column
@@ -54,8 +72,16 @@ func (c *columnBool) Index(chunk commit.Chunk) bitmap.Bitmap {
 	return chunk.OfBitmap(c.data)
 }
 
-// Snapshot writes the entire column into the specified destination buffer
+// accepts checks whether a value can be stored in the column.
+func (c *columnBool) accepts(value any) bool {
+	_, ok := value.(bool)
+	return ok
+}
+
+// Snapshot writes the entire column into the specified destination buffer. Every row with
+// a value is first written as false, so that the false values are kept, then the true ones.
 func (c *columnBool) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	dst.PutBitmap(commit.PutFalse, chunk, c.fill)
 	dst.PutBitmap(commit.PutTrue, chunk, c.data)
 }
 
@@ -65,6 +91,7 @@ func (c *columnBool) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 type rwBool struct {
 	rdBool
 	writer *commit.Buffer
+	txn    *Txn
 }
 
 // Set sets the value at the current transaction cursor
@@ -72,11 +99,25 @@ func (s rwBool) Set(value bool) {
 	s.writer.PutBool(*s.cursor, value)
 }
 
+// SetAll sets the value of every row selected by the transaction
+func (s rwBool) SetAll(value bool) {
+	op := commit.PutFalse
+	if value {
+		op = commit.PutTrue
+	}
+
+	s.txn.initialize()
+	s.txn.rangeRead(func(chunk commit.Chunk, _ bitmap.Bitmap) {
+		s.writer.PutBitmap(op, chunk, s.txn.index)
+	})
+}
+
 // Bool returns a bool column accessor
 func (txn *Txn) Bool(columnName string) rwBool {
 	return rwBool{
 		rdBool: readBoolOf(txn, columnName),
 		writer: txn.bufferFor(columnName),
+		txn:    txn,
 	}
 }
 
@@ -87,6 +128,9 @@ type rdBool reader[Column]
 
 // Get loads the value at the current transaction cursor
 func (s rdBool) Get() bool {
+	if c, ok := s.reader.(*columnBool); ok {
+		return c.data.Contains(*s.cursor)
+	}
 	return s.reader.Contains(*s.cursor)
 }
 
@@ -0,0 +1,153 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/kelindar/column/commit"
+)
+
+// maxBytes is the largest value which can be carried by a single commit operation
+const maxBytes = 1<<16 - 1
+
+// --------------------------- Bytes ----------------------------
+
+// columnBytes represents a column which stores binary values
+type columnBytes struct {
+	chunks[[]byte]
+	option[[]byte]
+}
+
+// ForBytes creates a new column which stores binary values natively. The maximum size
+// of a value can be configured with WithMaxSize and is capped by the commit log, which
+// supports values of up to 64KB.
+func ForBytes(opts ...func(*option[[]byte])) Column {
+	config := configure(opts, option[[]byte]{
+		MaxSize: maxBytes,
+	})
+	if config.MaxSize <= 0 || config.MaxSize > maxBytes {
+		config.MaxSize = maxBytes
+	}
+
+	return &columnBytes{
+		chunks: make(chunks[[]byte], 0, 4),
+		option: config,
+	}
+}
+
+// WithMaxSize sets the maximum size of a value in a binary column. Writing a larger
+// value returns an error.
+func WithMaxSize(size int) func(*option[[]byte]) {
+	return func(v *option[[]byte]) {
+		v.MaxSize = size
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnBytes) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			value := r.Bytes()
+			if len(value) > c.MaxSize {
+				continue
+			}
+
+			// The reader's buffer is reused, so the value needs to be copied
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+			data[offset] = append(data[offset][:0], value...)
+		case commit.Delete:
+			fill.Remove(offset)
+			data[offset] = nil
+		}
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnBytes) Value(idx uint32) (any, bool) {
+	return c.LoadBytes(idx)
+}
+
+// LoadBytes retrieves a binary value at a specified index. The returned slice must not
+// be modified or retained after the transaction.
+func (c *columnBytes) LoadBytes(idx uint32) (v []byte, ok bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(index) {
+		v, ok = c.chunks[chunk].data[index], true
+	}
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnBytes) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnBytes) accepts(value any) bool {
+	v, ok := value.([]byte)
+	return ok && len(v) <= c.MaxSize
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnBytes) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		dst.PutBytes(commit.Put, chunk.Min()+x, data[x])
+	})
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwBytes represents read-write accessor for binary values
+type rwBytes struct {
+	rdBytes
+	writer *commit.Buffer
+}
+
+// Set stores a binary value at the current transaction cursor. If the value is larger
+// than the maximum size configured for the column, an error is returned.
+func (s rwBytes) Set(value []byte) error {
+	if len(value) > s.reader.MaxSize {
+		return fmt.Errorf("column: unable to set bytes, size %d exceeds the limit of %d",
+			len(value), s.reader.MaxSize)
+	}
+
+	s.writer.PutBytes(commit.Put, *s.cursor, value)
+	return nil
+}
+
+// Bytes returns a binary column accessor
+func (txn *Txn) Bytes(columnName string) rwBytes {
+	return rwBytes{
+		rdBytes: rdBytes(readerFor[*columnBytes](txn, columnName)),
+		writer:  txn.bufferFor(columnName),
+	}
+}
+
+// rdBytes represents a read-only accessor for binary values
+type rdBytes reader[*columnBytes]
+
+// Get loads the binary value at the current transaction cursor. The returned slice
+// must not be modified or retained after the transaction.
+func (s rdBytes) Get() ([]byte, bool) {
+	return s.reader.LoadBytes(*s.cursor)
+}
+
+// Reader returns a reader over the binary value at the current transaction cursor,
+// which allows the value to be streamed into a writer without copying it.
+func (s rdBytes) Reader() (io.Reader, bool) {
+	value, ok := s.reader.LoadBytes(*s.cursor)
+	if !ok {
+		return nil, false
+	}
+	return bytes.NewReader(value), true
+}
@@ -0,0 +1,434 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- Counter ----------------------------
+
+// columnCounter represents a counter which converges when multiple collections replay
+// each other's commits. Unlike other numeric columns, merges keep the delta in the
+// commit rather than the merged value, so the order in which they are applied does not
+// matter. Each collection should only ship its own commits to the others, since the
+// commits it has replayed would be counted twice.
+type columnCounter struct {
+	*numericColumn[int64]
+}
+
+// ForCounter creates a new int64 counter column, which is incremented with the Add method
+// of the Counter accessor. Since the commits of a counter carry deltas, indexes can not
+// be created on this column.
+func ForCounter() Column {
+	return &columnCounter{
+		numericColumn: makeNumeric(
+			func(buffer *commit.Buffer, idx uint32, value int64) { buffer.PutInt64(commit.Put, idx, value) },
+			func(r *commit.Reader, fill bitmap.Bitmap, data []int64, opts option[int64]) {
+				for r.Next() {
+					offset := r.IndexAtChunk()
+					switch r.Type {
+					case commit.Put:
+						fill[offset>>6] |= 1 << (offset & 0x3f)
+						data[offset] = r.Int64()
+					case commit.Merge:
+						if !fill.Contains(offset) {
+							data[offset] = 0
+						}
+						fill[offset>>6] |= 1 << (offset & 0x3f)
+						data[offset] += r.Int64()
+					case commit.Delete:
+						fill.Remove(offset)
+					}
+				}
+			}, rangeInt64s[int64], nil,
+		),
+	}
+}
+
+// isCounter checks whether the column is a counter
+func isCounter(c *column) bool {
+	_, ok := c.Column.(*columnCounter)
+	return ok
+}
+
+// rwCounter represents read-write accessor for counters
+type rwCounter struct {
+	rdNumber[int64]
+	writer *commit.Buffer
+}
+
+// Add atomically adds a delta to the counter at the current transaction cursor
+func (s rwCounter) Add(delta int64) {
+	s.writer.PutInt64(commit.Merge, s.txn.cursor, delta)
+}
+
+// Counter returns a counter column accessor
+func (txn *Txn) Counter(columnName string) rwCounter {
+	return rwCounter{
+		rdNumber: rdNumber[int64]{
+			reader: readerFor[*columnCounter](txn, columnName).reader.numericColumn,
+			txn:    txn,
+		},
+		writer: txn.bufferFor(columnName),
+	}
+}
+
+// --------------------------- Register ----------------------------
+
+var _ Textual = new(columnRegister)
+
+// register represents a value of a last-writer-wins register along with the time it
+// was written at.
+type register struct {
+	value string
+	clock int64
+}
+
+// newer checks whether the register should be replaced with the specified value. Ties
+// are broken by comparing the values, so that every replica picks the same one.
+func (r register) newer(clock int64, value string) bool {
+	return clock > r.clock || (clock == r.clock && value > r.value)
+}
+
+// columnRegister represents a column of last-writer-wins string registers. Every write
+// carries the time it was made at and only replaces a value written earlier, so that
+// the collections which replay each other's commits converge regardless of the order.
+type columnRegister struct {
+	chunks[register]
+	option[string]
+}
+
+// ForRegister creates a new column of last-writer-wins string registers. The clock
+// used to timestamp the writes can be specified with WithLWW, and defaults to the
+// wall clock of the machine.
+func ForRegister(opts ...func(*option[string])) Column {
+	return &columnRegister{
+		chunks: make(chunks[register], 0, 4),
+		option: configure(opts, option[string]{
+			Clock: func() int64 { return time.Now().UnixNano() },
+		}),
+	}
+}
+
+// WithLWW sets the clock which is used to timestamp the writes of a register column.
+// The clock should be monotonic and comparable across the collections, such as a
+// hybrid logical clock.
+func WithLWW(clock func() int64) func(*option[string]) {
+	return func(v *option[string]) {
+		v.Clock = clock
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnRegister) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put, commit.Merge:
+			clock, value, ok := decodeRegister(r.Bytes())
+			if ok && (!fill.Contains(offset) || data[offset].newer(clock, value)) {
+				fill[offset>>6] |= 1 << (offset & 0x3f)
+				data[offset] = register{value: value, clock: clock}
+			}
+		case commit.Delete:
+			fill.Remove(offset)
+		}
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnRegister) Value(idx uint32) (v interface{}, ok bool) {
+	return c.LoadString(idx)
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnRegister) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// LoadString retrieves a value at a specified index
+func (c *columnRegister) LoadString(idx uint32) (v string, ok bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(index) {
+		v, ok = c.chunks[chunk].data[index].value, true
+	}
+	return
+}
+
+// FilterString filters down the values based on the specified predicate.
+func (c *columnRegister) FilterString(chunk commit.Chunk, index bitmap.Bitmap, predicate func(v string) bool) {
+	if int(chunk) < len(c.chunks) {
+		fill, data := c.chunkAt(chunk)
+		index.And(fill)
+		index.Filter(func(idx uint32) bool {
+			return predicate(data[idx].value)
+		})
+	}
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnRegister) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		dst.PutBytes(commit.Put, chunk.Min()+x, encodeRegister(data[x].clock, data[x].value))
+	})
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnRegister) accepts(value any) bool {
+	return isTextual(value)
+}
+
+// encode timestamps a value before it is written into the commit buffer.
+func (c *columnRegister) encode(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return encodeRegister(c.Clock(), v), nil
+	case []byte:
+		return encodeRegister(c.Clock(), string(v)), nil
+	default:
+		return nil, fmt.Errorf("column: unable to encode %T into a register", value)
+	}
+}
+
+// encodeRegister encodes a value along with its timestamp
+func encodeRegister(clock int64, value string) []byte {
+	out := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(out, uint64(clock))
+	return append(out, value...)
+}
+
+// decodeRegister decodes a value along with its timestamp
+func decodeRegister(b []byte) (clock int64, value string, ok bool) {
+	if len(b) < 8 {
+		return 0, "", false
+	}
+	return int64(binary.BigEndian.Uint64(b)), string(b[8:]), true
+}
+
+// rwRegister represents read-write accessor for registers
+type rwRegister struct {
+	rdString[*columnRegister]
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor, timestamped with the clock of
+// the column. The value is discarded on commit if a newer one was already written.
+func (s rwRegister) Set(value string) {
+	s.writer.PutBytes(commit.Put, *s.cursor, encodeRegister(s.reader.Clock(), value))
+}
+
+// Register returns a last-writer-wins register column accessor
+func (txn *Txn) Register(columnName string) rwRegister {
+	return rwRegister{
+		rdString: readStringOf[*columnRegister](txn, columnName),
+		writer:   txn.bufferFor(columnName),
+	}
+}
+
+// --------------------------- Sketch ----------------------------
+
+// sketchPrecision is the number of bits of the hash used to select a register of the
+// sketches stored in a column, for a standard error of about 3%.
+const sketchPrecision = 10
+
+// Sketch represents a HyperLogLog sketch which estimates the number of distinct values
+// added to it. Sketches can be merged together, and the result does not depend on the
+// order in which the values were added or the sketches merged.
+type Sketch struct {
+	registers [1 << sketchPrecision]uint8
+}
+
+// NewSketch creates a new sketch with the specified values
+func NewSketch(values ...any) *Sketch {
+	s := new(Sketch)
+	for _, v := range values {
+		s.Add(v)
+	}
+	return s
+}
+
+// Add adds a value to the sketch
+func (s *Sketch) Add(value any) {
+	hllAdd(s.registers[:], hashOf(value))
+}
+
+// Merge merges another sketch into this one, so that it estimates the union of both sets
+func (s *Sketch) Merge(other *Sketch) {
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+}
+
+// Estimate estimates the number of distinct values added to the sketch
+func (s *Sketch) Estimate() int {
+	return hllCount(s.registers[:])
+}
+
+// apply applies a set of encoded registers to the sketch, keeping the largest ranks
+func (s *Sketch) apply(b []byte) {
+	for ; len(b) >= 3; b = b[3:] {
+		at := binary.BigEndian.Uint16(b) % uint16(len(s.registers))
+		if b[2] > s.registers[at] {
+			s.registers[at] = b[2]
+		}
+	}
+}
+
+// encode encodes the registers of the sketch which are set, as a sequence of 2-byte
+// register and 1-byte rank pairs.
+func (s *Sketch) encode() []byte {
+	out := make([]byte, 0, 64)
+	for i, r := range s.registers {
+		if r > 0 {
+			out = binary.BigEndian.AppendUint16(out, uint16(i))
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// columnSketch represents a column of HyperLogLog sketches, such as per-row counters of
+// unique visitors. Every merge carries the registers to union with the stored sketch,
+// so that the collections which replay each other's commits converge regardless of the
+// order, and a commit replayed twice is not counted twice.
+type columnSketch struct {
+	chunks[*Sketch]
+}
+
+// ForHLL creates a new column of HyperLogLog sketches, to which values are added with the
+// Add method of the HLL accessor and whose number of distinct values is estimated with its
+// Estimate method.
+func ForHLL() Column {
+	return &columnSketch{
+		chunks: make(chunks[*Sketch], 0, 4),
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnSketch) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+			data[offset] = new(Sketch)
+			data[offset].apply(r.Bytes())
+		case commit.Merge:
+			if !fill.Contains(offset) || data[offset] == nil {
+				data[offset] = new(Sketch)
+			}
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+			data[offset].apply(r.Bytes())
+		case commit.Delete:
+			fill.Remove(offset)
+			data[offset] = nil
+		}
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnSketch) Value(idx uint32) (v interface{}, ok bool) {
+	return c.LoadSketch(idx)
+}
+
+// LoadSketch retrieves a copy of the sketch at a specified index
+func (c *columnSketch) LoadSketch(idx uint32) (*Sketch, bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) >= len(c.chunks) || !c.chunks[chunk].fill.Contains(index) {
+		return nil, false
+	}
+
+	clone := *c.chunks[chunk].data[index]
+	return &clone, true
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnSketch) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnSketch) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		dst.PutBytes(commit.Put, chunk.Min()+x, data[x].encode())
+	})
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnSketch) accepts(value any) bool {
+	_, ok := value.(*Sketch)
+	return ok
+}
+
+// encode encodes a sketch before it is written into the commit buffer.
+func (c *columnSketch) encode(value any) (any, error) {
+	if v, ok := value.(*Sketch); ok {
+		return v.encode(), nil
+	}
+	return nil, fmt.Errorf("column: unable to encode %T into a sketch", value)
+}
+
+// rwSketch represents read-write accessor for sketches
+type rwSketch struct {
+	rdSketch
+	writer *commit.Buffer
+}
+
+// Add atomically adds the values to the sketch at the current transaction cursor
+func (s rwSketch) Add(values ...any) {
+	delta := make([]byte, 0, 3*len(values))
+	for _, v := range values {
+		at, rank := hllRank(1<<sketchPrecision, hashOf(v))
+		delta = binary.BigEndian.AppendUint16(delta, uint16(at))
+		delta = append(delta, rank)
+	}
+	s.writer.PutBytes(commit.Merge, *s.cursor, delta)
+}
+
+// Merge atomically merges a sketch into the sketch at the current transaction cursor
+func (s rwSketch) Merge(delta *Sketch) {
+	s.writer.PutBytes(commit.Merge, *s.cursor, delta.encode())
+}
+
+// HLL returns a HyperLogLog sketch column accessor
+func (txn *Txn) HLL(columnName string) rwSketch {
+	return rwSketch{
+		rdSketch: rdSketch(readerFor[*columnSketch](txn, columnName)),
+		writer:   txn.bufferFor(columnName),
+	}
+}
+
+// rdSketch represents a read-only accessor for sketches
+type rdSketch reader[*columnSketch]
+
+// Get loads a copy of the sketch at the current transaction cursor
+func (s rdSketch) Get() (*Sketch, bool) {
+	return s.reader.LoadSketch(*s.cursor)
+}
+
+// Estimate estimates the number of distinct values of the sketch at the current
+// transaction cursor
+func (s rdSketch) Estimate() (int, bool) {
+	sketch, ok := s.reader.LoadSketch(*s.cursor)
+	if !ok {
+		return 0, false
+	}
+	return sketch.Estimate(), true
+}
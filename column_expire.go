@@ -6,11 +6,24 @@ package column
 import (
 	"context"
 	"time"
+
+	"github.com/kelindar/bitmap"
 )
 
 // --------------------------- Expiration (Vacuum) ----------------------------
 
-// vacuum cleans up the expired objects on a specified interval.
+// startVacuum starts the goroutine which cleans up the expired objects, unless it is
+// already running. It is started by the first commit which sets an expiration, including
+// the commits of a restored snapshot or replayed from another collection, and stops once
+// the collection is closed.
+func (c *Collection) startVacuum() {
+	c.vacuums.Do(func() {
+		go c.vacuum(c.ctx, c.opts.Vacuum)
+	})
+}
+
+// vacuum cleans up the expired objects on a specified interval. The context is passed
+// down to the triggers which observe the deletion of expired rows.
 func (c *Collection) vacuum(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	for {
@@ -19,22 +32,68 @@ func (c *Collection) vacuum(ctx context.Context, interval time.Duration) {
 			ticker.Stop()
 			return
 		case <-ticker.C:
-			c.Query(func(txn *Txn) error {
-				ttl, now := txn.TTL(), time.Now()
-				return txn.With(expireColumn).Range(func(idx uint32) {
-					if expiresAt, ok := ttl.ExpiresAt(); ok && now.After(expiresAt) {
-						txn.DeleteAt(idx)
-					}
+			c.add(MetricVacuums, 1)
+			c.QueryContext(ctx, func(txn *Txn) error {
+				return txn.WithExpiringBefore(time.Now()).Range(func(idx uint32) {
+					txn.DeleteAt(idx)
 				})
 			})
 		}
 	}
 }
 
+// WithExpiringBefore filters down the rows to the ones which expire before the specified
+// time. The expirations are kept in a sorted index, so only the matching rows are visited
+// rather than every row with a time-to-live.
+func (txn *Txn) WithExpiringBefore(t time.Time) *Txn {
+	defer txn.trace("WithExpiringBefore", expireColumn)()
+	txn.initialize()
+	index, err := txn.sortIndexOf(expireIndex)
+	if err != nil {
+		txn.index.Clear()
+		return txn
+	}
+
+	// Zero is not an expiration, but the absence of a time-to-live
+	var expiring bitmap.Bitmap
+	from, until := SortKey(int64(1)), SortKey(t.UnixNano())
+	index.tree().Ascend(sortIndexItem{Key: from}, func(item sortIndexItem) bool {
+		if item.Key >= until {
+			return false
+		}
+
+		expiring.Set(item.Value)
+		return true
+	})
+
+	txn.index.And(expiring)
+	return txn
+}
+
+// ExpireKey sets the time-to-live of the row with the specified primary key. A zero
+// time-to-live means that the row never expires.
+func (c *Collection) ExpireKey(key string, ttl time.Duration) error {
+	if c.opts.NoTTL {
+		return errNoTTL
+	}
+
+	return c.QueryKey(key, func(r Row) error {
+		r.SetTTL(ttl)
+		return nil
+	})
+}
+
 // --------------------------- Expiration (Column) ----------------------------
 
-// TTL returns a read-write accessor for the time-to-live column
+// TTL returns a read-write accessor for the time-to-live column. If the time-to-live is
+// disabled for the collection, the transaction fails with an error and the accessor does
+// nothing.
 func (txn *Txn) TTL() rwTTL {
+	if txn.owner.opts.NoTTL {
+		txn.abort(errNoTTL)
+		return rwTTL{}
+	}
+
 	return rwTTL{
 		rw: rwInt64{
 			rdNumber: readNumberOf[int64](txn, expireColumn),
@@ -49,6 +108,10 @@ type rwTTL struct {
 
 // TTL returns the remaining time-to-live duration
 func (s rwTTL) TTL() (time.Duration, bool) {
+	if s.rw.writer == nil {
+		return 0, false
+	}
+
 	if expireAt, ok := s.rw.Get(); ok && expireAt != 0 {
 		return readTTL(expireAt), true
 	}
@@ -57,6 +120,10 @@ func (s rwTTL) TTL() (time.Duration, bool) {
 
 // ExpiresAt returns the expiration time
 func (s rwTTL) ExpiresAt() (time.Time, bool) {
+	if s.rw.writer == nil {
+		return time.Time{}, false
+	}
+
 	if expireAt, ok := s.rw.Get(); ok && expireAt != 0 {
 		return time.Unix(0, expireAt), true
 	}
@@ -65,12 +132,16 @@ func (s rwTTL) ExpiresAt() (time.Time, bool) {
 
 // Set sets the time-to-live value at the current transaction cursor
 func (s rwTTL) Set(ttl time.Duration) {
-	s.rw.Set(writeTTL(ttl))
+	if s.rw.writer != nil {
+		s.rw.Set(writeTTL(ttl))
+	}
 }
 
 // Extend extends time-to-live of the row current transaction cursor by a specified amount
 func (s rwTTL) Extend(delta time.Duration) {
-	s.rw.Merge(int64(delta.Nanoseconds()))
+	if s.rw.writer != nil {
+		s.rw.Merge(int64(delta.Nanoseconds()))
+	}
 }
 
 // readTTL converts expiration to a TTL
@@ -90,14 +161,24 @@ func writeTTL(ttl time.Duration) int64 {
 
 // TTL retrieves the time left before the row will be cleaned up
 func (r Row) TTL() (time.Duration, bool) {
+	if r.txn.owner.opts.NoTTL {
+		return 0, false
+	}
+
 	if expireAt, ok := r.Int64(expireColumn); ok {
 		return readTTL(expireAt), true
 	}
 	return 0, false
 }
 
-// SetTTL sets a time-to-live for a row and returns the expiration time
+// SetTTL sets a time-to-live for a row and returns the expiration time. If the time-to-live
+// is disabled for the collection, the transaction fails with an error instead.
 func (r Row) SetTTL(ttl time.Duration) (until time.Time) {
+	if r.txn.owner.opts.NoTTL {
+		r.txn.abort(errNoTTL)
+		return
+	}
+
 	var nanos int64
 	if ttl > 0 {
 		until = time.Now().Add(ttl)
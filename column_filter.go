@@ -0,0 +1,34 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/simd"
+)
+
+// --------------------------- Range Kernels ----------------------------
+
+// rangeOf filters down the index to the values within the inclusive [lo, hi] range, one
+// value at a time. The comparison is written so that NaN is never within the range, same
+// as with the vectorized kernels.
+func rangeOf[T simd.Number](index bitmap.Bitmap, data []T, lo, hi T) {
+	for blkAt, blk := range index {
+		if blk == 0 {
+			continue
+		}
+
+		offset := blkAt << 6
+		for bit := 0; bit < 64; bit++ {
+			if blk&(1<<bit) == 0 {
+				continue
+			}
+
+			if v := data[offset+bit]; !(v >= lo && v <= hi) {
+				blk &^= 1 << bit
+			}
+		}
+		index[blkAt] = blk
+	}
+}
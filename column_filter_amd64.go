@@ -0,0 +1,125 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+//go:build amd64
+// +build amd64
+
+package column
+
+import (
+	"unsafe"
+
+	"github.com/kelindar/bitmap"
+	"github.com/klauspost/cpuid/v2"
+)
+
+var avx2 = cpuid.CPU.Supports(cpuid.AVX2)
+
+//go:noescape
+func rangeFloat64sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi float64)
+
+//go:noescape
+func rangeFloat32sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi float32)
+
+//go:noescape
+func rangeInt64sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi int64)
+
+//go:noescape
+func rangeUint64sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi uint64)
+
+//go:noescape
+func rangeInt32sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi int32)
+
+//go:noescape
+func rangeUint32sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi uint32)
+
+//go:noescape
+func rangeInt16sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi int32)
+
+//go:noescape
+func rangeUint16sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo, hi int32)
+
+// blocksOf returns the number of blocks of the index which have all of their 64 values
+// in the data, and can hence be filtered by the vectorized kernels.
+func blocksOf[T any](index bitmap.Bitmap, data []T) int {
+	blocks := len(data) / 64
+	if len(index) < blocks {
+		blocks = len(index)
+	}
+	if !avx2 {
+		blocks = 0
+	}
+	return blocks
+}
+
+// rangeFloat64s filters down the index to the float64 values within the [lo, hi] range
+func rangeFloat64s[T ~float64](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeFloat64sAVX2(&index[0], unsafe.Pointer(&data[0]), n, float64(lo), float64(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeFloat32s filters down the index to the float32 values within the [lo, hi] range
+func rangeFloat32s[T ~float32](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeFloat32sAVX2(&index[0], unsafe.Pointer(&data[0]), n, float32(lo), float32(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeInt64s filters down the index to the int64 values within the [lo, hi] range
+func rangeInt64s[T ~int | ~int64](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeInt64sAVX2(&index[0], unsafe.Pointer(&data[0]), n, int64(lo), int64(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeUint64s filters down the index to the uint64 values within the [lo, hi] range
+func rangeUint64s[T uint | ~uint64](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeUint64sAVX2(&index[0], unsafe.Pointer(&data[0]), n, uint64(lo), uint64(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeInt32s filters down the index to the int32 values within the [lo, hi] range
+func rangeInt32s[T ~int32](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeInt32sAVX2(&index[0], unsafe.Pointer(&data[0]), n, int32(lo), int32(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeUint32s filters down the index to the uint32 values within the [lo, hi] range
+func rangeUint32s[T ~uint32](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeUint32sAVX2(&index[0], unsafe.Pointer(&data[0]), n, uint32(lo), uint32(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeInt16s filters down the index to the int16 values within the [lo, hi] range
+func rangeInt16s[T ~int16](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeInt16sAVX2(&index[0], unsafe.Pointer(&data[0]), n, int32(lo), int32(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeUint16s filters down the index to the uint16 values within the [lo, hi] range
+func rangeUint16s[T ~uint16](index bitmap.Bitmap, data []T, lo, hi T) {
+	if n := blocksOf(index, data); n > 0 {
+		rangeUint16sAVX2(&index[0], unsafe.Pointer(&data[0]), n, int32(lo), int32(hi))
+		index, data = index[n:], data[n*64:]
+	}
+	rangeOf(index, data, lo, hi)
+}
@@ -0,0 +1,372 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+//go:build amd64
+// +build amd64
+
+#include "textflag.h"
+
+// Each of the kernels below walks over the blocks of the index and, for every non-empty
+// block, compares the 64 values of the block against the bounds held in Y14 and Y15. The
+// comparison masks are accumulated in DX, one bit per value, and the block of the index is
+// then intersected with the values within the range. Floats are compared with ordered
+// predicates, so NaN is never within the range. Integers only have a signed greater-than
+// comparison, hence the values outside of the range are accumulated and the mask inverted,
+// and unsigned values are compared as signed ones once their sign bit is flipped.
+
+// RANGE_FLOAT64 sets the bits of DX for the 4 float64 values within the range
+#define RANGE_FLOAT64(off, shift) \
+	VMOVUPD    off(SI), Y0; \
+	VCMPPD     $0x1D, Y14, Y0, Y1; \
+	VCMPPD     $0x12, Y15, Y0, Y2; \
+	VANDPD     Y1, Y2, Y1; \
+	VMOVMSKPD  Y1, AX; \
+	SHLQ       $shift, AX; \
+	ORQ        AX, DX
+
+// RANGE_FLOAT32 sets the bits of DX for the 8 float32 values within the range
+#define RANGE_FLOAT32(off, shift) \
+	VMOVUPS    off(SI), Y0; \
+	VCMPPS     $0x1D, Y14, Y0, Y1; \
+	VCMPPS     $0x12, Y15, Y0, Y2; \
+	VANDPS     Y1, Y2, Y1; \
+	VMOVMSKPS  Y1, AX; \
+	SHLQ       $shift, AX; \
+	ORQ        AX, DX
+
+// OUTSIDE_INT64 sets the bits of DX for the 4 int64 values in Y0 outside of the range
+#define OUTSIDE_INT64(shift) \
+	VPCMPGTQ   Y0, Y14, Y1; \
+	VPCMPGTQ   Y15, Y0, Y2; \
+	VPOR       Y1, Y2, Y1; \
+	VMOVMSKPD  Y1, AX; \
+	SHLQ       $shift, AX; \
+	ORQ        AX, DX
+
+// OUTSIDE_INT32 sets the bits of DX for the 8 int32 values in Y0 outside of the range
+#define OUTSIDE_INT32(shift) \
+	VPCMPGTD   Y0, Y14, Y1; \
+	VPCMPGTD   Y15, Y0, Y2; \
+	VPOR       Y1, Y2, Y1; \
+	VMOVMSKPS  Y1, AX; \
+	SHLQ       $shift, AX; \
+	ORQ        AX, DX
+
+#define RANGE_INT64(off, shift) \
+	VMOVDQU    off(SI), Y0; \
+	OUTSIDE_INT64(shift)
+
+#define RANGE_UINT64(off, shift) \
+	VPXOR      off(SI), Y13, Y0; \
+	OUTSIDE_INT64(shift)
+
+#define RANGE_INT32(off, shift) \
+	VMOVDQU    off(SI), Y0; \
+	OUTSIDE_INT32(shift)
+
+#define RANGE_UINT32(off, shift) \
+	VPXOR      off(SI), Y13, Y0; \
+	OUTSIDE_INT32(shift)
+
+#define RANGE_INT16(off, shift) \
+	VPMOVSXWD  off(SI), Y0; \
+	OUTSIDE_INT32(shift)
+
+#define RANGE_UINT16(off, shift) \
+	VPMOVZXWD  off(SI), Y0; \
+	OUTSIDE_INT32(shift)
+
+// LOAD_BLOCK loads the next block of the index into R8 and skips it if it is empty
+#define LOAD_BLOCK(next) \
+	MOVQ       (DI), R8; \
+	TESTQ      R8, R8; \
+	JZ         next; \
+	XORQ       DX, DX
+
+// func rangeFloat64sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo float64, hi float64)
+TEXT ·rangeFloat64sAVX2(SB), NOSPLIT, $0-40
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	VBROADCASTSD lo+24(FP), Y14
+	VBROADCASTSD hi+32(FP), Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_FLOAT64(0, 0)
+	RANGE_FLOAT64(32, 4)
+	RANGE_FLOAT64(64, 8)
+	RANGE_FLOAT64(96, 12)
+	RANGE_FLOAT64(128, 16)
+	RANGE_FLOAT64(160, 20)
+	RANGE_FLOAT64(192, 24)
+	RANGE_FLOAT64(224, 28)
+	RANGE_FLOAT64(256, 32)
+	RANGE_FLOAT64(288, 36)
+	RANGE_FLOAT64(320, 40)
+	RANGE_FLOAT64(352, 44)
+	RANGE_FLOAT64(384, 48)
+	RANGE_FLOAT64(416, 52)
+	RANGE_FLOAT64(448, 56)
+	RANGE_FLOAT64(480, 60)
+	ANDQ R8, DX
+	MOVQ DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $512, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeFloat32sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo float32, hi float32)
+TEXT ·rangeFloat32sAVX2(SB), NOSPLIT, $0-32
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	VBROADCASTSS lo+24(FP), Y14
+	VBROADCASTSS hi+28(FP), Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_FLOAT32(0, 0)
+	RANGE_FLOAT32(32, 8)
+	RANGE_FLOAT32(64, 16)
+	RANGE_FLOAT32(96, 24)
+	RANGE_FLOAT32(128, 32)
+	RANGE_FLOAT32(160, 40)
+	RANGE_FLOAT32(192, 48)
+	RANGE_FLOAT32(224, 56)
+	ANDQ R8, DX
+	MOVQ DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $256, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeInt64sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo int64, hi int64)
+TEXT ·rangeInt64sAVX2(SB), NOSPLIT, $0-40
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	VPBROADCASTQ lo+24(FP), Y14
+	VPBROADCASTQ hi+32(FP), Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_INT64(0, 0)
+	RANGE_INT64(32, 4)
+	RANGE_INT64(64, 8)
+	RANGE_INT64(96, 12)
+	RANGE_INT64(128, 16)
+	RANGE_INT64(160, 20)
+	RANGE_INT64(192, 24)
+	RANGE_INT64(224, 28)
+	RANGE_INT64(256, 32)
+	RANGE_INT64(288, 36)
+	RANGE_INT64(320, 40)
+	RANGE_INT64(352, 44)
+	RANGE_INT64(384, 48)
+	RANGE_INT64(416, 52)
+	RANGE_INT64(448, 56)
+	RANGE_INT64(480, 60)
+	NOTQ  DX
+	ANDQ  R8, DX
+	MOVQ  DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $512, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeUint64sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo uint64, hi uint64)
+TEXT ·rangeUint64sAVX2(SB), NOSPLIT, $0-40
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	MOVQ         $0x8000000000000000, AX
+	MOVQ         AX, X13
+	VPBROADCASTQ X13, Y13
+	VPBROADCASTQ lo+24(FP), Y14
+	VPBROADCASTQ hi+32(FP), Y15
+	VPXOR        Y13, Y14, Y14
+	VPXOR        Y13, Y15, Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_UINT64(0, 0)
+	RANGE_UINT64(32, 4)
+	RANGE_UINT64(64, 8)
+	RANGE_UINT64(96, 12)
+	RANGE_UINT64(128, 16)
+	RANGE_UINT64(160, 20)
+	RANGE_UINT64(192, 24)
+	RANGE_UINT64(224, 28)
+	RANGE_UINT64(256, 32)
+	RANGE_UINT64(288, 36)
+	RANGE_UINT64(320, 40)
+	RANGE_UINT64(352, 44)
+	RANGE_UINT64(384, 48)
+	RANGE_UINT64(416, 52)
+	RANGE_UINT64(448, 56)
+	RANGE_UINT64(480, 60)
+	NOTQ  DX
+	ANDQ  R8, DX
+	MOVQ  DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $512, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeInt32sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo int32, hi int32)
+TEXT ·rangeInt32sAVX2(SB), NOSPLIT, $0-32
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	MOVL         lo+24(FP), AX
+	MOVQ         AX, X14
+	VPBROADCASTD X14, Y14
+	MOVL         hi+28(FP), AX
+	MOVQ         AX, X15
+	VPBROADCASTD X15, Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_INT32(0, 0)
+	RANGE_INT32(32, 8)
+	RANGE_INT32(64, 16)
+	RANGE_INT32(96, 24)
+	RANGE_INT32(128, 32)
+	RANGE_INT32(160, 40)
+	RANGE_INT32(192, 48)
+	RANGE_INT32(224, 56)
+	NOTQ  DX
+	ANDQ  R8, DX
+	MOVQ  DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $256, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeUint32sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo uint32, hi uint32)
+TEXT ·rangeUint32sAVX2(SB), NOSPLIT, $0-32
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	MOVL         $0x80000000, AX
+	MOVQ         AX, X13
+	VPBROADCASTD X13, Y13
+	MOVL         lo+24(FP), AX
+	MOVQ         AX, X14
+	VPBROADCASTD X14, Y14
+	MOVL         hi+28(FP), AX
+	MOVQ         AX, X15
+	VPBROADCASTD X15, Y15
+	VPXOR        Y13, Y14, Y14
+	VPXOR        Y13, Y15, Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_UINT32(0, 0)
+	RANGE_UINT32(32, 8)
+	RANGE_UINT32(64, 16)
+	RANGE_UINT32(96, 24)
+	RANGE_UINT32(128, 32)
+	RANGE_UINT32(160, 40)
+	RANGE_UINT32(192, 48)
+	RANGE_UINT32(224, 56)
+	NOTQ  DX
+	ANDQ  R8, DX
+	MOVQ  DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $256, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeInt16sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo int32, hi int32)
+TEXT ·rangeInt16sAVX2(SB), NOSPLIT, $0-32
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	MOVL         lo+24(FP), AX
+	MOVQ         AX, X14
+	VPBROADCASTD X14, Y14
+	MOVL         hi+28(FP), AX
+	MOVQ         AX, X15
+	VPBROADCASTD X15, Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_INT16(0, 0)
+	RANGE_INT16(16, 8)
+	RANGE_INT16(32, 16)
+	RANGE_INT16(48, 24)
+	RANGE_INT16(64, 32)
+	RANGE_INT16(80, 40)
+	RANGE_INT16(96, 48)
+	RANGE_INT16(112, 56)
+	NOTQ  DX
+	ANDQ  R8, DX
+	MOVQ  DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $128, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
+
+// func rangeUint16sAVX2(index *uint64, data unsafe.Pointer, blocks int, lo int32, hi int32)
+TEXT ·rangeUint16sAVX2(SB), NOSPLIT, $0-32
+	MOVQ         index+0(FP), DI
+	MOVQ         data+8(FP), SI
+	MOVQ         blocks+16(FP), CX
+	MOVL         lo+24(FP), AX
+	MOVQ         AX, X14
+	VPBROADCASTD X14, Y14
+	MOVL         hi+28(FP), AX
+	MOVQ         AX, X15
+	VPBROADCASTD X15, Y15
+
+loop:
+	LOAD_BLOCK(next)
+	RANGE_UINT16(0, 0)
+	RANGE_UINT16(16, 8)
+	RANGE_UINT16(32, 16)
+	RANGE_UINT16(48, 24)
+	RANGE_UINT16(64, 32)
+	RANGE_UINT16(80, 40)
+	RANGE_UINT16(96, 48)
+	RANGE_UINT16(112, 56)
+	NOTQ  DX
+	ANDQ  R8, DX
+	MOVQ  DX, (DI)
+
+next:
+	ADDQ $8, DI
+	ADDQ $128, SI
+	DECQ CX
+	JNZ  loop
+	VZEROUPPER
+	RET
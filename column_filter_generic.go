@@ -0,0 +1,51 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+//go:build !amd64
+// +build !amd64
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+)
+
+// rangeFloat64s filters down the index to the float64 values within the [lo, hi] range
+func rangeFloat64s[T ~float64](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeFloat32s filters down the index to the float32 values within the [lo, hi] range
+func rangeFloat32s[T ~float32](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeInt64s filters down the index to the int64 values within the [lo, hi] range
+func rangeInt64s[T ~int | ~int64](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeUint64s filters down the index to the uint64 values within the [lo, hi] range
+func rangeUint64s[T uint | ~uint64](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeInt32s filters down the index to the int32 values within the [lo, hi] range
+func rangeInt32s[T ~int32](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeUint32s filters down the index to the uint32 values within the [lo, hi] range
+func rangeUint32s[T ~uint32](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeInt16s filters down the index to the int16 values within the [lo, hi] range
+func rangeInt16s[T ~int16](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
+
+// rangeUint16s filters down the index to the uint16 values within the [lo, hi] range
+func rangeUint16s[T ~uint16](index bitmap.Bitmap, data []T, lo, hi T) {
+	rangeOf(index, data, lo, hi)
+}
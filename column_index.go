@@ -4,11 +4,17 @@
 package column
 
 import (
+	"context"
+	"encoding/binary"
+	"math"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
+	"github.com/kelindar/simd"
 
 	"github.com/tidwall/btree"
 )
@@ -37,13 +43,21 @@ type computed interface {
 	Column() string
 }
 
+// isComputed returns whether the column is computed from another one
+func isComputed(c *column) bool {
+	_, ok := c.Column.(computed)
+	return ok
+}
+
 // --------------------------- Index ----------------------------
 
 // columnIndex represents the index implementation
 type columnIndex struct {
-	fill bitmap.Bitmap     // The fill list for the column
-	name string            // The name of the target column
-	rule func(Reader) bool // The rule to apply when building the index
+	count   int64             // The number of rows in the index
+	updated int64             // The time of the last change, in unix nanoseconds
+	fill    bitmap.Bitmap     // The fill list for the column
+	name    string            // The name of the target column
+	rule    func(Reader) bool // The rule to apply when building the index
 }
 
 // newIndex creates a new bitmap index column.
@@ -71,18 +85,28 @@ func (c *columnIndex) Apply(chunk commit.Chunk, r *commit.Reader) {
 	// Index can only be updated based on the final stored value, so we can only work
 	// with put operations here. The trick is to update the final value after applying
 	// on the actual column.
+	var added, removed int64
 	for r.Next() {
-		switch r.Type {
-		case commit.Put:
-			if c.rule(r) {
-				c.fill.Set(uint32(r.Offset))
-			} else {
-				c.fill.Remove(uint32(r.Offset))
+		idx := uint32(r.Offset)
+		switch {
+		case r.Type == commit.Put && c.rule(r):
+			if !c.fill.Contains(idx) {
+				c.fill.Set(idx)
+				added++
+			}
+		case r.Type == commit.Put || r.Type == commit.Delete:
+			if c.fill.Contains(idx) {
+				c.fill.Remove(idx)
+				removed++
 			}
-		case commit.Delete:
-			c.fill.Remove(uint32(r.Offset))
 		}
 	}
+
+	// Keep track of the cardinality, for the statistics and query planning
+	if added > 0 || removed > 0 {
+		atomic.AddInt64(&c.count, added-removed)
+		atomic.StoreInt64(&c.updated, time.Now().UnixNano())
+	}
 }
 
 // Value retrieves a value at a specified index.
@@ -110,14 +134,27 @@ func (c *columnIndex) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 
 // --------------------------- Trigger ----------------------------
 
+// contextual represents a computed column which needs the context of the transaction
+// in order to apply the operations.
+type contextual interface {
+	ApplyContext(context.Context, commit.Chunk, *commit.Reader)
+}
+
 // columnTrigger represents the trigger implementation
 type columnTrigger struct {
-	name string       // The name of the target column
-	clbk func(Reader) // The trigger callback
+	name string                        // The name of the target column
+	clbk func(context.Context, Reader) // The trigger callback
 }
 
 // newTrigger creates a new trigger column.
 func newTrigger(indexName, columnName string, callback func(r Reader)) *column {
+	return newTriggerContext(indexName, columnName, func(_ context.Context, r Reader) {
+		callback(r)
+	})
+}
+
+// newTriggerContext creates a new trigger column which receives the context.
+func newTriggerContext(indexName, columnName string, callback func(ctx context.Context, r Reader)) *column {
 	return columnFor(indexName, &columnTrigger{
 		name: columnName,
 		clbk: callback,
@@ -136,9 +173,15 @@ func (c *columnTrigger) Grow(idx uint32) {
 
 // Apply applies a set of operations to the column.
 func (c *columnTrigger) Apply(chunk commit.Chunk, r *commit.Reader) {
+	c.ApplyContext(context.Background(), chunk, r)
+}
+
+// ApplyContext applies a set of operations to the column, passing the context of the
+// transaction to the trigger callback.
+func (c *columnTrigger) ApplyContext(ctx context.Context, chunk commit.Chunk, r *commit.Reader) {
 	for r.Next() {
 		if r.Type == commit.Put || r.Type == commit.Delete {
-			c.clbk(r)
+			c.clbk(ctx, r)
 		}
 	}
 }
@@ -174,22 +217,90 @@ type sortIndexItem struct {
 type columnSortIndex struct {
 	btree    *btree.BTreeG[sortIndexItem] // 1 constantly sorted data structure
 	backMap  map[uint32]string            // for constant key lookups
-	backLock sync.Mutex                   // protect backMap access
+	backLock sync.Mutex                   // protect backMap and btree access
 	name     string                       // The name of the target column
+	key      func(*commit.Reader) string  // The encoder of the keys
 }
 
-// newSortIndex creates a new bitmap index column.
-func newSortIndex(indexName, columnName string) *column {
-	byKeys := func(a, b sortIndexItem) bool {
-		return a.Key < b.Key
-	}
+// newSortIndex creates a new bitmap index column. The keys are encoded according to the
+// type of the source column, so that numbers are kept in their numeric order.
+func newSortIndex(indexName, columnName string, source Column) *column {
 	return columnFor(indexName, &columnSortIndex{
-		btree:   btree.NewBTreeG(byKeys),
+		btree:   btree.NewBTreeG(byKeyAndOffset),
 		backMap: make(map[uint32]string),
 		name:    columnName,
+		key:     sortKeyOf(source),
 	})
 }
 
+// sortKeyer represents a column whose values are encoded into order-preserving keys
+type sortKeyer interface {
+	sortKey(r *commit.Reader) string
+}
+
+// sortKeyOf returns the encoder of the keys of a sorted index over the source column.
+// The values which are not numbers are compared as their raw bytes.
+func sortKeyOf(source Column) func(*commit.Reader) string {
+	if v, ok := source.(sortKeyer); ok {
+		return v.sortKey
+	}
+
+	return func(r *commit.Reader) string {
+		return strings.Clone(r.String()) // alloc required
+	}
+}
+
+// sortKey encodes the current value of the reader into an order-preserving key
+func (c *numericColumn[T]) sortKey(r *commit.Reader) string {
+	return encodeSortKey(decodeNumber[T](r))
+}
+
+// SortKey encodes a number into the key of a sorted index over a numeric column, so it
+// can be used as a bound of AscendRange and DescendRange or as the key of CountKey. The
+// number must be of the same kind (signed, unsigned or floating-point) as the column.
+func SortKey[T Number](v T) string {
+	return encodeSortKey(v)
+}
+
+// encodeSortKey encodes a number into 8 big-endian bytes, whose byte order matches the
+// numeric order. The sign bit of signed integers is flipped, while negative floats have
+// all of their bits inverted.
+func encodeSortKey[T simd.Number](v T) string {
+	var u uint64
+	switch x := any(v).(type) {
+	case int, int8, int16, int32, int64:
+		u = uint64(int64(v)) ^ (1 << 63)
+	case float32:
+		u = floatSortKey(float64(x))
+	case float64:
+		u = floatSortKey(x)
+	default:
+		u = uint64(v)
+	}
+
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	return string(b[:])
+}
+
+// floatSortKey returns the order-preserving bits of a floating-point number
+func floatSortKey(v float64) uint64 {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		return ^bits
+	}
+	return bits | (1 << 63)
+}
+
+// byKeyAndOffset orders the items by their key and then by their offset, so that rows
+// sharing the same key are kept as distinct items in a stable, ascending offset order.
+func byKeyAndOffset(a, b sortIndexItem) bool {
+	if a.Key != b.Key {
+		return a.Key < b.Key
+	}
+	return a.Value < b.Value
+}
+
 // Grow grows the size of the column until we have enough to store
 func (c *columnSortIndex) Grow(idx uint32) {
 	return
@@ -215,23 +326,70 @@ func (c *columnSortIndex) Apply(chunk commit.Chunk, r *commit.Reader) {
 					Value: r.Index(),
 				})
 			}
-			upsertKey := strings.Clone(r.String()) // alloc required
+			upsertKey := c.key(r)
 			c.backMap[r.Index()] = upsertKey
 			c.btree.Set(sortIndexItem{
 				Key:   upsertKey,
 				Value: r.Index(),
 			})
 		case commit.Delete:
-			delKey, _ := c.backMap[r.Index()]
+			if delKey, exists := c.backMap[r.Index()]; exists {
+				delete(c.backMap, r.Index())
+				c.btree.Delete(sortIndexItem{
+					Key:   delKey,
+					Value: r.Index(),
+				})
+			}
+		}
+		c.backLock.Unlock()
+	}
+}
+
+// reindex replaces the keys of the rows of a chunk with the values of the reader, which
+// contains a snapshot of the source column for that chunk, or simply removes them if the
+// reader is nil. Only the offsets of the chunk are looked up, rather than every key.
+func (c *columnSortIndex) reindex(chunk commit.Chunk, r *commit.Reader) {
+	c.backLock.Lock()
+	for idx := chunk.Min(); len(c.backMap) > 0 && idx <= chunk.Max(); idx++ {
+		if key, exists := c.backMap[idx]; exists {
+			delete(c.backMap, idx)
 			c.btree.Delete(sortIndexItem{
-				Key:   delKey,
-				Value: r.Index(),
+				Key:   key,
+				Value: idx,
 			})
 		}
-		c.backLock.Unlock()
+	}
+	c.backLock.Unlock()
+
+	if r != nil {
+		c.Apply(chunk, r)
 	}
 }
 
+// tree returns a copy-on-write clone of the tree, which can be scanned without holding
+// the lock while the index keeps being updated by the commits.
+func (c *columnSortIndex) tree() *btree.BTreeG[sortIndexItem] {
+	c.backLock.Lock()
+	defer c.backLock.Unlock()
+	return c.btree.Copy()
+}
+
+// rangeKey iterates over the offsets of the rows with the specified key, in ascending
+// order of their offset.
+func (c *columnSortIndex) rangeKey(key string, fn func(idx uint32)) {
+	c.backLock.Lock()
+	defer c.backLock.Unlock()
+
+	c.btree.Ascend(sortIndexItem{Key: key}, func(item sortIndexItem) bool {
+		if item.Key != key {
+			return false
+		}
+
+		fn(item.Value)
+		return true
+	})
+}
+
 // Value retrieves a value at a specified index.
 func (c *columnSortIndex) Value(idx uint32) (v interface{}, ok bool) {
 	return nil, false
@@ -247,7 +405,128 @@ func (c *columnSortIndex) Index(chunk commit.Chunk) bitmap.Bitmap {
 	return nil
 }
 
-// Snapshot writes the entire column into the specified destination buffer
+// Snapshot writes the entire column into the specified destination buffer. The index itself
+// is not written, since it is rebuilt from its source column once a snapshot is restored.
 func (c *columnSortIndex) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	// No-op
 }
+
+// ----------------------- Trigram Index --------------------------
+
+// columnTrigram implements an inverted trigram index over a string column, allowing
+// prefix and substring queries to be answered without scanning every value.
+type columnTrigram struct {
+	lock    sync.RWMutex             // protect the postings and back map
+	posting map[uint32]bitmap.Bitmap // trigram -> offsets containing it
+	backMap map[uint32]string        // offset -> indexed value
+	name    string                   // The name of the target column
+}
+
+// newTrigramIndex creates a new trigram index column.
+func newTrigramIndex(indexName, columnName string) *column {
+	return columnFor(indexName, &columnTrigram{
+		posting: make(map[uint32]bitmap.Bitmap, 64),
+		backMap: make(map[uint32]string),
+		name:    columnName,
+	})
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnTrigram) Grow(idx uint32) {
+	return
+}
+
+// Column returns the target name of the column on which this index should apply.
+func (c *columnTrigram) Column() string {
+	return c.name
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnTrigram) Apply(chunk commit.Chunk, r *commit.Reader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for r.Next() {
+		switch r.Type {
+		case commit.Put:
+			c.remove(r.Index())
+			value := strings.Clone(r.String()) // alloc required
+			c.backMap[r.Index()] = value
+			trigramsOf(value, true, func(t uint32) {
+				posting := c.posting[t]
+				posting.Set(r.Index())
+				c.posting[t] = posting
+			})
+		case commit.Delete:
+			c.remove(r.Index())
+		}
+	}
+}
+
+// remove removes the previously indexed value at a given offset
+func (c *columnTrigram) remove(idx uint32) {
+	value, exists := c.backMap[idx]
+	if !exists {
+		return
+	}
+
+	delete(c.backMap, idx)
+	trigramsOf(value, true, func(t uint32) {
+		if posting, ok := c.posting[t]; ok {
+			posting.Remove(idx)
+		}
+	})
+}
+
+// candidates narrows down the index to the offsets that contain every trigram of
+// the pattern. If the pattern is too short to be indexed, it returns false and the
+// caller needs to scan instead.
+func (c *columnTrigram) candidates(pattern string, anchored bool, dst *bitmap.Bitmap) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	first, indexed := true, false
+	trigramsOf(pattern, anchored, func(t uint32) {
+		indexed = true
+		switch posting := c.posting[t]; {
+		case first:
+			posting.Clone(dst)
+			first = false
+		default:
+			dst.And(posting)
+		}
+	})
+	return indexed
+}
+
+// Value retrieves a value at a specified index.
+func (c *columnTrigram) Value(idx uint32) (v interface{}, ok bool) {
+	return nil, false
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnTrigram) Contains(idx uint32) bool {
+	return false
+}
+
+// Index returns the fill list for the column
+func (c *columnTrigram) Index(chunk commit.Chunk) bitmap.Bitmap {
+	return nil
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnTrigram) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	// No-op
+}
+
+// trigramsOf iterates over every trigram of the value. If anchored, the value is
+// padded at the start so that prefixes produce their own distinct trigrams.
+func trigramsOf(value string, anchored bool, fn func(uint32)) {
+	if anchored {
+		value = "\x00\x00" + value
+	}
+
+	for i := 0; i+3 <= len(value); i++ {
+		fn(uint32(value[i])<<16 | uint32(value[i+1])<<8 | uint32(value[i+2]))
+	}
+}
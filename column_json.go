@@ -0,0 +1,257 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- JSON ----------------------------
+
+// columnJSON represents a column that contains raw JSON documents
+type columnJSON struct {
+	columnString
+}
+
+// ForJSON creates a new column that contains raw JSON documents. The documents can be
+// queried using the WithJSONPath filter and indexed using CreateJSONIndex.
+func ForJSON() Column {
+	return &columnJSON{
+		columnString: columnString{
+			chunks: make(chunks[string], 0, 4),
+			option: option[string]{
+				Merge: func(_, delta string) string { return delta },
+			},
+		},
+	}
+}
+
+// Value returns the value at the given index
+func (c *columnJSON) Value(idx uint32) (any, bool) {
+	return c.LoadJSON(idx)
+}
+
+// LoadJSON retrieves the raw JSON document at a specified index
+func (c *columnJSON) LoadJSON(idx uint32) (json.RawMessage, bool) {
+	if v, ok := c.LoadString(idx); ok {
+		return json.RawMessage(v), true
+	}
+	return nil, false
+}
+
+// FilterPath filters down the values based on the specified predicate, evaluated on
+// the value extracted at the path. Documents which do not contain the path are removed.
+func (c *columnJSON) FilterPath(chunk commit.Chunk, index bitmap.Bitmap, path jsonPath, predicate func(v any) bool) {
+	c.FilterString(chunk, index, func(v string) bool {
+		value, ok := path.Lookup(s2b(v))
+		return ok && predicate(value)
+	})
+}
+
+// accepts checks whether a value can be stored in the column. The documents larger than
+// what a single commit operation can carry are not accepted.
+func (c *columnJSON) accepts(value any) bool {
+	switch v := value.(type) {
+	case json.RawMessage:
+		return len(v) <= maxBytes && json.Valid(v)
+	case []byte:
+		return len(v) <= maxBytes && json.Valid(v)
+	case string:
+		return len(v) <= maxBytes && json.Valid(s2b(v))
+	default:
+		return false
+	}
+}
+
+// --------------------------- Path ----------------------------
+
+// jsonPath represents a parsed path into a JSON document, such as "$.items[0].name".
+// Each step is either an object key (string) or an array index (int).
+type jsonPath []any
+
+// parseJSONPath parses a path expression. The path must start with the "$" root and
+// can contain dotted object keys and bracketed array indexes.
+func parseJSONPath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("column: invalid json path '%s', must start with '$'", path)
+	}
+
+	out := make(jsonPath, 0, 4)
+	for rest := path[1:]; len(rest) > 0; {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("column: invalid json path '%s', empty key", path)
+			}
+
+			out = append(out, key)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("column: invalid json path '%s', unterminated index", path)
+			}
+
+			at, err := strconv.Atoi(rest[1:end])
+			if err != nil || at < 0 {
+				return nil, fmt.Errorf("column: invalid json path '%s', invalid index", path)
+			}
+
+			out = append(out, at)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("column: invalid json path '%s', unexpected '%c'", path, rest[0])
+		}
+	}
+	return out, nil
+}
+
+// Lookup decodes the document and returns the value at the path. Numbers are decoded
+// as float64, same as the encoding/json package does.
+func (p jsonPath) Lookup(data []byte) (any, bool) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false
+	}
+
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+
+			if value, ok = object[step]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := value.([]any)
+			if !ok || step >= len(array) {
+				return nil, false
+			}
+
+			value = array[step]
+		}
+	}
+	return value, true
+}
+
+// --------------------------- Filters ----------------------------
+
+// WithJSONPath filters down the rows to the ones where the value at the specified path
+// of the JSON document matches the predicate. Rows whose document does not contain the
+// path or whose path is invalid are filtered out.
+func (txn *Txn) WithJSONPath(column, path string, predicate func(v any) bool) *Txn {
+	defer txn.trace("WithJSONPath", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	doc, ok := c.Column.(*columnJSON)
+	steps, err := parseJSONPath(path)
+	if !ok || err != nil {
+		txn.index.Clear()
+		return txn
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		doc.FilterPath(chunk, index, steps, predicate)
+	})
+	return txn
+}
+
+// CreateJSONIndex creates an index over the value extracted at the specified path of a
+// JSON column. A row is part of the index when its document contains the path and the
+// predicate returns true for the extracted value.
+func (c *Collection) CreateJSONIndex(indexName, columnName, path string, fn func(v any) bool) error {
+	if fn == nil {
+		return fmt.Errorf("column: create index must specify name, column and function")
+	}
+
+	column, ok := c.cols.Load(columnName)
+	if !ok {
+		return fmt.Errorf("column: unable to create index, column '%v' does not exist", columnName)
+	}
+
+	if _, ok := column.Column.(*columnJSON); !ok {
+		return fmt.Errorf("column: unable to create index, column '%v' is not a json column", columnName)
+	}
+
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+
+	return c.CreateIndex(indexName, columnName, func(r Reader) bool {
+		value, ok := steps.Lookup(r.Bytes())
+		return ok && fn(value)
+	})
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwJSON represents read-write accessor for JSON documents
+type rwJSON struct {
+	rdJSON
+	writer *commit.Buffer
+}
+
+// Set encodes the value as JSON and stores it at the current transaction cursor. If the
+// encoded document is larger than 64KB, an error is returned.
+func (s rwJSON) Set(value any) error {
+	encoded, err := json.Marshal(value)
+	switch {
+	case err != nil:
+		return err
+	case len(encoded) > maxBytes:
+		return fmt.Errorf("column: unable to set json, size %d exceeds the limit of %d",
+			len(encoded), maxBytes)
+	}
+
+	s.writer.PutBytes(commit.Put, *s.cursor, encoded)
+	return nil
+}
+
+// JSON returns a JSON document column accessor
+func (txn *Txn) JSON(columnName string) rwJSON {
+	return rwJSON{
+		rdJSON: rdJSON(readerFor[*columnJSON](txn, columnName)),
+		writer: txn.bufferFor(columnName),
+	}
+}
+
+// rdJSON represents a read-only accessor for JSON documents
+type rdJSON reader[*columnJSON]
+
+// Get loads the raw JSON document at the current transaction cursor
+func (s rdJSON) Get() (json.RawMessage, bool) {
+	return s.reader.LoadJSON(*s.cursor)
+}
+
+// Unmarshal decodes the JSON document at the current transaction cursor into the
+// destination value.
+func (s rdJSON) Unmarshal(dst any) bool {
+	encoded, ok := s.reader.LoadString(*s.cursor)
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal(s2b(encoded), dst) == nil
+}
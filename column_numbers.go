@@ -29,7 +29,7 @@ func makeInts(opts ...func(*option[int])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeInt64s[int], opts,
 	)
 }
 
@@ -49,6 +49,28 @@ func (s rwInt) Merge(delta int) {
 	s.writer.PutInt(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwInt) UpdateAll(fn func(old int) int) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwInt) AddAll(delta int) {
+	s.rangeAll(func(idx uint32, _ int) {
+		s.writer.PutInt(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwInt) ScaleAll(factor int) {
+	s.updateAll(s.writer, func(v int) int { return v * factor })
+}
+
 // Int returns a read-write accessor for int column
 func (txn *Txn) Int(columnName string) rwInt {
 	return rwInt{
@@ -78,7 +100,7 @@ func makeInt16s(opts ...func(*option[int16])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeInt16s[int16], opts,
 	)
 }
 
@@ -98,6 +120,28 @@ func (s rwInt16) Merge(delta int16) {
 	s.writer.PutInt16(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwInt16) UpdateAll(fn func(old int16) int16) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwInt16) AddAll(delta int16) {
+	s.rangeAll(func(idx uint32, _ int16) {
+		s.writer.PutInt16(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwInt16) ScaleAll(factor int16) {
+	s.updateAll(s.writer, func(v int16) int16 { return v * factor })
+}
+
 // Int16 returns a read-write accessor for int16 column
 func (txn *Txn) Int16(columnName string) rwInt16 {
 	return rwInt16{
@@ -127,7 +171,7 @@ func makeInt32s(opts ...func(*option[int32])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeInt32s[int32], opts,
 	)
 }
 
@@ -147,6 +191,28 @@ func (s rwInt32) Merge(delta int32) {
 	s.writer.PutInt32(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwInt32) UpdateAll(fn func(old int32) int32) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwInt32) AddAll(delta int32) {
+	s.rangeAll(func(idx uint32, _ int32) {
+		s.writer.PutInt32(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwInt32) ScaleAll(factor int32) {
+	s.updateAll(s.writer, func(v int32) int32 { return v * factor })
+}
+
 // Int32 returns a read-write accessor for int32 column
 func (txn *Txn) Int32(columnName string) rwInt32 {
 	return rwInt32{
@@ -176,7 +242,7 @@ func makeInt64s(opts ...func(*option[int64])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeInt64s[int64], opts,
 	)
 }
 
@@ -196,6 +262,28 @@ func (s rwInt64) Merge(delta int64) {
 	s.writer.PutInt64(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwInt64) UpdateAll(fn func(old int64) int64) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwInt64) AddAll(delta int64) {
+	s.rangeAll(func(idx uint32, _ int64) {
+		s.writer.PutInt64(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwInt64) ScaleAll(factor int64) {
+	s.updateAll(s.writer, func(v int64) int64 { return v * factor })
+}
+
 // Int64 returns a read-write accessor for int64 column
 func (txn *Txn) Int64(columnName string) rwInt64 {
 	return rwInt64{
@@ -225,7 +313,7 @@ func makeUints(opts ...func(*option[uint])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeUint64s[uint], opts,
 	)
 }
 
@@ -245,6 +333,28 @@ func (s rwUint) Merge(delta uint) {
 	s.writer.PutUint(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwUint) UpdateAll(fn func(old uint) uint) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwUint) AddAll(delta uint) {
+	s.rangeAll(func(idx uint32, _ uint) {
+		s.writer.PutUint(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwUint) ScaleAll(factor uint) {
+	s.updateAll(s.writer, func(v uint) uint { return v * factor })
+}
+
 // Uint returns a read-write accessor for uint column
 func (txn *Txn) Uint(columnName string) rwUint {
 	return rwUint{
@@ -274,7 +384,7 @@ func makeUint16s(opts ...func(*option[uint16])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeUint16s[uint16], opts,
 	)
 }
 
@@ -294,6 +404,28 @@ func (s rwUint16) Merge(delta uint16) {
 	s.writer.PutUint16(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwUint16) UpdateAll(fn func(old uint16) uint16) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwUint16) AddAll(delta uint16) {
+	s.rangeAll(func(idx uint32, _ uint16) {
+		s.writer.PutUint16(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwUint16) ScaleAll(factor uint16) {
+	s.updateAll(s.writer, func(v uint16) uint16 { return v * factor })
+}
+
 // Uint16 returns a read-write accessor for uint16 column
 func (txn *Txn) Uint16(columnName string) rwUint16 {
 	return rwUint16{
@@ -323,7 +455,7 @@ func makeUint32s(opts ...func(*option[uint32])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeUint32s[uint32], opts,
 	)
 }
 
@@ -343,6 +475,28 @@ func (s rwUint32) Merge(delta uint32) {
 	s.writer.PutUint32(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwUint32) UpdateAll(fn func(old uint32) uint32) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwUint32) AddAll(delta uint32) {
+	s.rangeAll(func(idx uint32, _ uint32) {
+		s.writer.PutUint32(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwUint32) ScaleAll(factor uint32) {
+	s.updateAll(s.writer, func(v uint32) uint32 { return v * factor })
+}
+
 // Uint32 returns a read-write accessor for uint32 column
 func (txn *Txn) Uint32(columnName string) rwUint32 {
 	return rwUint32{
@@ -372,7 +526,7 @@ func makeUint64s(opts ...func(*option[uint64])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeUint64s[uint64], opts,
 	)
 }
 
@@ -392,6 +546,28 @@ func (s rwUint64) Merge(delta uint64) {
 	s.writer.PutUint64(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwUint64) UpdateAll(fn func(old uint64) uint64) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwUint64) AddAll(delta uint64) {
+	s.rangeAll(func(idx uint32, _ uint64) {
+		s.writer.PutUint64(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwUint64) ScaleAll(factor uint64) {
+	s.updateAll(s.writer, func(v uint64) uint64 { return v * factor })
+}
+
 // Uint64 returns a read-write accessor for uint64 column
 func (txn *Txn) Uint64(columnName string) rwUint64 {
 	return rwUint64{
@@ -421,7 +597,7 @@ func makeFloat32s(opts ...func(*option[float32])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeFloat32s[float32], opts,
 	)
 }
 
@@ -441,6 +617,28 @@ func (s rwFloat32) Merge(delta float32) {
 	s.writer.PutFloat32(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwFloat32) UpdateAll(fn func(old float32) float32) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwFloat32) AddAll(delta float32) {
+	s.rangeAll(func(idx uint32, _ float32) {
+		s.writer.PutFloat32(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwFloat32) ScaleAll(factor float32) {
+	s.updateAll(s.writer, func(v float32) float32 { return v * factor })
+}
+
 // Float32 returns a read-write accessor for float32 column
 func (txn *Txn) Float32(columnName string) rwFloat32 {
 	return rwFloat32{
@@ -470,7 +668,7 @@ func makeFloat64s(opts ...func(*option[float64])) Column {
 					fill.Remove(offset)
 				}
 			}
-		}, opts,
+		}, rangeFloat64s[float64], opts,
 	)
 }
 
@@ -490,6 +688,28 @@ func (s rwFloat64) Merge(delta float64) {
 	s.writer.PutFloat64(commit.Merge, s.txn.cursor, delta)
 }
 
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwFloat64) UpdateAll(fn func(old float64) float64) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwFloat64) AddAll(delta float64) {
+	s.rangeAll(func(idx uint32, _ float64) {
+		s.writer.PutFloat64(commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwFloat64) ScaleAll(factor float64) {
+	s.updateAll(s.writer, func(v float64) float64 { return v * factor })
+}
+
 // Float64 returns a read-write accessor for float64 column
 func (txn *Txn) Float64(columnName string) rwFloat64 {
 	return rwFloat64{
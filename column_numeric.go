@@ -5,6 +5,10 @@ package column
 
 import (
 	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -13,6 +17,11 @@ import (
 
 //go:generate go run ./codegen/main.go
 
+// Number represents the numeric types which can be stored in a numeric column
+type Number interface {
+	int | int16 | int32 | int64 | uint | uint16 | uint32 | uint64 | float32 | float64
+}
+
 // readNumber is a helper function for point reads
 func readNumber[T simd.Number](txn *Txn, columnName string) (value T, found bool) {
 	if column, ok := txn.columnAt(columnName); ok {
@@ -29,20 +38,23 @@ func readNumber[T simd.Number](txn *Txn, columnName string) (value T, found bool
 type numericColumn[T simd.Number] struct {
 	chunks[T]
 	option[T]
-	write func(*commit.Buffer, uint32, T)
-	apply func(*commit.Reader, bitmap.Bitmap, []T, option[T])
+	write  func(*commit.Buffer, uint32, T)
+	apply  func(*commit.Reader, bitmap.Bitmap, []T, option[T])
+	filter func(bitmap.Bitmap, []T, T, T)
 }
 
 // makeNumeric creates a new vector for simd.Numbers
 func makeNumeric[T simd.Number](
 	write func(*commit.Buffer, uint32, T),
 	apply func(*commit.Reader, bitmap.Bitmap, []T, option[T]),
+	filter func(bitmap.Bitmap, []T, T, T),
 	opts []func(*option[T]),
 ) *numericColumn[T] {
 	return &numericColumn[T]{
 		chunks: make(chunks[T], 0, 4),
 		write:  write,
 		apply:  apply,
+		filter: filter,
 		option: configure(opts, option[T]{
 			Merge: func(value, delta T) T { return value + delta },
 		}),
@@ -90,6 +102,12 @@ func (c *numericColumn[T]) LoadUint64(idx uint32) (uint64, bool) {
 	return uint64(v), ok
 }
 
+// accepts checks whether a value can be stored in the column.
+func (c *numericColumn[T]) accepts(value any) bool {
+	_, ok := value.(T)
+	return ok
+}
+
 // --------------------------- Filtering ----------------------------
 
 // filterNumbers filters down the values based on the specified predicate.
@@ -118,6 +136,168 @@ func (c *numericColumn[T]) FilterUint64(chunk commit.Chunk, index bitmap.Bitmap,
 	filterNumbers(c, chunk, index, predicate)
 }
 
+// filterRange filters down the values to the ones within the inclusive [lo, hi] range.
+// The bounds are first narrowed to the values representable by the column, so the values
+// are compared in their native type and a bound out of the domain of the column, such as
+// a negative bound of an unsigned column, is handled exactly. Unlike filterNumbers, no
+// predicate is called and the values are compared by the vectorized kernel of the column.
+func filterRange[T, C simd.Number](column *numericColumn[T], chunk commit.Chunk, index bitmap.Bitmap, lo, hi C) {
+	if int(chunk) >= len(column.chunks) {
+		return
+	}
+
+	min, max, ok := boundsOf[T](lo, hi)
+	if !ok {
+		index.Clear()
+		return
+	}
+
+	fill, data := column.chunkAt(chunk)
+	index.And(fill)
+	column.filter(index, data, min, max)
+}
+
+// boundsOf narrows the inclusive [lo, hi] range to the values of type T, and returns
+// false if no value of type T is within the range.
+func boundsOf[T, C simd.Number](lo, hi C) (min, max T, ok bool) {
+	switch any(min).(type) {
+	case float32:
+		flo, fhi, ok := floatBounds(lo, hi)
+		min, max := float32(flo), float32(fhi)
+		if float64(min) < flo {
+			min = math.Nextafter32(min, float32(math.Inf(1)))
+		}
+		if float64(max) > fhi {
+			max = math.Nextafter32(max, float32(math.Inf(-1)))
+		}
+		return T(min), T(max), ok && min <= max
+	case float64:
+		flo, fhi, ok := floatBounds(lo, hi)
+		return T(flo), T(fhi), ok
+	case int, int8, int16, int32, int64:
+		ilo, ihi, ok := intBounds(lo, hi)
+		size := 8 * int(unsafe.Sizeof(min))
+		tmin, tmax := int64(-1)<<(size-1), int64(^uint64(0)>>(65-size))
+		if ilo < tmin {
+			ilo = tmin
+		}
+		if ihi > tmax {
+			ihi = tmax
+		}
+		return T(ilo), T(ihi), ok && ilo <= ihi
+	default:
+		ulo, uhi, ok := uintBounds(lo, hi)
+		if tmax := ^uint64(0) >> (64 - 8*unsafe.Sizeof(min)); uhi > tmax {
+			uhi = tmax
+		}
+		return T(ulo), T(uhi), ok && ulo <= uhi
+	}
+}
+
+// floatBounds converts the bounds of a range to float64, rounding them towards the
+// inside of the range, and returns false if the range is empty.
+func floatBounds[C simd.Number](lo, hi C) (float64, float64, bool) {
+	switch any(lo).(type) {
+	case float32, float64:
+		flo, fhi := float64(lo), float64(hi)
+		return flo, fhi, flo <= fhi
+	case int, int8, int16, int32, int64:
+		flo, fhi := float64(lo), float64(hi)
+		if flo < 1<<63 && int64(flo) < int64(lo) {
+			flo = math.Nextafter(flo, math.Inf(1))
+		}
+		if fhi >= 1<<63 || int64(fhi) > int64(hi) {
+			fhi = math.Nextafter(fhi, math.Inf(-1))
+		}
+		return flo, fhi, lo <= hi
+	default:
+		flo, fhi := float64(lo), float64(hi)
+		if flo < 1<<64 && uint64(flo) < uint64(lo) {
+			flo = math.Nextafter(flo, math.Inf(1))
+		}
+		if fhi >= 1<<64 || uint64(fhi) > uint64(hi) {
+			fhi = math.Nextafter(fhi, math.Inf(-1))
+		}
+		return flo, fhi, lo <= hi
+	}
+}
+
+// intBounds converts the bounds of a range to int64, rounding them towards the inside of
+// the range and clamping them, and returns false if the range is empty.
+func intBounds[C simd.Number](lo, hi C) (int64, int64, bool) {
+	switch any(lo).(type) {
+	case float32, float64:
+		flo, fhi := math.Ceil(float64(lo)), math.Floor(float64(hi))
+		switch {
+		case math.IsNaN(flo) || math.IsNaN(fhi) || flo > fhi || flo >= 1<<63 || fhi < -1<<63:
+			return 0, 0, false
+		case flo < -1<<63:
+			flo = -1 << 63
+		}
+
+		if fhi >= 1<<63 {
+			return int64(flo), math.MaxInt64, true
+		}
+		return int64(flo), int64(fhi), true
+	case int, int8, int16, int32, int64:
+		return int64(lo), int64(hi), lo <= hi
+	default:
+		if uint64(lo) > math.MaxInt64 {
+			return 0, 0, false
+		}
+		if uint64(hi) > math.MaxInt64 {
+			return int64(lo), math.MaxInt64, true
+		}
+		return int64(lo), int64(hi), lo <= hi
+	}
+}
+
+// uintBounds converts the bounds of a range to uint64, rounding them towards the inside
+// of the range and clamping them, and returns false if the range is empty.
+func uintBounds[C simd.Number](lo, hi C) (uint64, uint64, bool) {
+	switch any(lo).(type) {
+	case float32, float64:
+		flo, fhi := math.Ceil(float64(lo)), math.Floor(float64(hi))
+		switch {
+		case math.IsNaN(flo) || math.IsNaN(fhi) || flo > fhi || flo >= 1<<64 || fhi < 0:
+			return 0, 0, false
+		case flo < 0:
+			flo = 0
+		}
+
+		if fhi >= 1<<64 {
+			return uint64(flo), math.MaxUint64, true
+		}
+		return uint64(flo), uint64(fhi), true
+	case int, int8, int16, int32, int64:
+		switch {
+		case hi < 0 || lo > hi:
+			return 0, 0, false
+		case lo < 0:
+			return 0, uint64(hi), true
+		default:
+			return uint64(lo), uint64(hi), true
+		}
+	default:
+		return uint64(lo), uint64(hi), lo <= hi
+	}
+}
+
+// FilterRangeFloat64 filters down the values to the ones within the inclusive range.
+func (c *numericColumn[T]) FilterRangeFloat64(chunk commit.Chunk, index bitmap.Bitmap, lo, hi float64) {
+	filterRange(c, chunk, index, lo, hi)
+}
+
+// FilterRangeInt64 filters down the values to the ones within the inclusive range.
+func (c *numericColumn[T]) FilterRangeInt64(chunk commit.Chunk, index bitmap.Bitmap, lo, hi int64) {
+	filterRange(c, chunk, index, lo, hi)
+}
+
+// FilterRangeUint64 filters down the values to the ones within the inclusive range.
+func (c *numericColumn[T]) FilterRangeUint64(chunk commit.Chunk, index bitmap.Bitmap, lo, hi uint64) {
+	filterRange(c, chunk, index, lo, hi)
+}
+
 // --------------------------- Apply & Snapshot ----------------------------
 
 // Apply applies a set of operations to the column.
@@ -168,7 +348,27 @@ func (s rdNumber[T]) Avg() float64 {
 			ct += index.Count()
 		}
 	})
-	return float64(sum) / float64(ct)
+	return average(sum, ct)
+}
+
+// average divides the sum by the count. The integer sums are divided natively first, with
+// only the quotient and the remainder converted, so that the large sums such as counters
+// beyond 2^53 do not lose their precision before the division.
+func average[T simd.Number](sum T, count int) float64 {
+	if count == 0 {
+		return math.NaN()
+	}
+
+	switch any(sum).(type) {
+	case float32, float64:
+		return float64(sum) / float64(count)
+	case int, int8, int16, int32, int64:
+		v, n := int64(sum), int64(count)
+		return float64(v/n) + float64(v%n)/float64(n)
+	default:
+		v, n := uint64(sum), uint64(count)
+		return float64(v/n) + float64(v%n)/float64(n)
+	}
 }
 
 // Min finds the smallest value from the column values selected by this transaction
@@ -199,6 +399,289 @@ func (s rdNumber[T]) Max() (max T, ok bool) {
 	return
 }
 
+// Quantile computes the q-th quantile (0 <= q <= 1) of the column values selected by this
+// transaction, linearly interpolating between the two closest ranks. For example, a q of
+// 0.95 returns the 95th percentile of the values. The NaN and infinite values are skipped.
+func (s rdNumber[T]) Quantile(q float64) (float64, bool) {
+	values := s.Slice(nil)
+	finite := values[:0]
+	for _, v := range values {
+		if isFinite(v) {
+			finite = append(finite, v)
+		}
+	}
+
+	values = finite
+	if len(values) == 0 || q < 0 || q > 1 || math.IsNaN(q) {
+		return 0, false
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := q * float64(len(values)-1)
+	lo, hi := int(math.Floor(rank)), int(math.Ceil(rank))
+	return float64(values[lo]) + (rank-float64(lo))*(float64(values[hi])-float64(values[lo])), true
+}
+
+// Median computes the median of the column values selected by this transaction
+func (s rdNumber[T]) Median() (float64, bool) {
+	return s.Quantile(0.5)
+}
+
+// Bin represents a single bin of a histogram, counting the values within [Min, Max). The
+// last bin of a histogram also includes its upper bound.
+type Bin struct {
+	Min   float64 // The lower bound of the bin
+	Max   float64 // The upper bound of the bin
+	Count int     // The number of values within the bin
+}
+
+// Histogram computes a histogram of the column values selected by this transaction, with
+// the specified number of equal-width bins spanning from the smallest to the largest value.
+// The NaN and infinite values are skipped.
+func (s rdNumber[T]) Histogram(bins int) []Bin {
+	if bins <= 0 {
+		return nil
+	}
+
+	count, lo, hi := 0, 0.0, 0.0
+	s.txn.initialize()
+	s.txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		s.rangeValues(chunk, index, func(v T) {
+			if !isFinite(v) {
+				return
+			}
+
+			if value := float64(v); count == 0 || value < lo {
+				lo = value
+			}
+			if value := float64(v); count == 0 || value > hi {
+				hi = value
+			}
+			count++
+		})
+	})
+
+	if count == 0 {
+		return nil
+	}
+
+	width := (hi - lo) / float64(bins)
+	out := make([]Bin, bins)
+	for i := range out {
+		out[i].Min = lo + float64(i)*width
+		out[i].Max = lo + float64(i+1)*width
+	}
+	out[bins-1].Max = hi
+
+	s.txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		s.rangeValues(chunk, index, func(v T) {
+			if !isFinite(v) {
+				return
+			}
+
+			at := bins - 1
+			if width > 0 {
+				at = int((float64(v) - lo) / width)
+			}
+
+			switch {
+			case at < 0:
+				at = 0
+			case at >= bins:
+				at = bins - 1
+			}
+			out[at].Count++
+		})
+	})
+	return out
+}
+
+// isFinite returns whether a number is neither NaN nor infinite
+func isFinite[T simd.Number](v T) bool {
+	f := float64(v)
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Slice appends the column values selected by this transaction into the destination, in
+// the order of their rows, and returns the extended slice. The values are copied chunk by
+// chunk, in blocks of 64 values whenever all of the rows of a block are selected, so that
+// the result can be handed to vectorized routines without iterating row by row.
+func (s rdNumber[T]) Slice(dst []T) []T {
+	s.txn.initialize()
+	s.txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		if int(chunk) >= len(s.reader.chunks) {
+			return
+		}
+
+		fill, data := s.reader.chunks[chunk].fill, s.reader.chunks[chunk].data
+		for i, word := range index {
+			if i >= len(fill) {
+				break
+			}
+
+			word &= fill[i]
+			offset := i << 6
+			switch {
+			case word == 0:
+				continue
+			case word == math.MaxUint64 && offset+64 <= len(data):
+				dst = append(dst, data[offset:offset+64]...)
+			default:
+				for ; word != 0; word &= word - 1 {
+					if x := offset + bits.TrailingZeros64(word); x < len(data) {
+						dst = append(dst, data[x])
+					}
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// updateAll writes the result of the function applied to the current value of every row
+// selected by this transaction which has a value. The values are read chunk by chunk,
+// directly from the column, and the results are written as a single put per row. Since
+// the results are computed from the values read by the transaction, the concurrent
+// merges into these rows are overwritten.
+func (s rdNumber[T]) updateAll(writer *commit.Buffer, fn func(T) T) {
+	s.rangeAll(func(idx uint32, v T) {
+		s.reader.write(writer, idx, fn(v))
+	})
+}
+
+// rangeAll iterates over the current value of every row selected by this transaction
+// which has a value, chunk by chunk and directly from the column.
+func (s rdNumber[T]) rangeAll(fn func(idx uint32, v T)) {
+	s.txn.initialize()
+	s.txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		offset := chunk.Min()
+		s.rangeIndexed(chunk, index, func(x uint32, v T) {
+			fn(offset+x, v)
+		})
+	})
+}
+
+// rangeValues iterates over the values of a chunk which are present in the index
+func (s rdNumber[T]) rangeValues(chunk commit.Chunk, index bitmap.Bitmap, fn func(T)) {
+	s.rangeIndexed(chunk, index, func(_ uint32, v T) {
+		fn(v)
+	})
+}
+
+// rangeIndexed iterates over the values of a chunk which are present in the index, along
+// with their offset within the chunk
+func (s rdNumber[T]) rangeIndexed(chunk commit.Chunk, index bitmap.Bitmap, fn func(uint32, T)) {
+	if int(chunk) >= len(s.reader.chunks) {
+		return
+	}
+
+	fill, data := s.reader.chunks[chunk].fill, s.reader.chunks[chunk].data
+	index.Range(func(x uint32) {
+		if fill.Contains(x) && int(x) < len(data) {
+			fn(x, data[x])
+		}
+	})
+}
+
+// rwNumber represents a generic read-write accessor for numbers
+type rwNumber[T Number] struct {
+	rdNumber[T]
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s rwNumber[T]) Set(value T) {
+	putNumber(s.writer, commit.Put, s.txn.cursor, value)
+}
+
+// Merge atomically merges a delta to the value at the current transaction cursor
+func (s rwNumber[T]) Merge(delta T) {
+	putNumber(s.writer, commit.Merge, s.txn.cursor, delta)
+}
+
+// UpdateAll sets the value of every selected row which has one to the result of the
+// function applied to its current value. This is not atomic, the values are replaced
+// with the results computed from the values read by the transaction.
+func (s rwNumber[T]) UpdateAll(fn func(old T) T) {
+	s.updateAll(s.writer, fn)
+}
+
+// AddAll atomically merges a delta to the value of every selected row which has one,
+// same as Merge does for a single row
+func (s rwNumber[T]) AddAll(delta T) {
+	s.rangeAll(func(idx uint32, _ T) {
+		putNumber(s.writer, commit.Merge, idx, delta)
+	})
+}
+
+// ScaleAll multiplies the value of every selected row which has one by a factor. This is
+// not atomic, the values are replaced with the products of the values read by the
+// transaction.
+func (s rwNumber[T]) ScaleAll(factor T) {
+	s.updateAll(s.writer, func(v T) T { return v * factor })
+}
+
+// NumberOf returns a read-write accessor for a numeric column of type T. This is
+// equivalent to the typed accessors such as Int16() or Float64() on the transaction.
+func NumberOf[T Number](txn *Txn, columnName string) rwNumber[T] {
+	return rwNumber[T]{
+		rdNumber: readNumberOf[T](txn, columnName),
+		writer:   txn.bufferFor(columnName),
+	}
+}
+
+// putNumber writes a number of any supported type into the buffer
+func putNumber[T Number](buffer *commit.Buffer, op commit.OpType, idx uint32, value T) {
+	switch v := any(value).(type) {
+	case int:
+		buffer.PutInt(op, idx, v)
+	case int16:
+		buffer.PutInt16(op, idx, v)
+	case int32:
+		buffer.PutInt32(op, idx, v)
+	case int64:
+		buffer.PutInt64(op, idx, v)
+	case uint:
+		buffer.PutUint(op, idx, v)
+	case uint16:
+		buffer.PutUint16(op, idx, v)
+	case uint32:
+		buffer.PutUint32(op, idx, v)
+	case uint64:
+		buffer.PutUint64(op, idx, v)
+	case float32:
+		buffer.PutFloat32(op, idx, v)
+	case float64:
+		buffer.PutFloat64(op, idx, v)
+	}
+}
+
+// decodeNumber reads a numeric value of a specific type from the commit reader
+func decodeNumber[T simd.Number](r *commit.Reader) (v T) {
+	switch any(v).(type) {
+	case int:
+		return T(r.Int())
+	case int16:
+		return T(r.Int16())
+	case int32:
+		return T(r.Int32())
+	case int64:
+		return T(r.Int64())
+	case uint:
+		return T(r.Uint())
+	case uint16:
+		return T(r.Uint16())
+	case uint32:
+		return T(r.Uint32())
+	case uint64:
+		return T(r.Uint64())
+	case float32:
+		return T(r.Float32())
+	default:
+		return T(r.Float64())
+	}
+}
+
 // readNumberOf creates a new numeric reader
 func readNumberOf[T simd.Number](txn *Txn, columnName string) rdNumber[T] {
 	column, ok := txn.columnAt(columnName)
@@ -0,0 +1,304 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+const (
+	earthRadius = 6371008.8 // The mean radius of the earth, in meters
+	cellSize    = 0.1       // The size of a grid cell, in degrees
+	cellLimit   = 4096      // The max number of cells to lookup before scanning
+	cellsAround = 3600      // The number of grid cells around a parallel (360 / cellSize)
+)
+
+// --------------------------- Point ----------------------------
+
+// Point represents a geographical point, with latitude and longitude in degrees.
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// MarshalBinary encodes the point into a 16-byte binary representation.
+func (p Point) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[0:8], math.Float64bits(p.Lat))
+	binary.BigEndian.PutUint64(out[8:16], math.Float64bits(p.Lon))
+	return out, nil
+}
+
+// UnmarshalBinary decodes the point from its binary representation.
+func (p *Point) UnmarshalBinary(b []byte) error {
+	if len(b) != 16 {
+		return fmt.Errorf("column: unable to decode point, invalid size %d", len(b))
+	}
+
+	p.Lat = math.Float64frombits(binary.BigEndian.Uint64(b[0:8]))
+	p.Lon = math.Float64frombits(binary.BigEndian.Uint64(b[8:16]))
+	return nil
+}
+
+// DistanceTo computes the great-circle distance to another point, in meters.
+func (p Point) DistanceTo(other Point) float64 {
+	lat1, lat2 := p.Lat*math.Pi/180, other.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (other.Lon - p.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// cellOf returns the grid cell which contains the point
+func cellOf(lat, lon float64) uint64 {
+	return cellAt(math.Floor(lat/cellSize), math.Floor(lon/cellSize))
+}
+
+// cellAt returns the grid cell at the specified row and column of the grid. The columns
+// wrap around at the antimeridian, so the longitudes 180 and -180 share the same cells.
+func cellAt(y, x float64) uint64 {
+	x = math.Mod(x+cellsAround/2, cellsAround)
+	if x < 0 {
+		x += cellsAround
+	}
+
+	return uint64(uint32(int32(y)))<<32 | uint64(uint32(int32(x-cellsAround/2)))
+}
+
+// --------------------------- Point Column ----------------------------
+
+// columnPoint represents a geographical point column, backed by a grid index
+type columnPoint struct {
+	chunks[Point]
+	lock  sync.RWMutex             // The lock to protect the grid
+	cells map[uint64]bitmap.Bitmap // The grid cell -> offsets lookup
+}
+
+// ForPoint creates a new column for geographical points, which can be queried using
+// WithinRadius and WithinBox filters on the transaction.
+func ForPoint() Column {
+	return &columnPoint{
+		chunks: make(chunks[Point], 0, 4),
+		cells:  make(map[uint64]bitmap.Bitmap, 64),
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnPoint) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			var value Point
+			if err := value.UnmarshalBinary(r.Bytes()); err != nil {
+				continue
+			}
+
+			if fill.Contains(offset) {
+				c.unindex(r.Index(), data[offset])
+			}
+
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+			data[offset] = value
+			c.index(r.Index(), value)
+		case commit.Delete:
+			if fill.Contains(offset) {
+				c.unindex(r.Index(), data[offset])
+				fill.Remove(offset)
+			}
+		}
+	}
+}
+
+// index adds the offset into the grid cell of the point
+func (c *columnPoint) index(idx uint32, p Point) {
+	cell := cellOf(p.Lat, p.Lon)
+	offsets := c.cells[cell]
+	offsets.Set(idx)
+	c.cells[cell] = offsets
+}
+
+// unindex removes the offset from the grid cell of the point
+func (c *columnPoint) unindex(idx uint32, p Point) {
+	if offsets, ok := c.cells[cellOf(p.Lat, p.Lon)]; ok {
+		offsets.Remove(idx)
+	}
+}
+
+// candidates computes the offsets which are located within the grid cells covering the
+// specified bounding box. The longitudes of the box may extend past the antimeridian, in
+// which case the cells wrap around. If the box covers too many cells, it returns false
+// and the caller needs to scan instead.
+func (c *columnPoint) candidates(minLat, minLon, maxLat, maxLon float64, dst *bitmap.Bitmap) bool {
+	y0, y1 := math.Floor(minLat/cellSize), math.Floor(maxLat/cellSize)
+	x0, x1 := math.Floor(minLon/cellSize), math.Floor(maxLon/cellSize)
+	if x1-x0+1 > cellsAround {
+		x1 = x0 + cellsAround - 1 // Every cell of a parallel, once
+	}
+
+	if (y1-y0+1)*(x1-x0+1) > cellLimit {
+		return false
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if offsets, ok := c.cells[cellAt(y, x)]; ok {
+				dst.Or(offsets)
+			}
+		}
+	}
+	return true
+}
+
+// Value retrieves a value at a specified index
+func (c *columnPoint) Value(idx uint32) (v any, ok bool) {
+	return c.LoadPoint(idx)
+}
+
+// LoadPoint retrieves a point at a specified index
+func (c *columnPoint) LoadPoint(idx uint32) (v Point, ok bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(index) {
+		v, ok = c.chunks[chunk].data[index], true
+	}
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnPoint) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// FilterPoint filters down the values based on the specified predicate.
+func (c *columnPoint) FilterPoint(chunk commit.Chunk, index bitmap.Bitmap, predicate func(v Point) bool) {
+	if int(chunk) < len(c.chunks) {
+		fill, data := c.chunkAt(chunk)
+		index.And(fill)
+		index.Filter(func(idx uint32) bool {
+			return predicate(data[idx])
+		})
+	}
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnPoint) accepts(value any) bool {
+	switch value.(type) {
+	case Point, *Point:
+		return true
+	default:
+		return false
+	}
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnPoint) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		encoded, _ := data[x].MarshalBinary()
+		dst.PutBytes(commit.Put, chunk.Min()+x, encoded)
+	})
+}
+
+// --------------------------- Filters ----------------------------
+
+// WithinRadius filters down the rows to the ones with a point located within the given
+// distance (in meters) from the specified latitude and longitude. The candidates are
+// looked up in a plain latitude/longitude grid rather than a spherical index, using the
+// bounding box of the circle. The box wraps around at the antimeridian and spans every
+// longitude when the circle covers a pole, where the lookup falls back to a scan.
+func (txn *Txn) WithinRadius(column string, lat, lon, meters float64) *Txn {
+	defer txn.trace("WithinRadius", column)()
+	center := Point{Lat: lat, Lon: lon}
+	dLat := meters / earthRadius * 180 / math.Pi
+	dLon := dLat / math.Max(math.Cos(lat*math.Pi/180), 1e-9)
+	if lat-dLat <= -90 || lat+dLat >= 90 {
+		dLon = 180 // The circle covers a pole
+	}
+	return txn.withinPoints(column, lat-dLat, lon-dLon, lat+dLat, lon+dLon, func(v Point) bool {
+		return center.DistanceTo(v) <= meters
+	})
+}
+
+// WithinBox filters down the rows to the ones with a point located within the bounding
+// box specified by its south-west and north-east corners.
+func (txn *Txn) WithinBox(column string, minLat, minLon, maxLat, maxLon float64) *Txn {
+	defer txn.trace("WithinBox", column)()
+	return txn.withinPoints(column, minLat, minLon, maxLat, maxLon, func(v Point) bool {
+		return v.Lat >= minLat && v.Lat <= maxLat && v.Lon >= minLon && v.Lon <= maxLon
+	})
+}
+
+// withinPoints narrows down the query using the grid of the point column and filters
+// the remaining candidates using the predicate.
+func (txn *Txn) withinPoints(column string, minLat, minLon, maxLat, maxLon float64, predicate func(Point) bool) *Txn {
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	points, ok := c.Column.(*columnPoint)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	var candidates bitmap.Bitmap
+	indexed := points.candidates(minLat, minLon, maxLat, maxLon, &candidates)
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		if indexed {
+			index.And(chunk.OfBitmap(candidates))
+		}
+		points.FilterPoint(chunk, index, predicate)
+	})
+	return txn
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwPoint represents read-write accessor for points
+type rwPoint struct {
+	rdPoint
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s rwPoint) Set(lat, lon float64) {
+	encoded, _ := Point{Lat: lat, Lon: lon}.MarshalBinary()
+	s.writer.PutBytes(commit.Put, *s.cursor, encoded)
+}
+
+// Point returns a point column accessor
+func (txn *Txn) Point(columnName string) rwPoint {
+	return rwPoint{
+		rdPoint: rdPoint(readerFor[*columnPoint](txn, columnName)),
+		writer:  txn.bufferFor(columnName),
+	}
+}
+
+// rdPoint represents a read-only accessor for points
+type rdPoint reader[*columnPoint]
+
+// Get loads the value at the current transaction cursor
+func (s rdPoint) Get() (lat, lon float64, ok bool) {
+	v, ok := s.reader.LoadPoint(*s.cursor)
+	return v.Lat, v.Lon, ok
+}
@@ -81,6 +81,16 @@ func (c *columnRecord) Value(idx uint32) (out any, has bool) {
 	return
 }
 
+// accepts checks whether a value can be stored in the column.
+func (c *columnRecord) accepts(value any) bool {
+	switch value.(type) {
+	case encoding.BinaryMarshaler, []byte:
+		return true
+	default:
+		return false
+	}
+}
+
 // --------------------------- Writer ----------------------------
 
 // rwRecord represents read-write accessor for primary keys.
@@ -0,0 +1,277 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+const (
+	sliceAdd    = '+' // Merge operation which adds a value to the set
+	sliceRemove = '-' // Merge operation which removes a value from the set
+)
+
+// --------------------------- Slice ----------------------------
+
+// columnSlice represents a multi-value column which stores a small set of strings per
+// row. Each distinct value is backed by a bitmap of the rows which contain it.
+type columnSlice struct {
+	chunks[[]uint32]
+	lock    sync.RWMutex             // The lock to protect the dictionary and bitmaps
+	seek    map[string]uint32        // The value -> id lookup
+	data    []string                 // The id -> value lookup
+	members map[uint32]bitmap.Bitmap // The id -> rows containing the value
+}
+
+// ForSlice creates a new multi-value column which stores a set of strings per row and
+// which can be queried using WithMember filter on the transaction.
+func ForSlice() Column {
+	return &columnSlice{
+		chunks:  make(chunks[[]uint32], 0, 4),
+		seek:    make(map[string]uint32, 64),
+		data:    make([]string, 0, 64),
+		members: make(map[uint32]bitmap.Bitmap, 64),
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnSlice) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			c.clear(r.Index(), data[offset])
+			data[offset] = data[offset][:0]
+			decodeSlice(r.Bytes(), func(v []byte) {
+				data[offset] = c.add(r.Index(), data[offset], v)
+			})
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+		case commit.Merge:
+			value := r.Bytes()
+			if len(value) == 0 {
+				continue
+			}
+
+			switch value[0] {
+			case sliceAdd:
+				data[offset] = c.add(r.Index(), data[offset], value[1:])
+			case sliceRemove:
+				data[offset] = c.remove(r.Index(), data[offset], value[1:])
+			}
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+		case commit.Delete:
+			c.clear(r.Index(), data[offset])
+			data[offset] = nil
+			fill.Remove(offset)
+		}
+	}
+}
+
+// add adds a value to the set of a row, if not already present
+func (c *columnSlice) add(idx uint32, set []uint32, value []byte) []uint32 {
+	id, ok := c.seek[string(value)]
+	if !ok {
+		id = uint32(len(c.data))
+		c.data = append(c.data, string(value))
+		c.seek[c.data[id]] = id
+	}
+
+	for _, v := range set {
+		if v == id {
+			return set
+		}
+	}
+
+	rows := c.members[id]
+	rows.Set(idx)
+	c.members[id] = rows
+	return append(set, id)
+}
+
+// remove removes a value from the set of a row, if present
+func (c *columnSlice) remove(idx uint32, set []uint32, value []byte) []uint32 {
+	id, ok := c.seek[string(value)]
+	if !ok {
+		return set
+	}
+
+	for i, v := range set {
+		if v == id {
+			rows := c.members[id]
+			rows.Remove(idx)
+			c.members[id] = rows
+			return append(set[:i], set[i+1:]...)
+		}
+	}
+	return set
+}
+
+// clear removes the row from all of the values of the set
+func (c *columnSlice) clear(idx uint32, set []uint32) {
+	for _, id := range set {
+		rows := c.members[id]
+		rows.Remove(idx)
+		c.members[id] = rows
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnSlice) Value(idx uint32) (any, bool) {
+	return c.LoadSlice(idx)
+}
+
+// LoadSlice retrieves the set of values at a specified index
+func (c *columnSlice) LoadSlice(idx uint32) ([]string, bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) >= len(c.chunks) || !c.chunks[chunk].fill.Contains(index) {
+		return nil, false
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	set := c.chunks[chunk].data[index]
+	out := make([]string, 0, len(set))
+	for _, id := range set {
+		out = append(out, c.data[id])
+	}
+	return out, true
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnSlice) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnSlice) accepts(value any) bool {
+	_, ok := value.([]string)
+	return ok
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnSlice) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	var encoded []byte
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		encoded = encoded[:0]
+		for _, id := range data[x] {
+			encoded = appendSlice(encoded, c.data[id])
+		}
+		dst.PutBytes(commit.Put, chunk.Min()+x, encoded)
+	})
+}
+
+// --------------------------- Filters ----------------------------
+
+// WithMember filters down the rows to the ones which contain the specified value in
+// their set. This is backed by a bitmap per distinct value and does not scan the rows.
+func (txn *Txn) WithMember(column, value string) *Txn {
+	defer txn.trace("WithMember", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	slice, ok := c.Column.(*columnSlice)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	slice.lock.RLock()
+	var members bitmap.Bitmap
+	if id, ok := slice.seek[value]; ok {
+		slice.members[id].Clone(&members)
+	}
+	slice.lock.RUnlock()
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		index.And(chunk.OfBitmap(members))
+	})
+	return txn
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwSlice represents read-write accessor for multi-value columns
+type rwSlice struct {
+	rdSlice
+	writer *commit.Buffer
+}
+
+// Set replaces the set of values at the current transaction cursor
+func (s rwSlice) Set(values []string) {
+	s.writer.PutBytes(commit.Put, *s.cursor, encodeSlice(values))
+}
+
+// Add adds a value to the set at the current transaction cursor
+func (s rwSlice) Add(value string) {
+	s.writer.PutBytes(commit.Merge, *s.cursor, append([]byte{sliceAdd}, value...))
+}
+
+// Remove removes a value from the set at the current transaction cursor
+func (s rwSlice) Remove(value string) {
+	s.writer.PutBytes(commit.Merge, *s.cursor, append([]byte{sliceRemove}, value...))
+}
+
+// Slice returns a multi-value column accessor
+func (txn *Txn) Slice(columnName string) rwSlice {
+	return rwSlice{
+		rdSlice: rdSlice(readerFor[*columnSlice](txn, columnName)),
+		writer:  txn.bufferFor(columnName),
+	}
+}
+
+// rdSlice represents a read-only accessor for multi-value columns
+type rdSlice reader[*columnSlice]
+
+// Get loads the set of values at the current transaction cursor
+func (s rdSlice) Get() ([]string, bool) {
+	return s.reader.LoadSlice(*s.cursor)
+}
+
+// --------------------------- Encoding ----------------------------
+
+// encodeSlice encodes a set of values as a sequence of length-prefixed strings
+func encodeSlice(values []string) []byte {
+	out := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		out = appendSlice(out, v)
+	}
+	return out
+}
+
+// appendSlice appends a length-prefixed string to the buffer
+func appendSlice(dst []byte, value string) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(value)))
+	return append(dst, value...)
+}
+
+// decodeSlice decodes a sequence of length-prefixed strings
+func decodeSlice(b []byte, fn func(v []byte)) {
+	for len(b) > 0 {
+		size, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < size {
+			return
+		}
+
+		fn(b[n : n+int(size)])
+		b = b[n+int(size):]
+	}
+}
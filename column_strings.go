@@ -4,9 +4,10 @@
 package column
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"sync"
+	"sync/atomic"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
@@ -16,22 +17,57 @@ import (
 
 // --------------------------- Enum ----------------------------
 
+// ErrUnknownValue is returned when a value which is not in the vocabulary is written into
+// an enum column created with ForEnumOf().
+var ErrUnknownValue = errors.New("value is not in the vocabulary")
+
 var _ Textual = new(columnEnum)
 
 // columnEnum represents a string column
 type columnEnum struct {
 	chunks[uint32]
-	seek *intmap.Sync // The hash->location table
-	data []string     // The string data
+	seek   *intmap.Sync // The hash->location table
+	data   []string     // The string data
+	hits   uint64       // The number of predicate cache hits
+	misses uint64       // The number of predicate cache misses
+	option[string]
 }
 
 // makeEnum creates a new column
-func makeEnum() Column {
+func makeEnum(opts ...func(*option[string])) Column {
 	return &columnEnum{
 		chunks: make(chunks[uint32], 0, 4),
 		seek:   intmap.NewSync(64, .95),
 		data:   make([]string, 0, 64),
+		option: configure(opts, option[string]{}),
+	}
+}
+
+// ForEnumOf creates a new enum column with a fixed vocabulary. The values are stored as
+// their position in the vocabulary, and writing any other value rolls back the transaction
+// with an error wrapping ErrUnknownValue.
+func ForEnumOf(values ...string) Column {
+	column := makeEnum().(*columnEnum)
+	for _, v := range values {
+		column.findOrAdd([]byte(v))
+	}
+
+	column.Validate = column.known
+	return column
+}
+
+// known checks whether the value is in the vocabulary of the column
+func (c *columnEnum) known(value string) error {
+	if _, ok := c.lookup(value); !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownValue, value)
 	}
+	return nil
+}
+
+// Vocabulary returns a copy of the distinct values of the column, in the order they were
+// first written or, for an enum with a fixed vocabulary, in the order they were declared.
+func (c *columnEnum) Vocabulary() []string {
+	return append([]string(nil), c.data...)
 }
 
 // Apply applies a set of operations to the column.
@@ -61,11 +97,27 @@ func (c *columnEnum) findOrAdd(v []byte) uint32 {
 	return at
 }
 
+// lookup returns the location of a string in the dictionary, without adding it
+func (c *columnEnum) lookup(value string) (uint32, bool) {
+	at, ok := c.seek.Load(uint32(xxh3.HashString(value)))
+	return at, ok && c.readAt(at) == value
+}
+
 // readAt reads a string at a location
 func (c *columnEnum) readAt(at uint32) string {
 	return c.data[at]
 }
 
+// loadID retrieves the dictionary location of the value at a specified index
+func (c *columnEnum) loadID(idx uint32) (at uint32, ok bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(index) {
+		at, ok = c.chunks[chunk].data[index], true
+	}
+	return
+}
+
 // Value retrieves a value at a specified index
 func (c *columnEnum) Value(idx uint32) (v interface{}, ok bool) {
 	return c.LoadString(idx)
@@ -84,55 +136,186 @@ func (c *columnEnum) LoadString(idx uint32) (v string, ok bool) {
 // FilterString filters down the values based on the specified predicate. The column for
 // this filter must be a string.
 func (c *columnEnum) FilterString(chunk commit.Chunk, index bitmap.Bitmap, predicate func(v string) bool) {
+	c.filterCached(chunk, index, predicate, 1)
+}
+
+// filterCached filters down the values based on the specified predicate, caching up to
+// the specified number of recently evaluated locations.
+func (c *columnEnum) filterCached(chunk commit.Chunk, index bitmap.Bitmap, predicate func(v string) bool, size int) {
 	if int(chunk) >= len(c.chunks) {
 		return
 	}
 
-	fill, locs := c.chunkAt(chunk)
-	cache := struct {
-		index uint32 // Last seen offset
-		value bool   // Last evaluated predicate
-	}{
-		index: math.MaxUint32,
-		value: false,
-	}
-
 	// Do a quick ellimination of elements which are NOT contained in this column, this
 	// allows us not to check contains during the filter itself
+	fill, locs := c.chunkAt(chunk)
 	index.And(fill)
 
 	// Filters down the strings, if strings repeat we avoid reading every time by
-	// caching the last seen index/value combination.
+	// caching the recently seen index/value combinations.
+	cache := newEnumCache(size)
 	index.Filter(func(idx uint32) bool {
-		if at := locs[idx]; at != cache.index {
-			cache.index = at
-			cache.value = predicate(c.readAt(at))
-			return cache.value
+		at := locs[idx]
+		if value, ok := cache.load(at); ok {
+			return value
 		}
 
-		// The value is cached, avoid evaluating it
-		return cache.value
+		value := predicate(c.readAt(at))
+		cache.store(at, value)
+		return value
+	})
+
+	atomic.AddUint64(&c.hits, cache.hits)
+	atomic.AddUint64(&c.misses, cache.misses)
+}
+
+// filterEqual filters down the values to the ones equal to the specified string, by
+// comparing the dictionary locations instead of the strings themselves.
+func (c *columnEnum) filterEqual(chunk commit.Chunk, index bitmap.Bitmap, value string) {
+	at, ok := c.lookup(value)
+	if !ok || int(chunk) >= len(c.chunks) {
+		index.Clear()
+		return
+	}
+
+	fill, locs := c.chunkAt(chunk)
+	index.And(fill)
+	for blkAt, blk := range index {
+		if blk == 0 {
+			continue
+		}
+
+		offset := blkAt << 6
+		for bit := 0; bit < 64; bit++ {
+			if blk&(1<<bit) != 0 && locs[offset+bit] != at {
+				blk &^= 1 << bit
+			}
+		}
+		index[blkAt] = blk
+	}
+}
+
+// filterOneOf filters down the values to the ones equal to any of the specified strings, by
+// checking the dictionary locations against the set of locations of those strings.
+func (c *columnEnum) filterOneOf(chunk commit.Chunk, index bitmap.Bitmap, values []string) {
+	var set bitmap.Bitmap
+	for _, v := range values {
+		if at, ok := c.lookup(v); ok {
+			set.Set(at)
+		}
+	}
+
+	c.filterIDs(chunk, index, set)
+}
+
+// filterIDs filters down the values to the ones whose dictionary location is in the set
+func (c *columnEnum) filterIDs(chunk commit.Chunk, index bitmap.Bitmap, set bitmap.Bitmap) {
+	if set.Count() == 0 || int(chunk) >= len(c.chunks) {
+		index.Clear()
+		return
+	}
+
+	fill, locs := c.chunkAt(chunk)
+	index.And(fill)
+	index.Filter(func(idx uint32) bool {
+		return set.Contains(locs[idx])
 	})
 }
 
+// Stats returns the predicate cache statistics of the column
+func (c *columnEnum) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
 // Contains checks whether the column has a value at a specified index.
 func (c *columnEnum) Contains(idx uint32) bool {
 	chunk := commit.ChunkAt(idx)
 	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
 }
 
+// accepts checks whether a value can be stored in the column.
+func (c *columnEnum) accepts(value any) bool {
+	return isTextual(value)
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *columnEnum) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	fill, locs := c.chunkAt(chunk)
 	fill.Range(func(idx uint32) {
-		dst.PutString(commit.Put, idx, c.readAt(locs[idx]))
+		dst.PutString(commit.Put, chunk.Min()+idx, c.readAt(locs[idx]))
 	})
 }
 
+// --------------------------- Enum Cache ----------------------------
+
+// maxEnumCache is the maximum number of entries in an enum predicate cache
+const maxEnumCache = 64
+
+// CacheStats represents the statistics of the predicate cache of an enum column
+type CacheStats struct {
+	Hits   uint64 // The number of predicates answered from the cache
+	Misses uint64 // The number of predicates evaluated
+}
+
+// enumCache represents a small LRU cache of location -> predicate result
+type enumCache struct {
+	size   int                  // The capacity of the cache
+	used   int                  // The number of entries in use
+	keys   [maxEnumCache]uint32 // The locations, most recently used first
+	values [maxEnumCache]bool   // The predicate results
+	hits   uint64               // The number of hits
+	misses uint64               // The number of misses
+}
+
+// newEnumCache creates a new cache with the specified capacity
+func newEnumCache(size int) enumCache {
+	switch {
+	case size < 1:
+		size = 1
+	case size > maxEnumCache:
+		size = maxEnumCache
+	}
+	return enumCache{size: size}
+}
+
+// load looks up the location and moves it to the front if found
+func (c *enumCache) load(at uint32) (bool, bool) {
+	for i := 0; i < c.used; i++ {
+		if c.keys[i] != at {
+			continue
+		}
+
+		value := c.values[i]
+		copy(c.keys[1:i+1], c.keys[:i])
+		copy(c.values[1:i+1], c.values[:i])
+		c.keys[0], c.values[0] = at, value
+		c.hits++
+		return value, true
+	}
+
+	c.misses++
+	return false, false
+}
+
+// store adds a location to the front, evicting the least recently used one if full
+func (c *enumCache) store(at uint32, value bool) {
+	if c.used < c.size {
+		c.used++
+	}
+
+	copy(c.keys[1:c.used], c.keys[:c.used-1])
+	copy(c.values[1:c.used], c.values[:c.used-1])
+	c.keys[0], c.values[0] = at, value
+}
+
 // rwEnum represents read-write accessor for enum
 type rwEnum struct {
 	rdString[*columnEnum]
 	writer *commit.Buffer
+	txn    *Txn
 }
 
 // Set sets the value at the current transaction cursor
@@ -140,11 +323,25 @@ func (s rwEnum) Set(value string) {
 	s.writer.PutString(commit.Put, *s.cursor, value)
 }
 
+// ID returns the dictionary identifier of the value at the current transaction cursor. The
+// identifiers are stable for the lifetime of the column and can be compared instead of the
+// strings, or resolved with Values() where the identifier is the position of the string.
+func (s rwEnum) ID() (uint32, bool) {
+	return s.reader.loadID(*s.cursor)
+}
+
+// Lookup returns the dictionary identifier of a string, or false if the string was never
+// written into the column.
+func (s rwEnum) Lookup(value string) (uint32, bool) {
+	return s.reader.lookup(value)
+}
+
 // Enum returns a enumerable column accessor
 func (txn *Txn) Enum(columnName string) rwEnum {
 	return rwEnum{
 		rdString: readStringOf[*columnEnum](txn, columnName),
 		writer:   txn.bufferFor(columnName),
+		txn:      txn,
 	}
 }
 
@@ -182,7 +379,7 @@ func (c *columnString) Apply(chunk commit.Chunk, r *commit.Reader) {
 			data[offset] = string(r.Bytes())
 		case commit.Merge:
 			fill[offset>>6] |= 1 << (offset & 0x3f)
-			data[offset] = r.SwapString(c.Merge(data[offset], r.String()))
+			data[offset] = r.SwapString(c.Merge(data[offset], string(r.Bytes())))
 		case commit.Delete:
 			fill.Remove(uint32(offset))
 		}
@@ -224,6 +421,21 @@ func (c *columnString) FilterString(chunk commit.Chunk, index bitmap.Bitmap, pre
 	}
 }
 
+// accepts checks whether a value can be stored in the column.
+func (c *columnString) accepts(value any) bool {
+	return isTextual(value)
+}
+
+// isTextual checks whether a value can be stored as a string
+func isTextual(value any) bool {
+	switch value.(type) {
+	case string, []byte:
+		return true
+	default:
+		return false
+	}
+}
+
 // Snapshot writes the entire column into the specified destination buffer
 func (c *columnString) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
 	fill, data := c.chunkAt(chunk)
@@ -258,10 +470,16 @@ func (txn *Txn) String(columnName string) rwString {
 
 // --------------------------- Key ----------------------------
 
+// primaryKey represents a column which can be used as a primary key of the collection
+type primaryKey interface {
+	Column
+	OffsetOf(key string) (uint32, bool)
+	LoadString(idx uint32) (string, bool)
+}
+
 // columnKey represents the primary key column implementation
 type columnKey struct {
 	columnString
-	name string            // Name of the column
 	lock sync.RWMutex      // Lock to protect the lookup table
 	seek map[string]uint32 // Lookup table for O(1) index seek
 }
@@ -296,7 +514,9 @@ func (c *columnKey) Apply(chunk commit.Chunk, r *commit.Reader) {
 		case commit.Delete:
 			fill.Remove(uint32(offset))
 			c.lock.Lock()
-			delete(c.seek, string(data[offset]))
+			if at, ok := c.seek[data[offset]]; ok && at == uint32(r.Offset) {
+				delete(c.seek, data[offset])
+			}
 			c.lock.Unlock()
 		}
 	}
@@ -310,15 +530,28 @@ func (c *columnKey) OffsetOf(v string) (uint32, bool) {
 	return idx, ok
 }
 
+// checkKey checks whether a key can be stored in the primary key column, such as a UUID
+// key which must be either 16 raw bytes or in its canonical string form.
+func checkKey(pk primaryKey, key string) error {
+	if v, ok := pk.(typed); ok && !v.accepts(key) {
+		return fmt.Errorf("column: invalid key '%s'", key)
+	}
+	return nil
+}
+
 // rwKey represents read-write accessor for primary keys.
 type rwKey struct {
 	cursor *uint32
 	writer *commit.Buffer
-	reader *columnKey
+	reader primaryKey
 }
 
 // Set sets the value at the current transaction index
 func (s rwKey) Set(value string) error {
+	if err := checkKey(s.reader, value); err != nil {
+		return err
+	}
+
 	if _, ok := s.reader.OffsetOf(value); !ok {
 		s.writer.PutString(commit.Put, *s.cursor, value)
 		return nil
@@ -340,7 +573,7 @@ func (txn *Txn) Key() rwKey {
 
 	return rwKey{
 		cursor: &txn.cursor,
-		writer: txn.bufferFor(txn.owner.pk.name),
+		writer: txn.bufferFor(txn.owner.pkName),
 		reader: txn.owner.pk,
 	}
 }
@@ -4,13 +4,20 @@
 package column
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
+	"github.com/kelindar/simd"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,6 +39,8 @@ func TestColumns(t *testing.T) {
 		{column: ForUint64(), value: uint64(99)},
 		{column: ForFloat32(), value: float32(99.5)},
 		{column: ForFloat64(), value: float64(99.5)},
+		{column: ForPoint(), value: Point{Lat: 48.8566, Lon: 2.3522}},
+		{column: ForBytes(), value: []byte("test")},
 	}
 
 	for _, tc := range tests {
@@ -39,9 +48,12 @@ func TestColumns(t *testing.T) {
 			testColumn(t, tc.column, tc.value)
 		})
 
-		t.Run(fmt.Sprintf("%T-put-delete", tc.column), func(t *testing.T) {
-			testPutDelete(t, tc.column, tc.value)
-		})
+		// The deletions of a boolean column are covered by TestBoolPutDelete
+		if _, ok := tc.column.(*columnBool); !ok {
+			t.Run(fmt.Sprintf("%T-put-delete", tc.column), func(t *testing.T) {
+				testPutDelete(t, tc.column, tc.value)
+			})
+		}
 
 		t.Run(fmt.Sprintf("%T-snapshot", tc.column), func(t *testing.T) {
 			testSnapshot(t, tc.column, tc.value)
@@ -184,6 +196,39 @@ func testPutDelete(t *testing.T, column Column, value interface{}) {
 	assert.False(t, ok)
 }
 
+func TestBoolPutDelete(t *testing.T) {
+	column := ForBool()
+	column.Grow(0)
+	applyChanges(column, Update{commit.Put, 0, true})
+	applyChanges(column, Update{commit.Put, 0, false})
+
+	// A false value is still a value
+	v, ok := column.Value(0)
+	assert.True(t, ok)
+	assert.Equal(t, false, v)
+	assert.True(t, column.Contains(0))
+
+	// A deletion in the buffer of the column itself is a false value, not a deletion
+	applyChanges(column, Update{commit.Put, 0, true}, Update{commit.Delete, 0, nil})
+	v, ok = column.Value(0)
+	assert.True(t, ok)
+	assert.Equal(t, false, v)
+	assert.True(t, column.Contains(0))
+
+	// Deletions are applied through the row markers
+	buf := commit.NewBuffer(10)
+	buf.Column = rowColumn
+	buf.PutOperation(commit.Delete, 0)
+	r := new(commit.Reader)
+	r.Seek(buf)
+	column.Apply(0, r)
+
+	// Should be deleted
+	_, ok = column.Value(0)
+	assert.False(t, ok)
+	assert.False(t, column.Contains(0))
+}
+
 // testSnapshot test a snapshot of a column
 func testSnapshot(t *testing.T, column Column, value interface{}) {
 	buf := commit.NewBuffer(8)
@@ -191,6 +236,29 @@ func testSnapshot(t *testing.T, column Column, value interface{}) {
 	assert.False(t, buf.IsEmpty())
 }
 
+func TestBoolSnapshot(t *testing.T) {
+	column := ForBool()
+	column.Grow(100)
+	applyChanges(column, Update{commit.Put, 1, true}, Update{commit.Put, 2, false})
+
+	// The snapshot keeps the false values apart from the missing ones
+	buf := commit.NewBuffer(8)
+	column.Snapshot(0, buf)
+	r := new(commit.Reader)
+	r.Seek(buf)
+
+	restored := ForBool()
+	restored.Grow(100)
+	restored.Apply(0, r)
+	for i, expect := range []any{nil, true, false, nil} {
+		v, ok := restored.Value(uint32(i))
+		assert.Equal(t, expect != nil, ok)
+		if ok {
+			assert.Equal(t, expect, v)
+		}
+	}
+}
+
 func TestFromKind(t *testing.T) {
 	for _, v := range []reflect.Kind{
 		reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -222,6 +290,27 @@ type Update struct {
 	Value interface{}
 }
 
+func TestStringMergeCopy(t *testing.T) {
+	column := ForString(WithMerge(func(value, delta string) string {
+		return delta
+	}))
+	column.Grow(0)
+
+	buf := commit.NewBuffer(10)
+	buf.PutAny(commit.Merge, 0, "hello")
+	r := new(commit.Reader)
+	r.Seek(buf)
+	column.Apply(0, r)
+
+	// Re-using the commit buffer must not change the merged value
+	buf.Reset("")
+	buf.PutAny(commit.Put, 0, "jello")
+
+	v, ok := column.Value(0)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", v)
+}
+
 func TestForString(t *testing.T) {
 	coll := NewCollection()
 	coll.CreateColumn("id", ForInt64())
@@ -341,6 +430,29 @@ func TestSnapshotBool(t *testing.T) {
 	assert.Equal(t, input, output)
 }
 
+func TestSnapshotEnum(t *testing.T) {
+	input := ForEnum()
+	input.Grow(chunkSize + 8)
+
+	// Write into the second chunk, whose offsets do not start at zero
+	buf := commit.NewBuffer(8)
+	buf.PutString(commit.Put, chunkSize+2, "a")
+	buf.PutString(commit.Put, chunkSize+5, "b")
+	rdr := commit.NewReader()
+	rdr.Seek(buf)
+	input.Apply(1, rdr)
+
+	// Snapshot the chunk, the offsets must be absolute
+	buf.Reset("test")
+	input.Snapshot(1, buf)
+	rdr.Seek(buf)
+	var offsets []uint32
+	for rdr.Next() {
+		offsets = append(offsets, rdr.Index())
+	}
+	assert.Equal(t, []uint32{chunkSize + 2, chunkSize + 5}, offsets)
+}
+
 func TestSnapshotIndex(t *testing.T) {
 	predicateFn := func(Reader) bool {
 		return true
@@ -734,6 +846,129 @@ func TestNumberMerge(t *testing.T) {
 	})
 }
 
+func TestFilterRangeBounds(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("u64", ForUint64())
+	coll.CreateColumn("i16", ForInt16())
+	coll.CreateColumn("f32", ForFloat32())
+	for _, v := range []struct {
+		u uint64
+		i int16
+		f float32
+	}{
+		{0, math.MinInt16, 0.1},
+		{1, -1, 1.5},
+		{math.MaxInt64 + 10, 0, -2.5},
+		{math.MaxUint64, math.MaxInt16, 2},
+	} {
+		coll.Insert(func(r Row) error {
+			r.SetUint64("u64", v.u)
+			r.SetInt16("i16", v.i)
+			r.SetFloat32("f32", v.f)
+			return nil
+		})
+	}
+
+	count := func(fn func(txn *Txn) *Txn) (n int) {
+		coll.Query(func(txn *Txn) error {
+			n = fn(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	// Unsigned values above math.MaxInt64
+	assert.Equal(t, 2, count(func(txn *Txn) *Txn { return txn.WithUint64Range("u64", math.MaxInt64, math.MaxUint64) }))
+	assert.Equal(t, 2, count(func(txn *Txn) *Txn { return txn.WithInt64Range("u64", -5, 1) }))
+	assert.Equal(t, 1, count(func(txn *Txn) *Txn { return txn.WithInt64Range("u64", 1, math.MaxInt64) }))
+	assert.Equal(t, 1, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("u64", 0.5, 1.5) }))
+
+	// Negative values and bounds out of the domain of the column
+	assert.Equal(t, 2, count(func(txn *Txn) *Txn { return txn.WithInt64Range("i16", math.MinInt64, -1) }))
+	assert.Equal(t, 2, count(func(txn *Txn) *Txn { return txn.WithUint64Range("i16", 0, math.MaxUint64) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithUint64Range("i16", math.MaxUint64-1, math.MaxUint64) }))
+	assert.Equal(t, 1, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("i16", -0.5, 0.5) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("i16", math.NaN(), 1) }))
+
+	// Floating-point values are not truncated by integer bounds
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithInt64Range("f32", 1, 1) }))
+	assert.Equal(t, 4, count(func(txn *Txn) *Txn { return txn.WithInt64Range("f32", -3, 2) }))
+	assert.Equal(t, 1, count(func(txn *Txn) *Txn { return txn.WithUint64Range("f32", 2, 2) }))
+	assert.Equal(t, 1, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("f32", 0.1, 1) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("f32", 0.11, 1) }))
+}
+
+func TestFilterRangeKernels(t *testing.T) {
+	testRangeKernel(t, rangeInt64s[int], []int{math.MinInt64, -5, 0, 5, math.MaxInt64}, -5, 5)
+	testRangeKernel(t, rangeInt64s[int64], []int64{math.MinInt64, -5, 0, 5, math.MaxInt64}, math.MinInt64, 0)
+	testRangeKernel(t, rangeUint64s[uint], []uint{0, 5, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64}, 5, math.MaxInt64+1)
+	testRangeKernel(t, rangeUint64s[uint64], []uint64{0, 5, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64}, math.MaxInt64, math.MaxUint64)
+	testRangeKernel(t, rangeInt32s[int32], []int32{math.MinInt32, -5, 0, 5, math.MaxInt32}, -5, math.MaxInt32)
+	testRangeKernel(t, rangeUint32s[uint32], []uint32{0, 5, math.MaxInt32, math.MaxInt32 + 1, math.MaxUint32}, math.MaxInt32, math.MaxInt32+1)
+	testRangeKernel(t, rangeInt16s[int16], []int16{math.MinInt16, -5, 0, 5, math.MaxInt16}, math.MinInt16, -5)
+	testRangeKernel(t, rangeUint16s[uint16], []uint16{0, 5, math.MaxInt16, math.MaxInt16 + 1, math.MaxUint16}, 5, math.MaxUint16)
+	testRangeKernel(t, rangeFloat32s[float32], []float32{float32(math.Inf(-1)), -0.5, 0, float32(math.NaN()), 1.5}, -0.5, 1.5)
+	testRangeKernel(t, rangeFloat64s[float64], []float64{math.Inf(-1), -0.5, 0, math.NaN(), math.Inf(1)}, math.Inf(-1), math.Inf(1))
+}
+
+// testRangeKernel checks a vectorized range kernel against the scalar one, on a chunk
+// with values and a selection which vary for every block
+func testRangeKernel[T simd.Number](t *testing.T, kernel func(bitmap.Bitmap, []T, T, T), values []T, lo, hi T) {
+	data := make([]T, chunkSize+10)
+	for i := range data {
+		data[i] = values[(i*7+i/64)%len(values)]
+	}
+
+	index := make(bitmap.Bitmap, chunkSize/64)
+	for i := range index {
+		index[i] = uint64(i%3) * 0x9e3779b97f4a7c15 >> (i % 5)
+	}
+
+	expect := append(bitmap.Bitmap(nil), index...)
+	rangeOf(expect, data, lo, hi)
+	kernel(index, data, lo, hi)
+	assert.Equal(t, expect, index, "%T", lo)
+	assert.NotZero(t, index.Count(), "%T", lo)
+}
+
+func TestFilterRangeChunks(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("i64", ForInt64())
+	coll.CreateColumn("u32", ForUint32())
+	coll.CreateColumn("f64", ForFloat64())
+	for i := 0; i < 2*chunkSize; i++ {
+		coll.Insert(func(r Row) error {
+			r.SetInt64("i64", int64(i)-100)
+			r.SetUint32("u32", uint32(i))
+			r.SetFloat64("f64", float64(i)/2)
+			return nil
+		})
+	}
+
+	count := func(fn func(txn *Txn) *Txn) (n int) {
+		coll.Query(func(txn *Txn) error {
+			n = fn(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	// The range filters match the same values as the equivalent predicates
+	assert.Equal(t, 20011, count(func(txn *Txn) *Txn { return txn.WithInt64Range("i64", -10, 20000) }))
+	assert.Equal(t, 20011, count(func(txn *Txn) *Txn {
+		return txn.WithInt("i64", func(v int64) bool { return v >= -10 && v <= 20000 })
+	}))
+
+	assert.Equal(t, 101, count(func(txn *Txn) *Txn { return txn.WithUint64Range("u32", 100, 200) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithUint64Range("u32", 200, 100) }))
+	assert.Equal(t, 3, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("f64", 0.5, 1.5) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("f64", math.NaN(), 1) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithFloat64Range("xxx", 0, 1) }))
+	assert.Equal(t, 2*chunkSize, count(func(txn *Txn) *Txn {
+		return txn.WithFloat("f64", func(v float64) bool { return true })
+	}))
+}
+
 func TestIssue87(t *testing.T) {
 	table := NewCollection()
 	table.CreateColumn("birthdate", ForRecord(func() *time.Time { return new(time.Time) }))
@@ -788,3 +1023,1095 @@ func invoke(any interface{}, name string, args ...interface{}) []reflect.Value {
 
 	return reflect.ValueOf(any).MethodByName(name).Call(inputs)
 }
+
+func TestPointColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("name", ForString())
+	coll.CreateColumn("location", ForPoint())
+
+	cities := map[string]Point{
+		"paris":  {48.8566, 2.3522},
+		"london": {51.5074, -0.1278},
+		"berlin": {52.5200, 13.4050},
+		"nyc":    {40.7128, -74.0060},
+	}
+
+	for name, p := range cities {
+		name, p := name, p
+		coll.Insert(func(r Row) error {
+			r.SetString("name", name)
+			r.SetPoint("location", p.Lat, p.Lon)
+			return nil
+		})
+	}
+
+	// Paris to London is ~344km
+	assert.InDelta(t, 343500, cities["paris"].DistanceTo(cities["london"]), 1500)
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithinRadius("location", 48.85, 2.35, 10000).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithinRadius("location", 48.85, 2.35, 400000).Count())
+		return nil
+	})
+
+	// Large radius falls back to scanning
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 4, txn.WithinRadius("location", 48.85, 2.35, 10000000).Count())
+		return nil
+	})
+
+	// Europe only
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.WithinBox("location", 35, -10, 60, 20).Count())
+		return nil
+	})
+
+	// Invalid columns
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithinBox("name", 35, -10, 60, 20).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithinBox("invalid", 35, -10, 60, 20).Count())
+		return nil
+	})
+
+	// Move paris to new york and make sure the index is updated
+	coll.Query(func(txn *Txn) error {
+		location := txn.Point("location")
+		return txn.WithString("name", func(v string) bool {
+			return v == "paris"
+		}).Range(func(idx uint32) {
+			location.Set(40.7, -74.0)
+		})
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithinRadius("location", 40.7128, -74.0060, 5000).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithinBox("location", 35, -10, 60, 20).Count())
+		return nil
+	})
+
+	// Read back the point
+	coll.QueryAt(0, func(r Row) error {
+		_, _, ok := r.Point("location")
+		assert.True(t, ok)
+		return nil
+	})
+
+	// Insert through a map
+	_, err := coll.InsertObject(map[string]any{"location": Point{1, 1}})
+	assert.NoError(t, err)
+	_, err = coll.InsertObject(map[string]any{"location": "invalid"})
+	assert.Error(t, err)
+}
+
+func TestPointAntimeridian(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("location", ForPoint())
+	for _, p := range []Point{{0, 179.99}, {0, -179.99}, {0, 180}, {0, 170}, {89.99, 0}, {89.99, 90}} {
+		p := p
+		coll.Insert(func(r Row) error {
+			r.SetPoint("location", p.Lat, p.Lon)
+			return nil
+		})
+	}
+
+	// The circle extends on both sides of the antimeridian
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.WithinRadius("location", 0, 179.99, 5000).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.WithinRadius("location", 0, -179.99, 5000).Count())
+		return nil
+	})
+
+	// The circle covers the north pole
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithinRadius("location", 89.99, 45, 5000).Count())
+		return nil
+	})
+}
+
+func TestTimeColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("created_at", ForTime())
+	coll.CreateColumn("name", ForString())
+
+	base := time.Date(2022, 5, 10, 13, 30, 0, 0, time.UTC)
+	for i := 0; i < 48; i++ {
+		at := base.Add(time.Duration(i) * time.Hour)
+		coll.Insert(func(r Row) error {
+			r.SetTime("created_at", at)
+			return nil
+		})
+	}
+
+	// Filter by time range
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 10, txn.WithTimeRange("created_at", base, base.Add(10*time.Hour)).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithTimeRange("name", base, base.Add(10*time.Hour)).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithTimeRange("invalid", base, base.Add(10*time.Hour)).Count())
+		return nil
+	})
+
+	// Group by day
+	days := make(map[time.Time]int)
+	coll.Query(func(txn *Txn) error {
+		createdAt := txn.Time("created_at")
+		return txn.Range(func(idx uint32) {
+			day, ok := createdAt.Truncate(24 * time.Hour)
+			assert.True(t, ok)
+			days[day]++
+		})
+	})
+	assert.Equal(t, map[time.Time]int{
+		TruncateDay(base):                     11,
+		TruncateDay(base.Add(24 * time.Hour)): 24,
+		TruncateDay(base.Add(48 * time.Hour)): 13,
+	}, days)
+	assert.Equal(t, time.Date(2022, 5, 10, 13, 0, 0, 0, time.UTC), TruncateHour(base))
+
+	// Read back and insert through a map
+	coll.QueryAt(0, func(r Row) error {
+		v, ok := r.Time("created_at")
+		assert.True(t, ok)
+		assert.True(t, base.Equal(v))
+		return nil
+	})
+
+	idx, err := coll.InsertObject(map[string]any{"created_at": base})
+	assert.NoError(t, err)
+	coll.QueryAt(idx, func(r Row) error {
+		v, _ := r.Time("created_at")
+		assert.True(t, base.Equal(v))
+		return nil
+	})
+
+	_, err = coll.InsertObject(map[string]any{"created_at": "invalid"})
+	assert.Error(t, err)
+}
+
+func TestUUIDColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("id", ForUUIDKey())
+	coll.CreateColumn("parent", ForUUID())
+
+	id1 := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	id2 := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", formatUUID(id1))
+
+	// Insert using the canonical form of the key
+	assert.NoError(t, coll.InsertKey("123e4567-e89b-12d3-a456-426614174000", func(r Row) error {
+		r.SetUUID("parent", id2)
+		return nil
+	}))
+	assert.NoError(t, coll.InsertKey(string(id2[:]), func(r Row) error {
+		r.SetUUID("parent", id2)
+		return nil
+	}))
+	assert.Error(t, coll.InsertKey(string(id1[:]), func(r Row) error {
+		return nil
+	}))
+
+	// Read the values back
+	assert.NoError(t, coll.QueryKey(string(id1[:]), func(r Row) error {
+		key, ok := r.Key()
+		assert.True(t, ok)
+		assert.Equal(t, formatUUID(id1), key)
+
+		id, ok := r.UUID("id")
+		assert.True(t, ok)
+		assert.Equal(t, id1, id)
+
+		parent, ok := r.UUID("parent")
+		assert.True(t, ok)
+		assert.Equal(t, id2, parent)
+		return nil
+	}))
+
+	// Equality filters, both on the key and a regular column
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithUUID("id", id2).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithUUID("id", [16]byte{}).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithUUID("parent", id2).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithUUID("invalid", id2).Count())
+		return nil
+	})
+
+	// Delete by key
+	assert.NoError(t, coll.DeleteKey(formatUUID(id2)))
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithUUID("parent", id2).Count())
+		assert.Equal(t, 0, txn.WithUUID("id", id2).Count())
+		return nil
+	})
+
+	// Invalid values
+	_, ok := decodeUUID([]byte("123e4567-e89b-12d3-a456-42661417400z"))
+	assert.False(t, ok)
+	_, ok = decodeUUID([]byte("123e4567+e89b-12d3-a456-426614174000"))
+	assert.False(t, ok)
+
+	// Malformed keys are rejected rather than silently dropped
+	assert.Error(t, coll.InsertKey("not-a-uuid", func(r Row) error { return nil }))
+	assert.Error(t, coll.UpsertKey("123e4567-e89b-12d3-a456", func(r Row) error { return nil }))
+	assert.Error(t, coll.BulkLoad(func(loader *Loader) error {
+		_, err := loader.InsertKey("not-a-uuid", func(r Row) error { return nil })
+		return err
+	}))
+	assert.Equal(t, 1, coll.Count())
+}
+
+func TestSliceColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("name", ForString())
+	coll.CreateColumn("tags", ForSlice())
+
+	alice, _ := coll.Insert(func(r Row) error {
+		r.SetString("name", "alice")
+		r.SetSlice("tags", []string{"vip", "admin", "vip"})
+		return nil
+	})
+	bob, _ := coll.Insert(func(r Row) error {
+		r.SetString("name", "bob")
+		r.AddToSet("tags", "guest")
+		return nil
+	})
+	coll.InsertObject(map[string]any{
+		"name": "carol",
+		"tags": []string{"vip"},
+	})
+
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithMember("tags", "vip").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithMember("tags", "unknown").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithMember("name", "vip").Count())
+		assert.Equal(t, 0, txn.WithMember("invalid", "vip").Count())
+		return nil
+	})
+
+	// Duplicates are removed from the set
+	coll.QueryAt(alice, func(r Row) error {
+		tags, ok := r.Slice("tags")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"vip", "admin"}, tags)
+		return nil
+	})
+
+	// Add and remove members
+	assert.NoError(t, coll.QueryAt(bob, func(r Row) error {
+		r.AddToSet("tags", "vip")
+		r.AddToSet("tags", "vip")
+		return nil
+	}))
+	assert.NoError(t, coll.QueryAt(alice, func(r Row) error {
+		r.RemoveFromSet("tags", "vip")
+		r.RemoveFromSet("tags", "unknown")
+		return nil
+	}))
+
+	coll.QueryAt(bob, func(r Row) error {
+		tags, _ := r.Slice("tags")
+		assert.Equal(t, []string{"guest", "vip"}, tags)
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithMember("tags", "vip").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithMember("tags", "admin").Count())
+		return nil
+	})
+
+	// Deleting a row removes it from all members
+	coll.DeleteAt(bob)
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithMember("tags", "vip").Count())
+		assert.Equal(t, 0, txn.WithMember("tags", "guest").Count())
+		return nil
+	})
+
+	// Restore from a snapshot
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, coll.Snapshot(buffer))
+	other := NewCollection()
+	other.CreateColumn("name", ForString())
+	other.CreateColumn("tags", ForSlice())
+	assert.NoError(t, other.Restore(buffer))
+	other.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithMember("tags", "vip").Count())
+		return nil
+	})
+	other.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithMember("tags", "admin").Count())
+		return nil
+	})
+}
+
+func TestEnumCache(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("class", ForEnum())
+	coll.CreateColumn("name", ForString())
+	for i := 0; i < 300; i++ {
+		class := []string{"mage", "rogue", "druid"}[i%3]
+		coll.Insert(func(r Row) error {
+			r.SetEnum("class", class)
+			return nil
+		})
+	}
+
+	// Alternating values always miss with a single-entry cache
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 100, txn.WithString("class", func(v string) bool {
+			return v == "mage"
+		}).Count())
+		return nil
+	})
+
+	stats, ok := coll.CacheStats("class")
+	assert.True(t, ok)
+	assert.Equal(t, CacheStats{Hits: 0, Misses: 300}, stats)
+
+	// A larger cache captures all of the values
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 200, txn.WithCache(4).WithString("class", func(v string) bool {
+			return v != "mage"
+		}).Count())
+		return nil
+	})
+
+	stats, _ = coll.CacheStats("class")
+	assert.Equal(t, CacheStats{Hits: 297, Misses: 303}, stats)
+
+	// Not an enum
+	_, ok = coll.CacheStats("name")
+	assert.False(t, ok)
+	_, ok = coll.CacheStats("invalid")
+	assert.False(t, ok)
+}
+
+func TestEnumCacheEviction(t *testing.T) {
+	cache := newEnumCache(2)
+	cache.store(1, true)
+	cache.store(2, false)
+
+	v, ok := cache.load(1)
+	assert.True(t, ok)
+	assert.True(t, v)
+
+	// 2 is the least recently used and gets evicted
+	cache.store(3, true)
+	_, ok = cache.load(2)
+	assert.False(t, ok)
+	_, ok = cache.load(1)
+	assert.True(t, ok)
+	_, ok = cache.load(3)
+	assert.True(t, ok)
+
+	assert.Equal(t, 1, newEnumCache(0).size)
+	assert.Equal(t, maxEnumCache, newEnumCache(1000).size)
+}
+
+func TestJSONColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("payload", ForJSON())
+	coll.CreateColumn("name", ForString())
+
+	idx, err := coll.Insert(func(r Row) error {
+		return r.SetJSON("payload", map[string]any{
+			"status": "active",
+			"items":  []any{map[string]any{"qty": 3}},
+		})
+	})
+	assert.NoError(t, err)
+	coll.Insert(func(r Row) error {
+		return r.SetJSON("payload", map[string]any{"status": "closed"})
+	})
+	_, err = coll.InsertObject(map[string]any{
+		"payload": json.RawMessage(`{"status":"active","items":[]}`),
+	})
+	assert.NoError(t, err)
+
+	// Invalid documents are rejected
+	_, err = coll.InsertObject(map[string]any{"payload": "{invalid"})
+	assert.Error(t, err)
+
+	// Documents larger than 64KB are rejected
+	large := strings.Repeat("x", maxBytes)
+	_, err = coll.Insert(func(r Row) error {
+		return r.SetJSON("payload", large)
+	})
+	assert.Error(t, err)
+	_, err = coll.InsertObject(map[string]any{"payload": `"` + large + `"`})
+	assert.Error(t, err)
+
+	isActive := func(v any) bool { return v == "active" }
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.WithJSONPath("payload", "$.status", isActive).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithJSONPath("payload", "$.items[0].qty", func(v any) bool {
+			return v == float64(3)
+		}).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithJSONPath("payload", "status", isActive).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithJSONPath("name", "$.status", isActive).Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithJSONPath("invalid", "$.status", isActive).Count())
+		return nil
+	})
+
+	// Read the document back
+	coll.QueryAt(idx, func(r Row) error {
+		raw, ok := r.JSON("payload")
+		assert.True(t, ok)
+		assert.JSONEq(t, `{"status":"active","items":[{"qty":3}]}`, string(raw))
+
+		var doc struct{ Status string }
+		assert.True(t, r.txn.JSON("payload").Unmarshal(&doc))
+		assert.Equal(t, "active", doc.Status)
+		return nil
+	})
+
+	// Index over the extracted path, kept up to date on writes
+	assert.NoError(t, coll.CreateJSONIndex("active", "payload", "$.status", isActive))
+	assert.Error(t, coll.CreateJSONIndex("bad", "payload", "status", isActive))
+	assert.Error(t, coll.CreateJSONIndex("bad", "name", "$.status", isActive))
+	assert.Error(t, coll.CreateJSONIndex("bad", "invalid", "$.status", isActive))
+	assert.Error(t, coll.CreateJSONIndex("bad", "payload", "$.status", nil))
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.With("active").Count())
+		return nil
+	})
+
+	coll.QueryAt(idx, func(r Row) error {
+		return r.SetJSON("payload", map[string]any{"status": "closed"})
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.With("active").Count())
+		return nil
+	})
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		expect jsonPath
+		err    bool
+	}{
+		{path: "$", expect: jsonPath{}},
+		{path: "$.a.b", expect: jsonPath{"a", "b"}},
+		{path: "$.a[1].b", expect: jsonPath{"a", 1, "b"}},
+		{path: "$[0][2]", expect: jsonPath{0, 2}},
+		{path: "a.b", err: true},
+		{path: "$.", err: true},
+		{path: "$.a[1", err: true},
+		{path: "$.a[x]", err: true},
+		{path: "$.a[-1]", err: true},
+		{path: "$a", err: true},
+	}
+
+	for _, tc := range tests {
+		path, err := parseJSONPath(tc.path)
+		if tc.err {
+			assert.Error(t, err, tc.path)
+			continue
+		}
+
+		assert.NoError(t, err, tc.path)
+		assert.Equal(t, tc.expect, path, tc.path)
+	}
+
+	_, ok := jsonPath{"a"}.Lookup([]byte("[1]"))
+	assert.False(t, ok)
+	_, ok = jsonPath{3}.Lookup([]byte("[1]"))
+	assert.False(t, ok)
+	_, ok = jsonPath{}.Lookup([]byte("{"))
+	assert.False(t, ok)
+}
+
+type testProfile struct {
+	Name  string
+	Score int
+}
+
+func TestTypedColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("profile", ForTyped[testProfile](nil))
+
+	idx, err := coll.Insert(func(r Row) error {
+		return Typed[testProfile](r.txn, "profile").Set(testProfile{Name: "alice", Score: 10})
+	})
+	assert.NoError(t, err)
+	_, err = coll.InsertObject(map[string]any{
+		"profile": testProfile{Name: "bob", Score: 20},
+	})
+	assert.NoError(t, err)
+
+	// Wrong type is rejected
+	_, err = coll.InsertObject(map[string]any{"profile": "bob"})
+	assert.Error(t, err)
+
+	coll.QueryAt(idx, func(r Row) error {
+		profile := Typed[testProfile](r.txn, "profile")
+		v, ok := profile.Get()
+		assert.True(t, ok)
+		assert.Equal(t, testProfile{Name: "alice", Score: 10}, v)
+		assert.Equal(t, float64(0), testing.AllocsPerRun(100, func() {
+			profile.Get()
+		}))
+		return nil
+	})
+
+	coll.Query(func(txn *Txn) error {
+		profile := Typed[testProfile](txn, "profile")
+		total := 0
+		txn.Range(func(idx uint32) {
+			v, _ := profile.Get()
+			total += v.Score
+		})
+		assert.Equal(t, 30, total)
+		return nil
+	})
+
+	// Restore from a snapshot
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, coll.Snapshot(buffer))
+	other := NewCollection()
+	other.CreateColumn("profile", ForTyped[testProfile](JSONCodec[testProfile]()))
+	assert.NoError(t, other.Restore(buffer))
+	other.QueryAt(idx, func(r Row) error {
+		v, ok := r.Any("profile")
+		assert.True(t, ok)
+		assert.Equal(t, testProfile{Name: "alice", Score: 10}, v)
+		return nil
+	})
+
+	// Delete the value
+	coll.DeleteAt(idx)
+	coll.QueryAt(idx, func(r Row) error {
+		_, ok := Typed[testProfile](r.txn, "profile").Get()
+		assert.False(t, ok)
+		return nil
+	})
+}
+
+func TestTypedColumnLog(t *testing.T) {
+	writer := make(commit.Channel, 10)
+	primary := NewCollection(Options{Writer: writer})
+	primary.CreateColumn("profile", ForTyped[testProfile](nil))
+	replica := NewCollection()
+	replica.CreateColumn("profile", ForTyped[testProfile](nil))
+
+	// The values are logged encoded, so they can be replayed elsewhere
+	idx, err := primary.Insert(func(r Row) error {
+		return Typed[testProfile](r.txn, "profile").Set(testProfile{Name: "alice", Score: 10})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, replica.Replay(<-writer))
+	assert.NoError(t, replica.QueryAt(idx, func(r Row) error {
+		v, ok := Typed[testProfile](r.txn, "profile").Get()
+		assert.True(t, ok)
+		assert.Equal(t, testProfile{Name: "alice", Score: 10}, v)
+		return nil
+	}))
+
+	// A value which can not be decoded is rejected
+	buffer := commit.NewBuffer(10)
+	buffer.Reset("profile")
+	buffer.PutBytes(commit.Put, idx, []byte("{invalid"))
+	assert.Error(t, replica.Replay(commit.Commit{
+		Chunk:   0,
+		Updates: []*commit.Buffer{buffer},
+	}))
+
+	// The staged values are released once the transactions are finished
+	primary.Query(func(txn *Txn) error {
+		profile := Typed[testProfile](txn, "profile")
+		return txn.Range(func(idx uint32) {
+			profile.Set(testProfile{Name: "bob"})
+		})
+	})
+	primary.Query(func(txn *Txn) error {
+		Typed[testProfile](txn, "profile").Set(testProfile{Name: "carol"})
+		return fmt.Errorf("rollback")
+	})
+
+	staged := 0
+	column, _ := primary.cols.Load("profile")
+	column.Column.(*columnTyped[testProfile]).staged.Range(func(_, _ any) bool {
+		staged++
+		return true
+	})
+	assert.Equal(t, 0, staged)
+}
+
+func TestBytesColumn(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("data", ForBytes(WithMaxSize(8)))
+
+	idx, err := coll.Insert(func(r Row) error {
+		return r.SetBytes("data", []byte("hello"))
+	})
+	assert.NoError(t, err)
+
+	// Values exceeding the maximum size are rejected
+	_, err = coll.Insert(func(r Row) error {
+		return r.SetBytes("data", []byte("hello world"))
+	})
+	assert.Error(t, err)
+	_, err = coll.InsertObject(map[string]any{"data": []byte("hello world")})
+	assert.Error(t, err)
+	assert.Equal(t, 1, coll.Count())
+
+	coll.QueryAt(idx, func(r Row) error {
+		v, ok := r.Bytes("data")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("hello"), v)
+
+		reader, ok := r.txn.Bytes("data").Reader()
+		assert.True(t, ok)
+		out, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), out)
+		return nil
+	})
+
+	// Delete the row
+	coll.DeleteAt(idx)
+	coll.QueryAt(idx, func(r Row) error {
+		_, ok := r.Bytes("data")
+		assert.False(t, ok)
+		_, ok = r.txn.Bytes("data").Reader()
+		assert.False(t, ok)
+		return nil
+	})
+
+	// The size is capped by the commit log
+	assert.Equal(t, maxBytes, ForBytes(WithMaxSize(1<<20)).(*columnBytes).MaxSize)
+	assert.Equal(t, maxBytes, ForBytes(WithMaxSize(0)).(*columnBytes).MaxSize)
+}
+
+func TestCRDTConverge(t *testing.T) {
+	newReplica := func(clock int64) (*Collection, commit.Channel) {
+		writer := make(commit.Channel, 1024)
+		c := NewCollection(Options{Writer: writer})
+		assert.NoError(t, c.CreateColumn("hits", ForCounter()))
+		assert.NoError(t, c.CreateColumn("name", ForRegister(WithLWW(func() int64 {
+			return clock
+		}))))
+		assert.Error(t, c.CreateIndex("many", "hits", func(r Reader) bool { return true }))
+		assert.Error(t, c.CreateSortIndex("sorted", "hits"))
+
+		c.Insert(func(r Row) error { return nil })
+		for len(writer) > 0 {
+			<-writer
+		}
+		return c, writer
+	}
+
+	a, logA := newReplica(1)
+	b, logB := newReplica(2)
+	c, logC := newReplica(2)
+
+	// Concurrent writes on each of the replicas
+	write := func(dst *Collection, delta int64, name string) {
+		assert.NoError(t, dst.QueryAt(0, func(r Row) error {
+			r.txn.Counter("hits").Add(delta)
+			r.txn.Register("name").Set(name)
+			return nil
+		}))
+	}
+	write(a, 5, "a")
+	write(b, 3, "b")
+	write(c, 1, "c")
+	write(a, 2, "a2")
+
+	// Each replica replays the commits of the others, in a different order
+	drain := func(ch commit.Channel) (out []commit.Commit) {
+		for len(ch) > 0 {
+			out = append(out, <-ch)
+		}
+		return
+	}
+	fromA, fromB, fromC := drain(logA), drain(logB), drain(logC)
+	replay := func(dst *Collection, changes ...[]commit.Commit) {
+		for _, list := range changes {
+			for _, change := range list {
+				assert.NoError(t, dst.Replay(change.Clone()))
+			}
+		}
+	}
+	replay(a, fromC, fromB)
+	replay(b, fromA, fromC)
+	replay(c, fromB, fromA)
+
+	for _, replica := range []*Collection{a, b, c} {
+		assert.NoError(t, replica.QueryAt(0, func(r Row) error {
+			hits, _ := r.txn.Counter("hits").Get()
+			name, _ := r.txn.Register("name").Get()
+			assert.Equal(t, int64(11), hits)
+			assert.Equal(t, "c", name) // same clock as "b", larger value wins
+			return nil
+		}))
+	}
+}
+
+func TestRegisterSnapshot(t *testing.T) {
+	input := NewCollection()
+	assert.NoError(t, input.CreateColumn("name", ForRegister()))
+	idx, err := input.Insert(func(r Row) error {
+		return r.SetMany(map[string]any{"name": "Roman"})
+	})
+	assert.NoError(t, err)
+
+	// Snapshot preserves the timestamps, so older writes are discarded
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, input.Snapshot(buffer))
+	output := NewCollection()
+	assert.NoError(t, output.CreateColumn("name", ForRegister(WithLWW(func() int64 {
+		return 1
+	}))))
+	assert.NoError(t, output.Restore(buffer))
+	assert.NoError(t, output.QueryAt(idx, func(r Row) error {
+		r.txn.Register("name").Set("Merlin")
+		return nil
+	}))
+	assert.NoError(t, output.QueryAt(idx, func(r Row) error {
+		name, ok := r.txn.Register("name").Get()
+		assert.True(t, ok)
+		assert.Equal(t, "Roman", name)
+		return nil
+	}))
+}
+
+func TestSketch(t *testing.T) {
+	input := NewCollection()
+	assert.NoError(t, input.CreateColumn("visitors", ForHLL()))
+	idx, err := input.Insert(func(r Row) error {
+		r.txn.HLL("visitors").Add("alice", "bob")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Adding the same visitors again does not change the estimate
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, input.QueryAt(idx, func(r Row) error {
+			r.txn.HLL("visitors").Add("bob", "carol")
+			r.txn.HLL("visitors").Merge(NewSketch("alice", "dave"))
+			return nil
+		}))
+	}
+
+	assert.NoError(t, input.QueryAt(idx, func(r Row) error {
+		count, ok := r.txn.HLL("visitors").Estimate()
+		assert.True(t, ok)
+		assert.Equal(t, 4, count)
+
+		sketch, ok := r.txn.HLL("visitors").Get()
+		assert.True(t, ok)
+		sketch.Add("eve") // a copy, which does not change the column
+		assert.Equal(t, 5, sketch.Estimate())
+		return nil
+	}))
+
+	// Snapshot and restore the sketches
+	buffer := new(bytes.Buffer)
+	assert.NoError(t, input.Snapshot(buffer))
+	output := NewCollection()
+	assert.NoError(t, output.CreateColumn("visitors", ForHLL()))
+	assert.NoError(t, output.Restore(buffer))
+	assert.NoError(t, output.QueryAt(idx, func(r Row) error {
+		count, ok := r.txn.HLL("visitors").Estimate()
+		assert.True(t, ok)
+		assert.Equal(t, 4, count)
+		return nil
+	}))
+
+	// Deleting the row removes the sketch
+	assert.True(t, output.DeleteAt(idx))
+	value, ok := output.cols.Load("visitors")
+	assert.True(t, ok)
+	_, ok = value.Value(idx)
+	assert.False(t, ok)
+}
+
+func TestSketchLarge(t *testing.T) {
+	sketch := NewSketch()
+	for i := 0; i < 10000; i++ {
+		sketch.Add(i)
+	}
+
+	other := new(Sketch)
+	other.apply(sketch.encode())
+	assert.Equal(t, sketch.Estimate(), other.Estimate())
+	assert.InEpsilon(t, 10000, sketch.Estimate(), 0.1)
+}
+
+func TestWithValidator(t *testing.T) {
+	errNegative := errors.New("age must not be negative")
+	coll := NewCollection()
+	coll.CreateColumn("age", ForInt(WithValidator(func(v int) error {
+		if v < 0 {
+			return errNegative
+		}
+		return nil
+	})))
+	coll.CreateColumn("class", ForEnum(WithValidator(func(v string) error {
+		switch v {
+		case "mage", "rogue", "fighter":
+			return nil
+		default:
+			return fmt.Errorf("class '%s' is not allowed", v)
+		}
+	})))
+
+	idx, err := coll.Insert(func(r Row) error {
+		r.SetInt("age", 10)
+		r.SetEnum("class", "mage")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Invalid values roll back the entire transaction
+	err = coll.Query(func(txn *Txn) error {
+		txn.Insert(func(r Row) error {
+			r.SetInt("age", 20)
+			return nil
+		})
+		return txn.QueryAt(idx, func(r Row) error {
+			r.SetInt("age", -1)
+			return nil
+		})
+	})
+	assert.ErrorIs(t, err, errNegative)
+	assert.Equal(t, 1, coll.Count())
+
+	_, err = coll.Insert(func(r Row) error {
+		r.SetEnum("class", "bard")
+		return nil
+	})
+	assert.ErrorContains(t, err, "class 'bard' is not allowed")
+	assert.Equal(t, 1, coll.Count())
+
+	// Deltas are validated once merged into the current value
+	assert.Error(t, coll.QueryAt(idx, func(r Row) error {
+		r.MergeInt("age", -11)
+		return nil
+	}))
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		r.MergeInt("age", -10)
+		return nil
+	}))
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		age, _ := r.Int("age")
+		class, _ := r.Enum("class")
+		assert.Equal(t, 0, age)
+		assert.Equal(t, "mage", class)
+		return nil
+	}))
+}
+
+func TestMergeModes(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("gold", ForInt16(WithSaturatingAdd[int16]()))
+	coll.CreateColumn("quota", ForUint32(WithSaturatingAdd[uint32]()))
+	coll.CreateColumn("hp", ForInt(WithClamp(0, 100)))
+	coll.CreateColumn("mana", ForFloat64(WithClamp(0.0, 1.0)))
+	coll.CreateColumn("score", ForInt64(WithCheckedAdd[int64]()))
+
+	idx, err := coll.Insert(func(r Row) error {
+		r.SetInt16("gold", math.MaxInt16-10)
+		r.SetUint32("quota", 5)
+		r.SetInt("hp", 50)
+		r.SetFloat64("mana", 0.5)
+		r.SetInt64("score", math.MaxInt64-1)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// The saturating and clamped merges stay within their bounds
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		r.MergeInt16("gold", 100)
+		r.MergeUint32("quota", 10)
+		r.MergeInt("hp", 80)
+		r.MergeFloat64("mana", -2)
+		return nil
+	}))
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		gold, _ := r.Int16("gold")
+		hp, _ := r.Int("hp")
+		mana, _ := r.Float64("mana")
+		assert.Equal(t, int16(math.MaxInt16), gold)
+		assert.Equal(t, 100, hp)
+		assert.Equal(t, 0.0, mana)
+		return nil
+	}))
+
+	// Unsigned deltas are always positive, so a large delta saturates at the maximum
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		r.MergeUint32("quota", math.MaxUint32)
+		return nil
+	}))
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		quota, _ := r.Uint32("quota")
+		assert.Equal(t, uint32(math.MaxUint32), quota)
+		return nil
+	}))
+
+	// The checked merges roll back the transaction on overflow
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		r.MergeInt64("score", 1)
+		return nil
+	}))
+	err = coll.QueryAt(idx, func(r Row) error {
+		r.MergeInt("hp", -10)
+		r.MergeInt64("score", 1)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrOverflow)
+	assert.NoError(t, coll.QueryAt(idx, func(r Row) error {
+		score, _ := r.Int64("score")
+		hp, _ := r.Int("hp")
+		assert.Equal(t, int64(math.MaxInt64), score)
+		assert.Equal(t, 100, hp)
+		return nil
+	}))
+
+	assert.Equal(t, int16(math.MinInt16), saturatingAdd[int16](math.MinInt16+1, -5))
+	assert.Equal(t, uint16(math.MaxUint16), maxOf[uint16]())
+	assert.Equal(t, int32(math.MinInt32), minOf[int32]())
+}
+
+func TestVector(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("kind", ForEnum())
+	coll.CreateColumn("embedding", ForVector(3))
+	coll.CreateIndex("fruit", "kind", func(r Reader) bool {
+		return r.String() == "fruit"
+	})
+
+	data := []struct {
+		kind   string
+		vector []float32
+	}{
+		{"fruit", []float32{1, 0, 0}},
+		{"fruit", []float32{0.9, 0.1, 0}},
+		{"fruit", []float32{0, 1, 0}},
+		{"veggie", []float32{1, 0.05, 0}},
+		{"veggie", []float32{0, 0, 1}},
+	}
+	for _, v := range data {
+		coll.Insert(func(r Row) error {
+			r.SetEnum("kind", v.kind)
+			r.SetVector("embedding", v.vector)
+			return nil
+		})
+	}
+
+	// Search the entire collection
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		out, err := txn.Nearest("embedding", []float32{2, 0, 0}, 3)
+		assert.NoError(t, err)
+		assert.Len(t, out, 3)
+		assert.Equal(t, uint32(0), out[0].Index)
+		assert.InDelta(t, 1.0, out[0].Score, 1e-6)
+		assert.Equal(t, uint32(3), out[1].Index)
+		assert.Equal(t, uint32(1), out[2].Index)
+		return nil
+	}))
+
+	// Search within a selection
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		out, err := txn.With("fruit").Nearest("embedding", []float32{1, 0, 0}, 10)
+		assert.NoError(t, err)
+		assert.Len(t, out, 3)
+		assert.Equal(t, []uint32{0, 1, 2}, []uint32{out[0].Index, out[1].Index, out[2].Index})
+		return nil
+	}))
+
+	// Read and overwrite a vector
+	assert.NoError(t, coll.QueryAt(2, func(r Row) error {
+		v, ok := r.Vector("embedding")
+		assert.True(t, ok)
+		assert.Equal(t, []float32{0, 1, 0}, v)
+		return r.SetMany(map[string]any{"embedding": []float32{1, 0, 0.01}})
+	}))
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		out, _ := txn.With("fruit").Nearest("embedding", []float32{0, 0, 1}, 1)
+		assert.Equal(t, uint32(2), out[0].Index)
+		return nil
+	}))
+
+	// Invalid searches
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		_, err := txn.Nearest("embedding", []float32{1, 0}, 1)
+		assert.Error(t, err)
+		_, err = txn.Nearest("embedding", []float32{0, 0, 0}, 1)
+		assert.Error(t, err)
+		_, err = txn.Nearest("kind", []float32{1, 0, 0}, 1)
+		assert.Error(t, err)
+		_, err = txn.Nearest("unknown", []float32{1, 0, 0}, 1)
+		assert.Error(t, err)
+		assert.Error(t, txn.QueryAt(0, func(r Row) error {
+			return r.SetMany(map[string]any{"embedding": []float32{1}})
+		}))
+		return nil
+	}))
+
+	// Snapshot and restore
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, coll.Snapshot(buffer))
+	other := NewCollection()
+	other.CreateColumn("kind", ForEnum())
+	other.CreateColumn("embedding", ForVector(3))
+	assert.NoError(t, other.Restore(buffer))
+	assert.NoError(t, other.QueryAt(4, func(r Row) error {
+		v, ok := r.Vector("embedding")
+		assert.True(t, ok)
+		assert.Equal(t, []float32{0, 0, 1}, v)
+		return nil
+	}))
+}
+
+func TestDot(t *testing.T) {
+	a := []float32{1, 2, 3, 4, 5, 6, 7}
+	assert.Equal(t, float32(140), dot(a, a))
+	assert.Panics(t, func() { ForVector(0) })
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- Time ----------------------------
+
+// columnTime represents a time column, stored as int64 nanoseconds since unix epoch. It
+// supports all of the numeric operations of an int64 column.
+type columnTime struct {
+	*numericColumn[int64]
+}
+
+// ForTime creates a new column for time values, which are stored as int64 nanoseconds
+// since unix epoch and can be queried using WithTimeRange filter on the transaction.
+func ForTime() Column {
+	return &columnTime{
+		numericColumn: makeInt64s().(*numericColumn[int64]),
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnTime) Value(idx uint32) (any, bool) {
+	return c.LoadTime(idx)
+}
+
+// LoadTime retrieves a time value at a specified index
+func (c *columnTime) LoadTime(idx uint32) (time.Time, bool) {
+	if v, ok := c.load(idx); ok {
+		return time.Unix(0, v), true
+	}
+	return time.Time{}, false
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnTime) accepts(value any) bool {
+	switch value.(type) {
+	case time.Time, int64:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithTimeRange filters down the rows to the ones with a time value within the half-open
+// interval [from, to). The column for this filter must be created with ForTime.
+func (txn *Txn) WithTimeRange(column string, from, to time.Time) *Txn {
+	defer txn.trace("WithTimeRange", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	times, ok := c.Column.(*columnTime)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	lo, hi := from.UnixNano(), to.UnixNano()
+	if hi <= lo {
+		txn.index.Clear()
+		return txn
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		times.FilterRangeInt64(chunk, index, lo, hi-1)
+	})
+	return txn
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwTime represents read-write accessor for time values
+type rwTime struct {
+	rdTime
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s rwTime) Set(value time.Time) {
+	s.writer.PutInt64(commit.Put, *s.cursor, value.UnixNano())
+}
+
+// Time returns a time column accessor
+func (txn *Txn) Time(columnName string) rwTime {
+	return rwTime{
+		rdTime: rdTime(readerFor[*columnTime](txn, columnName)),
+		writer: txn.bufferFor(columnName),
+	}
+}
+
+// rdTime represents a read-only accessor for time values
+type rdTime reader[*columnTime]
+
+// Get loads the value at the current transaction cursor
+func (s rdTime) Get() (time.Time, bool) {
+	return s.reader.LoadTime(*s.cursor)
+}
+
+// Truncate loads the value at the current transaction cursor and rounds it down to a
+// multiple of the specified duration (e.g. time.Hour or 24*time.Hour), in UTC.
+func (s rdTime) Truncate(d time.Duration) (time.Time, bool) {
+	if v, ok := s.Get(); ok {
+		return v.UTC().Truncate(d), true
+	}
+	return time.Time{}, false
+}
+
+// --------------------------- Grouping ----------------------------
+
+// TruncateHour rounds the time down to the beginning of its hour, in UTC.
+func TruncateHour(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+// TruncateDay rounds the time down to the beginning of its day, in UTC.
+func TruncateDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
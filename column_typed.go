@@ -0,0 +1,285 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Codec represents an encoder/decoder of a value of a specific type, used by the typed
+// column to carry values through the commit log and into snapshots.
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec returns a codec which encodes values as JSON.
+func JSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+// jsonCodec represents a JSON codec for a type
+type jsonCodec[T any] struct{}
+
+// Encode encodes the value as JSON
+func (jsonCodec[T]) Encode(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Decode decodes the value from JSON
+func (jsonCodec[T]) Decode(data []byte) (out T, err error) {
+	err = json.Unmarshal(data, &out)
+	return
+}
+
+// --------------------------- Typed ----------------------------
+
+// tickets generates the identifiers of the values staged by the transactions
+var tickets uint64
+
+// columnTyped represents a generic column which keeps decoded values in a typed slice
+type columnTyped[T any] struct {
+	chunks[T]
+	codec  Codec[T]
+	staged sync.Map // The values written by the pending transactions, by ticket
+}
+
+// ForTyped creates a new column which stores values of the specified type. Unlike
+// ForRecord, the values are kept decoded in memory and are read without allocating.
+// The values written by a transaction are handed over to the column in their native
+// form, and the codec is only used when the values are snapshotted, restored or written
+// into a commit log. If the codec is nil, values are encoded as JSON.
+func ForTyped[T any](codec Codec[T]) Column {
+	if codec == nil {
+		codec = JSONCodec[T]()
+	}
+
+	return &columnTyped[T]{
+		chunks: make(chunks[T], 0, 4),
+		codec:  codec,
+	}
+}
+
+// Apply applies a set of operations to the column. A put carries an encoded value, from
+// a snapshot or a replayed commit, while a merge carries the ticket of a staged value.
+func (c *columnTyped[T]) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			if value, err := c.codec.Decode(r.Bytes()); err == nil { // Rejected by validate
+				fill[offset>>6] |= 1 << (offset & 0x3f)
+				data[offset] = value
+			}
+		case commit.Merge:
+			if value, ok := c.staged.Load(r.Uint64()); ok {
+				fill[offset>>6] |= 1 << (offset & 0x3f)
+				data[offset] = value.(T)
+			}
+		case commit.Delete:
+			var empty T
+			fill.Remove(offset)
+			data[offset] = empty
+		}
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnTyped[T]) Value(idx uint32) (any, bool) {
+	return c.load(idx)
+}
+
+// load retrieves a typed value at a specified index
+func (c *columnTyped[T]) load(idx uint32) (v T, ok bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(index) {
+		v, ok = c.chunks[chunk].data[index], true
+	}
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnTyped[T]) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnTyped[T]) accepts(value any) bool {
+	_, ok := value.(T)
+	return ok
+}
+
+// encode encodes a value of the column type for the commit buffer
+func (c *columnTyped[T]) encode(value any) (any, error) {
+	if v, ok := value.(T); ok {
+		return c.codec.Encode(v)
+	}
+	return value, nil
+}
+
+// validates returns true, since the encoded values must be decoded before being applied
+func (c *columnTyped[T]) validates() bool {
+	return true
+}
+
+// validate checks whether the encoded values written into the column can be decoded, so
+// a corrupted snapshot or commit is rejected rather than silently dropped.
+func (c *columnTyped[T]) validate(r *commit.Reader) error {
+	for r.Next() {
+		if r.Type != commit.Put {
+			continue
+		}
+
+		if _, err := c.codec.Decode(r.Bytes()); err != nil {
+			return fmt.Errorf("unable to decode value at row %d: %w", r.Index(), err)
+		}
+	}
+	return nil
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnTyped[T]) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		if encoded, err := c.codec.Encode(data[x]); err == nil {
+			dst.PutBytes(commit.Put, chunk.Min()+x, encoded)
+		}
+	})
+}
+
+// --------------------------- Staging ----------------------------
+
+// stager represents a column which receives the values written by the transactions in
+// their native form, rather than encoded in the commit buffer.
+type stager interface {
+	stage(txn *Txn, columnName string, value any) bool
+	encodeStaged(r *commit.Reader, dst *commit.Buffer)
+}
+
+// stagedValue represents a value staged by a transaction, until it is finished
+type stagedValue struct {
+	owner  *sync.Map
+	ticket uint64
+}
+
+// stage hands a value over to the column and writes its ticket at the current cursor
+// of the transaction. It returns false if the value is not of the column type.
+func (c *columnTyped[T]) stage(txn *Txn, columnName string, value any) bool {
+	v, ok := value.(T)
+	if !ok {
+		return false
+	}
+
+	ticket := atomic.AddUint64(&tickets, 1)
+	c.staged.Store(ticket, v)
+	txn.staged = append(txn.staged, stagedValue{owner: &c.staged, ticket: ticket})
+	txn.bufferFor(columnName).PutUint64(commit.Merge, txn.cursor, ticket)
+	return true
+}
+
+// encodeStaged encodes the values of a chunk which were staged, once they are applied,
+// so that the operations can be written into a commit log.
+func (c *columnTyped[T]) encodeStaged(r *commit.Reader, dst *commit.Buffer) {
+	for r.Next() {
+		if r.Type != commit.Merge {
+			dst.PutFrom(r, r.Index())
+			continue
+		}
+
+		if value, ok := c.load(r.Index()); ok {
+			if encoded, err := c.codec.Encode(value); err == nil {
+				dst.PutBytes(commit.Put, r.Index(), encoded)
+			}
+		}
+	}
+}
+
+// encodeStaged returns the updates of a chunk which are written into the commit log, with
+// the values staged by the transaction encoded. It must be called while the chunk is
+// locked, after the updates are applied, and the buffers are released by the returned
+// function once the commit is logged.
+func (txn *Txn) encodeStaged(chunk commit.Chunk) ([]*commit.Buffer, func()) {
+	if len(txn.staged) == 0 {
+		return txn.updates, func() {}
+	}
+
+	var encoded []*commit.Buffer
+	updates := make([]*commit.Buffer, 0, len(txn.updates))
+	for _, u := range txn.updates {
+		column, ok := txn.owner.cols.Load(u.Column)
+		if !ok {
+			updates = append(updates, u)
+			continue
+		}
+
+		s, ok := column.Column.(stager)
+		if !ok {
+			updates = append(updates, u)
+			continue
+		}
+
+		dst := txn.owner.txns.acquirePage(u.Column)
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			s.encodeStaged(r, dst)
+		})
+		updates = append(updates, dst)
+		encoded = append(encoded, dst)
+	}
+
+	return updates, func() {
+		for _, buffer := range encoded {
+			txn.owner.txns.releasePage(buffer)
+		}
+	}
+}
+
+// unstage releases the values staged by the transaction
+func (txn *Txn) unstage() {
+	for _, v := range txn.staged {
+		v.owner.Delete(v.ticket)
+	}
+	txn.staged = txn.staged[:0]
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwTyped represents read-write accessor for typed columns
+type rwTyped[T any] struct {
+	rdTyped[T]
+	txn  *Txn
+	name string
+}
+
+// Set stores the value at the current transaction cursor. The value is handed over as-is
+// without being encoded, so it must not be modified afterwards.
+func (s rwTyped[T]) Set(value T) error {
+	s.reader.stage(s.txn, s.name, value)
+	return nil
+}
+
+// Typed returns a read-write accessor for a typed column. Since methods can not have
+// type parameters, this is a function rather than a method of the transaction.
+func Typed[T any](txn *Txn, columnName string) rwTyped[T] {
+	return rwTyped[T]{
+		rdTyped: rdTyped[T](readerFor[*columnTyped[T]](txn, columnName)),
+		txn:     txn,
+		name:    columnName,
+	}
+}
+
+// rdTyped represents a read-only accessor for typed columns
+type rdTyped[T any] reader[*columnTyped[T]]
+
+// Get loads the value at the current transaction cursor
+func (s rdTyped[T]) Get() (T, bool) {
+	return s.reader.load(*s.cursor)
+}
@@ -0,0 +1,304 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding"
+	"encoding/hex"
+	"sync"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- UUID ----------------------------
+
+// columnUUID represents a column of fixed 16-byte universally unique identifiers
+type columnUUID struct {
+	chunks[[16]byte]
+}
+
+// ForUUID creates a new column which stores UUIDs as fixed 16-byte values.
+func ForUUID() Column {
+	return &columnUUID{
+		chunks: make(chunks[[16]byte], 0, 4),
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnUUID) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			if value, ok := decodeUUID(r.Bytes()); ok {
+				fill[offset>>6] |= 1 << (offset & 0x3f)
+				data[offset] = value
+			}
+		case commit.Delete:
+			fill.Remove(offset)
+		}
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnUUID) Value(idx uint32) (any, bool) {
+	return c.LoadUUID(idx)
+}
+
+// LoadUUID retrieves a UUID at a specified index
+func (c *columnUUID) LoadUUID(idx uint32) (v [16]byte, ok bool) {
+	chunk := commit.ChunkAt(idx)
+	index := idx - chunk.Min()
+	if int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(index) {
+		v, ok = c.chunks[chunk].data[index], true
+	}
+	return
+}
+
+// LoadString retrieves a UUID at a specified index, in its canonical string form
+func (c *columnUUID) LoadString(idx uint32) (string, bool) {
+	if v, ok := c.LoadUUID(idx); ok {
+		return formatUUID(v), true
+	}
+	return "", false
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnUUID) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return c.chunks[chunk].fill.Contains(idx - chunk.Min())
+}
+
+// FilterUUID filters down the values based on the specified predicate.
+func (c *columnUUID) FilterUUID(chunk commit.Chunk, index bitmap.Bitmap, predicate func(v [16]byte) bool) {
+	if int(chunk) < len(c.chunks) {
+		fill, data := c.chunkAt(chunk)
+		index.And(fill)
+		index.Filter(func(idx uint32) bool {
+			return predicate(data[idx])
+		})
+	}
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnUUID) accepts(value any) bool {
+	switch v := value.(type) {
+	case [16]byte:
+		return true
+	case []byte:
+		_, ok := decodeUUID(v)
+		return ok
+	case string:
+		_, ok := decodeUUID(s2b(v))
+		return ok
+	case encoding.BinaryMarshaler: // e.g. uuid.UUID
+		return true
+	default:
+		return false
+	}
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnUUID) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		dst.PutBytes(commit.Put, chunk.Min()+x, data[x][:])
+	})
+}
+
+// --------------------------- UUID Key ----------------------------
+
+// columnUUIDKey represents a UUID column which is used as a primary key
+type columnUUIDKey struct {
+	columnUUID
+	lock sync.RWMutex        // Lock to protect the lookup table
+	seek map[[16]byte]uint32 // Lookup table for O(1) index seek
+}
+
+// ForUUIDKey creates a new primary key column which stores UUIDs as fixed 16-byte
+// values. The keys can be specified either as raw 16 bytes or in their canonical
+// string form (e.g. "123e4567-e89b-12d3-a456-426614174000").
+func ForUUIDKey() Column {
+	return &columnUUIDKey{
+		seek: make(map[[16]byte]uint32, 64),
+		columnUUID: columnUUID{
+			chunks: make(chunks[[16]byte], 0, 4),
+		},
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnUUIDKey) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			value, ok := decodeUUID(r.Bytes())
+			if !ok {
+				continue // Rejected by checkKey before being written
+			}
+
+			if fill.Contains(offset) {
+				delete(c.seek, data[offset])
+			}
+
+			fill[offset>>6] |= 1 << (offset & 0x3f)
+			data[offset] = value
+			c.seek[value] = r.Index()
+		case commit.Delete:
+			if fill.Contains(offset) {
+				if c.seek[data[offset]] == r.Index() {
+					delete(c.seek, data[offset])
+				}
+				fill.Remove(offset)
+			}
+		}
+	}
+}
+
+// OffsetOf returns the offset for a particular key, in its raw or canonical form
+func (c *columnUUIDKey) OffsetOf(key string) (uint32, bool) {
+	id, ok := decodeUUID(s2b(key))
+	if !ok {
+		return 0, false
+	}
+
+	return c.OffsetOfUUID(id)
+}
+
+// OffsetOfUUID returns the offset for a particular UUID
+func (c *columnUUIDKey) OffsetOfUUID(id [16]byte) (uint32, bool) {
+	c.lock.RLock()
+	idx, ok := c.seek[id]
+	c.lock.RUnlock()
+	return idx, ok
+}
+
+// --------------------------- Filters ----------------------------
+
+// WithUUID filters down the rows to the ones with the specified UUID. If the column is
+// a primary key, the row is looked up directly, otherwise the column is scanned.
+func (txn *Txn) WithUUID(column string, id [16]byte) *Txn {
+	defer txn.trace("WithUUID", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	switch ids := c.Column.(type) {
+	case *columnUUIDKey:
+		idx, found := ids.OffsetOfUUID(id)
+		txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+			if !found || commit.ChunkAt(idx) != chunk {
+				index.Clear()
+				return
+			}
+
+			at := idx - chunk.Min()
+			match := index.Contains(at)
+			index.Clear()
+			if match {
+				index.Set(at)
+			}
+		})
+	case *columnUUID:
+		txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+			ids.FilterUUID(chunk, index, func(v [16]byte) bool {
+				return v == id
+			})
+		})
+	default:
+		txn.index.Clear()
+	}
+	return txn
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwUUID represents read-write accessor for UUIDs
+type rwUUID struct {
+	rdUUID
+	writer *commit.Buffer
+}
+
+// Set sets the value at the current transaction cursor
+func (s rwUUID) Set(value [16]byte) {
+	s.writer.PutBytes(commit.Put, *s.cursor, value[:])
+}
+
+// UUID returns a UUID column accessor
+func (txn *Txn) UUID(columnName string) rwUUID {
+	return rwUUID{
+		rdUUID: readUUIDOf(txn, columnName),
+		writer: txn.bufferFor(columnName),
+	}
+}
+
+// uuidReader represents a column which can load UUIDs
+type uuidReader interface {
+	Column
+	LoadUUID(idx uint32) ([16]byte, bool)
+}
+
+// rdUUID represents a read-only accessor for UUIDs
+type rdUUID reader[uuidReader]
+
+// Get loads the value at the current transaction cursor
+func (s rdUUID) Get() ([16]byte, bool) {
+	return s.reader.LoadUUID(*s.cursor)
+}
+
+// readUUIDOf creates a new UUID reader
+func readUUIDOf(txn *Txn, columnName string) rdUUID {
+	return rdUUID(readerFor[uuidReader](txn, columnName))
+}
+
+// --------------------------- Encoding ----------------------------
+
+// decodeUUID decodes a UUID from either its raw or its canonical string form
+func decodeUUID(b []byte) (out [16]byte, ok bool) {
+	switch len(b) {
+	case 16:
+		copy(out[:], b)
+		return out, true
+	case 36:
+		if b[8] != '-' || b[13] != '-' || b[18] != '-' || b[23] != '-' {
+			return out, false
+		}
+
+		at := 0
+		for _, x := range [...]int{0, 2, 4, 6, 9, 11, 14, 16, 19, 21, 24, 26, 28, 30, 32, 34} {
+			if _, err := hex.Decode(out[at:at+1], b[x:x+2]); err != nil {
+				return out, false
+			}
+			at++
+		}
+		return out, true
+	default:
+		return out, false
+	}
+}
+
+// formatUUID encodes a UUID into its canonical string form
+func formatUUID(v [16]byte) string {
+	var out [36]byte
+	hex.Encode(out[0:8], v[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], v[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], v[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], v[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:36], v[10:16])
+	return string(out[:])
+}
@@ -0,0 +1,290 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- Vector ----------------------------
+
+// columnVector represents a column which stores a fixed-length vector of float32 per row,
+// such as an embedding. The vectors of a chunk are stored contiguously, along with their
+// norms, so that the similarity with a query vector can be computed in a single pass.
+type columnVector struct {
+	dim    int           // The number of dimensions of every vector
+	chunks []vectorChunk // The chunks of vectors
+}
+
+// vectorChunk represents the vectors of a single chunk
+type vectorChunk struct {
+	fill bitmap.Bitmap // The fill-list
+	data []float32     // The vectors, allocated on the first write
+	norm []float32     // The norm of every vector
+}
+
+// ForVector creates a new column which stores fixed-length vectors of float32 with the
+// specified number of dimensions, and which can be searched using Nearest on the
+// transaction.
+func ForVector(dim int) Column {
+	if dim <= 0 {
+		panic(fmt.Errorf("column: vector dimension must be positive, got %d", dim))
+	}
+
+	return &columnVector{
+		dim:    dim,
+		chunks: make([]vectorChunk, 0, 4),
+	}
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnVector) Grow(idx uint32) {
+	chunk := int(commit.ChunkAt(idx))
+	for i := len(c.chunks); i <= chunk; i++ {
+		c.chunks = append(c.chunks, vectorChunk{
+			fill: make(bitmap.Bitmap, chunkSize/64),
+		})
+	}
+}
+
+// Apply applies a set of operations to the column.
+func (c *columnVector) Apply(chunk commit.Chunk, r *commit.Reader) {
+	segment := &c.chunks[chunk]
+	for r.Next() {
+		offset := r.IndexAtChunk()
+		switch r.Type {
+		case commit.Put:
+			value := r.Bytes()
+			if len(value) != 4*c.dim {
+				continue
+			}
+
+			// Allocate the vectors of the chunk lazily, as they can be large
+			if segment.data == nil {
+				segment.data = make([]float32, chunkSize*c.dim)
+				segment.norm = make([]float32, chunkSize)
+			}
+
+			vector := segment.data[int(offset)*c.dim : int(offset+1)*c.dim]
+			decodeVector(vector, value)
+			segment.norm[offset] = float32(math.Sqrt(float64(dot(vector, vector))))
+			segment.fill[offset>>6] |= 1 << (offset & 0x3f)
+		case commit.Delete:
+			segment.fill.Remove(offset)
+		}
+	}
+}
+
+// Value retrieves a value at a specified index
+func (c *columnVector) Value(idx uint32) (any, bool) {
+	return c.LoadVector(idx)
+}
+
+// LoadVector retrieves a copy of the vector at a specified index
+func (c *columnVector) LoadVector(idx uint32) ([]float32, bool) {
+	chunk := commit.ChunkAt(idx)
+	offset := int(idx - chunk.Min())
+	if int(chunk) >= len(c.chunks) || !c.chunks[chunk].fill.Contains(uint32(offset)) {
+		return nil, false
+	}
+
+	out := make([]float32, c.dim)
+	copy(out, c.chunks[chunk].data[offset*c.dim:])
+	return out, true
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnVector) Contains(idx uint32) bool {
+	chunk := commit.ChunkAt(idx)
+	return int(chunk) < len(c.chunks) && c.chunks[chunk].fill.Contains(idx-chunk.Min())
+}
+
+// Index returns the fill list for the segment
+func (c *columnVector) Index(chunk commit.Chunk) (fill bitmap.Bitmap) {
+	if int(chunk) < len(c.chunks) {
+		fill = c.chunks[chunk].fill
+	}
+	return
+}
+
+// accepts checks whether a value can be stored in the column.
+func (c *columnVector) accepts(value any) bool {
+	v, ok := value.([]float32)
+	return ok && len(v) == c.dim
+}
+
+// encode encodes a vector into its binary representation
+func (c *columnVector) encode(value any) (any, error) {
+	v, ok := value.([]float32)
+	if !ok || len(v) != c.dim {
+		return nil, fmt.Errorf("column: unable to encode %T as a vector of %d dimensions", value, c.dim)
+	}
+	return appendVector(nil, v), nil
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnVector) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	var buffer []byte
+	segment := c.chunks[chunk]
+	segment.fill.Range(func(x uint32) {
+		buffer = appendVector(buffer[:0], segment.data[int(x)*c.dim:int(x+1)*c.dim])
+		dst.PutBytes(commit.Put, chunk.Min()+x, buffer)
+	})
+}
+
+// --------------------------- Nearest ----------------------------
+
+// Neighbor represents a row returned by a nearest-neighbour search.
+type Neighbor struct {
+	Index uint32  // The index of the row
+	Score float32 // The cosine similarity with the query, between -1 and 1
+}
+
+// Nearest returns the k rows of the current selection whose vectors are the most similar
+// to the query vector, according to their cosine similarity, ordered from the most to the
+// least similar. The rows without a vector are ignored. This can be combined with the
+// filters of the transaction for a hybrid search.
+func (txn *Txn) Nearest(column string, query []float32, k int) ([]Neighbor, error) {
+	defer txn.trace("Nearest", column)()
+	c, ok := txn.columnAt(column)
+	if !ok {
+		return nil, fmt.Errorf("column: unable to search, column '%s' does not exist", column)
+	}
+
+	vectors, ok := c.Column.(*columnVector)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("column: unable to search, column '%s' is not a vector", column)
+	case len(query) != vectors.dim:
+		return nil, fmt.Errorf("column: unable to search, query has %d dimensions instead of %d",
+			len(query), vectors.dim)
+	case k <= 0:
+		return nil, nil
+	}
+
+	norm := float32(math.Sqrt(float64(dot(query, query))))
+	if norm == 0 {
+		return nil, fmt.Errorf("column: unable to search, query vector is zero")
+	}
+
+	top := make(neighbors, 0, k)
+	txn.initialize()
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		if int(chunk) >= len(vectors.chunks) {
+			return
+		}
+
+		offset := chunk.Min()
+		segment := vectors.chunks[chunk]
+		index.Range(func(x uint32) {
+			if !segment.fill.Contains(x) || segment.norm[x] == 0 {
+				return
+			}
+
+			vector := segment.data[int(x)*vectors.dim : int(x+1)*vectors.dim]
+			score := dot(query, vector) / (norm * segment.norm[x])
+			switch {
+			case len(top) < k:
+				heap.Push(&top, Neighbor{Index: offset + x, Score: score})
+			case score > top[0].Score:
+				top[0] = Neighbor{Index: offset + x, Score: score}
+				heap.Fix(&top, 0)
+			}
+		})
+	})
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Score != top[j].Score {
+			return top[i].Score > top[j].Score
+		}
+		return top[i].Index < top[j].Index
+	})
+	return top, nil
+}
+
+// neighbors represents a min-heap of the neighbours, by their score
+type neighbors []Neighbor
+
+func (h neighbors) Len() int           { return len(h) }
+func (h neighbors) Less(i, j int) bool { return h[i].Score < h[j].Score }
+func (h neighbors) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *neighbors) Push(x any)        { *h = append(*h, x.(Neighbor)) }
+func (h *neighbors) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// dot computes the dot product of two vectors of the same length. The loop is unrolled
+// so that the compiler keeps four independent accumulators.
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// --------------------------- Reader/Writer ----------------------------
+
+// rwVector represents read-write accessor for vector values
+type rwVector struct {
+	rdVector
+	writer *commit.Buffer
+}
+
+// Set sets the vector at the current transaction cursor. The vector must have the
+// number of dimensions of the column, otherwise it is ignored.
+func (s rwVector) Set(value []float32) {
+	s.writer.PutBytes(commit.Put, *s.cursor, appendVector(nil, value))
+}
+
+// Vector returns a vector column accessor
+func (txn *Txn) Vector(columnName string) rwVector {
+	return rwVector{
+		rdVector: rdVector(readerFor[*columnVector](txn, columnName)),
+		writer:   txn.bufferFor(columnName),
+	}
+}
+
+// rdVector represents a read-only accessor for vector values
+type rdVector reader[*columnVector]
+
+// Get loads a copy of the vector at the current transaction cursor
+func (s rdVector) Get() ([]float32, bool) {
+	return s.reader.LoadVector(*s.cursor)
+}
+
+// --------------------------- Encoding ----------------------------
+
+// appendVector appends a vector encoded as a sequence of little-endian float32
+func appendVector(dst []byte, value []float32) []byte {
+	for _, v := range value {
+		dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(v))
+	}
+	return dst
+}
+
+// decodeVector decodes a sequence of little-endian float32 into the destination vector
+func decodeVector(dst []float32, b []byte) {
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+var (
+	// ErrVersionConflict is returned when a row was modified since the expected version was read.
+	ErrVersionConflict = errors.New("column: row version conflict")
+	errNotVersioned    = errors.New("column: versioning is disabled for this collection")
+)
+
+// --------------------------- Version (Row) ----------------------------
+
+// Version retrieves the current version of the row. The version is incremented every time
+// the row is modified and is only available if the collection was created with the
+// Versioned option.
+func (r Row) Version() (uint64, bool) {
+	if !r.txn.owner.opts.Versioned {
+		return 0, false
+	}
+
+	return r.Uint64(versionColumn)
+}
+
+// --------------------------- Version (Txn) ----------------------------
+
+// expectedVersion represents the version at which a row is expected to be when committing
+type expectedVersion struct {
+	index   uint32 // The index of the row
+	version uint64 // The expected version of the row
+}
+
+// QueryAtVersion jumps at a particular offset in the collection, similarly to QueryAt, but
+// only if the row is still at the expected version. The version is checked again when
+// the transaction is committed and the entire transaction fails with ErrVersionConflict
+// if the row was modified in the meantime, allowing compare-and-swap semantics.
+func (txn *Txn) QueryAtVersion(index uint32, version uint64, f func(Row) error) error {
+	if !txn.owner.opts.Versioned {
+		return errNotVersioned
+	}
+
+	return txn.QueryAt(index, func(r Row) error {
+		if current, _ := r.Version(); current != version {
+			return ErrVersionConflict
+		}
+
+		txn.expect = append(txn.expect, expectedVersion{
+			index:   index,
+			version: version,
+		})
+		return f(r)
+	})
+}
+
+// commitVersions increments the version of every row modified by the transaction. If the
+// transaction already contains the versions (e.g. replayed from a commit log), they are
+// kept as-is.
+func (txn *Txn) commitVersions() {
+	var rows bitmap.Bitmap
+	for _, u := range txn.updates {
+		switch {
+		case u.IsEmpty() || u.Column == rowColumn:
+			continue
+		case u.Column == versionColumn:
+			return
+		}
+
+		txn.reader.Seek(u)
+		for txn.reader.Next() {
+			rows.Set(txn.reader.Index())
+		}
+	}
+
+	if rows.Count() == 0 {
+		return
+	}
+
+	buffer := txn.bufferFor(versionColumn)
+	rows.Range(func(idx uint32) {
+		buffer.PutUint64(commit.Merge, idx, 1)
+	})
+}
+
+// lockExpected acquires the write locks of all the chunks which are modified by the
+// transaction or which contain a row with an expected version or a conditional update.
+// The shards are locked in ascending order, so that concurrent transactions can not
+// deadlock.
+func (txn *Txn) lockExpected() {
+	txn.dirty.Range(func(chunk uint32) {
+		txn.held.Set(chunk % shards)
+	})
+	for _, e := range txn.expect {
+		txn.held.Set(uint32(commit.ChunkAt(e.index)) % shards)
+	}
+	for _, u := range txn.deferred {
+		txn.held.Set(uint32(commit.ChunkAt(u.index)) % shards)
+	}
+
+	txn.held.Range(func(shard uint32) {
+		txn.owner.slock.Lock(uint(shard))
+	})
+	txn.locked = true
+}
+
+// unlockExpected releases the write locks acquired by lockExpected.
+func (txn *Txn) unlockExpected() {
+	txn.held.Range(func(shard uint32) {
+		txn.owner.slock.Unlock(uint(shard))
+	})
+	txn.held.Clear()
+	txn.locked = false
+}
+
+// checkVersions checks that none of the rows with an expected version were modified.
+func (txn *Txn) checkVersions() error {
+	column, ok := txn.owner.cols.Load(versionColumn)
+	if !ok {
+		return errNotVersioned
+	}
+
+	versions := column.Column.(*numericColumn[uint64])
+	for _, e := range txn.expect {
+		if current, _ := versions.load(e.index); current != e.version {
+			return ErrVersionConflict
+		}
+	}
+	return nil
+}
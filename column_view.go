@@ -0,0 +1,168 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// --------------------------- View ----------------------------
+
+// columnView represents a materialized view, which is a bitmap of the rows matching a
+// query. It is recomputed for every chunk which is modified by a commit.
+type columnView struct {
+	fill    bitmap.Bitmap       // The fill list for the view
+	query   func(txn *Txn) *Txn // The query which selects the rows of the view
+	aliases map[string]string   // The columns renamed since the view was created
+}
+
+// newView creates a new materialized view column.
+func newView(viewName string, query func(txn *Txn) *Txn) *column {
+	return columnFor(viewName, &columnView{
+		fill:  make(bitmap.Bitmap, 0, 4),
+		query: query,
+	})
+}
+
+// Grow grows the size of the column until we have enough to store
+func (c *columnView) Grow(idx uint32) {
+	c.fill.Grow(idx)
+}
+
+// Column returns the target name of the column. Views do not depend on a single
+// column, as they are refreshed whenever a chunk is modified.
+func (c *columnView) Column() string {
+	return ""
+}
+
+// Apply applies a set of operations to the column. Views are refreshed separately,
+// once all of the updates of the chunk have been applied.
+func (c *columnView) Apply(chunk commit.Chunk, r *commit.Reader) {}
+
+// Value retrieves a value at a specified index.
+func (c *columnView) Value(idx uint32) (v interface{}, ok bool) {
+	if idx < uint32(len(c.fill))<<6 {
+		v, ok = c.fill.Contains(idx), true
+	}
+	return
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnView) Contains(idx uint32) bool {
+	return c.fill.Contains(idx)
+}
+
+// Index returns the fill list for the column
+func (c *columnView) Index(chunk commit.Chunk) bitmap.Bitmap {
+	return chunk.OfBitmap(c.fill)
+}
+
+// Snapshot writes the entire column into the specified destination buffer. Views are
+// not written, since they are recomputed when the snapshot is restored.
+func (c *columnView) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {}
+
+// rename records that a column was renamed, so that the query of the view, which refers
+// to the columns by name, keeps reading the same column. The caller must hold the write
+// lock of every chunk.
+func (c *columnView) rename(oldName, newName string) {
+	if c.aliases == nil {
+		c.aliases = make(map[string]string, 1)
+	}
+
+	for name, target := range c.aliases {
+		if target == oldName {
+			c.aliases[name] = newName
+		}
+	}
+
+	c.aliases[oldName] = newName
+	delete(c.aliases, newName)
+}
+
+// refresh runs the query of the view on a single chunk and replaces the content of the
+// view for that chunk with the result. The caller must hold the lock of the chunk.
+func (c *columnView) refresh(owner *Collection, chunk commit.Chunk) {
+	txn := owner.txns.acquire(owner)
+	defer owner.txns.release(txn)
+	defer txn.reset()
+
+	// Restrict the transaction to the rows of this chunk, the chunk is already locked
+	txn.setup = true
+	txn.consistent = true
+	txn.from = chunk
+	for name, target := range c.aliases {
+		if column, ok := owner.cols.Load(target); ok {
+			txn.columns = append(txn.columns, columnCache{name: name, col: column})
+		}
+	}
+
+	txn.index.Clear()
+	txn.index.Grow(chunk.Max())
+	owner.lock.RLock()
+	copy(chunk.OfBitmap(txn.index), chunk.OfBitmap(owner.fill))
+	owner.lock.RUnlock()
+
+	// Replace the chunk of the view with the result of the query
+	result := c.query(txn)
+	dst := chunk.OfBitmap(c.fill)
+	for i := range dst {
+		dst[i] = 0
+	}
+	copy(dst, chunk.OfBitmap(result.index))
+}
+
+// --------------------------- Collection ----------------------------
+
+// CreateView creates a materialized view with a specified name, which contains the rows
+// selected by the query. The view is kept up to date as the chunks are modified by the
+// commits, and can be used as an index in a transaction, e.g. txn.With("rich_mages").
+// The query must only apply filters and must not write. A view can be dropped with
+// DropIndex.
+func (c *Collection) CreateView(viewName string, query func(txn *Txn) *Txn) error {
+	if query == nil || viewName == "" {
+		return fmt.Errorf("column: create view must specify name and query")
+	}
+
+	if _, ok := c.cols.Load(viewName); ok {
+		return fmt.Errorf("column: unable to create view, column '%v' already exists", viewName)
+	}
+
+	// Create and add the view column
+	view := newView(viewName, query)
+	c.lock.Lock()
+	view.Grow(uint32(c.opts.Capacity))
+	c.cols.Store(viewName, view)
+	c.lock.Unlock()
+
+	// Compute the view for every chunk, under a read lock of that chunk
+	for chunk := commit.Chunk(0); int(chunk) < c.chunks(); chunk++ {
+		c.readLockChunk(chunk)
+		c.refreshView(view, chunk)
+		c.slock.RUnlock(uint(chunk))
+	}
+	return nil
+}
+
+// refreshViews recomputes every view for every chunk. The caller must hold the write lock
+// of every chunk.
+func (c *Collection) refreshViews() {
+	chunks := c.chunks()
+	c.cols.Range(func(column *column) {
+		for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+			c.refreshView(column, chunk)
+		}
+	})
+}
+
+// refreshView recomputes the view for a specified chunk.
+func (c *Collection) refreshView(column *column, chunk commit.Chunk) {
+	if view, ok := column.Column.(*columnView); ok {
+		column.lock.RLock()
+		view.refresh(c, chunk)
+		column.lock.RUnlock()
+	}
+}
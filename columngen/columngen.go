@@ -0,0 +1,268 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package columngen generates random typed data for the columns of a collection, so
+// that benchmarks and performance issues can be reproduced with synthetic data which
+// resembles the real one.
+//
+//	columngen.Fill(players, 100000, columngen.Schema{
+//		"age":   {Min: 18, Max: 80, Distribution: columngen.Normal},
+//		"class": {Cardinality: 5, Distribution: columngen.Zipf, Prefix: "class-"},
+//		"guild": {Cardinality: 1000, NullRate: 0.3},
+//	})
+package columngen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kelindar/column"
+)
+
+// batchSize is the number of rows inserted per transaction
+const batchSize = 10000
+
+// Distribution represents the distribution of the generated values.
+type Distribution uint8
+
+// Various distributions
+const (
+	Uniform Distribution = iota // Values are uniformly distributed in the range
+	Normal                      // Values are normally distributed around the middle of the range
+	Zipf                        // Lower values are exponentially more frequent than higher ones
+)
+
+// Spec represents the specification of the values generated for a column.
+type Spec struct {
+	Distribution Distribution // The distribution of the values
+	Min, Max     float64      // The range of numeric values, in seconds since epoch for time
+	Cardinality  int          // The number of distinct values, unbounded for numbers if zero
+	NullRate     float64      // The probability of a value not being set, from 0 to 1
+	Prefix       string       // The prefix of the string values
+}
+
+// Schema represents the specifications of the values, keyed by column name.
+type Schema map[string]Spec
+
+// Generator represents a random data generator with its own random source.
+type Generator struct {
+	rng  *rand.Rand
+	keys int
+}
+
+// New creates a new generator with a specified seed. Generators with the same seed fill
+// the collections with the same data.
+func New(seed int64) *Generator {
+	return &Generator{
+		rng: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Fill inserts n rows into the collection with values generated according to the schema,
+// using a generator with a fixed seed.
+func Fill(c *column.Collection, n int, schema Schema) error {
+	return New(1).Fill(c, n, schema)
+}
+
+// Fill inserts n rows into the collection with values generated according to the schema.
+// The columns which are not in the schema are left empty. If the collection has a key
+// column, it must be present in the schema and a unique key is generated for every row.
+func (g *Generator) Fill(c *column.Collection, n int, schema Schema) error {
+	columns, key, err := g.resolve(c, schema)
+	if err != nil {
+		return err
+	}
+
+	for done := 0; done < n; {
+		size := batchSize
+		if n-done < size {
+			size = n - done
+		}
+
+		if err := c.Query(func(txn *column.Txn) error {
+			for i := 0; i < size; i++ {
+				if err := g.insert(txn, columns, key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		done += size
+	}
+	return nil
+}
+
+// insert inserts a single row with generated values
+func (g *Generator) insert(txn *column.Txn, columns []generator, key *generator) error {
+	fill := func(r column.Row) error {
+		for _, c := range columns {
+			if c.spec.NullRate > 0 && g.rng.Float64() < c.spec.NullRate {
+				continue
+			}
+			switch v := g.value(c).(type) {
+			case bool:
+				r.SetBool(c.name, v)
+			case time.Time:
+				r.SetTime(c.name, v)
+			default:
+				r.SetAny(c.name, v)
+			}
+		}
+		return nil
+	}
+
+	if key == nil {
+		_, err := txn.Insert(fill)
+		return err
+	}
+
+	g.keys++
+	return txn.InsertKey(key.spec.Prefix+strconv.Itoa(g.keys), fill)
+}
+
+// --------------------------- Values ----------------------------
+
+// generator represents a column to generate the values for
+type generator struct {
+	name string
+	kind string
+	spec Spec
+	zipf *rand.Zipf
+}
+
+// resolve validates the schema against the columns of the collection
+func (g *Generator) resolve(c *column.Collection, schema Schema) ([]generator, *generator, error) {
+	var key *generator
+	columns := make([]generator, 0, len(schema))
+	types := make(map[string]string, len(schema))
+	for _, info := range c.Columns() {
+		if !info.Computed {
+			types[info.Name] = info.Type
+		}
+	}
+
+	// Sort the columns so that the same seed generates the same data
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		spec := schema[name]
+		kind, ok := types[name]
+		switch {
+		case !ok:
+			return nil, nil, fmt.Errorf("columngen: column '%s' does not exist", name)
+		case !supported(kind):
+			return nil, nil, fmt.Errorf("columngen: column '%s' of type %s is not supported", name, kind)
+		case spec.Max < spec.Min:
+			return nil, nil, fmt.Errorf("columngen: column '%s' has an invalid range", name)
+		case kind == "key":
+			key = &generator{name: name, kind: kind, spec: spec}
+			continue
+		}
+
+		// Textual values must have a bounded cardinality
+		if spec.Cardinality <= 0 && (kind == "string" || kind == "enum") {
+			spec.Cardinality = 100
+		}
+
+		// The zipf distribution requires an upper bound for the ranks
+		c := generator{name: name, kind: kind, spec: spec}
+		if spec.Distribution == Zipf {
+			c.zipf = rand.NewZipf(g.rng, 1.1, 1, uint64(g.levels(c)-1))
+		}
+		columns = append(columns, c)
+	}
+
+	for _, info := range c.Columns() {
+		if info.Type == "key" && key == nil {
+			return nil, nil, fmt.Errorf("columngen: key column '%s' must be specified", info.Name)
+		}
+	}
+	return columns, key, nil
+}
+
+// supported checks whether values can be generated for a column type
+func supported(kind string) bool {
+	switch kind {
+	case "int", "int16", "int32", "int64", "uint", "uint16", "uint32", "uint64",
+		"float32", "float64", "string", "enum", "key", "bool", "time":
+		return true
+	default:
+		return false
+	}
+}
+
+// levels returns the number of distinct values to choose from
+func (g *Generator) levels(c generator) int {
+	switch {
+	case c.kind == "bool":
+		return 2
+	case c.spec.Cardinality > 0:
+		return c.spec.Cardinality
+	default:
+		return 1000
+	}
+}
+
+// sample samples a position in the range, from 0 to 1
+func (g *Generator) sample(c generator) float64 {
+	switch c.spec.Distribution {
+	case Normal:
+		return math.Max(0, math.Min(1, 0.5+g.rng.NormFloat64()/6))
+	case Zipf:
+		return float64(c.zipf.Uint64()) / float64(g.levels(c)-1)
+	default:
+		return g.rng.Float64()
+	}
+}
+
+// value generates a value for the column
+func (g *Generator) value(c generator) any {
+	u := g.sample(c)
+	if c.spec.Cardinality > 0 || c.kind == "bool" {
+		levels := g.levels(c)
+		level := math.Min(math.Floor(u*float64(levels)), float64(levels-1))
+		switch c.kind {
+		case "string", "enum":
+			return c.spec.Prefix + strconv.Itoa(int(level))
+		case "bool":
+			return level == 1
+		}
+		u = level / math.Max(1, float64(levels-1))
+	}
+
+	v := c.spec.Min + u*(c.spec.Max-c.spec.Min)
+	switch c.kind {
+	case "int":
+		return int(math.Round(v))
+	case "int16":
+		return int16(math.Round(v))
+	case "int32":
+		return int32(math.Round(v))
+	case "int64":
+		return int64(math.Round(v))
+	case "uint":
+		return uint(math.Round(v))
+	case "uint16":
+		return uint16(math.Round(v))
+	case "uint32":
+		return uint32(math.Round(v))
+	case "uint64":
+		return uint64(math.Round(v))
+	case "float32":
+		return float32(v)
+	case "time":
+		return time.Unix(0, int64(v*float64(time.Second)))
+	default:
+		return v
+	}
+}
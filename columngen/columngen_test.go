@@ -0,0 +1,113 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package columngen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFill(t *testing.T) {
+	players := newPlayers()
+	assert.NoError(t, Fill(players, 25000, Schema{
+		"age":     {Min: 18, Max: 80, Distribution: Normal},
+		"balance": {Min: 0, Max: 1000, Cardinality: 10},
+		"class":   {Cardinality: 5, Distribution: Zipf, Prefix: "class-"},
+		"guild":   {Cardinality: 100, NullRate: 0.5},
+		"active":  {},
+		"created": {Min: 0, Max: 1e9},
+	}))
+	assert.Equal(t, 25000, players.Count())
+
+	classes := make(map[string]int)
+	balances := make(map[float64]struct{})
+	guilds, active := 0, 0
+	assert.NoError(t, players.Query(func(txn *column.Txn) error {
+		age := txn.Int("age")
+		balance := txn.Float64("balance")
+		class := txn.Enum("class")
+		guild := txn.Enum("guild")
+		isActive := txn.Bool("active")
+		created := txn.Time("created")
+		return txn.Range(func(idx uint32) {
+			v, _ := age.Get()
+			assert.True(t, v >= 18 && v <= 80)
+			b, _ := balance.Get()
+			balances[b] = struct{}{}
+			c, _ := class.Get()
+			classes[c]++
+			if _, ok := guild.Get(); ok {
+				guilds++
+			}
+			if isActive.Get() {
+				active++
+			}
+			ts, ok := created.Get()
+			assert.True(t, ok)
+			assert.True(t, ts.Before(time.Unix(1e9+1, 0)))
+		})
+	}))
+
+	assert.Len(t, balances, 10)
+	assert.Len(t, classes, 5)
+	assert.Greater(t, classes["class-0"], classes["class-4"])
+	assert.InDelta(t, 12500, guilds, 1000)
+	assert.InDelta(t, 12500, active, 1000)
+}
+
+func TestFillDeterministic(t *testing.T) {
+	schema := Schema{
+		"age":   {Min: 18, Max: 80},
+		"guild": {Cardinality: 100, NullRate: 0.1},
+	}
+
+	a, b := newPlayers(), newPlayers()
+	assert.NoError(t, New(42).Fill(a, 100, schema))
+	assert.NoError(t, New(42).Fill(b, 100, schema))
+	for i := uint32(0); i < 100; i++ {
+		assert.NoError(t, a.QueryAt(i, func(r1 column.Row) error {
+			return b.QueryAt(i, func(r2 column.Row) error {
+				v1, _ := r1.Int("age")
+				v2, _ := r2.Int("age")
+				assert.Equal(t, v1, v2)
+				return nil
+			})
+		}))
+	}
+}
+
+func TestFillKey(t *testing.T) {
+	players := column.NewCollection()
+	assert.NoError(t, players.CreateColumn("id", column.ForKey()))
+	assert.NoError(t, players.CreateColumn("age", column.ForInt()))
+	assert.Error(t, Fill(players, 10, Schema{"age": {Max: 10}}))
+	assert.NoError(t, Fill(players, 10, Schema{"id": {Prefix: "player-"}, "age": {Max: 10}}))
+	assert.Equal(t, 10, players.Count())
+	assert.NoError(t, players.QueryKey("player-10", func(r column.Row) error {
+		return nil
+	}))
+}
+
+func TestFillInvalid(t *testing.T) {
+	players := newPlayers()
+	assert.Error(t, Fill(players, 10, Schema{"invalid": {}}))
+	assert.Error(t, Fill(players, 10, Schema{"age": {Min: 10, Max: 1}}))
+	assert.NoError(t, players.CreateColumn("data", column.ForBytes()))
+	assert.Error(t, Fill(players, 10, Schema{"data": {}}))
+}
+
+// newPlayers creates a new collection with a few columns
+func newPlayers() *column.Collection {
+	out := column.NewCollection()
+	out.CreateColumn("age", column.ForInt())
+	out.CreateColumn("balance", column.ForFloat64())
+	out.CreateColumn("class", column.ForEnum())
+	out.CreateColumn("guild", column.ForEnum())
+	out.CreateColumn("active", column.ForBool())
+	out.CreateColumn("created", column.ForTime())
+	return out
+}
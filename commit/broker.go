@@ -0,0 +1,159 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package commit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+)
+
+var _ Logger = new(Sink)
+
+// Publisher represents a contract for publishing messages into a partitioned topic of a
+// message broker, such as a Kafka topic or a set of NATS subjects, one per partition.
+type Publisher interface {
+	Publish(partition int, payload []byte) error
+}
+
+// Consumer represents a contract for consuming the messages published into a partitioned
+// topic of a message broker. Fetch blocks until a message is available or the context is
+// cancelled.
+type Consumer interface {
+	Fetch(ctx context.Context) (Message, error)
+}
+
+// Replayer represents a contract that a replica, such as a collection, must implement to
+// apply the commits consumed from a message broker.
+type Replayer interface {
+	Replay(commit Commit) error
+}
+
+// Message represents a message consumed from a partition of a message broker
+type Message struct {
+	Partition int    // The partition of the message
+	Offset    uint64 // The offset of the message within its partition
+	Payload   []byte // The encoded commit
+}
+
+// --------------------------- Sink ----------------------------
+
+// Sink represents a commit logger which publishes every commit into a message broker. The
+// commits are partitioned by chunk, so the commits of a chunk are always published into the
+// same partition and consumed in the order they were made.
+type Sink struct {
+	lock       sync.Mutex
+	publisher  Publisher
+	partitions int
+	buffer     bytes.Buffer
+}
+
+// NewSink creates a new commit logger which publishes the commits into the specified number
+// of partitions using the publisher.
+func NewSink(publisher Publisher, partitions int) *Sink {
+	if partitions <= 0 {
+		partitions = 1
+	}
+
+	return &Sink{
+		publisher:  publisher,
+		partitions: partitions,
+	}
+}
+
+// Append encodes the commit and publishes it into the partition of its chunk
+func (s *Sink) Append(commit Commit) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.buffer.Reset()
+	if _, err := commit.WriteTo(&s.buffer); err != nil {
+		return err
+	}
+
+	partition := int(commit.Chunk) % s.partitions
+	return s.publisher.Publish(partition, s.buffer.Bytes())
+}
+
+// --------------------------- Source ----------------------------
+
+// Source represents a consumer of the commits published by a Sink, which replays them into
+// a replica. It keeps track of the offset of the last message applied in each partition,
+// so the messages delivered more than once are skipped and the consumption can resume
+// from the offsets persisted along with a snapshot of the replica.
+type Source struct {
+	lock     sync.Mutex
+	consumer Consumer
+	target   Replayer
+	offsets  map[int]uint64
+}
+
+// NewSource creates a new source which replays the commits fetched by the consumer into the
+// target. The offsets are the offsets of the last messages already applied to the target,
+// per partition, and can be nil if the target is empty.
+func NewSource(consumer Consumer, target Replayer, offsets map[int]uint64) *Source {
+	source := &Source{
+		consumer: consumer,
+		target:   target,
+		offsets:  make(map[int]uint64, len(offsets)),
+	}
+
+	for partition, offset := range offsets {
+		source.offsets[partition] = offset
+	}
+	return source
+}
+
+// Run fetches and replays the commits until the context is cancelled or an error occurs,
+// such as a gap in the commits of a chunk. It returns nil once the context is cancelled.
+func (s *Source) Run(ctx context.Context) error {
+	for {
+		msg, err := s.consumer.Fetch(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if err := s.apply(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// apply decodes and replays a message, unless it was already applied
+func (s *Source) apply(msg Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if last, ok := s.offsets[msg.Partition]; ok && msg.Offset <= last {
+		return nil // Already applied
+	}
+
+	var commit Commit
+	if _, err := commit.ReadFrom(bytes.NewReader(msg.Payload)); err != nil {
+		return fmt.Errorf("commit: unable to decode message %d of partition %d, %w", msg.Offset, msg.Partition, err)
+	}
+
+	if err := s.target.Replay(commit); err != nil {
+		return err
+	}
+
+	s.offsets[msg.Partition] = msg.Offset
+	return nil
+}
+
+// Offsets returns the offsets of the last messages applied, per partition.
+func (s *Source) Offsets() map[int]uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	offsets := make(map[int]uint64, len(s.offsets))
+	for partition, offset := range s.offsets {
+		offsets[partition] = offset
+	}
+	return offsets
+}
@@ -5,6 +5,7 @@ package commit
 
 import (
 	"encoding"
+	"encoding/binary"
 	"fmt"
 	"math"
 
@@ -111,6 +112,41 @@ func (b *Buffer) IsEmpty() bool {
 	return len(b.buffer) == 0
 }
 
+// Size returns the size of the encoded operations, in bytes.
+func (b *Buffer) Size() int {
+	return len(b.buffer)
+}
+
+// Mark represents a position in the buffer, to which it can be truncated back.
+type Mark struct {
+	last   int32 // The last offset written
+	chunk  Chunk // The current chunk
+	size   int   // The size of the buffer
+	chunks int   // The number of chunk headers
+}
+
+// Mark returns the current position in the buffer.
+func (b *Buffer) Mark() Mark {
+	return Mark{
+		last:   b.last,
+		chunk:  b.chunk,
+		size:   len(b.buffer),
+		chunks: len(b.chunks),
+	}
+}
+
+// Truncate discards all of the operations written after the specified mark.
+func (b *Buffer) Truncate(m Mark) {
+	if m.size > len(b.buffer) || m.chunks > len(b.chunks) {
+		return // The buffer was reset since
+	}
+
+	b.last = m.last
+	b.chunk = m.chunk
+	b.buffer = b.buffer[:m.size]
+	b.chunks = b.chunks[:m.chunks]
+}
+
 // Range iterates over the chunks present in the buffer
 func (b *Buffer) RangeChunks(fn func(chunk Chunk)) {
 	for _, c := range b.chunks {
@@ -283,6 +319,28 @@ func (b *Buffer) PutBitmap(op OpType, chunk Chunk, value bitmap.Bitmap) {
 	})
 }
 
+// PutFrom appends the current operation of the reader, along with its value, at a
+// different index.
+func (b *Buffer) PutFrom(r *Reader, idx uint32) {
+	b.putRaw(r.Type, idx, r.buffer[r.i0:r.i1], r.isString())
+}
+
+// putRaw appends an operation along with its encoded value.
+func (b *Buffer) putRaw(op OpType, idx uint32, value []byte, isString bool) {
+	switch {
+	case isString:
+		b.PutBytes(op, idx, value)
+	case len(value) == 8:
+		b.writeUint64(op, idx, binary.BigEndian.Uint64(value))
+	case len(value) == 4:
+		b.writeUint32(op, idx, binary.BigEndian.Uint32(value))
+	case len(value) == 2:
+		b.writeUint16(op, idx, binary.BigEndian.Uint16(value))
+	default:
+		b.PutOperation(op, idx)
+	}
+}
+
 // writeUint64 appends a uint64 value.
 func (b *Buffer) writeUint64(op OpType, idx uint32, value uint64) {
 	delta := b.writeChunk(idx)
@@ -5,6 +5,7 @@ package commit
 
 import (
 	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"reflect"
 	"unsafe"
@@ -73,6 +74,27 @@ func (b *Buffer) ReadFrom(src io.Reader) (int64, error) {
 	return r.Offset(), nil
 }
 
+// --------------------------- Checksum ----------------------------
+
+// crcTable is the table of the CRC-32 checksums, using the Castagnoli polynomial
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum returns the CRC-32 checksum of the column, the chunk headers and the operations
+// of the buffer, which can be used to verify its integrity once it is read back.
+func (b *Buffer) Checksum() uint32 {
+	crc := crc32.Update(0, crcTable, toBytes(b.Column))
+
+	var temp [12]byte
+	for _, v := range b.chunks {
+		binary.BigEndian.PutUint32(temp[0:4], uint32(v.Chunk))
+		binary.BigEndian.PutUint32(temp[4:8], v.Start)
+		binary.BigEndian.PutUint32(temp[8:12], v.Value)
+		crc = crc32.Update(crc, crcTable, temp[:])
+	}
+
+	return crc32.Update(crc, crcTable, b.buffer)
+}
+
 // readChunksFrom reads the list of chunks from the reader
 func readChunksFrom(r *iostream.Reader) ([]header, error) {
 	size, err := r.ReadUvarint()
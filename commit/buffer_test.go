@@ -229,6 +229,62 @@ func TestBufferClone(t *testing.T) {
 	assert.EqualValues(t, buf, cloned)
 }
 
+func TestBufferTruncate(t *testing.T) {
+	buf := NewBuffer(0)
+	buf.PutInt16(Put, 10, 100)
+	expect := buf.Clone()
+
+	// Write in the same and in a different chunk, then truncate
+	mark := buf.Mark()
+	buf.PutString(Put, 20, "hello")
+	buf.PutInt16(Put, 70000, 200)
+	buf.Truncate(mark)
+	assert.EqualValues(t, expect, buf)
+
+	// Subsequent writes are read back correctly
+	buf.PutInt16(Put, 11, 300)
+	reader := NewReader()
+	reader.Seek(buf)
+	assert.True(t, reader.Next())
+	assert.Equal(t, 10, int(reader.Offset))
+	assert.True(t, reader.Next())
+	assert.Equal(t, 11, int(reader.Offset))
+	assert.Equal(t, int16(300), reader.Int16())
+	assert.False(t, reader.Next())
+
+	// Truncating a reset buffer is a no-op
+	buf.Reset("")
+	buf.Truncate(mark)
+	assert.True(t, buf.IsEmpty())
+}
+
+func TestBufferPutFrom(t *testing.T) {
+	src := NewBuffer(0)
+	src.PutString(Put, 5, "hi")
+	src.PutUint16(Put, 6, 16)
+	src.PutUint32(Merge, 7, 32)
+	src.PutUint64(Put, 8, 64)
+	src.PutBool(9, true)
+	src.PutOperation(Delete, 10)
+
+	// Copy every operation at a different index
+	dst := NewBuffer(0)
+	reader := NewReader()
+	reader.Seek(src)
+	for reader.Next() {
+		dst.PutFrom(reader, reader.Index()+70000)
+	}
+
+	expect := NewBuffer(0)
+	expect.PutString(Put, 70005, "hi")
+	expect.PutUint16(Put, 70006, 16)
+	expect.PutUint32(Merge, 70007, 32)
+	expect.PutUint64(Put, 70008, 64)
+	expect.PutBool(70009, true)
+	expect.PutOperation(Delete, 70010)
+	assert.Equal(t, expect, dst)
+}
+
 func TestPutNil(t *testing.T) {
 	buf := NewBuffer(0)
 	buf.PutAny(PutTrue, 0, nil)
@@ -290,6 +346,25 @@ func TestBufferWriteToFailures(t *testing.T) {
 	}
 }
 
+func TestBufferChecksum(t *testing.T) {
+	input := NewBuffer(0)
+	input.Column = "test"
+	input.PutInt16(Put, 10, 100)
+	input.PutString(Put, 20, "hello")
+
+	buffer := bytes.NewBuffer(nil)
+	_, err := input.WriteTo(buffer)
+	assert.NoError(t, err)
+
+	output := NewBuffer(0)
+	_, err = output.ReadFrom(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, input.Checksum(), output.Checksum())
+
+	output.PutString(Put, 30, "world")
+	assert.NotEqual(t, input.Checksum(), output.Checksum())
+}
+
 func TestBufferReadFromFailures(t *testing.T) {
 	input := NewBuffer(0)
 	input.Column = "test"
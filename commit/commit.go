@@ -30,6 +30,9 @@ const (
 	chunkSize   = 1 << chunkShift
 )
 
+// ChunkSize is the number of rows in a chunk
+const ChunkSize = chunkSize
+
 // Chunk represents a chunk number
 type Chunk uint32
 
@@ -76,12 +79,22 @@ func min(v1, v2 int32) int32 {
 // in the same transaction, it would result in multiple commits per transaction.
 type Commit struct {
 	ID      uint64    // The commit ID
+	Prev    uint64    // The ID of the previous commit of the chunk, zero if unknown
+	Source  uint64    // The identifier of the collection which made the commit, zero if unknown
 	Chunk   Chunk     // The chunk number
 	Updates []*Buffer // The update buffers
 }
 
+// extended is written in place of the chunk number when the encoding of a commit also
+// contains the previous commit ID and the source. Since chunk numbers are 32-bit, it can not be mistaken
+// for one when reading the commits encoded without it.
+const extended = 1 << 32
+
 // Clone clones a commit into a new one
 func (c *Commit) Clone() (clone Commit) {
+	clone.ID = c.ID
+	clone.Prev = c.Prev
+	clone.Source = c.Source
 	clone.Chunk = c.Chunk
 	for _, u := range c.Updates {
 		if len(u.buffer) > 0 {
@@ -91,20 +104,47 @@ func (c *Commit) Clone() (clone Commit) {
 	return
 }
 
+// Iterate decodes the operations of the commit and calls the provided function for each of
+// them, along with the index of the row, the name of the column and the value. Since the
+// commit does not carry the schema, the fixed-size values are decoded as the unsigned
+// integer of their size (uint16, uint32 or uint64), which can be converted to the type of
+// the column, for example with math.Float64frombits. The variable-size values are decoded
+// as strings, while the operations without a value, such as the insertions, deletions and
+// boolean values encoded as PutTrue or PutFalse, are given a nil value.
+func (c *Commit) Iterate(fn func(op OpType, idx uint32, column string, value any)) {
+	reader := NewReader()
+	for _, u := range c.Updates {
+		reader.Range(u, c.Chunk, func(r *Reader) {
+			for r.Next() {
+				fn(r.Type, r.Index(), u.Column, r.value())
+			}
+		})
+	}
+}
+
 // WriteTo writes data to w until there's no more data to write or when an error occurs. The return
 // value n is the number of bytes written. Any error encountered during the write is also returned.
 func (c *Commit) WriteTo(dst io.Writer) (int64, error) {
 	w := iostream.NewWriter(dst)
 
-	// Write the chunk ID
+	// Write the marker of the extended encoding, followed by the chunk ID
+	if err := w.WriteUvarint(extended); err != nil {
+		return w.Offset(), err
+	}
 	if err := w.WriteUvarint(uint64(c.Chunk)); err != nil {
 		return w.Offset(), err
 	}
 
-	// Write the commit ID
+	// Write the commit ID, the previous one and the source
 	if err := w.WriteUvarint(c.ID); err != nil {
 		return w.Offset(), err
 	}
+	if err := w.WriteUvarint(c.Prev); err != nil {
+		return w.Offset(), err
+	}
+	if err := w.WriteUvarint(c.Source); err != nil {
+		return w.Offset(), err
+	}
 
 	// Write all of the columns for the current chunk
 	reader := NewReader()
@@ -155,18 +195,33 @@ func (c *Commit) WriteTo(dst io.Writer) (int64, error) {
 func (c *Commit) ReadFrom(src io.Reader) (int64, error) {
 	r := iostream.NewReader(src)
 
-	// Read chunk ID
+	// Read chunk ID, which is preceded by a marker in the extended encoding
 	chunk, err := r.ReadUvarint()
+	isExtended := chunk == extended
+	if err == nil && isExtended {
+		chunk, err = r.ReadUvarint()
+	}
+
 	c.Chunk = Chunk(chunk)
 	if err != nil {
 		return r.Offset(), err
 	}
 
-	// Read commit ID
+	// Read commit ID, along with the previous one and the source if present
 	if c.ID, err = r.ReadUvarint(); err != nil {
 		return r.Offset(), err
 	}
 
+	c.Prev, c.Source = 0, 0
+	if isExtended {
+		if c.Prev, err = r.ReadUvarint(); err != nil {
+			return r.Offset(), err
+		}
+		if c.Source, err = r.ReadUvarint(); err != nil {
+			return r.Offset(), err
+		}
+	}
+
 	// Read each update buffer in the commit
 	if err := r.ReadRange(func(i int, r *iostream.Reader) error {
 		buffer := NewBuffer(256)
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/kelindar/bitmap"
+	"github.com/kelindar/iostream"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +36,9 @@ func BenchmarkColumn(b *testing.B) {
 
 func TestCommitClone(t *testing.T) {
 	commit := Commit{
+		ID:     2,
+		Prev:   1,
+		Source: 3,
 		Updates: []*Buffer{{
 			buffer: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
 			chunks: []header{{
@@ -47,6 +51,29 @@ func TestCommitClone(t *testing.T) {
 	assert.EqualValues(t, commit, clone)
 }
 
+func TestCommitIterate(t *testing.T) {
+	rows, names, ages := NewBuffer(0), NewBuffer(0), NewBuffer(0)
+	rows.Reset("row")
+	rows.PutOperation(Insert, 5)
+	names.Reset("name")
+	names.PutString(Put, 5, "Roman")
+	ages.Reset("age")
+	ages.PutInt32(Merge, 5, 30)
+	ages.PutInt64(Put, chunkSize, 10) // Another chunk
+
+	var ops []string
+	commit := Commit{Updates: []*Buffer{rows, names, ages}}
+	commit.Iterate(func(op OpType, idx uint32, column string, value any) {
+		ops = append(ops, fmt.Sprintf("%s %s[%d]=%v", op, column, idx, value))
+	})
+
+	assert.Equal(t, []string{
+		"insert row[5]=<nil>",
+		"put name[5]=Roman",
+		"merge age[5]=30",
+	}, ops)
+}
+
 func TestWriterChannel(t *testing.T) {
 	w := make(Channel, 1)
 	w.Append(Commit{
@@ -141,8 +168,10 @@ func TestMin(t *testing.T) {
 func TestCommitCodec(t *testing.T) {
 	buffer := bytes.NewBuffer(nil)
 	input := Commit{
-		ID:    Next(),
-		Chunk: 0,
+		ID:     Next(),
+		Prev:   1,
+		Source: 2,
+		Chunk:  0,
 		Updates: []*Buffer{
 			newInterleaved("a"),
 			newInterleaved("b"),
@@ -151,7 +180,7 @@ func TestCommitCodec(t *testing.T) {
 
 	// Write into the buffer
 	n, err := input.WriteTo(buffer)
-	assert.Equal(t, int64(197), n)
+	assert.Equal(t, int64(204), n)
 	assert.NoError(t, err)
 
 	// Read the commit back
@@ -162,6 +191,8 @@ func TestCommitCodec(t *testing.T) {
 
 	// Make sure commit can be read back
 	assert.Equal(t, input.ID, output.ID)
+	assert.Equal(t, input.Prev, output.Prev)
+	assert.Equal(t, input.Source, output.Source)
 	assert.Equal(t, input.Chunk, output.Chunk)
 
 	updates := make([]int64, 0, 64)
@@ -174,6 +205,20 @@ func TestCommitCodec(t *testing.T) {
 	assert.Equal(t, []int64{20, 1, 21, 2, 40, 4, 41, 5, 60, 7, 61, 8}, updates)
 }
 
+func TestCommitCodecLegacy(t *testing.T) {
+	buffer := bytes.NewBuffer(nil)
+	w := iostream.NewWriter(buffer)
+	assert.NoError(t, w.WriteUvarint(3))  // Chunk
+	assert.NoError(t, w.WriteUvarint(42)) // Commit ID
+	assert.NoError(t, w.WriteUvarint(0))  // Updates
+
+	// Read the commit encoded without the previous commit ID
+	output := Commit{Prev: 1, Source: 2}
+	_, err := output.ReadFrom(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, Commit{ID: 42, Chunk: 3}, output)
+}
+
 // newInterleaved creates a new interleaved buffer
 func newInterleaved(columnName string) *Buffer {
 	buf := NewBuffer(10)
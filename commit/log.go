@@ -96,8 +96,7 @@ func (l *Log) Range(fn func(Commit) error) error {
 	defer l.lock.Unlock()
 
 	for {
-		var commit Commit
-		_, err := commit.ReadFrom(l.reader)
+		commit, err := l.read()
 		switch {
 		case err == io.EOF:
 			return nil
@@ -112,6 +111,12 @@ func (l *Log) Range(fn func(Commit) error) error {
 	}
 }
 
+// read reads the next commit of the log, the lock must be held
+func (l *Log) read() (commit Commit, err error) {
+	_, err = commit.ReadFrom(l.reader)
+	return
+}
+
 // Name calls the corresponding Name() method on the underlying source
 func (l *Log) Name() (name string) {
 	if file, ok := l.source.(interface {
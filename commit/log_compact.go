@@ -0,0 +1,179 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package commit
+
+import (
+	"io"
+	"sort"
+
+	"github.com/kelindar/iostream"
+	"github.com/klauspost/compress/s2"
+)
+
+// RowColumn is the name of the column which holds the insertions and deletions of rows
+const RowColumn = "row"
+
+// Compact reads the commits of the log and writes a compacted log into the destination,
+// containing a single commit per chunk. For every row and column, the operations which
+// are superseded by a later put or delete are dropped, while the merges are kept in order
+// since they depend on the previous value. The rows which are both inserted and deleted
+// within the log are removed entirely. Replaying the compacted log results in the same
+// state as replaying the original one. The compacted commit of a chunk takes the ID of its
+// last commit, and remains chained to the previous ones if they all come from the same
+// source.
+func (l *Log) Compact(dst io.Writer) error {
+	state := make(map[Chunk]*compactChunk)
+	reader := NewReader()
+	if err := l.Range(func(commit Commit) error {
+		chunk, ok := state[commit.Chunk]
+		if !ok {
+			chunk = &compactChunk{
+				prev:    commit.Prev,
+				source:  commit.Source,
+				rows:    make(map[uint32][]operation),
+				columns: make(map[string]map[uint32][]operation),
+			}
+			state[commit.Chunk] = chunk
+		}
+
+		// The commits of several sources can not be chained together once compacted
+		if commit.ID > chunk.id {
+			chunk.id = commit.ID
+		}
+		if commit.Source != chunk.source {
+			chunk.prev, chunk.source = 0, 0
+		}
+
+		// The rows are inserted and deleted before the columns are updated
+		for _, u := range commit.Updates {
+			if u.Column == RowColumn {
+				reader.Range(u, commit.Chunk, chunk.markers)
+			}
+		}
+		for _, u := range commit.Updates {
+			if u.Column != RowColumn {
+				reader.Range(u, commit.Chunk, chunk.column(u.Column))
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Write a commit for each chunk, in order
+	chunks := make([]Chunk, 0, len(state))
+	for chunk := range state {
+		chunks = append(chunks, chunk)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i] < chunks[j] })
+
+	writer := iostream.NewWriter(s2.NewWriter(dst))
+	for _, chunk := range chunks {
+		commit := state[chunk].encode(chunk)
+		if len(commit.Updates) == 0 {
+			continue
+		}
+
+		if _, err := commit.WriteTo(writer); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// operation represents an operation of the log, along with its encoded value
+type operation struct {
+	kind     OpType // The type of the operation
+	value    []byte // The encoded value
+	isString bool   // Whether the value is a variable-size one
+}
+
+// compactChunk represents the compacted operations of a chunk
+type compactChunk struct {
+	id      uint64                            // The last commit ID of the chunk
+	prev    uint64                            // The commit ID preceding the compacted ones
+	source  uint64                            // The source of the commits, zero if several
+	rows    map[uint32][]operation            // The insertions and deletions of the rows
+	columns map[string]map[uint32][]operation // The operations of each column
+	order   []string                          // The columns, in order of appearance
+}
+
+// markers folds the insertions and deletions of the rows
+func (c *compactChunk) markers(r *Reader) {
+	for r.Next() {
+		idx := r.Index()
+		op := operation{kind: r.Type}
+		switch {
+		case r.Type != Delete:
+			c.rows[idx] = append(c.rows[idx], op)
+		case len(c.rows[idx]) > 0 && c.rows[idx][0].kind == Insert:
+			delete(c.rows, idx) // Inserted within the log, so it never existed
+		default:
+			c.rows[idx] = []operation{op}
+		}
+
+		// Once deleted, the previous values of the row are no longer needed
+		if r.Type == Delete {
+			for _, values := range c.columns {
+				delete(values, idx)
+			}
+		}
+	}
+}
+
+// column returns a function which folds the operations of a column
+func (c *compactChunk) column(name string) func(r *Reader) {
+	values, ok := c.columns[name]
+	if !ok {
+		values = make(map[uint32][]operation)
+		c.columns[name] = values
+		c.order = append(c.order, name)
+	}
+
+	return func(r *Reader) {
+		for r.Next() {
+			idx := r.Index()
+			op := operation{kind: r.Type, value: r.Bytes(), isString: r.isString()}
+			switch r.Type {
+			case Merge, Skip:
+				values[idx] = append(values[idx], op)
+			default:
+				values[idx] = []operation{op} // A put or a delete supersedes the previous ones
+			}
+		}
+	}
+}
+
+// encode encodes the compacted operations of the chunk into a commit
+func (c *compactChunk) encode(chunk Chunk) Commit {
+	commit := Commit{ID: c.id, Prev: c.prev, Source: c.source, Chunk: chunk}
+	if len(c.rows) > 0 {
+		commit.Updates = append(commit.Updates, encodeOps(RowColumn, c.rows))
+	}
+
+	for _, name := range c.order {
+		if values := c.columns[name]; len(values) > 0 {
+			commit.Updates = append(commit.Updates, encodeOps(name, values))
+		}
+	}
+	return commit
+}
+
+// encodeOps encodes the operations of a column into a buffer, ordered by index
+func encodeOps(column string, ops map[uint32][]operation) *Buffer {
+	index := make([]uint32, 0, len(ops))
+	for idx := range ops {
+		index = append(index, idx)
+	}
+	sort.Slice(index, func(i, j int) bool { return index[i] < index[j] })
+
+	buffer := NewBuffer(len(index))
+	buffer.Reset(column)
+	for _, idx := range index {
+		for _, op := range ops[idx] {
+			buffer.putRaw(op.kind, idx, op.value, op.isString)
+		}
+	}
+	return buffer
+}
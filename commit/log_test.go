@@ -5,6 +5,7 @@ package commit
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -138,3 +139,182 @@ func TestLogOpenFileInvalid(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, logger)
 }
+
+func TestLogCompact(t *testing.T) {
+	rows, names, scores := NewBuffer(0), NewBuffer(0), NewBuffer(0)
+	rows.Reset(RowColumn)
+	names.Reset("name")
+	scores.Reset("score")
+
+	// Insert three rows, then update and delete some of them
+	for i := uint32(0); i < 3; i++ {
+		rows.PutOperation(Insert, i)
+		names.PutString(Put, i, fmt.Sprintf("row %d", i))
+	}
+
+	logger := Open(bytes.NewBuffer(nil))
+	assert.NoError(t, logger.Append(Commit{ID: 1, Prev: 0, Updates: []*Buffer{rows.Clone(), names.Clone()}}))
+
+	rows.Reset(RowColumn)
+	names.Reset("name")
+	names.PutString(Put, 0, "updated")
+	scores.PutInt64(Put, 0, 10)
+	scores.PutInt64(Merge, 0, 5)
+	rows.PutOperation(Delete, 1)
+	rows.PutOperation(Delete, 5)
+	assert.NoError(t, logger.Append(Commit{ID: 2, Prev: 1, Updates: []*Buffer{names, scores, rows}}))
+
+	dst := bytes.NewBuffer(nil)
+	assert.NoError(t, logger.Compact(dst))
+
+	// Read back the compacted log
+	var commits []Commit
+	assert.NoError(t, Open(dst).Range(func(commit Commit) error {
+		commits = append(commits, commit)
+		return nil
+	}))
+	assert.Len(t, commits, 1)
+	assert.Equal(t, uint64(2), commits[0].ID)
+	assert.Equal(t, uint64(0), commits[0].Prev)
+
+	ops := make(map[string][]string)
+	reader := NewReader()
+	for _, u := range commits[0].Updates {
+		reader.Seek(u)
+		for reader.Next() {
+			op := fmt.Sprintf("%s %d", reader.Type, reader.Index())
+			switch u.Column {
+			case "name":
+				op += " " + reader.String()
+			case "score":
+				op += fmt.Sprintf(" %d", reader.Int64())
+			}
+			ops[u.Column] = append(ops[u.Column], op)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		RowColumn: {"insert 0", "insert 2", "delete 5"},
+		"name":    {"put 0 updated", "put 2 row 2"},
+		"score":   {"put 0 10", "merge 0 5"},
+	}, ops)
+}
+
+func TestMergeLogs(t *testing.T) {
+	first, second := Open(bytes.NewBuffer(nil)), Open(bytes.NewBuffer(nil))
+	for _, id := range []int{1, 3, 5} {
+		assert.NoError(t, first.Append(newCommit(id)))
+	}
+	for _, id := range []int{2, 4} {
+		assert.NoError(t, second.Append(newCommit(id)))
+	}
+
+	var arr []uint64
+	assert.NoError(t, MergeLogs(first, second, Open(bytes.NewBuffer(nil))).Range(func(commit Commit) error {
+		arr = append(arr, commit.ID)
+		return nil
+	}))
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, arr)
+}
+
+func TestMergeLogsStopOnError(t *testing.T) {
+	first := Open(bytes.NewBuffer(nil))
+	assert.NoError(t, first.Append(newCommit(1)))
+	assert.NoError(t, first.Append(newCommit(2)))
+
+	assert.Error(t, MergeLogs(first).Range(func(commit Commit) error {
+		return io.ErrClosedPipe
+	}))
+
+	// A corrupted log is reported
+	assert.Error(t, MergeLogs(Open(bytes.NewBuffer([]byte{0xff, 0x06}))).Range(func(commit Commit) error {
+		return nil
+	}))
+}
+
+// --------------------------- Broker ----------------------------
+
+func TestSinkSource(t *testing.T) {
+	broker := newBroker(4)
+	sink := NewSink(broker, 4)
+	for i := 1; i <= 8; i++ {
+		commit := newCommit(i)
+		commit.Chunk = Chunk(i % 3)
+		assert.NoError(t, sink.Append(commit))
+	}
+
+	assert.Len(t, broker.partitions[0], 2)
+	assert.Len(t, broker.partitions[1], 3)
+	assert.Len(t, broker.partitions[2], 3)
+	assert.Len(t, broker.partitions[3], 0)
+
+	// Redeliver the first messages of partition 1, which must be skipped
+	replica := new(replayer)
+	source := NewSource(broker, replica, map[int]uint64{2: 0})
+	broker.messages = append(broker.messages, Message{Partition: 1, Offset: 0, Payload: broker.partitions[1][0]})
+	assert.Equal(t, io.EOF, source.Run(context.Background()))
+
+	assert.Len(t, replica.commits, 7)
+	assert.Equal(t, map[int]uint64{0: 1, 1: 2, 2: 2}, source.Offsets())
+	for _, commit := range replica.commits {
+		assert.Equal(t, "a", commit.Updates[0].Column)
+	}
+
+	// Invalid payload
+	broker.messages = []Message{{Partition: 3, Offset: 0, Payload: []byte{0xff}}}
+	assert.Error(t, source.Run(context.Background()))
+
+	// Cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, source.Run(ctx))
+}
+
+// broker represents an in-memory message broker
+type broker struct {
+	partitions [][][]byte
+	messages   []Message
+}
+
+// newBroker creates a new in-memory broker with the number of partitions
+func newBroker(partitions int) *broker {
+	return &broker{partitions: make([][][]byte, partitions)}
+}
+
+// Publish appends a message into the partition
+func (b *broker) Publish(partition int, payload []byte) error {
+	msg := Message{
+		Partition: partition,
+		Offset:    uint64(len(b.partitions[partition])),
+		Payload:   append([]byte(nil), payload...),
+	}
+
+	b.partitions[partition] = append(b.partitions[partition], msg.Payload)
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+// Fetch returns the next message, or io.EOF once there are no more messages
+func (b *broker) Fetch(ctx context.Context) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+
+	if len(b.messages) == 0 {
+		return Message{}, io.EOF
+	}
+
+	msg := b.messages[0]
+	b.messages = b.messages[1:]
+	return msg, nil
+}
+
+// replayer records the commits replayed
+type replayer struct {
+	commits []Commit
+}
+
+func (r *replayer) Replay(commit Commit) error {
+	r.commits = append(r.commits, commit)
+	return nil
+}
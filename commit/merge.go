@@ -0,0 +1,71 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package commit
+
+import (
+	"io"
+)
+
+// Merged represents several commit logs merged into a single stream of commits
+type Merged struct {
+	logs []*Log
+}
+
+// MergeLogs merges several commit logs into a single stream, where the commits are ordered
+// by their commit ID regardless of their chunk. Each of the logs, for example one per topic
+// partition or one per rotated file, is expected to be ordered itself.
+func MergeLogs(logs ...*Log) *Merged {
+	return &Merged{logs: logs}
+}
+
+// Range iterates over the commits of all the logs, in the order of their commit ID, and
+// calls the provided callback function on each of them. If the callback returns an error,
+// the iteration will stop.
+func (m *Merged) Range(fn func(Commit) error) error {
+	heads := make([]*Commit, len(m.logs))
+	for i := range m.logs {
+		if err := m.advance(heads, i); err != nil {
+			return err
+		}
+	}
+
+	for {
+		next := -1
+		for i, head := range heads {
+			if head != nil && (next < 0 || head.ID < heads[next].ID) {
+				next = i
+			}
+		}
+
+		if next < 0 {
+			return nil // All of the logs were read
+		}
+
+		if err := fn(*heads[next]); err != nil {
+			return err
+		}
+
+		if err := m.advance(heads, next); err != nil {
+			return err
+		}
+	}
+}
+
+// advance reads the next commit of a log into the heads, or clears it at the end of the log
+func (m *Merged) advance(heads []*Commit, i int) error {
+	log := m.logs[i]
+	log.lock.Lock()
+	commit, err := log.read()
+	log.lock.Unlock()
+	switch {
+	case err == io.EOF:
+		heads[i] = nil
+		return nil
+	case err != nil:
+		return err
+	default:
+		heads[i] = &commit
+		return nil
+	}
+}
@@ -33,6 +33,14 @@ func (r *Reader) Seek(b *Buffer) {
 	r.use(b.buffer)
 }
 
+// Column returns the name of the column of the buffer being read, if any.
+func (r *Reader) Column() string {
+	if r.parent == nil {
+		return ""
+	}
+	return r.parent.Column
+}
+
 // Rewind rewinds the reader back to zero.
 func (r *Reader) Rewind() {
 	r.use(r.buffer)
@@ -393,6 +401,27 @@ func (r *Reader) readOffset() {
 	}
 }
 
+// value decodes the current value, without knowing its actual type.
+func (r *Reader) value() any {
+	switch {
+	case r.isString():
+		return string(r.Bytes()) // Copy, since the buffer may be reused
+	case r.i1-r.i0 == 8:
+		return r.Uint64()
+	case r.i1-r.i0 == 4:
+		return r.Uint32()
+	case r.i1-r.i0 == 2:
+		return r.Uint16()
+	default:
+		return nil
+	}
+}
+
+// isString returns whether the current value is a variable-size one.
+func (r *Reader) isString() bool {
+	return r.headString+3 == r.i0
+}
+
 // readFixed reads the fixed-size value at the current position.
 func (r *Reader) readFixed(v byte) {
 	size := int(1 << (v >> 4 & 0b11) & 0b1110)
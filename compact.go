@@ -0,0 +1,141 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// shrinker represents a column which can release the memory of its trailing chunks
+type shrinker interface {
+	shrink(from commit.Chunk) int
+}
+
+// Compact moves the rows of the collection into the lowest free indexes, so that they are
+// contiguous, and releases the memory of the trailing chunks which are no longer used. The
+// primary keys and indexes are updated accordingly, but the indexes of the moved rows
+// change. It returns the approximate number of bytes freed. The compaction should not run
+// concurrently with other transactions which insert rows.
+func (c *Collection) Compact() (freed int) {
+	c.Query(func(txn *Txn) error {
+		txn.moveRows(c.reserveMoves())
+		return nil
+	})
+
+	// Lock the entire collection and release the unused chunks, unless a spilled chunk can
+	// not be reloaded, in which case the error was reported and nothing is released
+	defer c.writeUnlockAll()
+	if err := c.writeLockAll(); err != nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	keep := commit.ChunkAt(uint32(c.opts.Capacity-1)) + 1
+	if last, ok := c.fill.Max(); ok && commit.ChunkAt(last) >= keep {
+		keep = commit.ChunkAt(last) + 1
+	}
+
+	if int(keep) < len(c.commits) {
+		c.commits = c.commits[:keep]
+	}
+
+	c.cols.Range(func(column *column) {
+		if s, ok := column.Column.(shrinker); ok {
+			freed += s.shrink(keep)
+		}
+	})
+	return
+}
+
+// reserveMoves finds the rows which are located after the first N indexes, where N is the
+// number of rows, and reserves a free index for each of them.
+func (c *Collection) reserveMoves() map[uint32]uint32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	count := uint32(c.fill.Count())
+	moves := make(map[uint32]uint32)
+	c.fill.Range(func(idx uint32) {
+		if idx >= count {
+			moves[idx] = 0
+		}
+	})
+
+	// Assign the free indexes in ascending order
+	var src bitmap.Bitmap
+	for idx := range moves {
+		src.Set(idx)
+	}
+
+	next := uint32(0)
+	src.Range(func(idx uint32) {
+		for c.fill.Contains(next) {
+			next++
+		}
+
+		c.fill.Set(next)
+		moves[idx] = next
+	})
+	return moves
+}
+
+// moveRows copies the values of the rows to their new index and deletes the old ones. The
+// new indexes are recorded as insertions first, so that they are released if the move is
+// aborted or the transaction fails to commit.
+func (txn *Txn) moveRows(moves map[uint32]uint32) {
+	if len(moves) == 0 {
+		return
+	}
+
+	var src bitmap.Bitmap
+	for idx := range moves {
+		src.Set(idx)
+	}
+
+	rows := txn.bufferFor(rowColumn)
+	src.Range(func(idx uint32) {
+		rows.PutOperation(commit.Insert, moves[idx])
+	})
+
+	// Copy the values of every moved row, chunk by chunk
+	buffer := txn.owner.txns.acquirePage("")
+	defer txn.owner.txns.releasePage(buffer)
+	limit, _ := src.Max()
+	for chunk := commit.Chunk(0); chunk <= commit.ChunkAt(limit); chunk++ {
+		if chunk.OfBitmap(src).Count() == 0 {
+			continue
+		}
+
+		if err := txn.owner.readLockChunk(chunk); err != nil {
+			txn.owner.slock.RUnlock(uint(chunk))
+			txn.abort(err)
+			return
+		}
+
+		txn.owner.cols.Range(func(column *column) {
+			switch {
+			case isComputed(column) || column.name == generationColumn:
+				return // The moved rows keep the generation of their new index
+			case !column.Snapshot(chunk, buffer):
+				return
+			}
+
+			txn.reader.Seek(buffer)
+			for txn.reader.Next() {
+				if dst, ok := moves[txn.reader.Index()]; ok {
+					txn.bufferFor(column.name).PutFrom(txn.reader, dst)
+				}
+			}
+		})
+		txn.owner.slock.RUnlock(uint(chunk))
+	}
+
+	// Delete the rows at their old index
+	src.Range(func(idx uint32) {
+		rows.PutOperation(commit.Delete, idx)
+	})
+}
@@ -0,0 +1,236 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package debug provides an HTTP handler which can be mounted on a mux in order to
+// inspect the state of collections at runtime. It lists the registered collections,
+// their schema and statistics, looks up rows by key and runs read-only filter queries.
+//
+//	console := debug.New()
+//	console.Register("players", players)
+//	mux.Handle("/debug/column/", http.StripPrefix("/debug/column", console))
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kelindar/column"
+)
+
+// defaultLimit is the default number of rows returned by a query
+const defaultLimit = 100
+
+// Handler represents a debug console serving the registered collections over HTTP. All
+// of the operations are read-only.
+type Handler struct {
+	lock        sync.RWMutex
+	collections map[string]*column.Collection
+}
+
+// New creates a new debug console handler.
+func New() *Handler {
+	return &Handler{
+		collections: make(map[string]*column.Collection),
+	}
+}
+
+// Register adds a collection to the console under the specified name.
+func (h *Handler) Register(name string, collection *column.Collection) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.collections[name] = collection
+}
+
+// Unregister removes a collection from the console.
+func (h *Handler) Unregister(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.collections, name)
+}
+
+// load loads a registered collection by its name
+func (h *Handler) load(name string) (*column.Collection, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	collection, ok := h.collections[name]
+	return collection, ok
+}
+
+// ServeHTTP serves the following read-only endpoints:
+//
+//	GET /                         lists the collections
+//	GET /{collection}             returns the schema and statistics of a collection
+//	GET /{collection}/keys/{key}  returns the row with the specified primary key
+//	GET /{collection}/query?q=... returns the rows matching a filter expression
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if path[0] == "" {
+		h.serveList(w)
+		return
+	}
+
+	collection, ok := h.load(path[0])
+	if !ok {
+		WriteError(w, http.StatusNotFound, fmt.Errorf("collection '%s' does not exist", path[0]))
+		return
+	}
+
+	switch {
+	case len(path) == 1:
+		h.serveInfo(w, path[0], collection)
+	case len(path) == 3 && path[1] == "keys":
+		h.serveKey(w, collection, path[2])
+	case len(path) == 2 && path[1] == "query":
+		h.serveQuery(w, r, collection)
+	default:
+		WriteError(w, http.StatusNotFound, fmt.Errorf("path '%s' does not exist", r.URL.Path))
+	}
+}
+
+// serveList writes the list of the collections
+func (h *Handler) serveList(w http.ResponseWriter) {
+	h.lock.RLock()
+	out := make([]collectionInfo, 0, len(h.collections))
+	for name, collection := range h.collections {
+		out = append(out, collectionInfo{
+			Name: name,
+			Rows: collection.Count(),
+		})
+	}
+	h.lock.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	writeJSON(w, out)
+}
+
+// serveInfo writes the schema and statistics of a collection
+func (h *Handler) serveInfo(w http.ResponseWriter, name string, collection *column.Collection) {
+	info := collectionInfo{
+		Name:    name,
+		Rows:    collection.Count(),
+		Columns: collection.Columns(),
+		Caches:  make(map[string]column.CacheStats),
+	}
+
+	for _, c := range info.Columns {
+		if stats, ok := collection.CacheStats(c.Name); ok {
+			info.Caches[c.Name] = stats
+		}
+	}
+	writeJSON(w, info)
+}
+
+// serveKey writes the row with the specified primary key
+func (h *Handler) serveKey(w http.ResponseWriter, collection *column.Collection, key string) {
+	var row map[string]any
+	if err := collection.QueryKey(key, func(r column.Row) error {
+		row = ReadRow(r.Index(), collection, func(name string) (any, bool) {
+			return r.Any(name)
+		})
+		return nil
+	}); err != nil {
+		WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	writeJSON(w, row)
+}
+
+// serveQuery writes the rows matching the filter expression
+func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, collection *column.Collection) {
+	filter, err := Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := defaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid limit '%s'", v))
+			return
+		}
+	}
+
+	result := queryResult{Rows: make([]map[string]any, 0, 16)}
+	err = collection.Query(func(txn *column.Txn) error {
+		filter.Apply(txn)
+		result.Count = txn.Count()
+		return txn.Range(func(idx uint32) {
+			if len(result.Rows) < limit {
+				result.Rows = append(result.Rows, ReadRow(idx, collection, func(name string) (any, bool) {
+					return txn.Any(name).Get()
+				}))
+			}
+		})
+	})
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// ReadRow reads all of the non-computed columns of a row into a map, along with its index
+// under the "$index" key, using the read function to load the value of each column.
+func ReadRow(idx uint32, collection *column.Collection, read func(string) (any, bool)) map[string]any {
+	row := map[string]any{"$index": idx}
+	for _, c := range collection.Columns() {
+		if c.Computed {
+			continue
+		}
+
+		if v, ok := read(c.Name); ok {
+			row[c.Name] = v
+		}
+	}
+	return row
+}
+
+// --------------------------- Responses ----------------------------
+
+// collectionInfo represents the description of a collection
+type collectionInfo struct {
+	Name    string                       `json:"name"`
+	Rows    int                          `json:"rows"`
+	Columns []column.ColumnInfo          `json:"columns,omitempty"`
+	Caches  map[string]column.CacheStats `json:"caches,omitempty"`
+}
+
+// queryResult represents the result of a query
+type queryResult struct {
+	Count int              `json:"count"`
+	Rows  []map[string]any `json:"rows"`
+}
+
+// writeJSON writes the value as a JSON response
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// WriteError writes an error response, as a JSON object with an "error" field.
+func WriteError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": err.Error(),
+	})
+}
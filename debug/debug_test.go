@@ -0,0 +1,139 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	players := column.NewCollection()
+	players.CreateColumn("name", column.ForKey())
+	players.CreateColumn("class", column.ForEnum())
+	players.CreateColumn("age", column.ForInt())
+	players.CreateColumn("active", column.ForBool())
+	players.CreateIndex("mage", "class", func(r column.Reader) bool {
+		return r.String() == "mage"
+	})
+
+	for i, name := range []string{"alice", "bob", "carol"} {
+		i := i
+		players.InsertKey(name, func(r column.Row) error {
+			r.SetEnum("class", []string{"mage", "rogue", "mage"}[i])
+			r.SetInt("age", 20+i*10)
+			r.SetBool("active", i != 1)
+			return nil
+		})
+	}
+
+	console := New()
+	console.Register("players", players)
+	console.Register("empty", column.NewCollection())
+	console.Register("removed", column.NewCollection())
+	console.Unregister("removed")
+
+	var list []collectionInfo
+	assert.Equal(t, http.StatusOK, get(t, console, "/", &list))
+	assert.Equal(t, []collectionInfo{{Name: "empty"}, {Name: "players", Rows: 3}}, list)
+
+	var info collectionInfo
+	assert.Equal(t, http.StatusOK, get(t, console, "/players", &info))
+	assert.Equal(t, 3, info.Rows)
+	assert.Contains(t, info.Columns, column.ColumnInfo{Name: "age", Type: "int"})
+	assert.Contains(t, info.Columns, column.ColumnInfo{Name: "mage", Type: "index", Computed: true})
+	assert.Contains(t, info.Caches, "class")
+
+	var row map[string]any
+	assert.Equal(t, http.StatusOK, get(t, console, "/players/keys/bob", &row))
+	assert.Equal(t, "rogue", row["class"])
+	assert.Equal(t, float64(30), row["age"])
+	assert.NotContains(t, row, "mage")
+
+	var result queryResult
+	assert.Equal(t, http.StatusOK, get(t, console, "/players/query?q=mage+and+age+>+20", &result))
+	assert.Equal(t, 1, result.Count)
+	assert.Equal(t, "carol", result.Rows[0]["name"])
+
+	assert.Equal(t, http.StatusOK, get(t, console, "/players/query?q=active=true&limit=1", &result))
+	assert.Equal(t, 2, result.Count)
+	assert.Len(t, result.Rows, 1)
+
+	assert.Equal(t, http.StatusOK, get(t, console, "/players/query", &result))
+	assert.Equal(t, 3, result.Count)
+
+	// Errors
+	assert.Equal(t, http.StatusNotFound, get(t, console, "/invalid", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, console, "/players/keys/dave", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, console, "/players/invalid", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, console, "/players/query?q=age+>", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, console, "/players/query?limit=x", nil))
+
+	w := httptest.NewRecorder()
+	console.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/players", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestParse(t *testing.T) {
+	filter, err := Parse("human AND !old and name = 'Roman' and age>=30")
+	assert.NoError(t, err)
+	assert.Equal(t, Filter{
+		{column: "human"},
+		{column: "old", negate: true},
+		{column: "name", op: "=", value: "Roman"},
+		{column: "age", op: ">=", value: "30"},
+	}, filter)
+
+	// The operators and keywords within quotes are part of the literal
+	filter, err = Parse(`name = 'a!=b' and title != "Tom and Jerry" and tag = '<x>'`)
+	assert.NoError(t, err)
+	assert.Equal(t, Filter{
+		{column: "name", op: "=", value: "a!=b"},
+		{column: "title", op: "!=", value: "Tom and Jerry"},
+		{column: "tag", op: "=", value: "<x>"},
+	}, filter)
+
+	for _, expr := range []string{"a and", "= 1", "a = ", "a b", "!", "a and and b", "'a = b'"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		clause clause
+		value  any
+		expect bool
+	}{
+		{clause{op: "=", value: "true"}, true, true},
+		{clause{op: "=", value: "x"}, true, false},
+		{clause{op: "!=", value: "a"}, "b", true},
+		{clause{op: "<", value: "b"}, "a", true},
+		{clause{op: ">", value: "10"}, float32(11), true},
+		{clause{op: "<=", value: "10"}, uint16(10), true},
+		{clause{op: "<", value: "10"}, int64(10), false},
+		{clause{op: "=", value: "x"}, int16(10), false},
+		{clause{op: "=", value: "[1]"}, []int{1}, true},
+		{clause{op: "?", value: "1"}, 1, false},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expect, tc.clause.match(tc.value), "%v %v", tc.clause, tc.value)
+	}
+}
+
+// get performs a GET request against the handler and decodes the response
+func get(t *testing.T, handler http.Handler, url string, out any) int {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if out != nil {
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(out))
+	}
+	return w.Code
+}
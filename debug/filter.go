@@ -0,0 +1,239 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kelindar/column"
+)
+
+// Filter represents a parsed filter expression, which is a set of clauses joined with
+// "and". A clause is either an index name (e.g. "human"), a negated index name (e.g.
+// "!human") or a comparison of a column with a literal (e.g. "age >= 30" or
+// "name = 'Roman'"). An empty expression matches every row.
+type Filter []clause
+
+// clause represents a single clause of the filter
+type clause struct {
+	column string // The column or index name
+	op     string // The comparison operator, empty for an index
+	value  string // The literal to compare with
+	negate bool   // Whether the index is negated
+}
+
+// operators contains the supported comparison operators, longest first
+var operators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// Parse parses a filter expression.
+func Parse(expr string) (Filter, error) {
+	var out Filter
+	for _, part := range splitAnd(expr) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("invalid filter '%s', empty clause", expr)
+		}
+
+		c, err := parseClause(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// splitAnd splits the expression on the "and" keyword, case-insensitively, unless it is
+// part of a quoted literal
+func splitAnd(expr string) []string {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+
+	var out []string
+	for {
+		lower := strings.ToLower(expr)
+		i := indexUnquoted(expr, func(at int) bool {
+			return strings.HasPrefix(lower[at:], " and ")
+		})
+		if i < 0 {
+			return append(out, expr)
+		}
+
+		out = append(out, expr[:i])
+		expr = expr[i+5:]
+	}
+}
+
+// parseClause parses a single clause of the filter
+func parseClause(part string) (clause, error) {
+	var op string
+	i := indexUnquoted(part, func(at int) bool {
+		for _, v := range operators {
+			if strings.HasPrefix(part[at:], v) {
+				op = v
+				return true
+			}
+		}
+		return false
+	})
+
+	if i > 0 {
+		name := strings.TrimSpace(part[:i])
+		value := strings.TrimSpace(part[i+len(op):])
+		if !isIdentifier(name) || value == "" {
+			return clause{}, fmt.Errorf("invalid clause '%s'", part)
+		}
+
+		return clause{column: name, op: op, value: unquote(value)}, nil
+	}
+
+	name := strings.TrimPrefix(part, "!")
+	if !isIdentifier(name) {
+		return clause{}, fmt.Errorf("invalid clause '%s'", part)
+	}
+	return clause{column: name, negate: name != part}, nil
+}
+
+// indexUnquoted returns the first position in the string, outside of the single or double
+// quoted sections, for which the function returns true, or -1 if there is none
+func indexUnquoted(v string, fn func(at int) bool) int {
+	var quote byte
+	for i := 0; i < len(v); i++ {
+		switch {
+		case quote != 0:
+			if v[i] == quote {
+				quote = 0
+			}
+		case v[i] == '\'' || v[i] == '"':
+			quote = v[i]
+		case fn(i):
+			return i
+		}
+	}
+	return -1
+}
+
+// isIdentifier checks whether the string is a valid column name
+func isIdentifier(v string) bool {
+	if v == "" {
+		return false
+	}
+
+	for _, r := range v {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unquote removes the single or double quotes around a literal
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '\'' || v[0] == '"') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// Apply applies the filter on the transaction
+func (f Filter) Apply(txn *column.Txn) {
+	for _, c := range f {
+		switch {
+		case c.op == "" && c.negate:
+			txn.Without(c.column)
+		case c.op == "":
+			txn.With(c.column)
+		default:
+			txn.WithValue(c.column, c.match)
+		}
+	}
+}
+
+// match compares the value of a column with the literal of the clause
+func (c clause) match(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		literal, err := strconv.ParseBool(c.value)
+		return err == nil && compare(c.op, boolToInt(v), boolToInt(literal))
+	case string:
+		return compare(c.op, strings.Compare(v, c.value), 0)
+	}
+
+	if number, ok := toFloat(v); ok {
+		literal, err := strconv.ParseFloat(c.value, 64)
+		switch {
+		case err != nil:
+			return false
+		case number < literal:
+			return compare(c.op, -1, 0)
+		case number > literal:
+			return compare(c.op, 1, 0)
+		default:
+			return compare(c.op, 0, 0)
+		}
+	}
+
+	return compare(c.op, strings.Compare(fmt.Sprint(v), c.value), 0)
+}
+
+// compare evaluates the operator on two ordered values
+func compare(op string, a, b int) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	default:
+		return false
+	}
+}
+
+// boolToInt converts a boolean to an integer for comparison
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// toFloat converts a numeric value to a float64
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/zeebo/xxh3"
+)
+
+// --------------------------- Enum ----------------------------
+
+// Distinct returns the distinct values of the enum column selected by this transaction,
+// in the order they were first added to the enum dictionary.
+func (s rwEnum) Distinct() []string {
+	seen := s.txn.distinctEnum(s.reader)
+	out := make([]string, 0, seen.Count())
+	seen.Range(func(at uint32) {
+		out = append(out, s.reader.readAt(at))
+	})
+	return out
+}
+
+// Values returns all of the values of the enum dictionary, regardless of the selection
+// of the transaction, indexed by their dictionary identifier. This may include values
+// which are no longer used by any row.
+func (s rwEnum) Values() []string {
+	return s.reader.Vocabulary()
+}
+
+// distinctEnum returns the set of dictionary locations of the selected rows
+func (txn *Txn) distinctEnum(enum *columnEnum) (seen bitmap.Bitmap) {
+	txn.initialize()
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		if int(chunk) >= len(enum.chunks) {
+			return
+		}
+
+		fill, locs := enum.chunkAt(chunk)
+		index.Range(func(x uint32) {
+			if fill.Contains(x) {
+				seen.Set(locs[x])
+			}
+		})
+	})
+	return
+}
+
+// --------------------------- Distinct Count ----------------------------
+
+// DistinctCount returns the number of distinct values of the column selected by this
+// transaction. The count is exact for enum and boolean columns, and is estimated with a
+// HyperLogLog sketch for any other column, with a typical error of about 1%.
+func (txn *Txn) DistinctCount(columnName string) int {
+	column, ok := txn.columnAt(columnName)
+	if !ok {
+		return 0
+	}
+
+	switch c := column.Column.(type) {
+	case *columnEnum:
+		return txn.distinctEnum(c).Count()
+	case *columnBool:
+		trues, falses := 0, 0
+		txn.initialize()
+		txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+			trues += countAnd(index, chunk.OfBitmap(c.data))
+			falses += countAnd(index, c.falses(chunk))
+		})
+
+		distinct := 0
+		if trues > 0 {
+			distinct++
+		}
+		if falses > 0 {
+			distinct++
+		}
+		return distinct
+	}
+
+	var sketch hyperLogLog
+	txn.initialize()
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		offset := chunk.Min()
+		index.Range(func(x uint32) {
+			if v, ok := column.Value(offset + x); ok {
+				sketch.Add(hashOf(v))
+			}
+		})
+	})
+	return sketch.Count()
+}
+
+// countAnd counts the number of bits which are set in both of the bitmaps
+func countAnd(a, b bitmap.Bitmap) (count int) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		count += bits.OnesCount64(a[i] & b[i])
+	}
+	return
+}
+
+// hashOf computes a hash of a column value
+func hashOf(value any) uint64 {
+	var buffer [8]byte
+	switch v := value.(type) {
+	case string:
+		return xxh3.HashString(v)
+	case []byte:
+		return xxh3.Hash(v)
+	case [16]byte:
+		return xxh3.Hash(v[:])
+	case time.Time:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v.UnixNano()))
+	case float64:
+		binary.LittleEndian.PutUint64(buffer[:], math.Float64bits(v))
+	case float32:
+		binary.LittleEndian.PutUint64(buffer[:], math.Float64bits(float64(v)))
+	case int:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case int16:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case int32:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case int64:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case uint:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case uint16:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case uint32:
+		binary.LittleEndian.PutUint64(buffer[:], uint64(v))
+	case uint64:
+		binary.LittleEndian.PutUint64(buffer[:], v)
+	default:
+		return xxh3.HashString(fmt.Sprint(v))
+	}
+	return xxh3.Hash(buffer[:])
+}
+
+// --------------------------- HyperLogLog ----------------------------
+
+// hllPrecision is the number of bits of the hash used to select a register
+const hllPrecision = 14
+
+// hyperLogLog represents a HyperLogLog sketch for estimating the cardinality of a set
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// Add adds a hashed value to the sketch
+func (h *hyperLogLog) Add(hash uint64) {
+	hllAdd(h.registers[:], hash)
+}
+
+// Count estimates the number of distinct values added to the sketch
+func (h *hyperLogLog) Count() int {
+	return hllCount(h.registers[:])
+}
+
+// hllRank returns the register selected by a hashed value and the rank of the value, for
+// a number of registers which is a power of two.
+func hllRank(registers int, hash uint64) (at uint64, rank uint8) {
+	precision := bits.TrailingZeros(uint(registers))
+	at = hash >> (64 - precision)
+	rank = uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1))) + 1
+	return
+}
+
+// hllAdd adds a hashed value to the registers of a sketch
+func hllAdd(registers []uint8, hash uint64) {
+	if at, rank := hllRank(len(registers), hash); rank > registers[at] {
+		registers[at] = rank
+	}
+}
+
+// hllCount estimates the number of distinct values added to the registers of a sketch
+func hllCount(registers []uint8) int {
+	m := float64(len(registers))
+	sum, zeros := 0.0, 0
+	for _, r := range registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	// Use the linear counting for small cardinalities, where it is more accurate
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+// --------------------------- Facets ----------------------------
+
+// facet represents the running counts of a single facet column
+type facet struct {
+	column *column         // The column of the facet
+	counts map[string]int  // The counts per value
+	locs   []int           // The counts per dictionary location, for enum columns
+	only   map[string]bool // The values to count, or nil for all of the values
+}
+
+// Facet counts the occurrences of the values of multiple columns over the rows selected
+// by this transaction, in a single pass. The facets map the column names to the values to
+// count, or to nil in order to count all of the values of the column. Columns which do not
+// exist are ignored.
+func (txn *Txn) Facet(facets map[string][]string) map[string]map[string]int {
+	list := make([]*facet, 0, len(facets))
+	out := make(map[string]map[string]int, len(facets))
+	for name, values := range facets {
+		column, ok := txn.columnAt(name)
+		if !ok {
+			continue
+		}
+
+		f := &facet{column: column, counts: make(map[string]int, len(values))}
+		if values != nil {
+			f.only = make(map[string]bool, len(values))
+			for _, v := range values {
+				f.only[v] = true
+				f.counts[v] = 0
+			}
+		}
+
+		list = append(list, f)
+		out[name] = f.counts
+	}
+
+	txn.initialize()
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		for _, f := range list {
+			f.count(chunk, index)
+		}
+	})
+
+	for _, f := range list {
+		f.flush()
+	}
+	return out
+}
+
+// count counts the values of the facet column within the chunk
+func (f *facet) count(chunk commit.Chunk, index bitmap.Bitmap) {
+	offset := chunk.Min()
+	switch c := f.column.Column.(type) {
+	case *columnEnum:
+		if int(chunk) >= len(c.chunks) {
+			return
+		}
+
+		fill, locs := c.chunkAt(chunk)
+		index.Range(func(x uint32) {
+			if !fill.Contains(x) {
+				return
+			}
+
+			at := int(locs[x])
+			if at >= len(f.locs) {
+				f.locs = append(f.locs, make([]int, at-len(f.locs)+1)...)
+			}
+			f.locs[at]++
+		})
+	case Textual:
+		index.Range(func(x uint32) {
+			if v, ok := c.LoadString(offset + x); ok {
+				f.add(v, 1)
+			}
+		})
+	default:
+		index.Range(func(x uint32) {
+			if v, ok := c.Value(offset + x); ok {
+				f.add(fmt.Sprint(v), 1)
+			}
+		})
+	}
+}
+
+// flush resolves the counts per dictionary location into the counts per value
+func (f *facet) flush() {
+	if enum, ok := f.column.Column.(*columnEnum); ok {
+		for at, count := range f.locs {
+			if count > 0 {
+				f.add(enum.readAt(uint32(at)), count)
+			}
+		}
+	}
+}
+
+// add adds to the count of a value, unless the value is not part of the facet
+func (f *facet) add(value string, count int) {
+	if f.only == nil || f.only[value] {
+		f.counts[value] += count
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// ChangeSummary represents the changes which would be made by a transaction.
+type ChangeSummary struct {
+	Inserted int                      // The number of rows which would be inserted
+	Updated  int                      // The number of existing rows which would be modified
+	Deleted  int                      // The number of rows which would be deleted
+	Columns  map[string]ColumnChanges // The changes for each of the affected columns
+}
+
+// ColumnChanges represents the changes which would be made to a single column.
+type ColumnChanges struct {
+	Inserted int // The number of values which would be written for inserted rows
+	Updated  int // The number of values which would be written for existing rows
+	Deleted  int // The number of values which would be removed by deleting rows
+}
+
+// IsEmpty returns whether the transaction would not change anything.
+func (s ChangeSummary) IsEmpty() bool {
+	return s.Inserted == 0 && s.Updated == 0 && s.Deleted == 0
+}
+
+// DryRun executes the transaction similarly to Query, but never commits it. Instead, it
+// returns a summary of the rows which would be inserted, updated and deleted, for each
+// of the columns. This can be used to validate risky operations before running them.
+func (c *Collection) DryRun(fn func(txn *Txn) error) (ChangeSummary, error) {
+	txn := c.txns.acquire(c)
+	defer c.txns.release(txn)
+
+	err := fn(txn)
+	summary := txn.summarize()
+
+	txn.freeInserts()
+	txn.rollback()
+	if err != nil {
+		return ChangeSummary{}, err
+	}
+	return summary, nil
+}
+
+// freeInserts releases the rows reserved by the inserts, when they will never be committed
+func (txn *Txn) freeInserts() {
+	if rows, ok := txn.findMarkers(); ok {
+		for idx := range insertsOf(rows) {
+			txn.owner.free(idx)
+		}
+	}
+}
+
+// summarize computes the summary of the pending changes of the transaction
+func (txn *Txn) summarize() ChangeSummary {
+	summary := ChangeSummary{
+		Columns: make(map[string]ColumnChanges, len(txn.updates)),
+	}
+
+	// Find the inserted and deleted rows
+	var inserts, deletes, updates bitmap.Bitmap
+	if rows, ok := txn.findMarkers(); ok {
+		txn.reader.Seek(rows)
+		for txn.reader.Next() {
+			switch txn.reader.Type {
+			case commit.Insert:
+				inserts.Set(txn.reader.Index())
+				deletes.Remove(txn.reader.Index())
+			case commit.Delete:
+				deletes.Set(txn.reader.Index())
+			}
+		}
+	}
+
+	// Count the values written in each of the columns
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn {
+			continue
+		}
+
+		var written bitmap.Bitmap
+		txn.reader.Seek(u)
+		for txn.reader.Next() {
+			written.Set(txn.reader.Index())
+		}
+
+		changes := summary.Columns[u.Column]
+		written.Range(func(idx uint32) {
+			switch {
+			case deletes.Contains(idx):
+			case inserts.Contains(idx):
+				changes.Inserted++
+			default:
+				changes.Updated++
+				updates.Set(idx)
+			}
+		})
+		summary.Columns[u.Column] = changes
+	}
+
+	// Count the values which would be removed by the deletes
+	deletes.Range(func(idx uint32) {
+		chunk := commit.ChunkAt(idx)
+		txn.owner.readLockChunk(chunk)
+		txn.owner.cols.Range(func(column *column) {
+			if !isComputed(column) && column.Contains(idx) {
+				changes := summary.Columns[column.name]
+				changes.Deleted++
+				summary.Columns[column.name] = changes
+			}
+		})
+		txn.owner.slock.RUnlock(uint(chunk))
+	})
+
+	summary.Inserted = inserts.Count()
+	summary.Updated = updates.Count()
+	summary.Deleted = deletes.Count()
+	return summary
+}
@@ -0,0 +1,255 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// EvictionPolicy represents the order in which the rows are evicted from a collection
+// which exceeds the MaxRows or MaxBytes limit of its options.
+type EvictionPolicy uint8
+
+// Various eviction policies
+const (
+	EvictLRU EvictionPolicy = iota // Evicts the least recently accessed rows first
+	EvictTTL                       // Evicts the rows which expire the soonest first
+)
+
+// sampleRows is the number of rows sampled to estimate the size of a row
+const sampleRows = 64
+
+// evictor keeps track of the order in which the rows of a size-bounded collection were
+// accessed, in an intrusive list of the indexes from the least to the most recently
+// accessed row, and of whether an eviction is pending or in progress.
+type evictor struct {
+	lock    sync.Mutex
+	linked  bitmap.Bitmap // The rows which are part of the list
+	prev    []uint32      // The previous row in the list, per index (plus one)
+	next    []uint32      // The next row in the list, per index (plus one)
+	head    uint32        // The least recently accessed row (plus one)
+	tail    uint32        // The most recently accessed row (plus one)
+	pending int32         // Whether a commit inserted rows since the last eviction
+	running int32         // Whether an eviction is in progress
+}
+
+// touch moves a row to the back of the access list
+func (e *evictor) touch(idx uint32) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if int(idx) >= len(e.prev) {
+		size := int(idx) + 1
+		e.prev = append(e.prev, make([]uint32, size-len(e.prev))...)
+		e.next = append(e.next, make([]uint32, size-len(e.next))...)
+	}
+
+	e.unlink(idx)
+	e.linked.Set(idx)
+	e.prev[idx] = e.tail
+	e.next[idx] = 0
+	if e.tail != 0 {
+		e.next[e.tail-1] = idx + 1
+	} else {
+		e.head = idx + 1
+	}
+	e.tail = idx + 1
+}
+
+// unlink removes a row from the access list, if it is part of it
+func (e *evictor) unlink(idx uint32) {
+	if !e.linked.Contains(idx) {
+		return
+	}
+
+	prev, next := e.prev[idx], e.next[idx]
+	if prev != 0 {
+		e.next[prev-1] = next
+	} else {
+		e.head = next
+	}
+	if next != 0 {
+		e.prev[next-1] = prev
+	} else {
+		e.tail = prev
+	}
+	e.linked.Remove(idx)
+}
+
+// oldest returns up to n rows of the result set, from the least to the most recently
+// accessed one. The rows which were never accessed, such as the ones loaded from a
+// snapshot, come first. The deleted rows found along the way are removed from the list.
+func (e *evictor) oldest(index bitmap.Bitmap, n int, skip func(uint32) bool) []uint32 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	out := make([]uint32, 0, n)
+	untracked := index.Clone(nil)
+	untracked.AndNot(e.linked)
+	for blkAt := 0; blkAt < len(untracked) && len(out) < n; blkAt++ {
+		for blk := untracked[blkAt]; blk != 0 && len(out) < n; blk &= blk - 1 {
+			if idx := uint32(blkAt<<6 + bits.TrailingZeros64(blk)); !skip(idx) {
+				out = append(out, idx)
+			}
+		}
+	}
+
+	for at := e.head; at != 0 && len(out) < n; {
+		idx := at - 1
+		at = e.next[idx]
+		switch {
+		case !index.Contains(idx):
+			e.unlink(idx)
+		case !skip(idx):
+			out = append(out, idx)
+		}
+	}
+	return out
+}
+
+// --------------------------- Collection ----------------------------
+
+// headroom is the percentage of the limit which is freed by an eviction, so a collection
+// at its limit does not evict a few rows on every insertion.
+const headroom = 10
+
+// evictPending evicts the rows in excess, if a commit inserted rows since the last eviction
+func (c *Collection) evictPending() {
+	if c.evictor != nil && atomic.CompareAndSwapInt32(&c.evictor.pending, 1, 0) {
+		c.evict()
+	}
+}
+
+// evict deletes the rows in excess once a commit made the collection exceed its MaxRows
+// or MaxBytes limit, in the order of the eviction policy, until the collection is back to
+// its low-water mark, which leaves some headroom below the limit. The rows are deleted by
+// a regular transaction, so the deletions are observed by the triggers and written in the
+// commit log.
+func (c *Collection) evict() {
+	if !atomic.CompareAndSwapInt32(&c.evictor.running, 0, 1) {
+		atomic.StoreInt32(&c.evictor.pending, 1) // Retried by the next commit
+		return
+	}
+
+	defer atomic.StoreInt32(&c.evictor.running, 0)
+	c.Query(func(txn *Txn) error {
+		limit := txn.rowLimit()
+		if c.Count() <= limit {
+			return nil
+		}
+
+		excess := c.Count() - (limit - limit*headroom/100)
+		victims := txn.victims(excess)
+		for _, idx := range victims {
+			txn.DeleteAt(idx)
+		}
+
+		c.add(MetricEvictions, int64(len(victims)))
+		return nil
+	})
+}
+
+// rowLimit returns the maximum number of rows the collection can hold. The limit derived
+// from MaxBytes is approximate, as the size of the rows is estimated on a sample of them.
+func (txn *Txn) rowLimit() int {
+	limit := math.MaxInt
+	if max := txn.owner.opts.MaxRows; max > 0 {
+		limit = max
+	}
+
+	if max := txn.owner.opts.MaxBytes; max > 0 {
+		if size := txn.rowSize(); size > 0 && int(max/size) < limit {
+			limit = int(max / size)
+		}
+	}
+	return limit
+}
+
+// rowSize estimates the average size of a row, in bytes, by sizing the values of a sample
+// of the rows of the collection.
+func (txn *Txn) rowSize() int64 {
+	txn.owner.lock.RLock()
+	sample := make([]uint32, 0, sampleRows)
+	for blkAt, blk := range txn.owner.fill {
+		for ; blk != 0 && len(sample) < sampleRows; blk &= blk - 1 {
+			sample = append(sample, uint32(blkAt<<6)+uint32(bits.TrailingZeros64(blk)))
+		}
+	}
+	txn.owner.lock.RUnlock()
+	if len(sample) == 0 {
+		return 0
+	}
+
+	var total int64
+	lock := txn.owner.slock
+	for _, idx := range sample {
+		chunk := commit.ChunkAt(idx)
+		txn.readLock(lock, chunk)
+		txn.owner.cols.Range(func(column *column) {
+			if !isComputed(column) {
+				if v, ok := column.Value(idx); ok {
+					total += int64(sizeOfValue(v))
+				}
+			}
+		})
+		txn.readUnlock(lock, chunk)
+	}
+
+	return total / int64(len(sample))
+}
+
+// victims returns the rows to evict, in the order of the eviction policy. The rows which
+// can not be ordered by the policy, such as the ones without a time-to-live, are ordered
+// by their last access.
+func (txn *Txn) victims(n int) []uint32 {
+	txn.initialize()
+	out := make([]uint32, 0, n)
+	if txn.owner.opts.Eviction == EvictTTL && !txn.owner.opts.NoTTL {
+		if index, err := txn.sortIndexOf(expireIndex); err == nil {
+			index.tree().Ascend(sortIndexItem{Key: SortKey(int64(1))}, func(item sortIndexItem) bool {
+				if txn.index.Contains(item.Value) {
+					out = append(out, item.Value)
+				}
+				return len(out) < n
+			})
+		}
+	}
+
+	if len(out) == n {
+		return out
+	}
+
+	// Fill up with the least recently accessed rows, skipping the ones already selected
+	var selected bitmap.Bitmap
+	for _, idx := range out {
+		selected.Set(idx)
+	}
+
+	return append(out, txn.owner.evictor.oldest(txn.index, n-len(out), selected.Contains)...)
+}
+
+// sizeOfValue estimates the size of a value, in bytes
+func sizeOfValue(v any) int {
+	switch v := v.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case []float32:
+		return 4 * len(v)
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	default:
+		return 8
+	}
+}
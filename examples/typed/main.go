@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+
+	// Create a new typed collection, with a column for every field of Player
+	players, err := NewPlayersCollection()
+	if err != nil {
+		panic(err)
+	}
+
+	// Insert a few players
+	for i, name := range []string{"Merlin", "Arthur", "Lancelot"} {
+		if _, err := players.InsertPlayer(Player{
+			Serial:  fmt.Sprintf("p%d", i),
+			Name:    name,
+			Class:   "knight",
+			Active:  i%2 == 0,
+			Age:     30 + i,
+			Balance: 100,
+			Joined:  time.Now(),
+		}); err != nil {
+			panic(err)
+		}
+	}
+
+	// Give every active player a bonus, using the typed cursor
+	players.QueryPlayers(func(txn PlayerTxn) error {
+		txn.With("active")
+		return txn.Range(func(p PlayerCursor) {
+			balance, _ := p.Balance()
+			p.SetBalance(balance + 50)
+		})
+	})
+
+	// Print out the players
+	players.QueryPlayers(func(txn PlayerTxn) error {
+		return txn.Range(func(p PlayerCursor) {
+			player, _ := p.Player()
+			fmt.Printf("%s (%d) has a balance of %.0f\n", player.Name, player.Age, player.Balance)
+		})
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import "time"
+
+//go:generate go run ../../codegen/main.go -type Player -in player.go -out player_gen.go
+
+// Player represents a player, mapped to the columns of a collection
+type Player struct {
+	Serial  string    `column:"serial,key"`
+	Name    string    `column:"name"`
+	Class   string    `column:"class,enum"`
+	Active  bool      `column:"active"`
+	Age     int       `column:"age"`
+	Balance float64   `column:"balance"`
+	Joined  time.Time `column:"joined"`
+}
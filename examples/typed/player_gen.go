@@ -0,0 +1,178 @@
+// This code was generated, DO NOT EDIT.
+// Any changes will be lost if this file is regenerated.
+
+package main
+
+import (
+	"time"
+
+	"github.com/kelindar/column"
+)
+
+// PlayersCollection represents a strongly-typed collection of Player
+type PlayersCollection struct {
+	*column.Collection
+}
+
+// NewPlayersCollection creates a new collection with a column for every field of Player
+func NewPlayersCollection(opts ...column.Options) (*PlayersCollection, error) {
+	c := column.NewCollection(opts...)
+	if err := column.CreateColumnsOfStruct[Player](c); err != nil {
+		return nil, err
+	}
+
+	return &PlayersCollection{Collection: c}, nil
+}
+
+// InsertPlayer inserts a Player into the collection and returns its index
+func (c *PlayersCollection) InsertPlayer(v Player) (index uint32, err error) {
+	err = c.Query(func(txn *column.Txn) (err error) {
+		index, err = txn.InsertStruct(&v)
+		return
+	})
+	return
+}
+
+// QueryPlayers runs a strongly-typed transaction on the collection
+func (c *PlayersCollection) QueryPlayers(fn func(PlayerTxn) error, opts ...column.QueryOption) error {
+	return c.Query(func(txn *column.Txn) error {
+		return fn(PlayerTxn{Txn: txn})
+	}, opts...)
+}
+
+// PlayerTxn represents a strongly-typed transaction on the collection
+type PlayerTxn struct {
+	*column.Txn
+}
+
+// Range iterates over the selected rows, with a typed cursor pointing to the current row
+func (txn PlayerTxn) Range(fn func(PlayerCursor)) error {
+	cursor := PlayerCursor{
+		txn:        txn.Txn,
+		colSerial:  txn.Key(),
+		colName:    txn.String("name"),
+		colClass:   txn.Enum("class"),
+		colActive:  txn.Bool("active"),
+		colAge:     txn.Int("age"),
+		colBalance: txn.Float64("balance"),
+		colJoined:  txn.Time("joined"),
+	}
+
+	return txn.Txn.Range(func(uint32) {
+		fn(cursor)
+	})
+}
+
+// PlayerCursor represents a strongly-typed cursor pointing to the current row
+type PlayerCursor struct {
+	txn       *column.Txn
+	colSerial interface {
+		Get() (string, bool)
+		Set(string) error
+	}
+	colName interface {
+		Get() (string, bool)
+		Set(string)
+	}
+	colClass interface {
+		Get() (string, bool)
+		Set(string)
+	}
+	colActive interface {
+		Get() bool
+		Set(bool)
+	}
+	colAge interface {
+		Get() (int, bool)
+		Set(int)
+	}
+	colBalance interface {
+		Get() (float64, bool)
+		Set(float64)
+	}
+	colJoined interface {
+		Get() (time.Time, bool)
+		Set(time.Time)
+	}
+}
+
+// Index returns the index of the current row
+func (c PlayerCursor) Index() uint32 {
+	return c.txn.Index()
+}
+
+// Player scans the current row into a Player
+func (c PlayerCursor) Player() (v Player, err error) {
+	err = c.txn.ScanStruct(c.txn.Index(), &v)
+	return
+}
+
+// Serial returns the value of the "serial" column at the current row
+func (c PlayerCursor) Serial() (string, bool) {
+	return c.colSerial.Get()
+}
+
+// SetSerial sets the value of the "serial" column at the current row
+func (c PlayerCursor) SetSerial(v string) error {
+	return c.colSerial.Set(v)
+}
+
+// Name returns the value of the "name" column at the current row
+func (c PlayerCursor) Name() (string, bool) {
+	return c.colName.Get()
+}
+
+// SetName sets the value of the "name" column at the current row
+func (c PlayerCursor) SetName(v string) {
+	c.colName.Set(v)
+}
+
+// Class returns the value of the "class" column at the current row
+func (c PlayerCursor) Class() (string, bool) {
+	return c.colClass.Get()
+}
+
+// SetClass sets the value of the "class" column at the current row
+func (c PlayerCursor) SetClass(v string) {
+	c.colClass.Set(v)
+}
+
+// Active returns the value of the "active" column at the current row
+func (c PlayerCursor) Active() bool {
+	return c.colActive.Get()
+}
+
+// SetActive sets the value of the "active" column at the current row
+func (c PlayerCursor) SetActive(v bool) {
+	c.colActive.Set(v)
+}
+
+// Age returns the value of the "age" column at the current row
+func (c PlayerCursor) Age() (int, bool) {
+	return c.colAge.Get()
+}
+
+// SetAge sets the value of the "age" column at the current row
+func (c PlayerCursor) SetAge(v int) {
+	c.colAge.Set(v)
+}
+
+// Balance returns the value of the "balance" column at the current row
+func (c PlayerCursor) Balance() (float64, bool) {
+	return c.colBalance.Get()
+}
+
+// SetBalance sets the value of the "balance" column at the current row
+func (c PlayerCursor) SetBalance(v float64) {
+	c.colBalance.Set(v)
+}
+
+// Joined returns the value of the "joined" column at the current row
+func (c PlayerCursor) Joined() (time.Time, bool) {
+	return c.colJoined.Get()
+}
+
+// SetJoined sets the value of the "joined" column at the current row
+func (c PlayerCursor) SetJoined(v time.Time) {
+	c.colJoined.Set(v)
+}
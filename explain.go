@@ -0,0 +1,147 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Plan represents the profile of a query, with the filtering steps in the order they
+// were applied.
+type Plan struct {
+	Steps    []Step        // The filtering steps of the query
+	Duration time.Duration // The total duration of the query, including the commit
+}
+
+// Step represents a single filtering step of a query.
+type Step struct {
+	Op       string        // The filtering operation, such as "With" or "WithString"
+	Columns  []string      // The columns used by the operation
+	Indexed  bool          // Whether the step was served by bitmap indexes, without a scan
+	Chunks   int           // The number of chunks scanned
+	Examined int           // The number of rows before the step
+	Matched  int           // The number of rows after the step
+	Duration time.Duration // The time spent in the step
+}
+
+// Indexes returns the names of the indexes used by the query.
+func (p Plan) Indexes() (out []string) {
+	for _, step := range p.Steps {
+		if step.Indexed {
+			out = append(out, step.Columns...)
+		}
+	}
+	return
+}
+
+// String returns a human-readable representation of the plan
+func (p Plan) String() string {
+	var sb strings.Builder
+	for i, s := range p.Steps {
+		scan := "scan"
+		if s.Indexed {
+			scan = "index"
+		}
+
+		fmt.Fprintf(&sb, "%d. %s(%s) %s chunks=%d rows=%d->%d in %v\n", i+1, s.Op,
+			strings.Join(s.Columns, ", "), scan, s.Chunks, s.Examined, s.Matched, s.Duration)
+	}
+
+	if p.Duration > 0 {
+		fmt.Fprintf(&sb, "total %v\n", p.Duration)
+	}
+	return sb.String()
+}
+
+// Profile enables the profiling of the subsequent filtering steps of the transaction,
+// which can be retrieved with Explain.
+func (txn *Txn) Profile() *Txn {
+	txn.profile = true
+	return txn
+}
+
+// Explain returns the plan of the query, with the filtering steps applied since the
+// profiling was enabled with Profile. This can be used to find out which indexes were
+// used and how many rows were examined by every step.
+func (txn *Txn) Explain() Plan {
+	return Plan{
+		Steps: append([]Step(nil), txn.steps...),
+	}
+}
+
+// noop is returned by trace when the transaction is not profiled
+func noop() {}
+
+// trace starts a filtering step and returns the function which completes it. Only the
+// outermost step is recorded, if a filter delegates to another one.
+func (txn *Txn) trace(op string, columns ...string) func() {
+	if !txn.profile || txn.tracing {
+		return noop
+	}
+
+	examined := txn.index.Count()
+	if !txn.setup {
+		examined = txn.owner.Count()
+	}
+
+	txn.tracing = true
+	start := time.Now()
+	return func() {
+		txn.tracing = false
+		txn.steps = append(txn.steps, Step{
+			Op:       op,
+			Columns:  columns,
+			Indexed:  txn.isIndexed(columns),
+			Chunks:   txn.chunksScanned(),
+			Examined: examined,
+			Matched:  txn.index.Count(),
+			Duration: time.Since(start),
+		})
+	}
+}
+
+// chunksScanned returns the number of chunks iterated over by the filters
+func (txn *Txn) chunksScanned() int {
+	if n := len(txn.index)>>bitmapShift - int(txn.from) + 1; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// isIndexed checks whether all of the columns are bitmaps, which do not require a scan
+func (txn *Txn) isIndexed(columns []string) bool {
+	for _, name := range columns {
+		column, ok := txn.columnAt(name)
+		if !ok {
+			return false
+		}
+
+		switch column.Column.(type) {
+		case *columnIndex, *columnView, *columnBool, *columnSlice:
+		default:
+			return false
+		}
+	}
+	return len(columns) > 0
+}
+
+// release reports the plan of a query to the query logger if it took longer than the
+// configured threshold, and releases the transaction.
+func (c *Collection) release(txn *Txn, start time.Time) {
+	c.observe(MetricQueryDuration, start)
+	if c.opts.QueryLogger != nil {
+		if elapsed := time.Since(start); elapsed >= c.opts.SlowQuery {
+			plan := txn.Explain()
+			plan.Duration = elapsed
+			c.opts.QueryLogger(plan)
+		}
+	}
+
+	if txn.cancel != nil {
+		txn.cancel()
+	}
+	c.txns.release(txn)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+)
+
+// ErrFrozen is returned when attempting to modify a collection which is frozen.
+var ErrFrozen = errors.New("column: unable to write, collection is frozen")
+
+// The states of a collection which is being frozen
+const (
+	stateWritable = iota // The collection can be modified
+	stateFreezing        // The writes are rejected, but some may still be in progress
+	stateFrozen          // The collection can no longer be modified
+)
+
+// Freeze makes the collection read-only, which is useful for reference datasets that are
+// loaded once. Once frozen, the transactions which modify the collection are rolled back
+// with ErrFrozen, while the queries no longer acquire the locks of the chunks they read.
+// This waits for the commits in progress to complete, and can not be undone. If a spilled
+// chunk can not be reloaded, the collection remains writable and the error is returned.
+func (c *Collection) Freeze() error {
+	for !atomic.CompareAndSwapUint32(&c.frozen, stateWritable, stateFreezing) {
+		if c.isReadOnly() {
+			return nil
+		}
+		runtime.Gosched() // Frozen concurrently, wait for it to complete
+	}
+
+	// Wait for the writes in progress, before the reads stop locking the chunks
+	for atomic.LoadInt64(&c.writers) > 0 {
+		runtime.Gosched()
+	}
+
+	// Reload the spilled chunks, as the reads of a frozen collection can not reload them
+	if err := c.reloadAll(); err != nil {
+		atomic.StoreUint32(&c.frozen, stateWritable)
+		return err
+	}
+
+	atomic.StoreUint32(&c.frozen, stateFrozen)
+	return nil
+}
+
+// IsFrozen returns whether the collection is frozen.
+func (c *Collection) IsFrozen() bool {
+	return atomic.LoadUint32(&c.frozen) != stateWritable
+}
+
+// isReadOnly returns whether the collection is frozen and no write is in progress, so
+// that the chunks can be read without being locked.
+func (c *Collection) isReadOnly() bool {
+	return atomic.LoadUint32(&c.frozen) == stateFrozen
+}
+
+// beginWrite registers a write in progress, unless the collection is frozen. Since the
+// counter is incremented before the flag is checked, Freeze either waits for the write
+// or the write observes the flag.
+func (c *Collection) beginWrite() error {
+	atomic.AddInt64(&c.writers, 1)
+	if c.IsFrozen() {
+		c.endWrite()
+		return ErrFrozen
+	}
+	return nil
+}
+
+// endWrite unregisters a write in progress.
+func (c *Collection) endWrite() {
+	atomic.AddInt64(&c.writers, -1)
+}
+
+// modifies checks whether the transaction has any pending updates
+func (txn *Txn) modifies() bool {
+	for _, u := range txn.updates {
+		if !u.IsEmpty() {
+			return true
+		}
+	}
+	return len(txn.deferred) > 0
+}
@@ -0,0 +1,160 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+var (
+	// ErrStale is returned when a row identifier refers to a row which was deleted, even if its
+	// index was since re-used by another row.
+	ErrStale         = errors.New("column: row identifier is stale")
+	errNoGenerations = errors.New("column: generations are disabled for this collection")
+)
+
+// RowID identifies a row of a collection created with the Generations option. It combines
+// the index of the row with the generation of its slot, which is incremented every time a
+// row is deleted, so that an identifier never refers to a different row once its index is
+// re-used.
+type RowID uint64
+
+// newRowID creates a new row identifier for an index and a generation
+func newRowID(index, generation uint32) RowID {
+	return RowID(uint64(generation)<<32 | uint64(index))
+}
+
+// Index returns the index of the row
+func (id RowID) Index() uint32 {
+	return uint32(id)
+}
+
+// Generation returns the generation of the slot when the identifier was created
+func (id RowID) Generation() uint32 {
+	return uint32(id >> 32)
+}
+
+// --------------------------- Row ID (Row) ----------------------------
+
+// RowID returns the identifier of the row, which remains valid until the row is deleted. It
+// is only available if the collection was created with the Generations option.
+func (r Row) RowID() (RowID, bool) {
+	generation, ok := r.txn.generationAt(r.Index())
+	return newRowID(r.Index(), generation), ok
+}
+
+// --------------------------- Row ID (Txn) ----------------------------
+
+// QueryByRowID jumps at the row referred to by the identifier, similarly to QueryAt. If the
+// row was deleted since the identifier was created, it fails with ErrStale, even if its
+// index was re-used by another row.
+func (txn *Txn) QueryByRowID(id RowID, f func(Row) error) error {
+	if !txn.owner.opts.Generations {
+		return errNoGenerations
+	}
+
+	return txn.QueryAt(id.Index(), func(r Row) error {
+		txn.owner.lock.RLock()
+		exists := txn.owner.fill.Contains(id.Index())
+		txn.owner.lock.RUnlock()
+
+		if current, _ := txn.generationAt(id.Index()); !exists || current != id.Generation() {
+			return ErrStale
+		}
+		return f(r)
+	})
+}
+
+// QueryByRowID jumps at the row referred to by the identifier and executes the function
+// within a transaction. If the row was deleted since the identifier was created, it fails
+// with ErrStale.
+func (c *Collection) QueryByRowID(id RowID, fn func(Row) error) error {
+	return c.Query(func(txn *Txn) error {
+		return txn.QueryByRowID(id, fn)
+	})
+}
+
+// generationAt returns the current generation of a slot
+func (txn *Txn) generationAt(idx uint32) (uint32, bool) {
+	column, ok := txn.owner.cols.Load(generationColumn)
+	if !ok {
+		return 0, false
+	}
+
+	return column.Column.(*columnGeneration).load(idx), true
+}
+
+// --------------------------- Generation Column ----------------------------
+
+// columnGeneration represents the generation of every slot of the collection. Unlike the
+// other columns, the values are kept when a row is deleted and incremented instead.
+type columnGeneration struct {
+	chunks[uint32]
+}
+
+// makeGenerations creates a new generation column
+func makeGenerations() Column {
+	return &columnGeneration{
+		chunks: make(chunks[uint32], 0, 4),
+	}
+}
+
+// Apply applies a set of operations to the column. The deletions of the rows increment the
+// generation, while the values written are only those restored from a snapshot.
+func (c *columnGeneration) Apply(chunk commit.Chunk, r *commit.Reader) {
+	fill, data := c.chunkAt(chunk)
+	rows := r.Column() == rowColumn
+	for r.Next() {
+		switch offset := r.IndexAtChunk(); {
+		case rows && r.Type == commit.Delete:
+			data[offset]++
+			fill.Set(offset)
+		case !rows && r.Type == commit.Put:
+			data[offset] = r.Uint32()
+			fill.Set(offset)
+		}
+	}
+}
+
+// load retrieves the generation of a slot, which is zero if none of its rows were deleted
+func (c *columnGeneration) load(idx uint32) uint32 {
+	chunk := commit.ChunkAt(idx)
+	if int(chunk) < len(c.chunks) {
+		return c.chunks[chunk].data[idx-chunk.Min()]
+	}
+	return 0
+}
+
+// Value retrieves a value at a specified index. The generations belong to the indexes
+// rather than to the rows, hence they are not exposed as values of the rows.
+func (c *columnGeneration) Value(idx uint32) (any, bool) {
+	return nil, false
+}
+
+// Contains checks whether the column has a value at a specified index.
+func (c *columnGeneration) Contains(idx uint32) bool {
+	return false
+}
+
+// Index returns the fill list for the column, which is empty as there are no row values
+func (c *columnGeneration) Index(chunk commit.Chunk) bitmap.Bitmap {
+	return nil
+}
+
+// Snapshot writes the entire column into the specified destination buffer
+func (c *columnGeneration) Snapshot(chunk commit.Chunk, dst *commit.Buffer) {
+	fill, data := c.chunkAt(chunk)
+	fill.Range(func(x uint32) {
+		dst.PutUint32(commit.Put, chunk.Min()+x, data[x])
+	})
+}
+
+// shrink keeps the trailing chunks, since releasing them would reset their generations and
+// make the stale identifiers valid again.
+func (c *columnGeneration) shrink(from commit.Chunk) int {
+	return 0
+}
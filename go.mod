@@ -12,7 +12,6 @@ require (
 	github.com/stretchr/testify v1.8.4
 	github.com/tidwall/btree v1.6.0
 	github.com/zeebo/xxh3 v1.0.2
-
 )
 
 require (
@@ -20,7 +19,7 @@ require (
 	github.com/dustin/go-humanize v1.0.1
 	github.com/kelindar/async v1.1.0
 	github.com/kelindar/xxrand v1.0.2
-	github.com/klauspost/cpuid/v2 v2.2.5 // indirect
+	github.com/klauspost/cpuid/v2 v2.2.5
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.9.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
@@ -0,0 +1,90 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// rowHooks represents the set of hooks which observe the inserted and deleted rows.
+type rowHooks struct {
+	lock     sync.RWMutex
+	count    int32                      // The number of hooks, for a lock-free check
+	onInsert []func(idx uint32)         // The hooks invoked when a row is inserted
+	onDelete []func(idx uint32)         // The hooks invoked when a row is deleted
+	onDirty  []func(chunk commit.Chunk) // The hooks invoked when a chunk is modified
+}
+
+// OnInsert registers a hook which is invoked with the index of every row inserted into
+// the collection, independently of its columns. The hook is invoked while the chunk of
+// the row is locked, and must not query the collection.
+func (c *Collection) OnInsert(fn func(idx uint32)) {
+	c.hooks.lock.Lock()
+	defer c.hooks.lock.Unlock()
+	c.hooks.onInsert = append(c.hooks.onInsert, fn)
+	atomic.AddInt32(&c.hooks.count, 1)
+}
+
+// OnDelete registers a hook which is invoked with the index of every row deleted from
+// the collection, including the expired ones. The hook is invoked while the chunk of
+// the row is locked, and must not query the collection.
+func (c *Collection) OnDelete(fn func(idx uint32)) {
+	c.hooks.lock.Lock()
+	defer c.hooks.lock.Unlock()
+	c.hooks.onDelete = append(c.hooks.onDelete, fn)
+	atomic.AddInt32(&c.hooks.count, 1)
+}
+
+// OnDirty registers a hook which is invoked with every chunk modified by a commit, so that
+// the caches built on top of the collection can refresh the affected rows only. The hook
+// is invoked once the commit is applied and the chunk unlocked, hence it may query the
+// collection. The rows of a chunk range from chunk.Min() to chunk.Max().
+func (c *Collection) OnDirty(fn func(chunk commit.Chunk)) {
+	c.hooks.lock.Lock()
+	defer c.hooks.lock.Unlock()
+	c.hooks.onDirty = append(c.hooks.onDirty, fn)
+	atomic.AddInt32(&c.hooks.count, 1)
+}
+
+// isEmpty checks whether there are no hooks registered
+func (h *rowHooks) isEmpty() bool {
+	return atomic.LoadInt32(&h.count) == 0
+}
+
+// notify invokes the hooks for every insert and delete marker of the reader
+func (h *rowHooks) notify(r *commit.Reader) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	for r.Next() {
+		switch r.Type {
+		case commit.Insert:
+			for _, fn := range h.onInsert {
+				fn(r.Index())
+			}
+		case commit.Delete:
+			for _, fn := range h.onDelete {
+				fn(r.Index())
+			}
+		}
+	}
+}
+
+// notifyDirty invokes the hooks for every chunk of the dirty list
+func (h *rowHooks) notifyDirty(dirty bitmap.Bitmap) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if len(h.onDirty) == 0 {
+		return
+	}
+
+	dirty.Range(func(x uint32) {
+		for _, fn := range h.onDirty {
+			fn(commit.Chunk(x))
+		}
+	})
+}
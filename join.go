@@ -0,0 +1,62 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+)
+
+// Join represents a lookup join between the rows selected by a transaction and the rows
+// of another collection, matched on the primary key of the other collection.
+type Join struct {
+	txn   *Txn        // The transaction on the left side of the join
+	other *Collection // The collection on the right side of the join
+	local Textual     // The column containing the keys of the other collection
+	err   error       // The error while preparing the join
+}
+
+// Join creates a lookup join between the rows selected by this transaction and the other
+// collection. The values of the local column are looked up in the primary key of the
+// other collection, which must be named by the otherKey argument.
+func (txn *Txn) Join(other *Collection, localColumn, otherKey string) *Join {
+	join := &Join{txn: txn, other: other}
+	column, ok := txn.columnAt(localColumn)
+	switch {
+	case !ok:
+		join.err = fmt.Errorf("column: column '%s' does not exist", localColumn)
+	case other.pk == nil || other.pkName != otherKey:
+		join.err = fmt.Errorf("column: column '%s' is not the primary key of the joined collection", otherKey)
+	default:
+		if join.local, ok = column.Column.(Textual); !ok {
+			join.err = fmt.Errorf("column: column '%s' does not contain textual keys", localColumn)
+		}
+	}
+	return join
+}
+
+// Range iterates over the selected rows which have a matching row in the other collection,
+// with the left row pointing to the transaction and the right row pointing to the matching
+// row of the other collection. The rows without a match are skipped. Any changes made to the
+// right row are committed along with the other collection's transaction once Range returns.
+func (j *Join) Range(fn func(left, right Row)) error {
+	if j.err != nil {
+		return j.err
+	}
+
+	return j.other.Query(func(other *Txn) error {
+		return j.txn.Range(func(idx uint32) {
+			key, ok := j.local.LoadString(idx)
+			if !ok {
+				return
+			}
+
+			if at, ok := j.other.pk.OffsetOf(key); ok {
+				other.QueryAt(at, func(right Row) error {
+					fn(Row{j.txn}, right)
+					return nil
+				})
+			}
+		})
+	})
+}
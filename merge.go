@@ -0,0 +1,94 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// ErrOverflow is returned when a merge overflows an integer column created with the
+// WithCheckedAdd option.
+var ErrOverflow = errors.New("integer overflow")
+
+// Integer represents the integer types which can be stored in a numeric column
+type Integer interface {
+	int | int16 | int32 | int64 | uint | uint16 | uint32 | uint64
+}
+
+// WithSaturatingAdd sets the merge function of an integer column to an addition which
+// saturates at the bounds of the type, instead of wrapping around on overflow.
+func WithSaturatingAdd[T Integer]() func(*option[T]) {
+	return WithMerge(saturatingAdd[T])
+}
+
+// WithClamp sets the merge function of a numeric column to an addition whose result is
+// clamped within the inclusive [min, max] range. For integer columns, the addition also
+// saturates at the bounds of the type.
+func WithClamp[T Number](min, max T) func(*option[T]) {
+	return WithMerge(func(value, delta T) T {
+		switch v := saturatingAdd(value, delta); {
+		case v < min:
+			return min
+		case v > max:
+			return max
+		default:
+			return v
+		}
+	})
+}
+
+// WithCheckedAdd sets the merge function of an integer column to an addition which fails
+// on overflow. The deltas are checked when the transaction is committed, and a merge which
+// overflows rolls back the entire transaction with an error wrapping ErrOverflow.
+func WithCheckedAdd[T Integer]() func(*option[T]) {
+	return func(v *option[T]) {
+		v.Merge = func(value, delta T) T { return value + delta }
+		v.Check = func(value, delta T) error {
+			if _, overflow := addOverflow(value, delta); overflow {
+				return ErrOverflow
+			}
+			return nil
+		}
+	}
+}
+
+// addOverflow adds the delta to the value and returns whether the addition overflowed
+func addOverflow[T Number](value, delta T) (T, bool) {
+	sum := value + delta
+	return sum, (delta > 0 && sum < value) || (delta < 0 && sum > value)
+}
+
+// saturatingAdd adds the delta to the value, saturating at the bounds of integer types
+func saturatingAdd[T Number](value, delta T) T {
+	sum, overflow := addOverflow(value, delta)
+	switch {
+	case !overflow:
+		return sum
+	case delta > 0:
+		return maxOf[T]()
+	default:
+		return minOf[T]()
+	}
+}
+
+// maxOf returns the largest value of an integer type
+func maxOf[T Number]() T {
+	var zero T
+	if zero-1 > zero {
+		return zero - 1 // Unsigned
+	}
+
+	max := uint64(1)<<(8*unsafe.Sizeof(zero)-1) - 1
+	return T(max)
+}
+
+// minOf returns the smallest value of an integer type
+func minOf[T Number]() T {
+	var zero T
+	if zero-1 > zero {
+		return zero // Unsigned
+	}
+	return -maxOf[T]() - 1
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// Metric represents the name of a metric reported by a collection. The names follow the
+// Prometheus naming conventions.
+type Metric string
+
+// Various metrics reported by a collection
+const (
+	MetricInserts         Metric = "column_inserts_total"             // Counter of the inserted rows
+	MetricUpdates         Metric = "column_updates_total"             // Counter of the updated values
+	MetricDeletes         Metric = "column_deletes_total"             // Counter of the deleted rows
+	MetricVacuums         Metric = "column_vacuum_runs_total"         // Counter of the vacuum runs
+	MetricEvictions       Metric = "column_evictions_total"           // Counter of the evicted rows
+	MetricQueryDuration   Metric = "column_query_duration_seconds"    // Histogram of the query latency
+	MetricCommitSize      Metric = "column_commit_size_bytes"         // Histogram of the commit sizes
+	MetricSnapshotSeconds Metric = "column_snapshot_duration_seconds" // Histogram of the snapshot durations
+)
+
+// MetricsSink represents a receiver of the metrics of a collection, which can be used to
+// expose them to a monitoring system. The methods are called synchronously and must be
+// safe for concurrent use.
+type MetricsSink interface {
+	Add(metric Metric, delta int64)       // Increments a counter
+	Observe(metric Metric, value float64) // Records an observation of a histogram
+}
+
+// add increments a counter, if the metrics are enabled
+func (c *Collection) add(metric Metric, delta int64) {
+	if c.opts.Metrics != nil && delta > 0 {
+		c.opts.Metrics.Add(metric, delta)
+	}
+}
+
+// observe records the duration since the start, if the metrics are enabled
+func (c *Collection) observe(metric Metric, start time.Time) {
+	if c.opts.Metrics != nil {
+		c.opts.Metrics.Observe(metric, time.Since(start).Seconds())
+	}
+}
+
+// commitMetrics counts the inserted, updated and deleted rows of a chunk
+func (txn *Txn) commitMetrics(chunk commit.Chunk, markers *commit.Buffer) {
+	var inserts, updates, deletes int64
+	if markers != nil {
+		txn.reader.Range(markers, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				switch r.Type {
+				case commit.Insert:
+					inserts++
+				case commit.Delete:
+					deletes++
+				}
+			}
+		})
+	}
+
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				updates++
+			}
+		})
+	}
+
+	txn.owner.add(MetricInserts, inserts)
+	txn.owner.add(MetricUpdates, updates)
+	txn.owner.add(MetricDeletes, deletes)
+}
+
+// commitSize records the size of the commit, in bytes
+func (txn *Txn) commitSize() {
+	size := 0
+	for _, u := range txn.updates {
+		size += u.Size()
+	}
+
+	if size > 0 {
+		txn.owner.opts.Metrics.Observe(MetricCommitSize, float64(size))
+	}
+}
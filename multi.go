@@ -0,0 +1,117 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// MultiTxn represents a transaction spanning over multiple collections, which are all
+// committed or rolled back together.
+type MultiTxn struct {
+	txns  []*Txn    // The transactions, one per collection
+	start time.Time // The start time of the transaction
+}
+
+// On returns the transaction of the specified collection, which is part of this multi-
+// collection transaction. The same transaction is returned for the same collection.
+func (tx *MultiTxn) On(c *Collection) *Txn {
+	for _, txn := range tx.txns {
+		if txn.owner == c {
+			return txn
+		}
+	}
+
+	txn := c.txns.acquire(c)
+	tx.txns = append(tx.txns, txn)
+	return txn
+}
+
+// Atomic executes a transaction over multiple collections, such as orders and inventory,
+// and commits them together. If fn returns an error, or if any of the transactions fails
+// to commit (e.g. a version conflict), none of the collections is modified.
+//
+// The commit is done in two phases: first, all of the modified chunks of all of the
+// collections are write-locked and the expected versions are checked, then the changes
+// are applied to every collection before releasing the locks. Hence, concurrent readers
+// observe either none or all of the changes of a chunk.
+func Atomic(fn func(tx *MultiTxn) error) error {
+	tx := &MultiTxn{start: time.Now()}
+	defer tx.release()
+
+	// Execute the transaction and keep the error for later
+	err := fn(tx)
+	for _, txn := range tx.txns {
+		if err == nil {
+			err = txn.err
+		}
+	}
+
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	// Lock the collections in the same order, to prevent concurrent multi-collection
+	// transactions from deadlocking each other.
+	sort.Slice(tx.txns, func(i, j int) bool {
+		return reflect.ValueOf(tx.txns[i].owner).Pointer() < reflect.ValueOf(tx.txns[j].owner).Pointer()
+	})
+
+	// Reject the writes into the collections which are frozen
+	for i, txn := range tx.txns {
+		if err := txn.owner.beginWrite(); err != nil {
+			for _, started := range tx.txns[:i] {
+				started.owner.endWrite()
+			}
+
+			tx.rollback()
+			return err
+		}
+	}
+	defer func() {
+		for _, txn := range tx.txns {
+			txn.owner.endWrite()
+		}
+	}()
+
+	// Phase 1: lock and validate all of the transactions
+	for i, txn := range tx.txns {
+		if err := txn.prepare(true); err != nil {
+			for _, prepared := range tx.txns[:i] {
+				prepared.unlockExpected()
+			}
+
+			tx.rollback()
+			return err
+		}
+	}
+
+	// Phase 2: apply all of the transactions, then release the locks
+	for _, txn := range tx.txns {
+		txn.apply()
+	}
+	for _, txn := range tx.txns {
+		txn.finish()
+		txn.reset()
+	}
+	return nil
+}
+
+// rollback rolls back all of the transactions
+func (tx *MultiTxn) rollback() {
+	for _, txn := range tx.txns {
+		txn.freeInserts()
+		txn.rollback()
+	}
+}
+
+// release releases all of the transactions back to their pools
+func (tx *MultiTxn) release() {
+	for _, txn := range tx.txns {
+		txn.owner.release(txn, tx.start)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/column/commit"
+)
+
+// ReadAt reads the values of the specified columns at a particular row, without acquiring
+// a transaction. Only a shared lock of the row's chunk is held while the values are read,
+// so they are consistent with each other. If the row does not exist, for example when the
+// index is out of range, no values are returned. Otherwise, the returned flag is false if any
+// of the columns does not exist or does not contain a value for the row, in which case its
+// value is nil.
+func (c *Collection) ReadAt(idx uint32, columns ...string) (values []any, ok bool) {
+	chunk := uint(commit.ChunkAt(idx))
+	locked := c.readLockAt(chunk)
+	defer c.readUnlockAt(chunk, locked)
+
+	c.lock.RLock()
+	exists := c.fill.Contains(idx)
+	c.lock.RUnlock()
+	if !exists {
+		return nil, false
+	}
+
+	values, ok = make([]any, len(columns)), true
+	for i, name := range columns {
+		column, exists := c.cols.Load(name)
+		if !exists {
+			ok = false
+			continue
+		}
+
+		if v, has := column.Value(idx); has {
+			values[i] = v
+			continue
+		}
+		ok = false
+	}
+	return
+}
+
+// ReadKey reads the values of the specified columns at a row with a particular primary
+// key, without acquiring a transaction. See ReadAt for more details.
+func (c *Collection) ReadKey(key string, columns ...string) ([]any, bool) {
+	if c.pk == nil {
+		return nil, false
+	}
+
+	idx, ok := c.pk.OffsetOf(key)
+	if !ok {
+		return nil, false
+	}
+
+	return c.ReadAt(idx, columns...)
+}
+
+// ReadNumber reads a numeric value of type T at a particular row, without acquiring a
+// transaction nor allocating any memory. The column must be a numeric column of type T.
+func ReadNumber[T Number](c *Collection, idx uint32, columnName string) (value T, ok bool) {
+	column, exists := c.cols.Load(columnName)
+	if !exists {
+		return
+	}
+
+	numbers, isNumber := column.Column.(*numericColumn[T])
+	if !isNumber {
+		return
+	}
+
+	chunk := uint(commit.ChunkAt(idx))
+	locked := c.readLockAt(chunk)
+	value, ok = numbers.load(idx)
+	c.readUnlockAt(chunk, locked)
+	return
+}
+
+// readLockAt acquires the read lock of a chunk, unless the collection is frozen, and
+// returns whether the lock was acquired.
+func (c *Collection) readLockAt(chunk uint) bool {
+	if c.isReadOnly() {
+		return false
+	}
+
+	c.readLockChunk(commit.Chunk(chunk))
+	return true
+}
+
+// readUnlockAt releases the read lock of a chunk, if it was acquired by readLockAt.
+func (c *Collection) readUnlockAt(chunk uint, locked bool) {
+	if locked {
+		c.slock.RUnlock(chunk)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Schema represents a declarative definition of the columns and indexes of a collection.
+type Schema struct {
+	Columns map[string]Column      // The columns, keyed by their name
+	Indexes map[string]SchemaIndex // The bitmap indexes, keyed by their name
+	Prune   bool                   // Whether the columns and indexes not in the schema are dropped
+}
+
+// SchemaIndex represents a bitmap index defined in a schema.
+type SchemaIndex struct {
+	Column string            // The column to index
+	Filter func(Reader) bool // The predicate of the index
+}
+
+// Migration represents the set of changes required to bring a collection in line with
+// a schema. The names are sorted, for deterministic migrations.
+type Migration struct {
+	CreateColumns []string // The columns to create
+	CreateIndexes []string // The indexes to create, or to re-create on a different column
+	DropColumns   []string // The columns to drop, only if the schema is pruning
+	DropIndexes   []string // The indexes to drop, including the ones to re-create
+}
+
+// IsEmpty returns whether the collection is already in line with the schema.
+func (m Migration) IsEmpty() bool {
+	return len(m.CreateColumns) == 0 && len(m.CreateIndexes) == 0 &&
+		len(m.DropColumns) == 0 && len(m.DropIndexes) == 0
+}
+
+// DiffSchema computes the migration required to bring the collection in line with the
+// schema. An error is returned if a column exists with a different type, since changing
+// the type of a column requires a conversion of its values with AlterColumn.
+func DiffSchema(c *Collection, s Schema) (Migration, error) {
+	var m Migration
+	for _, name := range sortedKeys(s.Columns) {
+		existing, ok := c.cols.Load(name)
+		switch {
+		case !ok:
+			m.CreateColumns = append(m.CreateColumns, name)
+		case isComputed(existing):
+			return Migration{}, fmt.Errorf("column: unable to migrate column '%s', an index with this name exists", name)
+		case typeName(existing.Column) != typeName(s.Columns[name]):
+			return Migration{}, fmt.Errorf("column: unable to migrate column '%s' from %s to %s",
+				name, typeName(existing.Column), typeName(s.Columns[name]))
+		}
+	}
+
+	for _, name := range sortedKeys(s.Indexes) {
+		existing, ok := c.cols.Load(name)
+		if !ok {
+			m.CreateIndexes = append(m.CreateIndexes, name)
+			continue
+		}
+
+		index, ok := existing.Column.(*columnIndex)
+		switch {
+		case !ok:
+			return Migration{}, fmt.Errorf("column: unable to migrate index '%s', a column with this name exists", name)
+		case index.Column() != s.Indexes[name].Column:
+			m.DropIndexes = append(m.DropIndexes, name)
+			m.CreateIndexes = append(m.CreateIndexes, name)
+		}
+	}
+
+	// Find the columns and indexes which are not part of the schema
+	if s.Prune {
+		c.cols.Range(func(column *column) {
+			switch column.Column.(type) {
+			case *columnIndex:
+				if _, ok := s.Indexes[column.name]; !ok {
+					m.DropIndexes = append(m.DropIndexes, column.name)
+				}
+			default:
+				if _, ok := s.Columns[column.name]; !ok && !isComputed(column) && !c.isReserved(column.name) {
+					m.DropColumns = append(m.DropColumns, column.name)
+				}
+			}
+		})
+	}
+
+	sort.Strings(m.DropColumns)
+	sort.Strings(m.DropIndexes)
+	return m, nil
+}
+
+// ApplySchema computes the migration required to bring the collection in line with the
+// schema and executes it. The missing columns and indexes are created and, if the schema
+// is pruning, the columns and bitmap indexes which are not part of the schema are dropped.
+// Triggers, sort indexes, trigram indexes and views are left untouched, as well as the
+// built-in columns and the primary key.
+func ApplySchema(c *Collection, s Schema) error {
+	m, err := DiffSchema(c, s)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range m.DropIndexes {
+		if err := c.DropIndex(name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range m.DropColumns {
+		c.DropColumn(name)
+	}
+
+	for _, name := range m.CreateColumns {
+		if err := c.CreateColumn(name, s.Columns[name]); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range m.CreateIndexes {
+		index := s.Indexes[name]
+		if err := c.CreateIndex(name, index.Column, index.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isReserved checks whether the column is managed by the collection itself
+func (c *Collection) isReserved(columnName string) bool {
+	switch columnName {
+	case expireColumn, expireIndex, versionColumn, generationColumn, c.pkName:
+		return true
+	default:
+		return false
+	}
+}
+
+// sortedKeys returns the keys of the map in a sorted order
+func sortedKeys[T any](m map[string]T) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,477 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kelindar/column"
+	"github.com/kelindar/column/debug"
+)
+
+// defaultCount is the default number of keys returned by a SCAN
+const defaultCount = 10
+
+// The limits of a command, same as the ones of Redis
+const (
+	maxArgs     = 1024 * 1024       // The maximum number of arguments of a command
+	maxBulkSize = 512 * 1024 * 1024 // The maximum size of an argument, in bytes
+)
+
+// RESP represents a listener which serves a collection with a primary key over the Redis
+// serialization protocol, so the existing Redis clients and tooling can use it directly.
+// The keys are the primary keys of the rows and the fields of the hashes are the columns:
+//
+//	GET key                          returns the value column of a row
+//	SET key value                    stores the value column of a row
+//	DEL key [key ...]                deletes rows, returns the number of rows deleted
+//	HGET key field                   returns a column of a row
+//	HSET key field value [...]       stores columns of a row, returns the number of columns stored
+//	HGETALL key                      returns all of the columns of a row
+//	SCAN cursor [MATCH pattern] [FILTER expr] [COUNT n]
+//
+// The FILTER option of SCAN takes the same filter expressions as the debug console, such as
+// "human and age >= 30", while MATCH filters the keys with a glob pattern.
+type RESP struct {
+	collection *column.Collection
+	key        string // The name of the primary key column
+	value      string // The name of the column read and written by GET and SET
+	lock       sync.Mutex
+	listeners  map[net.Listener]struct{}
+}
+
+// NewRESP creates a new RESP listener for a collection, which must have a primary key column.
+// The value column is the column read and written by the GET and SET commands.
+func NewRESP(collection *column.Collection, value string) (*RESP, error) {
+	s := &RESP{
+		collection: collection,
+		value:      value,
+		listeners:  make(map[net.Listener]struct{}),
+	}
+
+	for _, c := range collection.Columns() {
+		if c.Type == "key" {
+			s.key = c.Name
+		}
+	}
+
+	if s.key == "" {
+		return nil, fmt.Errorf("server: unable to serve RESP, collection has no primary key")
+	}
+	return s, nil
+}
+
+// ListenAndServe listens on the TCP address and serves the connections until it is closed.
+func (s *RESP) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve accepts the connections on the listener and serves them until it is closed.
+func (s *RESP) Serve(listener net.Listener) error {
+	s.lock.Lock()
+	s.listeners[listener] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.listeners, listener)
+		s.lock.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		switch {
+		case errors.Is(err, net.ErrClosed):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes all of the listeners. The connections already accepted are served until
+// the clients close them.
+func (s *RESP) Close() (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for listener := range s.listeners {
+		if e := listener.Close(); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// serveConn serves the commands of a connection until it is closed
+func (s *RESP) serveConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	writer := &respWriter{Writer: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if err != io.EOF {
+				writer.Error(err)
+				writer.Flush()
+			}
+			return
+		}
+
+		quit := s.execute(writer, args)
+		if err := writer.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// execute executes a command and returns whether the connection should be closed
+func (s *RESP) execute(w *respWriter, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	cmd := strings.ToUpper(args[0])
+	switch {
+	case cmd == "QUIT":
+		w.Status("OK")
+		return true
+	case cmd == "PING" && len(args) == 1:
+		w.Status("PONG")
+	case cmd == "PING" && len(args) == 2:
+		w.Bulk(args[1])
+	case cmd == "COMMAND" || cmd == "CLIENT":
+		w.Array(0) // Sent by redis-cli and some clients on connect
+	case cmd == "GET" && len(args) == 2:
+		s.get(w, args[1])
+	case cmd == "SET" && len(args) == 3:
+		s.hset(w, args[1], []string{s.value, args[2]}, true)
+	case cmd == "DEL" && len(args) >= 2:
+		s.del(w, args[1:])
+	case cmd == "HGET" && len(args) == 3:
+		s.hget(w, args[1], args[2])
+	case cmd == "HSET" && len(args) >= 4 && len(args)%2 == 0:
+		s.hset(w, args[1], args[2:], false)
+	case cmd == "HGETALL" && len(args) == 2:
+		s.hgetall(w, args[1])
+	case cmd == "SCAN" && len(args) >= 2 && len(args)%2 == 0:
+		s.scan(w, args[1:])
+	default:
+		w.Error(fmt.Errorf("ERR unknown command or wrong number of arguments for '%s'", args[0]))
+	}
+	return false
+}
+
+// get writes the value column of a row
+func (s *RESP) get(w *respWriter, key string) {
+	s.hget(w, key, s.value)
+}
+
+// hget writes a column of a row
+func (s *RESP) hget(w *respWriter, key, field string) {
+	if !s.hasColumn(field) {
+		w.Nil()
+		return
+	}
+
+	var value any
+	var found bool
+	s.collection.QueryKey(key, func(r column.Row) error {
+		value, found = r.Any(field)
+		return nil
+	})
+
+	if !found {
+		w.Nil()
+		return
+	}
+
+	w.Bulk(formatValue(value))
+}
+
+// hgetall writes all of the non-computed columns of a row as field-value pairs
+func (s *RESP) hgetall(w *respWriter, key string) {
+	var fields []string
+	s.collection.QueryKey(key, func(r column.Row) error {
+		for _, c := range s.collection.Columns() {
+			if c.Computed || c.Name == s.key {
+				continue
+			}
+
+			if v, ok := r.Any(c.Name); ok {
+				fields = append(fields, c.Name, formatValue(v))
+			}
+		}
+		return nil
+	})
+
+	w.Array(len(fields))
+	for _, v := range fields {
+		w.Bulk(v)
+	}
+}
+
+// hset stores the columns of a row, given as field-value pairs
+func (s *RESP) hset(w *respWriter, key string, pairs []string, status bool) {
+	types := s.columnTypes()
+	values := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		typ, ok := types[pairs[i]]
+		if !ok || pairs[i] == s.key {
+			w.Error(fmt.Errorf("ERR column '%s' can not be written", pairs[i]))
+			return
+		}
+
+		value, err := convertString(pairs[i+1], typ)
+		if err != nil {
+			w.Error(fmt.Errorf("ERR invalid value of '%s', %v", pairs[i], err))
+			return
+		}
+		values[pairs[i]] = value
+	}
+
+	if err := s.collection.UpsertKey(key, func(r column.Row) error {
+		return r.SetMany(values)
+	}); err != nil {
+		w.Error(fmt.Errorf("ERR %v", err))
+		return
+	}
+
+	if status {
+		w.Status("OK")
+		return
+	}
+	w.Int(len(values))
+}
+
+// del deletes rows and writes the number of rows deleted
+func (s *RESP) del(w *respWriter, keys []string) {
+	deleted := 0
+	for _, key := range keys {
+		if s.collection.DeleteKey(key) == nil {
+			deleted++
+		}
+	}
+	w.Int(deleted)
+}
+
+// scan writes a page of the keys matching the options. The cursor is the index of the
+// row after the last one returned, or zero once all of the rows were returned.
+func (s *RESP) scan(w *respWriter, args []string) {
+	cursor, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		w.Error(fmt.Errorf("ERR invalid cursor"))
+		return
+	}
+
+	count, pattern, expr := defaultCount, "", ""
+	for i := 1; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "FILTER":
+			expr = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				w.Error(fmt.Errorf("ERR invalid count"))
+				return
+			}
+		default:
+			w.Error(fmt.Errorf("ERR syntax error"))
+			return
+		}
+	}
+
+	filter, err := debug.Parse(expr)
+	if err != nil {
+		w.Error(fmt.Errorf("ERR %v", err))
+		return
+	}
+
+	next := uint64(0)
+	keys := make([]string, 0, count)
+	err = s.collection.Query(func(txn *column.Txn) error {
+		filter.Apply(txn)
+		key := txn.Key()
+		return txn.Range(func(idx uint32) {
+			if uint64(idx) < cursor || next != 0 {
+				return
+			}
+
+			if len(keys) == count {
+				next = uint64(idx)
+				return
+			}
+
+			if v, ok := key.Get(); ok {
+				if matched, _ := path.Match(pattern, v); matched || pattern == "" {
+					keys = append(keys, v)
+				}
+			}
+		})
+	})
+	if err != nil {
+		w.Error(fmt.Errorf("ERR %v", err))
+		return
+	}
+
+	w.Array(2)
+	w.Bulk(strconv.FormatUint(next, 10))
+	w.Array(len(keys))
+	for _, v := range keys {
+		w.Bulk(v)
+	}
+}
+
+// hasColumn returns whether a column exists and can be read
+func (s *RESP) hasColumn(name string) bool {
+	for _, c := range s.collection.Columns() {
+		if c.Name == name && !c.Computed {
+			return true
+		}
+	}
+	return false
+}
+
+// columnTypes returns the types of the non-computed columns
+func (s *RESP) columnTypes() map[string]string {
+	types := make(map[string]string, 8)
+	for _, c := range s.collection.Columns() {
+		if !c.Computed {
+			types[c.Name] = c.Type
+		}
+	}
+	return types
+}
+
+// convertString converts a string argument to the type of a column
+func convertString(v string, typ string) (any, error) {
+	switch typ {
+	case "string", "enum":
+		return v, nil
+	case "bool":
+		return strconv.ParseBool(v)
+	default:
+		return convertNumber(json.Number(v), typ)
+	}
+}
+
+// formatValue formats a value of a column as a bulk string
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// --------------------------- Protocol ----------------------------
+
+// readCommand reads a command, either as an array of bulk strings or as an inline command
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, fmt.Errorf("ERR protocol error, invalid array length")
+	}
+
+	// The arguments are not pre-allocated past a small size, as the length is not trusted
+	capacity := n
+	if capacity > 64 {
+		capacity = 64
+	}
+
+	args := make([]string, 0, capacity)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("ERR protocol error, expected '$'")
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkSize {
+			return nil, fmt.Errorf("ERR protocol error, invalid bulk length")
+		}
+
+		buffer := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buffer); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buffer[:size]))
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by CRLF
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// respWriter writes the replies of the protocol
+type respWriter struct {
+	*bufio.Writer
+}
+
+// Status writes a simple string reply
+func (w *respWriter) Status(v string) {
+	w.WriteString("+" + v + "\r\n")
+}
+
+// Error writes an error reply
+func (w *respWriter) Error(err error) {
+	w.WriteString("-" + strings.ReplaceAll(err.Error(), "\r\n", " ") + "\r\n")
+}
+
+// Int writes an integer reply
+func (w *respWriter) Int(v int) {
+	w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+}
+
+// Bulk writes a bulk string reply
+func (w *respWriter) Bulk(v string) {
+	w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+}
+
+// Nil writes a nil bulk string reply
+func (w *respWriter) Nil() {
+	w.WriteString("$-1\r\n")
+}
+
+// Array writes the header of an array reply
+func (w *respWriter) Array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESP(t *testing.T) {
+	players := newPlayers()
+	conn := serveRESP(t, players)
+
+	assert.Equal(t, "PONG", conn.do("PING"))
+	assert.Equal(t, "OK", conn.do("SET", "alice", "mage"))
+	assert.Equal(t, "mage", conn.do("GET", "alice"))
+	assert.Equal(t, nil, conn.do("GET", "bob"))
+
+	assert.Equal(t, int64(2), conn.do("HSET", "alice", "age", "20", "balance", "10.5"))
+	assert.Equal(t, "20", conn.do("HGET", "alice", "age"))
+	assert.Equal(t, "10.5", conn.do("HGET", "alice", "balance"))
+	assert.Equal(t, nil, conn.do("HGET", "alice", "invalid"))
+	assert.Equal(t, nil, conn.do("HGET", "alice", "mage"))
+	assert.ElementsMatch(t, []any{"class", "mage", "age", "20", "balance", "10.5"}, conn.do("HGETALL", "alice"))
+	assert.Equal(t, []any{}, conn.do("HGETALL", "bob"))
+
+	assert.NoError(t, players.QueryKey("alice", func(r column.Row) error {
+		age, _ := r.Int("age")
+		assert.Equal(t, 20, age)
+		return nil
+	}))
+
+	assert.Equal(t, int64(1), conn.do("DEL", "alice", "bob"))
+	assert.Equal(t, nil, conn.do("GET", "alice"))
+
+	// Errors
+	assert.IsType(t, errorReply(""), conn.do("HSET", "alice", "age", "x"))
+	assert.IsType(t, errorReply(""), conn.do("HSET", "alice", "name", "bob"))
+	assert.IsType(t, errorReply(""), conn.do("HSET", "alice", "age"))
+	assert.IsType(t, errorReply(""), conn.do("INVALID"))
+	assert.IsType(t, errorReply(""), conn.do("SCAN", "x"))
+	assert.IsType(t, errorReply(""), conn.do("SCAN", "0", "FILTER", "age >"))
+	assert.Equal(t, "OK", conn.do("QUIT"))
+}
+
+func TestRESPScan(t *testing.T) {
+	players := newPlayers()
+	for i := 0; i < 10; i++ {
+		players.InsertKey(fmt.Sprintf("p%d", i), func(r column.Row) error {
+			r.SetEnum("class", []string{"mage", "rogue"}[i%2])
+			r.SetInt("age", 20+i)
+			return nil
+		})
+	}
+
+	conn := serveRESP(t, players)
+	var keys []any
+	cursor := "0"
+	for pages := 0; ; pages++ {
+		reply := conn.do("SCAN", cursor, "FILTER", "mage", "COUNT", "2").([]any)
+		keys = append(keys, reply[1].([]any)...)
+		if cursor = reply[0].(string); cursor == "0" {
+			assert.Equal(t, 2, pages)
+			break
+		}
+	}
+
+	assert.Equal(t, []any{"p0", "p2", "p4", "p6", "p8"}, keys)
+	assert.Equal(t, []any{"0", []any{"p1"}}, conn.do("SCAN", "0", "MATCH", "p1*", "COUNT", "100"))
+	assert.Equal(t, []any{"0", []any{"p8", "p9"}}, conn.do("SCAN", "0", "FILTER", "age >= 28"))
+}
+
+func TestRESPLimits(t *testing.T) {
+	for header, expect := range map[string]errorReply{
+		"*1048577\r\n":         "ERR protocol error, invalid array length",
+		"*-1\r\n":              "ERR protocol error, invalid array length",
+		"*1\r\n$536870913\r\n": "ERR protocol error, invalid bulk length",
+	} {
+		conn := serveRESP(t, newPlayers())
+		_, err := conn.Write([]byte(header))
+		assert.NoError(t, err)
+		assert.Equal(t, expect, conn.read())
+	}
+}
+
+func TestRESPNoKey(t *testing.T) {
+	_, err := NewRESP(column.NewCollection(), "value")
+	assert.Error(t, err)
+}
+
+// errorReply represents an error reply of the protocol
+type errorReply string
+
+// respConn represents a connection of a test client
+type respConn struct {
+	t *testing.T
+	net.Conn
+	reader *bufio.Reader
+}
+
+// serveRESP serves the collection and connects a test client to it
+func serveRESP(t *testing.T, collection *column.Collection) *respConn {
+	srv, err := NewRESP(collection, "class")
+	assert.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &respConn{t: t, Conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do sends a command and reads its reply
+func (c *respConn) do(args ...string) any {
+	var sb strings.Builder
+	sb.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, v := range args {
+		sb.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	}
+
+	_, err := c.Write([]byte(sb.String()))
+	assert.NoError(c.t, err)
+	return c.read()
+}
+
+// read reads a reply
+func (c *respConn) read() any {
+	line, err := readLine(c.reader)
+	assert.NoError(c.t, err)
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return errorReply(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+
+		buffer := make([]byte, n+2)
+		_, err := io.ReadFull(c.reader, buffer)
+		assert.NoError(c.t, err)
+		return string(buffer[:n])
+	default:
+		n, _ := strconv.Atoi(line[1:])
+		out := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			out = append(out, c.read())
+		}
+		return out
+	}
+}
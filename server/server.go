@@ -0,0 +1,345 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+// Package server provides an HTTP handler which serves collections with JSON, so that the
+// services which are not written in Go can use the same in-memory store. It reads, writes
+// and deletes rows by their primary key, scans the rows matching a filter expression page
+// by page, downloads snapshots and streams the changes committed to the collections.
+//
+//	srv := server.New()
+//	srv.Register("players", players)
+//	http.ListenAndServe(":8080", srv)
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kelindar/column"
+	"github.com/kelindar/column/debug"
+)
+
+// defaultLimit is the default number of rows returned by a scan
+const defaultLimit = 100
+
+// Server represents a handler serving the registered collections over HTTP.
+type Server struct {
+	lock        sync.RWMutex
+	collections map[string]*column.Collection
+}
+
+// New creates a new server handler.
+func New() *Server {
+	return &Server{
+		collections: make(map[string]*column.Collection),
+	}
+}
+
+// Register adds a collection to the server under the specified name.
+func (s *Server) Register(name string, collection *column.Collection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.collections[name] = collection
+}
+
+// Unregister removes a collection from the server.
+func (s *Server) Unregister(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.collections, name)
+}
+
+// load loads a registered collection by its name
+func (s *Server) load(name string) (*column.Collection, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	collection, ok := s.collections[name]
+	return collection, ok
+}
+
+// ServeHTTP serves the following endpoints:
+//
+//	GET    /{collection}/keys/{key}  returns the row with the specified primary key
+//	PUT    /{collection}/keys/{key}  inserts or updates the row with the columns of a JSON object
+//	DELETE /{collection}/keys/{key}  deletes the row with the specified primary key
+//	GET    /{collection}/scan?q=...  returns a page of the rows matching a filter expression
+//	GET    /{collection}/snapshot    downloads a snapshot of the collection
+//	GET    /{collection}/changes     streams the changes as newline-delimited JSON
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	collection, ok := s.load(path[0])
+	if !ok {
+		debug.WriteError(w, http.StatusNotFound, fmt.Errorf("collection '%s' does not exist", path[0]))
+		return
+	}
+
+	switch {
+	case len(path) == 3 && path[1] == "keys":
+		s.serveKey(w, r, collection, path[2])
+	case len(path) == 2 && r.Method != http.MethodGet:
+		debug.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+	case len(path) == 2 && path[1] == "scan":
+		s.serveScan(w, r, collection)
+	case len(path) == 2 && path[1] == "snapshot":
+		s.serveSnapshot(w, collection)
+	case len(path) == 2 && path[1] == "changes":
+		s.serveChanges(w, r, collection)
+	default:
+		debug.WriteError(w, http.StatusNotFound, fmt.Errorf("path '%s' does not exist", r.URL.Path))
+	}
+}
+
+// serveKey reads, writes or deletes the row with the specified primary key
+func (s *Server) serveKey(w http.ResponseWriter, r *http.Request, collection *column.Collection, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		var row map[string]any
+		if err := collection.QueryKey(key, func(r column.Row) error {
+			row = debug.ReadRow(r.Index(), collection, func(name string) (any, bool) {
+				return r.Any(name)
+			})
+			return nil
+		}); err != nil {
+			debug.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, row)
+
+	case http.MethodPut:
+		values, err := readObject(r, collection)
+		if err != nil {
+			debug.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := collection.UpsertKey(key, func(r column.Row) error {
+			return r.SetMany(values)
+		}); err != nil {
+			debug.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := collection.DeleteKey(key); err != nil {
+			debug.WriteError(w, http.StatusNotFound, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		debug.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+	}
+}
+
+// serveScan writes a page of the rows matching the filter expression. The rows are
+// returned in the order of their index, and the next page starts after the index
+// returned as the cursor of the previous one.
+func (s *Server) serveScan(w http.ResponseWriter, r *http.Request, collection *column.Collection) {
+	query := r.URL.Query()
+	filter, err := debug.Parse(query.Get("q"))
+	if err != nil {
+		debug.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := defaultLimit
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			debug.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid limit '%s'", v))
+			return
+		}
+	}
+
+	after := int64(-1)
+	if v := query.Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil || after < 0 {
+			debug.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid cursor '%s'", v))
+			return
+		}
+	}
+
+	result := scanResult{Rows: make([]map[string]any, 0, 16)}
+	err = collection.Query(func(txn *column.Txn) error {
+		filter.Apply(txn)
+		result.Count = txn.Count()
+		return txn.Range(func(idx uint32) {
+			switch {
+			case int64(idx) <= after:
+				return
+			case len(result.Rows) < limit:
+				result.Rows = append(result.Rows, debug.ReadRow(idx, collection, func(name string) (any, bool) {
+					return txn.Any(name).Get()
+				}))
+			case result.Next == nil:
+				next := result.Rows[len(result.Rows)-1]["$index"].(uint32)
+				result.Next = &next
+			}
+		})
+	})
+	if err != nil {
+		debug.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// serveSnapshot writes a snapshot of the collection. Since the snapshot is streamed, a
+// failure once it has started aborts the response.
+func (s *Server) serveSnapshot(w http.ResponseWriter, collection *column.Collection) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := collection.Snapshot(w); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// serveChanges streams the changes committed to the collection as newline-delimited JSON,
+// until the client disconnects. The events which do not fit in the buffer of a slow client
+// are dropped rather than slowing down the commits.
+func (s *Server) serveChanges(w http.ResponseWriter, r *http.Request, collection *column.Collection) {
+	opts := column.SubscribeOptions{
+		Index:  r.URL.Query().Get("index"),
+		Policy: column.OverflowDrop,
+	}
+	if v := r.URL.Query().Get("columns"); v != "" {
+		opts.Columns = strings.Split(v, ",")
+	}
+
+	events, err := collection.Subscribe(r.Context(), opts)
+	if err != nil {
+		debug.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	encoder := json.NewEncoder(w)
+	for event := range events {
+		if err := encoder.Encode(changeEvent{
+			Type:   event.Type.String(),
+			Index:  event.Index,
+			Values: event.Values,
+		}); err != nil {
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// --------------------------- Rows ----------------------------
+
+// readObject reads a JSON object from the body of the request, and converts its numbers
+// to the type of their columns.
+func readObject(r *http.Request, collection *column.Collection) (map[string]any, error) {
+	var values map[string]any
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid object, %w", err)
+	}
+
+	types := make(map[string]string, 8)
+	for _, c := range collection.Columns() {
+		types[c.Name] = c.Type
+	}
+
+	for k, v := range values {
+		if number, ok := v.(json.Number); ok {
+			value, err := convertNumber(number, types[k])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of '%s', %w", k, err)
+			}
+			values[k] = value
+		}
+	}
+	return values, nil
+}
+
+// convertNumber converts a JSON number to the type of a numeric column, and fails if the
+// number does not fit into it.
+func convertNumber(v json.Number, typ string) (any, error) {
+	switch typ {
+	case "int", "int16", "int32", "int64":
+		n, err := strconv.ParseInt(string(v), 10, bitsOf(typ))
+		switch typ {
+		case "int":
+			return int(n), err
+		case "int16":
+			return int16(n), err
+		case "int32":
+			return int32(n), err
+		default:
+			return n, err
+		}
+	case "uint", "uint16", "uint32", "uint64":
+		n, err := strconv.ParseUint(string(v), 10, bitsOf(typ))
+		switch typ {
+		case "uint":
+			return uint(n), err
+		case "uint16":
+			return uint16(n), err
+		case "uint32":
+			return uint32(n), err
+		default:
+			return n, err
+		}
+	case "float32":
+		n, err := strconv.ParseFloat(string(v), 32)
+		return float32(n), err
+	default:
+		return v.Float64()
+	}
+}
+
+// bitsOf returns the bit size of an integer column type, or zero for the size of an int
+func bitsOf(typ string) int {
+	switch strings.TrimPrefix(typ, "u") {
+	case "int16":
+		return 16
+	case "int32":
+		return 32
+	case "int64":
+		return 64
+	default:
+		return 0
+	}
+}
+
+// --------------------------- Responses ----------------------------
+
+// scanResult represents a page of the rows matching a filter
+type scanResult struct {
+	Count int              `json:"count"`          // The number of matching rows, across all pages
+	Rows  []map[string]any `json:"rows"`           // The rows of the page
+	Next  *uint32          `json:"next,omitempty"` // The cursor of the next page, if any
+}
+
+// changeEvent represents a change streamed to the clients
+type changeEvent struct {
+	Type   string         `json:"type"`
+	Index  uint32         `json:"index"`
+	Values map[string]any `json:"values,omitempty"`
+}
+
+// writeJSON writes the value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,182 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/column"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeys(t *testing.T) {
+	srv := New()
+	srv.Register("players", newPlayers())
+	srv.Register("removed", newPlayers())
+	srv.Unregister("removed")
+
+	assert.Equal(t, http.StatusNoContent, send(t, srv, http.MethodPut, "/players/keys/alice", `{"class":"mage","age":20,"balance":10.5}`))
+	assert.Equal(t, http.StatusNoContent, send(t, srv, http.MethodPut, "/players/keys/alice", `{"age":21,"level":300}`))
+
+	var row map[string]any
+	assert.Equal(t, http.StatusOK, get(t, srv, "/players/keys/alice", &row))
+	assert.Equal(t, "alice", row["name"])
+	assert.Equal(t, "mage", row["class"])
+	assert.Equal(t, float64(21), row["age"])
+	assert.Equal(t, 10.5, row["balance"])
+	assert.Equal(t, float64(300), row["level"])
+	assert.NotContains(t, row, "mage")
+
+	assert.Equal(t, http.StatusNoContent, send(t, srv, http.MethodDelete, "/players/keys/alice", ""))
+	assert.Equal(t, http.StatusNotFound, get(t, srv, "/players/keys/alice", nil))
+
+	// Errors
+	assert.Equal(t, http.StatusNotFound, get(t, srv, "/removed/keys/alice", nil))
+	assert.Equal(t, http.StatusNotFound, send(t, srv, http.MethodDelete, "/players/keys/bob", ""))
+	assert.Equal(t, http.StatusBadRequest, send(t, srv, http.MethodPut, "/players/keys/bob", `{"age":`))
+	assert.Equal(t, http.StatusBadRequest, send(t, srv, http.MethodPut, "/players/keys/bob", `{"age":1.5}`))
+	assert.Equal(t, http.StatusBadRequest, send(t, srv, http.MethodPut, "/players/keys/bob", `{"level":70000}`))
+	assert.Equal(t, http.StatusMethodNotAllowed, send(t, srv, http.MethodPost, "/players/keys/bob", ""))
+	assert.Equal(t, http.StatusMethodNotAllowed, send(t, srv, http.MethodPost, "/players/scan", ""))
+	assert.Equal(t, http.StatusNotFound, get(t, srv, "/players/invalid", nil))
+}
+
+func TestScan(t *testing.T) {
+	srv := New()
+	srv.Register("players", newPlayers())
+	for i := 0; i < 10; i++ {
+		send(t, srv, http.MethodPut, fmt.Sprintf("/players/keys/p%d", i),
+			fmt.Sprintf(`{"class":"%s","age":%d}`, []string{"mage", "rogue"}[i%2], 20+i))
+	}
+
+	var pages [][]map[string]any
+	url := "/players/scan?q=mage&limit=2"
+	for {
+		var result scanResult
+		assert.Equal(t, http.StatusOK, get(t, srv, url, &result))
+		assert.Equal(t, 5, result.Count)
+		pages = append(pages, result.Rows)
+		if result.Next == nil {
+			break
+		}
+
+		url = fmt.Sprintf("/players/scan?q=mage&limit=2&after=%d", *result.Next)
+	}
+
+	assert.Len(t, pages, 3)
+	assert.Len(t, pages[2], 1)
+	assert.Equal(t, "p0", pages[0][0]["name"])
+	assert.Equal(t, "p8", pages[2][0]["name"])
+
+	var result scanResult
+	assert.Equal(t, http.StatusOK, get(t, srv, "/players/scan?q=age+>=+28", &result))
+	assert.Equal(t, 2, result.Count)
+	assert.Len(t, result.Rows, 2)
+	assert.Nil(t, result.Next)
+
+	// Errors
+	assert.Equal(t, http.StatusBadRequest, get(t, srv, "/players/scan?q=age+>", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, srv, "/players/scan?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, srv, "/players/scan?after=x", nil))
+}
+
+func TestSnapshot(t *testing.T) {
+	srv := New()
+	srv.Register("players", newPlayers())
+	send(t, srv, http.MethodPut, "/players/keys/alice", `{"class":"mage","age":20}`)
+	send(t, srv, http.MethodPut, "/players/keys/bob", `{"class":"rogue","age":30}`)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/players/snapshot", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+
+	restored := newPlayers()
+	assert.NoError(t, restored.Restore(w.Body))
+	assert.Equal(t, 2, restored.Count())
+	assert.NoError(t, restored.QueryKey("bob", func(r column.Row) error {
+		age, _ := r.Int("age")
+		assert.Equal(t, 30, age)
+		return nil
+	}))
+}
+
+func TestChanges(t *testing.T) {
+	players := newPlayers()
+	srv := New()
+	srv.Register("players", players)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := newRequest(ctx, ts.URL+"/players/changes?columns=age")
+	resp, err := ts.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	send(t, srv, http.MethodPut, "/players/keys/alice", `{"class":"mage","age":20}`)
+	send(t, srv, http.MethodPut, "/players/keys/alice", `{"age":21}`)
+	send(t, srv, http.MethodDelete, "/players/keys/alice", "")
+
+	var events []changeEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < 3 && scanner.Scan() {
+		var event changeEvent
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, "insert", events[0].Type)
+	assert.Equal(t, float64(20), events[0].Values["age"])
+	assert.Equal(t, "update", events[1].Type)
+	assert.Equal(t, float64(21), events[1].Values["age"])
+	assert.Equal(t, "delete", events[2].Type)
+}
+
+// newPlayers creates a new collection of players
+func newPlayers() *column.Collection {
+	players := column.NewCollection()
+	players.CreateColumn("name", column.ForKey())
+	players.CreateColumn("class", column.ForEnum())
+	players.CreateColumn("age", column.ForInt())
+	players.CreateColumn("balance", column.ForFloat64())
+	players.CreateColumn("level", column.ForInt16())
+	players.CreateIndex("mage", "class", func(r column.Reader) bool {
+		return r.String() == "mage"
+	})
+	return players
+}
+
+// newRequest creates a new GET request bound to the context
+func newRequest(ctx context.Context, url string) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+}
+
+// get performs a GET request against the handler and decodes the response
+func get(t *testing.T, handler http.Handler, url string, out any) int {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if out != nil {
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(out))
+	}
+	return w.Code
+}
+
+// send performs a request with a body against the handler
+func send(t *testing.T, handler http.Handler, method, url, body string) int {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	return w.Code
+}
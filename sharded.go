@@ -0,0 +1,142 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/zeebo/xxh3"
+)
+
+// Sharded represents a set of collections with the same schema, where the rows are
+// partitioned across the collections by the hash of their primary key. This reduces the
+// lock contention of write-heavy workloads running on many cores.
+type Sharded struct {
+	shards []*Collection // The underlying collections
+	next   uint32        // The next shard for inserts without a key
+}
+
+// NewSharded creates a new set of collections partitioned into the specified number of
+// shards. The columns and indexes need to be created on every shard with Each().
+func NewSharded(shards int, opts ...Options) *Sharded {
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := &Sharded{shards: make([]*Collection, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewCollection(opts...)
+	}
+	return s
+}
+
+// Each executes the function on every shard, sequentially. This is typically used to
+// create the columns and the indexes of all of the shards, for example:
+//
+//	s.Each(func(c *Collection) error {
+//		return c.CreateColumn("name", ForKey())
+//	})
+func (s *Sharded) Each(fn func(*Collection) error) error {
+	for _, c := range s.shards {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShardOf returns the shard which contains the row with the specified primary key.
+func (s *Sharded) ShardOf(key string) *Collection {
+	return s.shards[xxh3.HashString(key)%uint64(len(s.shards))]
+}
+
+// Insert inserts a row without a primary key. The rows are distributed across all of
+// the shards in a round-robin manner.
+func (s *Sharded) Insert(fn func(Row) error) error {
+	at := atomic.AddUint32(&s.next, 1) % uint32(len(s.shards))
+	_, err := s.shards[at].Insert(fn)
+	return err
+}
+
+// InsertKey inserts a row with the specified primary key into its shard.
+func (s *Sharded) InsertKey(key string, fn func(Row) error) error {
+	return s.ShardOf(key).InsertKey(key, fn)
+}
+
+// UpsertKey inserts or updates a row with the specified primary key in its shard.
+func (s *Sharded) UpsertKey(key string, fn func(Row) error) error {
+	return s.ShardOf(key).UpsertKey(key, fn)
+}
+
+// QueryKey queries a row with the specified primary key in its shard.
+func (s *Sharded) QueryKey(key string, fn func(Row) error) error {
+	return s.ShardOf(key).QueryKey(key, fn)
+}
+
+// DeleteKey deletes a row with the specified primary key from its shard.
+func (s *Sharded) DeleteKey(key string) error {
+	return s.ShardOf(key).DeleteKey(key)
+}
+
+// Query executes the transaction on every shard concurrently. Each of the shards commits
+// or rolls back its own transaction, and the first error encountered is returned.
+func (s *Sharded) Query(fn func(txn *Txn) error, opts ...QueryOption) error {
+	_, err := QueryMerge(s, func(txn *Txn) (struct{}, error) {
+		return struct{}{}, fn(txn)
+	}, func(a, b struct{}) struct{} {
+		return a
+	}, opts...)
+	return err
+}
+
+// Count returns the total number of rows of all of the shards.
+func (s *Sharded) Count() (count int) {
+	for _, c := range s.shards {
+		count += c.Count()
+	}
+	return
+}
+
+// Close closes all of the shards.
+func (s *Sharded) Close() error {
+	return s.Each(func(c *Collection) error {
+		return c.Close()
+	})
+}
+
+// QueryMerge executes the transaction on every shard concurrently and merges the results
+// of the shards with the merge function. For example, it can be used to sum a column
+// over all of the shards. The first error encountered is returned.
+func QueryMerge[T any](s *Sharded, fn func(txn *Txn) (T, error), merge func(a, b T) T, opts ...QueryOption) (result T, err error) {
+	results := make([]T, len(s.shards))
+	errs := make([]error, len(s.shards))
+
+	var wg sync.WaitGroup
+	wg.Add(len(s.shards))
+	for i, c := range s.shards {
+		go func(i int, c *Collection) {
+			defer wg.Done()
+			errs[i] = c.Query(func(txn *Txn) (err error) {
+				results[i], err = fn(txn)
+				return
+			}, opts...)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i := range s.shards {
+		if errs[i] != nil {
+			return result, errs[i]
+		}
+
+		switch i {
+		case 0:
+			result = results[i]
+		default:
+			result = merge(result, results[i])
+		}
+	}
+	return result, nil
+}
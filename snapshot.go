@@ -4,28 +4,105 @@
 package column
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
 	"github.com/kelindar/iostream"
-	"github.com/klauspost/compress/s2"
 )
 
 var (
 	errUnexpectedEOF = errors.New("column: unable to restore, unexpected EOF")
 )
 
+// ReplayGapError is returned by Replay when a commit does not directly follow the last one
+// replayed on its chunk, meaning that some of the commits in between were never received.
+type ReplayGapError struct {
+	Chunk commit.Chunk // The chunk of the commit
+	Last  uint64       // The ID of the last commit replayed on the chunk
+	Prev  uint64       // The ID of the commit preceding the one being replayed
+}
+
+// Error returns the error message
+func (e *ReplayGapError) Error() string {
+	return fmt.Sprintf("column: unable to replay, missing commits of chunk %d between %d and %d",
+		e.Chunk, e.Last, e.Prev)
+}
+
+// ErrChecksum is returned when restoring a snapshot whose content does not match its
+// checksums, which happens when the snapshot is corrupted.
+var ErrChecksum = errors.New("column: unable to restore, checksum mismatch")
+
+// ErrSnapshotVersion is returned when restoring a snapshot whose version is not supported,
+// such as a snapshot written by a newer version of this library.
+var ErrSnapshotVersion = errors.New("column: unable to restore, unsupported snapshot version")
+
+// The versions of the encoding of the snapshots
+const (
+	snapshotLegacy    = 0x1               // The encoding without the checksums of the columns
+	snapshotChecksums = 0x2               // The encoding with a checksum after each column of a chunk
+	snapshotVersion   = snapshotChecksums // The encoding of the snapshots being written
+)
+
+// snapshotMagic is written in the header of a snapshot, before its version. Since the
+// snapshots written before the header was introduced start with their version directly,
+// it can not be mistaken for one of them.
+const snapshotMagic = 0x434f4c53 // "COLS"
+
+// snapshotHeader is the encoded magic number at the beginning of the snapshots
+var snapshotHeader = binary.AppendUvarint(nil, snapshotMagic)
+
+// stateDecoder decodes the state of a snapshot which follows its header, and returns the
+// last commit IDs for each chunk.
+type stateDecoder func(c *Collection, r *iostream.Reader, verify bool, progress func(rows int)) (map[commit.Chunk]uint64, error)
+
+// stateDecoders contains the decoder of every version of the snapshots which can be restored.
+// When the encoding changes, snapshotVersion is incremented and the decoders of the previous
+// versions are kept, so the existing backups can still be restored.
+var stateDecoders = map[uint64]stateDecoder{
+	snapshotLegacy: func(c *Collection, r *iostream.Reader, verify bool, progress func(rows int)) (map[commit.Chunk]uint64, error) {
+		return c.readChunks(r, false, false, progress)
+	},
+	snapshotChecksums: func(c *Collection, r *iostream.Reader, verify bool, progress func(rows int)) (map[commit.Chunk]uint64, error) {
+		return c.readChunks(r, true, verify, progress)
+	},
+}
+
 // --------------------------- Commit Replay ---------------------------
 
-// Replay replays a commit on a collection, applying the changes.
+// Replay replays a commit on a collection, applying the changes. Commits which were
+// already included by a catch-up of SyncFrom or a restored snapshot are skipped. The ID of
+// the last commit replayed is also kept for each chunk and source collection, so that the
+// commits which were already applied are skipped, which allows for an at-least-once
+// delivery of the commits. If a commit does not directly follow the last one replayed from
+// its source, a *ReplayGapError is returned and the commit is not applied.
 func (c *Collection) Replay(change commit.Commit) error {
-	return c.Query(func(txn *Txn) error {
+	if change.ID > 0 && change.ID <= c.lastSynced(change.Chunk) {
+		return nil // Already part of the synchronized state
+	}
+
+	// The commit is checked, applied and marked under the replay lock of its chunk, so
+	// that a commit delivered twice concurrently is only applied once.
+	tracked := change.ID > 0 && change.Source > 0
+	if tracked {
+		c.rlock.Lock(uint(change.Chunk))
+		defer c.rlock.Unlock(uint(change.Chunk))
+		switch last := c.lastReplayed(change.Source, change.Chunk); {
+		case change.ID <= last:
+			return nil // Already applied
+		case last > 0 && change.Prev > last:
+			return &ReplayGapError{Chunk: change.Chunk, Last: last, Prev: change.Prev}
+		}
+	}
+
+	if err := c.Query(func(txn *Txn) error {
 		txn.dirty.Set(uint32(change.Chunk))
 		for i := range change.Updates {
 			if !change.Updates[i].IsEmpty() {
@@ -33,31 +110,121 @@ func (c *Collection) Replay(change commit.Commit) error {
 			}
 		}
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+
+	if tracked {
+		c.markReplayed(change.Source, change.Chunk, change.ID)
+	}
+	return nil
+}
+
+// lastReplayed returns the ID of the last commit of a source replayed on a chunk
+func (c *Collection) lastReplayed(source uint64, chunk commit.Chunk) uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if commits := c.replayed[source]; int(chunk) < len(commits) {
+		return commits[chunk]
+	}
+	return 0
+}
+
+// markReplayed records the ID of the last commit of a source replayed on a chunk
+func (c *Collection) markReplayed(source uint64, chunk commit.Chunk, commitID uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.replayed == nil {
+		c.replayed = make(map[uint64][]uint64, 1)
+	}
+
+	commits := c.replayed[source]
+	for len(commits) <= int(chunk) {
+		commits = append(commits, 0)
+	}
+
+	commits[chunk] = commitID
+	c.replayed[source] = commits
 }
 
 // --------------------------- Snapshotting ---------------------------
 
 // Restore restores the collection from the underlying snapshot reader. This operation
-// should be called before any of transactions, right after initialization.
-func (c *Collection) Restore(snapshot io.Reader) error {
-	commits, err := c.readState(s2.NewReader(snapshot))
+// should be called before any of transactions, right after initialization. The
+// compression of the snapshot is detected automatically.
+func (c *Collection) Restore(snapshot io.Reader, opts ...RestoreOption) error {
+	_, err := c.restore(snapshot, opts...)
+	return err
+}
+
+// restore restores the collection from the snapshot and returns the last commit ID of
+// each chunk which is part of the restored state.
+func (c *Collection) restore(snapshot io.Reader, opts ...RestoreOption) (map[commit.Chunk]uint64, error) {
+	if c.IsFrozen() {
+		return nil, ErrFrozen
+	}
+
+	var options restoreOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	src := &countingReader{Reader: snapshot}
+	body, state, release, err := decoders(src)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Report the number of bytes read from the snapshot, along with the rows restored
+	var progress func(rows int)
+	if options.progress != nil {
+		progress = func(rows int) {
+			options.progress(src.read, rows)
+		}
+	}
+
+	defer release()
+	commits, err := c.readStateWith(state, options.verify, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	// The commits which are part of the snapshot are no longer replayed
+	for chunk, commitID := range commits {
+		c.markSynced(chunk, commitID)
 	}
 
 	// Reconcile the pending commit log
-	return commit.Open(snapshot).Range(func(commit commit.Commit) error {
+	if err := commit.Open(body).Range(func(commit commit.Commit) error {
 		lastCommit := commits[commit.Chunk]
 		if commit.ID > lastCommit {
+			commits[commit.Chunk] = commit.ID
 			return c.Replay(commit)
 		}
 		return nil
-	})
+	}); err != nil {
+		return commits, err
+	}
+
+	// The sorted indexes are not part of the snapshot, rebuild them from their columns
+	c.rebuildSorted()
+	return commits, nil
 }
 
-// Snapshot writes a collection snapshot into the underlying writer.
-func (c *Collection) Snapshot(dst io.Writer) error {
+// Snapshot writes a collection snapshot into the underlying writer. By default, the state
+// is compressed with S2, which can be changed with the WithCompression option.
+func (c *Collection) Snapshot(dst io.Writer, opts ...SnapshotOption) error {
+	var options snapshotOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	defer c.observe(MetricSnapshotSeconds, time.Now())
+	out, state, err := options.encoders(dst)
+	if err != nil {
+		return err
+	}
+
 	recorder, err := c.recorderOpen()
 	if err != nil {
 		return err
@@ -65,13 +232,17 @@ func (c *Collection) Snapshot(dst io.Writer) error {
 
 	// Take a snapshot of the current state
 	defer os.Remove(recorder.Name())
-	if _, err := c.writeState(s2.NewWriter(dst)); err != nil {
+	defer c.recorderClose()
+	if _, err := c.writeStateWith(state, options.progress); err != nil {
 		return err
 	}
 
 	// Close the recorder
 	c.recorderClose()
-	return recorder.Copy(dst)
+	if err := recorder.Copy(out); err != nil {
+		return err
+	}
+	return out.Close()
 }
 
 // recorderOpen opens a recorder for commits while the snapshot is in progress
@@ -109,12 +280,21 @@ func (c *Collection) isSnapshotting() (*commit.Log, bool) {
 
 // writeState writes collection state into the specified writer.
 func (c *Collection) writeState(dst io.Writer) (int64, error) {
+	return c.writeStateWith(dst, nil)
+}
+
+// writeStateWith writes collection state into the specified writer, and reports the
+// number of chunks written to the optional progress callback.
+func (c *Collection) writeStateWith(dst io.Writer, progress func(written, total int)) (int64, error) {
 	writer := iostream.NewWriter(dst)
 	buffer := c.txns.acquirePage(rowColumn)
 	defer c.txns.releasePage(buffer)
 
-	// Write the schema version
-	if err := writer.WriteUvarint(0x1); err != nil {
+	// Write the header, followed by the version of the encoding
+	if err := writer.WriteUvarint(snapshotMagic); err != nil {
+		return writer.Offset(), err
+	}
+	if err := writer.WriteUvarint(snapshotVersion); err != nil {
 		return writer.Offset(), err
 	}
 
@@ -129,31 +309,16 @@ func (c *Collection) writeState(dst io.Writer) (int64, error) {
 
 	// Write each chunk
 	if err := writer.WriteRange(chunks, func(i int, w *iostream.Writer) error {
-		return c.readChunk(commit.Chunk(i), func(lastCommit uint64, chunk commit.Chunk, fill bitmap.Bitmap) error {
-			offset := chunk.Min()
-
-			// Write the last written commit for this chunk
-			if err := writer.WriteUvarint(lastCommit); err != nil {
-				return err
-			}
-
-			// Write the inserts column
-			buffer.Reset(rowColumn)
-			fill.Range(func(idx uint32) {
-				buffer.PutOperation(commit.Insert, offset+idx)
-			})
-			if err := writer.WriteSelf(buffer); err != nil {
-				return err
-			}
+		if err := c.readChunk(commit.Chunk(i), func(lastCommit uint64, chunk commit.Chunk, fill bitmap.Bitmap) error {
+			return c.writeChunk(writer, buffer, lastCommit, chunk, fill, true)
+		}); err != nil {
+			return err
+		}
 
-			// Snapshot each column and write the buffer
-			return c.cols.RangeUntil(func(column *column) error {
-				if !column.Snapshot(chunk, buffer) {
-					return nil // Skip indexes
-				}
-				return writer.WriteSelf(buffer)
-			})
-		})
+		if progress != nil {
+			progress(i+1, chunks)
+		}
+		return nil
 	}); err != nil {
 		return writer.Offset(), err
 	}
@@ -161,18 +326,75 @@ func (c *Collection) writeState(dst io.Writer) (int64, error) {
 	return writer.Offset(), writer.Flush()
 }
 
+// writeChunk writes the state of a chunk, which must be read-locked, into the writer. If
+// required, the checksum of each column is written right after it.
+func (c *Collection) writeChunk(writer *iostream.Writer, buffer *commit.Buffer, lastCommit uint64, chunk commit.Chunk, fill bitmap.Bitmap, checksums bool) error {
+	offset := chunk.Min()
+	writeColumn := func(buffer *commit.Buffer) error {
+		if err := writer.WriteSelf(buffer); err != nil || !checksums {
+			return err
+		}
+		return writer.WriteUint32(buffer.Checksum())
+	}
+
+	// Write the last written commit for this chunk
+	if err := writer.WriteUvarint(lastCommit); err != nil {
+		return err
+	}
+
+	// Write the inserts column
+	buffer.Reset(rowColumn)
+	fill.Range(func(idx uint32) {
+		buffer.PutOperation(commit.Insert, offset+idx)
+	})
+	if err := writeColumn(buffer); err != nil {
+		return err
+	}
+
+	// Snapshot each column and write the buffer
+	return c.cols.RangeUntil(func(column *column) error {
+		if !column.Snapshot(chunk, buffer) {
+			return nil // Skip indexes
+		}
+		return writeColumn(buffer)
+	})
+}
+
 // readState reads a collection snapshotted state from the underlying reader. It
 // returns the last commit IDs for each chunk.
 func (c *Collection) readState(src io.Reader) (map[commit.Chunk]uint64, error) {
+	return c.readStateWith(src, false, nil)
+}
+
+// readStateWith reads a collection snapshotted state from the underlying reader. If
+// required, the checksums of the columns are verified and the number of rows restored
+// is reported to the progress callback after each chunk.
+func (c *Collection) readStateWith(src io.Reader, verify bool, progress func(rows int)) (map[commit.Chunk]uint64, error) {
 	r := iostream.NewReader(src)
-	commits := make(map[commit.Chunk]uint64)
 
-	// Read the version and make sure it matches
+	// Read the version, which is preceded by the header unless the snapshot was written
+	// before the header was introduced.
 	version, err := r.ReadUvarint()
-	if err != nil || version != 0x1 {
-		return nil, fmt.Errorf("column: unable to restore (version %d) %v", version, err)
+	if err == nil && version == snapshotMagic {
+		version, err = r.ReadUvarint()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("column: unable to restore, %w", err)
 	}
 
+	// Decode the state with the decoder of its version
+	decode, ok := stateDecoders[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrSnapshotVersion, version)
+	}
+	return decode(c, r, verify, progress)
+}
+
+// readChunks reads the chunks of a snapshotted state, following its header. If present,
+// the checksums of the columns are read and verified if required.
+func (c *Collection) readChunks(r *iostream.Reader, checksums, verify bool, progress func(rows int)) (map[commit.Chunk]uint64, error) {
+	commits := make(map[commit.Chunk]uint64)
+
 	// Read the number of columns
 	columns, err := r.ReadUvarint()
 	if err != nil {
@@ -181,7 +403,7 @@ func (c *Collection) readState(src io.Reader) (map[commit.Chunk]uint64, error) {
 
 	// Read each chunk
 	return commits, r.ReadRange(func(chunk int, r *iostream.Reader) error {
-		return c.Query(func(txn *Txn) error {
+		if err := c.Query(func(txn *Txn) error {
 			txn.dirty.Set(uint32(chunk))
 
 			// Read the last written commit ID for the chunk
@@ -200,13 +422,42 @@ func (c *Collection) readState(src io.Reader) (map[commit.Chunk]uint64, error) {
 				default:
 					txn.updates = append(txn.updates, buffer)
 				}
+
+				if checksums {
+					if err := readChecksum(r, buffer, verify); err != nil {
+						return err
+					}
+				}
 			}
 
 			return nil
-		})
+		}); err != nil {
+			return fmt.Errorf("%w (chunk %d)", err, chunk)
+		}
+
+		if progress != nil {
+			progress(c.Count())
+		}
+		return nil
 	})
 }
 
+// readChecksum reads the checksum written after a column and, if required, verifies it
+// against the content of the buffer.
+func readChecksum(r *iostream.Reader, buffer *commit.Buffer, verify bool) error {
+	checksum, err := r.ReadUint32()
+	switch {
+	case err == io.EOF:
+		return errUnexpectedEOF
+	case err != nil:
+		return err
+	case verify && checksum != buffer.Checksum():
+		return fmt.Errorf("%w of column '%s'", ErrChecksum, buffer.Column)
+	default:
+		return nil
+	}
+}
+
 // chunks returns the number of chunks and columns
 func (c *Collection) chunks() int {
 	c.lock.Lock()
@@ -224,9 +475,13 @@ func (c *Collection) chunks() int {
 func (c *Collection) readChunk(chunk commit.Chunk, fn func(uint64, commit.Chunk, bitmap.Bitmap) error) error {
 
 	// Lock both the chunk and the fill list
-	c.slock.RLock(uint(chunk))
-	c.lock.Lock()
+	err := c.readLockChunk(chunk)
 	defer c.slock.RUnlock(uint(chunk))
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
 	defer c.lock.Unlock()
 	return fn(c.commits[chunk], chunk, chunk.OfBitmap(c.fill))
 }
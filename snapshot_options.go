@@ -0,0 +1,277 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression represents the compression codec of a snapshot
+type Compression uint8
+
+// Various compression codecs supported by the snapshots
+const (
+	CompressionS2   Compression = iota // The S2 compression, used by default
+	CompressionNone                    // No compression of the state
+	CompressionZstd                    // The Zstandard compression of the entire snapshot
+)
+
+// zstdMagic is the magic number at the beginning of a Zstandard frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// SnapshotOption represents an option of a snapshot
+type SnapshotOption func(*snapshotOptions)
+
+// snapshotOptions represents the options of a snapshot
+type snapshotOptions struct {
+	compression Compression              // The compression codec
+	progress    func(written, total int) // The progress callback (optional)
+}
+
+// WithCompression sets the compression codec of the snapshot. The codec is detected when
+// the snapshot is restored.
+func WithCompression(codec Compression) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.compression = codec
+	}
+}
+
+// WithProgress sets a callback which is invoked every time a chunk of the collection is
+// written into the snapshot, with the number of chunks written and the total number of
+// chunks to write.
+func WithProgress(fn func(written, total int)) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.progress = fn
+	}
+}
+
+// RestoreOption represents an option of a restore
+type RestoreOption func(*restoreOptions)
+
+// restoreOptions represents the options of a restore
+type restoreOptions struct {
+	verify   bool                       // Whether the checksums are verified
+	progress func(read int64, rows int) // The progress callback (optional)
+}
+
+// WithVerify verifies the checksums of the columns while the snapshot is restored, and
+// fails with ErrChecksum if the snapshot is corrupted. The snapshots written before the
+// checksums were introduced can still be restored, but are not verified.
+func WithVerify() RestoreOption {
+	return func(o *restoreOptions) {
+		o.verify = true
+	}
+}
+
+// WithRestoreProgress sets a callback which is invoked every time a chunk of the collection
+// is restored, with the number of bytes read from the snapshot so far and the number of
+// rows restored.
+func WithRestoreProgress(fn func(read int64, rows int)) RestoreOption {
+	return func(o *restoreOptions) {
+		o.progress = fn
+	}
+}
+
+// encoders returns the writer of the entire snapshot and the writer of the state
+func (o *snapshotOptions) encoders(dst io.Writer) (io.WriteCloser, io.Writer, error) {
+	switch o.compression {
+	case CompressionS2:
+		return nopCloser{dst}, s2.NewWriter(dst), nil
+	case CompressionNone:
+		return nopCloser{dst}, dst, nil
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(dst)
+		return enc, enc, err
+	default:
+		return nil, nil, fmt.Errorf("column: unable to snapshot, unsupported compression %d", o.compression)
+	}
+}
+
+// decoders detects the compression of the snapshot and returns the reader of the entire
+// snapshot, the reader of the state and a function releasing the decoder.
+func decoders(src io.Reader) (*bufio.Reader, io.Reader, func(), error) {
+	body := bufio.NewReader(src)
+	magic, _ := body.Peek(len(snapshotHeader))
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		body = bufio.NewReader(dec)
+		return body, body, dec.Close, nil
+	case bytes.Equal(magic, snapshotHeader):
+		return body, body, func() {}, nil
+	case len(magic) > 0 && (magic[0] == snapshotChecksums || magic[0] == snapshotLegacy):
+		return body, body, func() {}, nil
+	default:
+		return body, s2.NewReader(body), func() {}, nil
+	}
+}
+
+// nopCloser represents a writer with a no-op Close method
+type nopCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer interface
+func (nopCloser) Close() error {
+	return nil
+}
+
+// countingReader represents a reader which counts the bytes read
+type countingReader struct {
+	io.Reader
+	read int64 // The number of bytes read
+}
+
+// Read implements io.Reader interface
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+// --------------------------- Snapshot Parts ---------------------------
+
+// WriterFactory creates the writer of a part of a snapshot, given its sequence number
+// starting at zero.
+type WriterFactory func(part int) (io.WriteCloser, error)
+
+// SnapshotParts writes a collection snapshot split into parts of at most partSize bytes,
+// each of them written into a writer created by the factory. This allows a large snapshot
+// to be uploaded in parallel, for example into an object storage. The parts must be given
+// back in the same order to RestoreParts. It returns the number of parts written.
+func (c *Collection) SnapshotParts(factory WriterFactory, partSize int64, opts ...SnapshotOption) (int, error) {
+	if partSize <= 0 {
+		return 0, fmt.Errorf("column: unable to snapshot, invalid part size %d", partSize)
+	}
+
+	dst := &partWriter{factory: factory, size: partSize}
+	if err := c.Snapshot(dst, opts...); err != nil {
+		dst.Close()
+		return dst.count, err
+	}
+
+	return dst.count, dst.Close()
+}
+
+// RestoreParts restores the collection from a snapshot written by SnapshotParts. The parts
+// are opened one at a time, in order, and are closed once they are read.
+func (c *Collection) RestoreParts(parts int, open func(part int) (io.ReadCloser, error), opts ...RestoreOption) error {
+	src := &partReader{open: open, count: parts}
+	defer src.Close()
+	return c.Restore(src, opts...)
+}
+
+// partWriter represents a writer which splits the output into parts of a bounded size
+type partWriter struct {
+	factory WriterFactory  // The factory of the parts
+	size    int64          // The maximum size of a part
+	part    io.WriteCloser // The part being written
+	written int64          // The number of bytes written into the current part
+	count   int            // The number of parts created
+}
+
+// Write implements io.Writer interface
+func (w *partWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if w.part == nil || w.written >= w.size {
+			if err := w.next(); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p
+		if remaining := w.size - w.written; int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+
+		m, err := w.part.Write(chunk)
+		n += m
+		w.written += int64(m)
+		p = p[m:]
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// next closes the current part and creates the next one
+func (w *partWriter) next() (err error) {
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	w.part, err = w.factory(w.count)
+	w.written = 0
+	w.count++
+	return
+}
+
+// Close closes the current part
+func (w *partWriter) Close() error {
+	if w.part == nil {
+		return nil
+	}
+
+	part := w.part
+	w.part = nil
+	return part.Close()
+}
+
+// partReader represents a reader which reads the parts one after another
+type partReader struct {
+	open  func(part int) (io.ReadCloser, error) // The function opening a part
+	part  io.ReadCloser                         // The part being read
+	next  int                                   // The sequence number of the next part
+	count int                                   // The number of parts
+}
+
+// Read implements io.Reader interface
+func (r *partReader) Read(p []byte) (int, error) {
+	for {
+		if r.part == nil {
+			if r.next >= r.count {
+				return 0, io.EOF
+			}
+
+			part, err := r.open(r.next)
+			if err != nil {
+				return 0, err
+			}
+
+			r.part = part
+			r.next++
+		}
+
+		n, err := r.part.Read(p)
+		if err == io.EOF {
+			err = r.Close()
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// Close closes the part being read
+func (r *partReader) Close() error {
+	if r.part == nil {
+		return nil
+	}
+
+	part := r.part
+	r.part = nil
+	return part.Close()
+}
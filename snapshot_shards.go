@@ -0,0 +1,164 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	manifestFile    = "manifest.json"
+	manifestVersion = 1
+)
+
+// --------------------------- Manifest ---------------------------
+
+// Manifest describes a set of shard snapshots which together form one logical dataset.
+type Manifest struct {
+	Version int          `json:"version"` // The version of the manifest format
+	Created time.Time    `json:"created"` // The time at which the snapshot was taken
+	Shards  []ShardEntry `json:"shards"`  // The shard snapshots, in the order of the shards
+}
+
+// ShardEntry describes a snapshot of a single shard.
+type ShardEntry struct {
+	File string `json:"file"` // The snapshot file name, relative to the directory
+	Rows int    `json:"rows"` // The number of rows at the time of the snapshot
+	Size int64  `json:"size"` // The size of the snapshot file in bytes
+}
+
+// ReadManifest reads the shard manifest from the specified snapshot directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(Manifest)
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("column: unable to read manifest, %w", err)
+	}
+
+	if out.Version != manifestVersion {
+		return nil, fmt.Errorf("column: unable to read manifest, unsupported version %d", out.Version)
+	}
+	return out, nil
+}
+
+// --------------------------- Shard Snapshotting ---------------------------
+
+// SnapshotShards writes a snapshot of each shard into its own file in the directory, in
+// parallel, and then writes a manifest describing the set. The manifest is written last,
+// so a directory without a manifest contains an incomplete snapshot.
+func SnapshotShards(dir string, shards ...*Collection) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	manifest := Manifest{
+		Version: manifestVersion,
+		Created: time.Now().UTC(),
+		Shards:  make([]ShardEntry, len(shards)),
+	}
+
+	if err := forEachShard(shards, func(i int, shard *Collection) error {
+		entry := &manifest.Shards[i]
+		entry.File = fmt.Sprintf("shard-%04d.snap", i)
+		entry.Rows = shard.Count()
+		return snapshotShard(filepath.Join(dir, entry.File), shard, entry)
+	}); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write the manifest atomically, so a reader never sees a partial manifest
+	tmp := filepath.Join(dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, manifestFile))
+}
+
+// snapshotShard writes a snapshot of a single shard into the file
+func snapshotShard(name string, shard *Collection, entry *ShardEntry) error {
+	dst, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer dst.Close()
+	if err := shard.Snapshot(dst); err != nil {
+		return err
+	}
+
+	stat, err := dst.Stat()
+	if err != nil {
+		return err
+	}
+
+	entry.Size = stat.Size()
+	return dst.Sync()
+}
+
+// RestoreShards restores each shard from the snapshot directory written by SnapshotShards,
+// in parallel. The number of shards must match the manifest and the shards should be
+// freshly created collections with the same schema as the ones snapshotted.
+func RestoreShards(dir string, shards ...*Collection) error {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(manifest.Shards) != len(shards) {
+		return fmt.Errorf("column: unable to restore, manifest has %d shards but %d were given",
+			len(manifest.Shards), len(shards))
+	}
+
+	return forEachShard(shards, func(i int, shard *Collection) error {
+		entry := manifest.Shards[i]
+		src, err := os.Open(filepath.Join(dir, entry.File))
+		if err != nil {
+			return err
+		}
+
+		defer src.Close()
+		if stat, err := src.Stat(); err != nil || stat.Size() != entry.Size {
+			return fmt.Errorf("column: unable to restore, shard file '%s' is corrupted", entry.File)
+		}
+
+		return shard.Restore(src)
+	})
+}
+
+// forEachShard runs the function on every shard concurrently and returns the first error
+func forEachShard(shards []*Collection, fn func(int, *Collection) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(shards))
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard *Collection) {
+			defer wg.Done()
+			if err := fn(i, shard); err != nil {
+				errs[i] = fmt.Errorf("column: shard %d: %w", i, err)
+			}
+		}(i, shard)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
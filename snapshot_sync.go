@@ -0,0 +1,213 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/kelindar/iostream"
+)
+
+// syncVersion is the version of the replication protocol
+const syncVersion = 0x1
+
+// --------------------------- Primary ---------------------------
+
+// AcceptSync reads a catch-up request sent by a replica with SyncFrom and serves the
+// chunks which were modified since the last synchronization of the replica.
+func (c *Collection) AcceptSync(conn io.ReadWriter) error {
+	r := iostream.NewReader(conn)
+	version, err := r.ReadUvarint()
+	if err != nil || version != syncVersion {
+		return fmt.Errorf("column: unable to sync (version %d) %v", version, err)
+	}
+
+	sinceID, err := r.ReadUvarint()
+	if err != nil {
+		return err
+	}
+
+	return c.ServeSync(conn, sinceID)
+}
+
+// ServeSync writes the state of every chunk which was modified by a commit with an ID
+// greater than the specified one. The response starts with a new commit ID, which every
+// commit included in the response precedes, and which the replica should use as the
+// starting point of its next catch-up.
+func (c *Collection) ServeSync(dst io.Writer, sinceID uint64) error {
+	writer := iostream.NewWriter(dst)
+	buffer := c.txns.acquirePage(rowColumn)
+	defer c.txns.releasePage(buffer)
+
+	// The commit IDs are taken while holding the chunk locks, so any commit with a
+	// smaller ID has already locked its chunk and is visible once the chunk is read.
+	watermark := commit.Next()
+	if err := writer.WriteUvarint(syncVersion); err != nil {
+		return err
+	}
+	if err := writer.WriteUvarint(watermark); err != nil {
+		return err
+	}
+
+	// Write every chunk that was modified, including the ones that became empty
+	c.lock.RLock()
+	chunks := len(c.commits)
+	c.lock.RUnlock()
+	for i := 0; i < chunks; i++ {
+		if err := c.readChunk(commit.Chunk(i), func(lastCommit uint64, chunk commit.Chunk, fill bitmap.Bitmap) error {
+			if lastCommit <= sinceID {
+				return nil // Already synchronized
+			}
+
+			if err := writer.WriteUvarint(uint64(chunk) + 1); err != nil {
+				return err
+			}
+			if err := writer.WriteUvarint(uint64(c.cols.Count()) + 1); err != nil { // extra 'insert' column
+				return err
+			}
+			return c.writeChunk(writer, buffer, lastCommit, chunk, fill, false)
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Write the end of the stream
+	if err := writer.WriteUvarint(0); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// --------------------------- Replica ---------------------------
+
+// SyncFrom requests a catch-up from a primary collection serving AcceptSync on the other
+// end of the connection, and replaces the state of every chunk which was modified since
+// the previous synchronization. The replica keeps track of the last commit ID applied to
+// each chunk, so the commits of the live stream which are already part of the state are
+// skipped by Replay. The replica should not be written to, other than by SyncFrom and
+// Replay.
+func (c *Collection) SyncFrom(conn io.ReadWriter) error {
+	w := iostream.NewWriter(conn)
+	if err := w.WriteUvarint(syncVersion); err != nil {
+		return err
+	}
+	if err := w.WriteUvarint(c.SyncID()); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Read the header of the response
+	r := iostream.NewReader(conn)
+	version, err := r.ReadUvarint()
+	if err != nil || version != syncVersion {
+		return fmt.Errorf("column: unable to sync (version %d) %v", version, err)
+	}
+
+	watermark, err := r.ReadUvarint()
+	if err != nil {
+		return err
+	}
+
+	// Read the chunks until the end of the stream
+	for {
+		next, err := r.ReadUvarint()
+		switch {
+		case err != nil:
+			return err
+		case next == 0:
+			atomic.StoreUint64(&c.syncID, watermark)
+			return nil
+		}
+
+		if err := c.syncChunk(r, commit.Chunk(next-1)); err != nil {
+			return err
+		}
+	}
+}
+
+// SyncID returns the commit ID from which the next catch-up of a replica starts, or zero
+// if the collection was never synchronized.
+func (c *Collection) SyncID() uint64 {
+	return atomic.LoadUint64(&c.syncID)
+}
+
+// syncChunk reads the state of a chunk and replaces the current one with it.
+func (c *Collection) syncChunk(r *iostream.Reader, chunk commit.Chunk) error {
+	columns, err := r.ReadUvarint()
+	if err != nil {
+		return err
+	}
+
+	lastCommit, err := r.ReadUvarint()
+	if err != nil {
+		return err
+	}
+
+	if err := c.Query(func(txn *Txn) error {
+		txn.dirty.Set(uint32(chunk))
+
+		// Delete all of the rows of the chunk, they are inserted back from the state
+		rows := txn.bufferFor(rowColumn)
+		c.lock.RLock()
+		chunk.Range(c.fill, func(idx uint32) {
+			rows.PutOperation(commit.Delete, idx)
+		})
+		c.lock.RUnlock()
+
+		for i := uint64(0); i < columns; i++ {
+			buffer := txn.owner.txns.acquirePage("")
+			_, err := buffer.ReadFrom(r)
+			switch {
+			case err == io.EOF:
+				txn.owner.txns.releasePage(buffer)
+				return errUnexpectedEOF
+			case err != nil:
+				txn.owner.txns.releasePage(buffer)
+				return err
+			case buffer.Column == rowColumn:
+				txn.reader.Seek(buffer)
+				for txn.reader.Next() {
+					rows.PutOperation(commit.Insert, txn.reader.Index())
+				}
+				txn.owner.txns.releasePage(buffer)
+			default:
+				txn.updates = append(txn.updates, buffer)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Keep track of the last commit applied to the chunk
+	c.markSynced(chunk, lastCommit)
+	return nil
+}
+
+// lastSynced returns the ID of the last commit of the primary which is part of the state
+// of a chunk, or zero if unknown.
+func (c *Collection) lastSynced(chunk commit.Chunk) uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if int(chunk) < len(c.synced) {
+		return c.synced[chunk]
+	}
+	return 0
+}
+
+// markSynced records the ID of the last commit of the primary applied to a chunk
+func (c *Collection) markSynced(chunk commit.Chunk, commitID uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for len(c.synced) <= int(chunk) {
+		c.synced = append(c.synced, 0)
+	}
+	c.synced[chunk] = commitID
+}
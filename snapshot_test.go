@@ -10,11 +10,13 @@ import (
 	"io"
 	"math"
 	"math/rand"
+	"net"
 	"os"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kelindar/async"
 	"github.com/kelindar/column/commit"
@@ -204,6 +206,246 @@ func TestSnapshot(t *testing.T) {
 	assert.Equal(t, amount, output.Count())
 }
 
+func TestSnapshotCompression(t *testing.T) {
+	amount := 50000
+	input := loadPlayers(amount)
+	for _, codec := range []Compression{CompressionS2, CompressionNone, CompressionZstd} {
+		t.Run(fmt.Sprintf("codec-%d", codec), func(t *testing.T) {
+			var written, total int
+			buffer := bytes.NewBuffer(nil)
+			assert.NoError(t, input.Snapshot(buffer, WithCompression(codec), WithProgress(func(w, n int) {
+				assert.Equal(t, written+1, w)
+				written, total = w, n
+			})))
+			assert.Equal(t, 4, total)
+			assert.Equal(t, total, written)
+
+			// Restore the snapshot, the codec is detected
+			output := newEmpty(amount)
+			assert.NoError(t, output.Restore(buffer))
+			assert.Equal(t, amount, output.Count())
+		})
+	}
+
+	assert.Error(t, input.Snapshot(io.Discard, WithCompression(Compression(42))))
+}
+
+func TestSnapshotParts(t *testing.T) {
+	amount := 50000
+	input := loadPlayers(amount)
+
+	// Write the snapshot in parts of a bounded size
+	var parts []*bytes.Buffer
+	count, err := input.SnapshotParts(func(part int) (io.WriteCloser, error) {
+		assert.Equal(t, len(parts), part)
+		parts = append(parts, bytes.NewBuffer(nil))
+		return nopCloser{parts[part]}, nil
+	}, 64*1024, WithCompression(CompressionZstd))
+	assert.NoError(t, err)
+	assert.Equal(t, len(parts), count)
+	assert.Greater(t, count, 1)
+	for _, part := range parts[:count-1] {
+		assert.Equal(t, 64*1024, part.Len())
+	}
+
+	// Restore the snapshot from the parts
+	output := newEmpty(amount)
+	assert.NoError(t, output.RestoreParts(count, func(part int) (io.ReadCloser, error) {
+		return io.NopCloser(parts[part]), nil
+	}))
+	assert.Equal(t, amount, output.Count())
+
+	// Failures to create a part are returned
+	_, err = input.SnapshotParts(func(part int) (io.WriteCloser, error) {
+		return nil, io.ErrClosedPipe
+	}, 1024)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	assert.NoError(t, input.Snapshot(io.Discard))
+	_, err = input.SnapshotParts(nil, 0)
+	assert.Error(t, err)
+}
+
+func TestRestoreVerify(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("name", ForString())
+	for i := 0; i < 100; i++ {
+		input.Insert(func(r Row) error {
+			r.SetString("name", "Roman")
+			return nil
+		})
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer, WithCompression(CompressionNone)))
+	encoded := buffer.Bytes()
+
+	// Restore with the verification, while reporting the progress
+	var read int64
+	var rows int
+	output := NewCollection()
+	output.CreateColumn("name", ForString())
+	assert.NoError(t, output.Restore(bytes.NewReader(encoded), WithVerify(), WithRestoreProgress(func(n int64, count int) {
+		read, rows = n, count
+	})))
+	assert.Equal(t, int64(len(encoded)), read)
+	assert.Equal(t, 100, rows)
+
+	// Corrupt one of the values, which is only detected by the verification
+	corrupted := bytes.Replace(encoded, []byte("Roman"), []byte("Rxman"), 1)
+	assert.NotEqual(t, encoded, corrupted)
+
+	output = NewCollection()
+	output.CreateColumn("name", ForString())
+	err := output.Restore(bytes.NewReader(corrupted), WithVerify())
+	assert.ErrorIs(t, err, ErrChecksum)
+	assert.Contains(t, err.Error(), "'name'")
+
+	output = NewCollection()
+	output.CreateColumn("name", ForString())
+	assert.NoError(t, output.Restore(bytes.NewReader(corrupted)))
+}
+
+func TestRestoreVersion(t *testing.T) {
+	input := NewCollection()
+	input.CreateColumn("name", ForString())
+	for i := 0; i < 100; i++ {
+		input.Insert(func(r Row) error {
+			r.SetString("name", "Roman")
+			return nil
+		})
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer, WithCompression(CompressionNone)))
+	encoded := buffer.Bytes()
+	assert.True(t, bytes.HasPrefix(encoded, append(snapshotHeader, snapshotVersion)))
+
+	// The snapshots written before the header was introduced can still be restored
+	for _, snapshot := range [][]byte{encoded, encoded[len(snapshotHeader):]} {
+		output := NewCollection()
+		output.CreateColumn("name", ForString())
+		assert.NoError(t, output.Restore(bytes.NewReader(snapshot), WithVerify()))
+		assert.Equal(t, 100, output.Count())
+	}
+
+	// An unknown version is reported as such
+	unknown := append(append([]byte{}, snapshotHeader...), encoded[len(snapshotHeader):]...)
+	unknown[len(snapshotHeader)] = 0x7f
+	output := NewCollection()
+	output.CreateColumn("name", ForString())
+	err := output.Restore(bytes.NewReader(unknown))
+	assert.ErrorIs(t, err, ErrSnapshotVersion)
+	assert.Contains(t, err.Error(), "127")
+}
+
+func TestRestoreSortIndex(t *testing.T) {
+	input := loadPlayers(500)
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, input.Snapshot(buffer))
+
+	// The sorted index exists, with keys which are overwritten by the snapshot
+	output := newEmpty(500)
+	assert.NoError(t, output.CreateSortIndex("by_balance", "balance"))
+	for i := 0; i < 10; i++ {
+		output.Insert(func(r Row) error {
+			r.SetFloat64("balance", -float64(i))
+			return nil
+		})
+	}
+
+	assert.NoError(t, output.Restore(buffer))
+	assert.NoError(t, output.Query(func(txn *Txn) error {
+		count, last := 0, math.Inf(-1)
+		balance := txn.Float64("balance")
+		assert.NoError(t, txn.Ascend("by_balance", func(idx uint32) {
+			v, _ := balance.Get()
+			assert.GreaterOrEqual(t, v, last)
+			count, last = count+1, v
+		}))
+		assert.Equal(t, 500, count)
+		return nil
+	}))
+
+	// Scans do not block the concurrent updates
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			output.QueryAt(uint32(i), func(r Row) error {
+				r.SetFloat64("balance", float64(i))
+				return nil
+			})
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		output.Query(func(txn *Txn) error {
+			return txn.Descend("by_balance", func(idx uint32) {})
+		})
+	}
+	wg.Wait()
+}
+
+func TestCheckpoint(t *testing.T) {
+	policy := CheckpointPolicy{Interval: time.Hour, Dir: t.TempDir(), Keep: 2}
+	newCollection := func() *Collection {
+		c := NewCollection(Options{Checkpoint: policy})
+		c.CreateColumn("name", ForString())
+		c.CreateColumn("balance", ForInt())
+		return c
+	}
+
+	// Insert some rows, checkpoint and then keep writing
+	input := newCollection()
+	for i := 0; i < 100; i++ {
+		input.Insert(func(r Row) error {
+			r.SetString("name", fmt.Sprintf("player %d", i))
+			r.SetInt("balance", 10)
+			return nil
+		})
+	}
+
+	assert.NoError(t, input.Checkpoint())
+	input.Query(func(txn *Txn) error {
+		balance := txn.Int("balance")
+		return txn.Range(func(idx uint32) {
+			balance.Merge(5)
+		})
+	})
+	input.Insert(func(r Row) error {
+		r.SetString("name", "late player")
+		return nil
+	})
+	assert.NoError(t, input.Close())
+
+	// Restore the snapshot along with the commits made after it
+	output := newCollection()
+	assert.NoError(t, output.RestoreCheckpoint())
+	assert.Equal(t, 101, output.Count())
+	output.Query(func(txn *Txn) error {
+		assert.Equal(t, 1500, txn.Int("balance").Sum())
+		return nil
+	})
+
+	// Only the last snapshots are kept, along with the commit logs since the oldest one
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, output.Checkpoint())
+	}
+
+	snapshots, err := output.checkpoint.list(checkpointSnapshot, ".bin")
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	logs, err := output.checkpoint.list(checkpointLog, ".log")
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2)
+	assert.NoError(t, output.Close())
+
+	// Without a policy, the checkpoints are not available
+	assert.Error(t, newEmpty(10).Checkpoint())
+	assert.Error(t, newEmpty(10).RestoreCheckpoint())
+}
+
 func TestLargeSnapshot(t *testing.T) {
 	const amount = 3_000_000
 
@@ -446,3 +688,435 @@ func (w *limitWriter) Write(p []byte) (int, error) {
 func (w *limitWriter) Read(p []byte) (int, error) {
 	return 0, nil
 }
+
+func TestSnapshotShards(t *testing.T) {
+	dir := t.TempDir()
+	shards := []*Collection{loadPlayers(500), loadPlayers(500), newEmpty(100)}
+	shards[1].Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	})
+
+	assert.NoError(t, SnapshotShards(dir, shards...))
+	manifest, err := ReadManifest(dir)
+	assert.NoError(t, err)
+	assert.Len(t, manifest.Shards, 3)
+	assert.Equal(t, 500, manifest.Shards[0].Rows)
+	assert.Equal(t, shards[1].Count(), manifest.Shards[1].Rows)
+	assert.Equal(t, 0, manifest.Shards[2].Rows)
+
+	// Restore into fresh shards
+	restored := []*Collection{newEmpty(100), newEmpty(100), newEmpty(100)}
+	assert.NoError(t, RestoreShards(dir, restored...))
+	for i := range shards {
+		assert.Equal(t, shards[i].Count(), restored[i].Count())
+	}
+
+	restored[1].Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("human").Count())
+		return nil
+	})
+
+	// Mismatched number of shards
+	assert.Error(t, RestoreShards(dir, newEmpty(100)))
+
+	// Corrupted shard file
+	assert.NoError(t, os.WriteFile(dir+"/shard-0001.snap", []byte("x"), 0644))
+	assert.Error(t, RestoreShards(dir, newEmpty(100), newEmpty(100), newEmpty(100)))
+
+	// Missing or invalid manifest
+	_, err = ReadManifest(t.TempDir())
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(dir+"/manifest.json", []byte(`{"version":9}`), 0644))
+	assert.Error(t, RestoreShards(dir, restored...))
+	assert.NoError(t, os.WriteFile(dir+"/manifest.json", []byte(`{`), 0644))
+	assert.Error(t, RestoreShards(dir, restored...))
+}
+
+func TestSyncFrom(t *testing.T) {
+	primary := loadPlayers(50000)
+	replica := newEmpty(50000)
+	defer primary.Close()
+	defer replica.Close()
+
+	// sync performs a catch-up of the replica over an in-memory connection
+	sync := func() {
+		server, client := net.Pipe()
+		go func() {
+			assert.NoError(t, primary.AcceptSync(server))
+			server.Close()
+		}()
+		assert.NoError(t, replica.SyncFrom(client))
+		client.Close()
+	}
+
+	// sum computes a checksum of a collection
+	sum := func(c *Collection) (count int, balance float64) {
+		c.Query(func(txn *Txn) error {
+			count = txn.Count()
+			balance = txn.Float64("balance").Sum()
+			return nil
+		})
+		return
+	}
+
+	sync()
+	assert.NotZero(t, replica.SyncID())
+	assert.Equal(t, 50000, replica.Count())
+	c1, b1 := sum(primary)
+	c2, b2 := sum(replica)
+	assert.Equal(t, c1, c2)
+	assert.Equal(t, b1, b2)
+
+	// Modify the primary, including deleting an entire chunk
+	assert.NoError(t, primary.Query(func(txn *Txn) error {
+		balance := txn.Float64("balance")
+		return txn.Range(func(idx uint32) {
+			switch {
+			case idx >= 3*chunkSize:
+				txn.DeleteAt(idx)
+			case idx < 100:
+				balance.Set(1000)
+			}
+		})
+	}))
+
+	sync()
+	c1, b1 = sum(primary)
+	c2, b2 = sum(replica)
+	assert.Equal(t, 3*chunkSize, c2)
+	assert.Equal(t, c1, c2)
+	assert.Equal(t, b1, b2)
+	assert.NoError(t, replica.QueryAt(0, func(r Row) error {
+		v, _ := r.Float64("balance")
+		assert.Equal(t, 1000.0, v)
+		return nil
+	}))
+}
+
+func TestSyncFromConcurrent(t *testing.T) {
+	primary := loadPlayers(5000)
+	replica := newEmpty(5000)
+	defer primary.Close()
+	defer replica.Close()
+
+	// sync performs a catch-up of the replica over an in-memory connection
+	catchUp := func() {
+		server, client := net.Pipe()
+		go func() {
+			assert.NoError(t, primary.AcceptSync(server))
+			server.Close()
+		}()
+		assert.NoError(t, replica.SyncFrom(client))
+		client.Close()
+	}
+
+	// Commit to the primary while the replica keeps catching up
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				primary.QueryAt(uint32((w*500+i)%5000), func(r Row) error {
+					r.SetFloat64("balance", float64(w*1000+i))
+					return nil
+				})
+				primary.Insert(func(r Row) error {
+					r.SetFloat64("balance", float64(i))
+					return nil
+				})
+			}
+		}(w)
+	}
+
+	for i := 0; i < 20; i++ {
+		catchUp()
+	}
+
+	// Once the writers are done, a final catch-up must converge
+	wg.Wait()
+	catchUp()
+
+	var b1, b2 float64
+	primary.Query(func(txn *Txn) error {
+		b1 = txn.Float64("balance").Sum()
+		return nil
+	})
+	replica.Query(func(txn *Txn) error {
+		b2 = txn.Float64("balance").Sum()
+		return nil
+	})
+	assert.Equal(t, primary.Count(), replica.Count())
+	assert.Equal(t, b1, b2)
+}
+
+func TestSyncReplaySkip(t *testing.T) {
+	writer := make(commit.Channel, 1024)
+	primary := NewCollection(Options{Writer: writer})
+	replica := NewCollection()
+	for _, c := range []*Collection{primary, replica} {
+		assert.NoError(t, c.CreateColumn("counter", ForInt64()))
+	}
+
+	idx, _ := primary.Insert(func(r Row) error {
+		r.SetInt64("counter", 1)
+		return nil
+	})
+	primary.QueryAt(idx, func(r Row) error {
+		r.MergeInt64("counter", 1)
+		return nil
+	})
+
+	// Catch up, the commits of the live stream are already part of the state
+	var buffer bytes.Buffer
+	assert.NoError(t, primary.ServeSync(&buffer, 0))
+	assert.NoError(t, replica.SyncFrom(struct {
+		io.Reader
+		io.Writer
+	}{&buffer, io.Discard}))
+
+	close(writer)
+	for change := range writer {
+		assert.NoError(t, replica.Replay(change))
+	}
+
+	assert.NoError(t, replica.QueryAt(idx, func(r Row) error {
+		v, _ := r.Int64("counter")
+		assert.Equal(t, int64(2), v)
+		return nil
+	}))
+
+	// Invalid version
+	assert.Error(t, replica.SyncFrom(struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader([]byte{0x2}), io.Discard}))
+}
+
+func TestReplicateBroker(t *testing.T) {
+	broker := &chanBroker{messages: make(chan commit.Message, 1024)}
+	primary := NewCollection(Options{Writer: commit.NewSink(broker, 4)})
+	replica := NewCollection()
+	for _, c := range []*Collection{primary, replica} {
+		assert.NoError(t, c.CreateColumn("name", ForKey()))
+		assert.NoError(t, c.CreateColumn("age", ForInt()))
+	}
+
+	primary.Query(func(txn *Txn) error {
+		for i := 0; i < 70000; i++ {
+			txn.InsertKey(fmt.Sprintf("p%d", i), func(r Row) error {
+				r.SetInt("age", i)
+				return nil
+			})
+		}
+		return nil
+	})
+	primary.DeleteKey("p0")
+	primary.QueryKey("p1", func(r Row) error {
+		r.SetInt("age", 100)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	source := commit.NewSource(broker, replica, nil)
+	go func() {
+		for len(broker.messages) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	assert.NoError(t, source.Run(ctx))
+	assert.Equal(t, primary.Count(), replica.Count())
+	assert.Len(t, source.Offsets(), 4)
+	assert.NoError(t, replica.QueryKey("p1", func(r Row) error {
+		age, _ := r.Int("age")
+		assert.Equal(t, 100, age)
+		return nil
+	}))
+}
+
+// chanBroker represents an in-memory message broker backed by a channel
+type chanBroker struct {
+	offsets  [4]uint64
+	messages chan commit.Message
+}
+
+func (b *chanBroker) Publish(partition int, payload []byte) error {
+	b.offsets[partition]++
+	b.messages <- commit.Message{
+		Partition: partition,
+		Offset:    b.offsets[partition],
+		Payload:   append([]byte(nil), payload...),
+	}
+	return nil
+}
+
+func (b *chanBroker) Fetch(ctx context.Context) (commit.Message, error) {
+	select {
+	case msg := <-b.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return commit.Message{}, ctx.Err()
+	}
+}
+
+func TestReplayIdempotent(t *testing.T) {
+	writer := make(commit.Channel, 1024)
+	primary := NewCollection(Options{Writer: writer})
+	newReplica := func() *Collection {
+		replica := NewCollection()
+		assert.NoError(t, replica.CreateColumn("counter", ForInt64()))
+		return replica
+	}
+
+	assert.NoError(t, primary.CreateColumn("counter", ForInt64()))
+	idx, _ := primary.Insert(func(r Row) error {
+		r.SetInt64("counter", 1)
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		primary.QueryAt(idx, func(r Row) error {
+			r.MergeInt64("counter", 1)
+			return nil
+		})
+	}
+
+	close(writer)
+	var changes []commit.Commit
+	for change := range writer {
+		changes = append(changes, change)
+	}
+	assert.Len(t, changes, 4)
+
+	// Keep a copy of the commits, as replaying a commit consumes its buffers
+	pristine := make([]commit.Commit, 0, len(changes))
+	for i := range changes {
+		pristine = append(pristine, changes[i].Clone())
+	}
+
+	// Deliver every commit twice, the duplicates are skipped
+	replica := newReplica()
+	for _, change := range changes {
+		assert.NoError(t, replica.Replay(change))
+		assert.NoError(t, replica.Replay(change))
+	}
+	assert.NoError(t, replica.Replay(changes[0]))
+	assert.NoError(t, replica.QueryAt(idx, func(r Row) error {
+		v, _ := r.Int64("counter")
+		assert.Equal(t, int64(4), v)
+		return nil
+	}))
+
+	// A missing commit is detected
+	replica = newReplica()
+	assert.NoError(t, replica.Replay(changes[0]))
+	err := replica.Replay(changes[2])
+	assert.Error(t, err)
+
+	var gap *ReplayGapError
+	assert.ErrorAs(t, err, &gap)
+	assert.Equal(t, changes[0].ID, gap.Last)
+	assert.Equal(t, changes[1].ID, gap.Prev)
+
+	// Deliver every commit concurrently several times, each is applied once
+	for n := 0; n < 20; n++ {
+		var applied int32
+		replica = newReplica()
+		assert.NoError(t, replica.CreateTrigger("on_counter", "counter", func(r Reader) {
+			atomic.AddInt32(&applied, 1)
+			runtime.Gosched() // Let the other deliveries run while the commit is applied
+		}))
+
+		for _, change := range pristine {
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(change commit.Commit) {
+					defer wg.Done()
+					<-start
+					assert.NoError(t, replica.Replay(change))
+				}(change.Clone())
+			}
+			close(start)
+			wg.Wait()
+		}
+
+		assert.Equal(t, int32(4), atomic.LoadInt32(&applied))
+		assert.NoError(t, replica.QueryAt(idx, func(r Row) error {
+			v, _ := r.Int64("counter")
+			assert.Equal(t, int64(4), v)
+			return nil
+		}))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	primary := loadPlayers(50000)
+	replica := loadPlayers(50000)
+	defer primary.Close()
+	defer replica.Close()
+
+	report, err := Verify(primary, replica, VerifyOptions{})
+	assert.NoError(t, err)
+	assert.True(t, report.Equal())
+	assert.Equal(t, 50000, report.Rows)
+	assert.Equal(t, 4, report.Chunks)
+
+	// Introduce a few differences
+	replica.DeleteAt(20000)
+	replica.QueryAt(40000, func(r Row) error {
+		r.SetFloat64("balance", -1)
+		return nil
+	})
+
+	report, err = Verify(primary, replica, VerifyOptions{})
+	assert.NoError(t, err)
+	assert.False(t, report.Truncated)
+	assert.Equal(t, []Difference{
+		{Index: 20000, Primary: true, Replica: false},
+		{Column: "balance", Index: 40000, Primary: report.Differences[1].Primary, Replica: -1.0},
+	}, report.Differences)
+	assert.Contains(t, report.Differences[0].String(), "row 20000")
+	assert.Contains(t, report.Differences[1].String(), "balance")
+
+	// Limit the number of differences
+	report, err = Verify(primary, replica, VerifyOptions{Limit: 1, Columns: []string{"balance"}})
+	assert.NoError(t, err)
+	assert.True(t, report.Truncated)
+	assert.Len(t, report.Differences, 1)
+
+	// Reaching the limit exactly does not truncate the report
+	report, err = Verify(primary, replica, VerifyOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.False(t, report.Truncated)
+	assert.Len(t, report.Differences, 2)
+
+	// A collection can be compared with itself while being written
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			primary.QueryAt(uint32(i), func(r Row) error {
+				r.SetFloat64("balance", 1)
+				return nil
+			})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		_, err = Verify(primary, primary, VerifyOptions{})
+		assert.NoError(t, err)
+	}
+	<-done
+
+	// Invalid columns and cancelled context
+	_, err = Verify(primary, replica, VerifyOptions{Columns: []string{"invalid"}})
+	assert.Error(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Verify(primary, replica, VerifyOptions{Context: ctx})
+	assert.ErrorIs(t, err, context.Canceled)
+}
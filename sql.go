@@ -0,0 +1,342 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// defaultImportBatch is the default number of rows imported per transaction
+const defaultImportBatch = 1000
+
+// ImportOption represents an option of an import
+type ImportOption func(*importOptions)
+
+// importOptions represents the options of an import
+type importOptions struct {
+	batch    int            // The number of rows imported per transaction
+	progress func(rows int) // The progress callback (optional)
+}
+
+// WithImportBatch sets the number of rows imported per transaction, 1000 by default or
+// if n is not positive.
+func WithImportBatch(n int) ImportOption {
+	return func(o *importOptions) {
+		o.batch = n
+	}
+}
+
+// WithImportProgress sets a callback which is invoked every time a batch of rows is
+// imported, with the number of rows imported so far.
+func WithImportProgress(fn func(rows int)) ImportOption {
+	return func(o *importOptions) {
+		o.progress = fn
+	}
+}
+
+// ImportSQL streams the rows of a query result into the collection, for example to warm
+// the collection from a Postgres or MySQL database at startup, and returns the number of
+// rows imported. The result columns are mapped to the collection columns with the same
+// name, and the missing columns are created from the types reported by the driver. NULL
+// values are left empty.
+//
+// If the collection has a primary key, the result must contain the key column and the
+// rows are upserted, so an import can be repeated. The rows are imported in batches, each
+// in its own transaction, and the batches imported before an error are kept. The rows are
+// closed once the import is done.
+func (c *Collection) ImportSQL(rows *sql.Rows, opts ...ImportOption) (int, error) {
+	defer rows.Close()
+	options := importOptions{batch: defaultImportBatch}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.batch <= 0 {
+		options.batch = defaultImportBatch
+	}
+
+	types, err := c.importColumns(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	names, _ := rows.Columns()
+	values := make([]any, len(names))
+	targets := make([]any, len(names))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+
+	imported, done := 0, false
+	for !done {
+		count := 0
+		err := c.Query(func(txn *Txn) error {
+			for count < options.batch {
+				if done = !rows.Next(); done {
+					return rows.Err()
+				}
+
+				if err := rows.Scan(targets...); err != nil {
+					return err
+				}
+
+				if err := txn.importRow(names, types, values); err != nil {
+					return err
+				}
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return imported, err
+		}
+
+		imported += count
+		if options.progress != nil && count > 0 {
+			options.progress(imported)
+		}
+	}
+	return imported, nil
+}
+
+// importRow inserts or upserts a row scanned from a query result
+func (txn *Txn) importRow(names []string, types []reflect.Type, values []any) error {
+	write := func(r Row) error {
+		for i, name := range names {
+			if values[i] == nil || name == txn.owner.pkName {
+				continue
+			}
+
+			value, err := convertSQL(values[i], types[i])
+			if err != nil {
+				return fmt.Errorf("column: unable to import '%s', %w", name, err)
+			}
+			if err := setField(r, name, value); err != nil {
+				return fmt.Errorf("column: unable to import '%s', %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	if txn.owner.pk == nil {
+		_, err := txn.Insert(write)
+		return err
+	}
+
+	for i, name := range names {
+		if name == txn.owner.pkName && values[i] != nil {
+			v, err := convertSQL(values[i], reflect.TypeOf(""))
+			if err != nil {
+				return fmt.Errorf("column: unable to import '%s', %w", name, err)
+			}
+			return txn.UpsertKey(v.String(), write)
+		}
+	}
+	return fmt.Errorf("column: unable to import, the row has no '%s' key", txn.owner.pkName)
+}
+
+// importColumns creates the missing columns of a query result, and returns the Go types of
+// the values stored in every column.
+func (c *Collection) importColumns(rows *sql.Rows) ([]reflect.Type, error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]string, len(columns))
+	for _, info := range c.Columns() {
+		existing[info.Name] = info.Type
+	}
+
+	types := make([]reflect.Type, 0, len(columns))
+	for _, col := range columns {
+		name := col.Name()
+		if typ, ok := existing[name]; ok {
+			types = append(types, goTypeOf(typ))
+			continue
+		}
+
+		typ := scanTypeOf(col)
+		column, err := columnOf(typ, "")
+		if err != nil {
+			return nil, fmt.Errorf("column: unable to import '%s', %w", name, err)
+		}
+
+		if err := c.CreateColumn(name, column); err != nil {
+			return nil, err
+		}
+		types = append(types, typ)
+	}
+	return types, nil
+}
+
+// scanTypeOf returns the Go type of the values of a result column. The nullable types,
+// such as sql.NullInt64, are mapped to the type of their value.
+func scanTypeOf(col *sql.ColumnType) reflect.Type {
+	typ := col.ScanType()
+	switch {
+	case typ == nil || typ == reflect.TypeOf(sql.RawBytes{}):
+		return reflect.TypeOf("")
+	case typ.Kind() == reflect.Pointer:
+		return typ.Elem()
+	case typ.Kind() == reflect.Struct && typ.NumField() == 2 && typ.Field(1).Name == "Valid":
+		return typ.Field(0).Type
+	default:
+		return typ
+	}
+}
+
+// goTypeOf returns the Go type of the values of an existing column, given its type name,
+// or nil if the values are stored as-is.
+func goTypeOf(typ string) reflect.Type {
+	switch typ {
+	case "int":
+		return reflect.TypeOf(int(0))
+	case "int16":
+		return reflect.TypeOf(int16(0))
+	case "int32":
+		return reflect.TypeOf(int32(0))
+	case "int64":
+		return reflect.TypeOf(int64(0))
+	case "uint":
+		return reflect.TypeOf(uint(0))
+	case "uint16":
+		return reflect.TypeOf(uint16(0))
+	case "uint32":
+		return reflect.TypeOf(uint32(0))
+	case "uint64":
+		return reflect.TypeOf(uint64(0))
+	case "float32":
+		return reflect.TypeOf(float32(0))
+	case "float64":
+		return reflect.TypeOf(float64(0))
+	case "bool":
+		return reflect.TypeOf(false)
+	case "string", "enum", "key":
+		return reflect.TypeOf("")
+	case "time":
+		return typeTime
+	case "bytes":
+		return typeBytes
+	default:
+		return nil
+	}
+}
+
+// convertSQL converts a value returned by a database driver to the Go type of a column.
+// The drivers which return the numbers as text, such as MySQL, are supported by parsing it.
+// The numbers which do not fit into the type of the column are rejected, not truncated.
+func convertSQL(v any, typ reflect.Type) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	if typ == nil || value.Type() == typ {
+		return value, nil
+	}
+
+	text, isText := v.(string)
+	if b, ok := v.([]byte); ok {
+		text, isText = string(b), true
+	}
+
+	switch {
+	case typ.Kind() == reflect.String:
+		if isText {
+			return reflect.ValueOf(text).Convert(typ), nil
+		}
+		return reflect.ValueOf(fmt.Sprint(v)), nil
+	case typ == typeBytes && isText:
+		return reflect.ValueOf([]byte(text)), nil
+	case isText:
+		return parseSQL(text, typ)
+	case isNumber(value.Kind()) && isNumber(typ.Kind()):
+		return convertNumber(value, typ)
+	case typ != typeTime && value.Type().ConvertibleTo(typ):
+		return value.Convert(typ), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("%T can not be converted to %v", v, typ)
+	}
+}
+
+// parseSQL parses a textual value returned by a database driver
+func parseSQL(text string, typ reflect.Type) (reflect.Value, error) {
+	var v any
+	var err error
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err = strconv.ParseInt(text, 10, typ.Bits())
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err = strconv.ParseUint(text, 10, typ.Bits())
+	case reflect.Float32, reflect.Float64:
+		v, err = strconv.ParseFloat(text, typ.Bits())
+	case reflect.Bool:
+		v, err = strconv.ParseBool(text)
+	default:
+		if typ != typeTime {
+			return reflect.Value{}, fmt.Errorf("'%s' can not be converted to %v", text, typ)
+		}
+		v, err = time.Parse(time.RFC3339Nano, text)
+		return reflect.ValueOf(v), err
+	}
+
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(v).Convert(typ), nil
+}
+
+// convertNumber converts a number returned by a database driver to the numeric type of a
+// column, unless the number does not fit into it. The floating-point numbers are only
+// converted to integers if they have no fractional part.
+func convertNumber(value reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	out := reflect.New(typ).Elem()
+	fits := true
+	switch {
+	case value.CanInt():
+		v := value.Int()
+		switch {
+		case out.CanInt():
+			fits = !out.OverflowInt(v)
+		case out.CanUint():
+			fits = v >= 0 && !out.OverflowUint(uint64(v))
+		}
+	case value.CanUint():
+		v := value.Uint()
+		switch {
+		case out.CanInt():
+			fits = v <= math.MaxInt64 && !out.OverflowInt(int64(v))
+		case out.CanUint():
+			fits = !out.OverflowUint(v)
+		}
+	default:
+		v := value.Float()
+		switch {
+		case out.CanInt():
+			fits = v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 && !out.OverflowInt(int64(v))
+		case out.CanUint():
+			fits = v == math.Trunc(v) && v >= 0 && v < math.MaxUint64 && !out.OverflowUint(uint64(v))
+		default:
+			fits = !out.OverflowFloat(v)
+		}
+	}
+
+	if !fits {
+		return reflect.Value{}, fmt.Errorf("%v does not fit into %v", value, typ)
+	}
+	return value.Convert(typ), nil
+}
+
+// isNumber returns whether the kind is an integer or a floating-point number
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
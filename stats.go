@@ -0,0 +1,218 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// statsBuckets is the number of buckets the sliding window is divided into
+const statsBuckets = 10
+
+// WriteStats represents the number of values written to the collection over the sliding
+// window configured with the Stats option.
+type WriteStats struct {
+	Window  time.Duration     // The duration of the sliding window
+	Columns map[string]uint64 // The number of values written, per column
+	Chunks  []ChunkWrites     // The chunks with the most values written, hottest first
+}
+
+// ChunkWrites represents the number of values written to a chunk.
+type ChunkWrites struct {
+	Chunk  commit.Chunk // The chunk
+	Writes uint64       // The number of values written to the chunk
+}
+
+// writeBucket represents the writes which happened during a slice of the window
+type writeBucket struct {
+	epoch   int64                   // The slice of time this bucket is for
+	columns map[string]uint64       // The number of writes per column
+	chunks  map[commit.Chunk]uint64 // The number of writes per chunk
+}
+
+// writeStats represents the write statistics over a sliding window
+type writeStats struct {
+	lock    sync.Mutex
+	window  time.Duration
+	buckets [statsBuckets]writeBucket
+}
+
+// newWriteStats creates new write statistics with a specified sliding window
+func newWriteStats(window time.Duration) *writeStats {
+	if window < statsBuckets {
+		window = statsBuckets
+	}
+
+	return &writeStats{
+		window: window,
+	}
+}
+
+// bucketAt returns the bucket for the specified time, resetting it if it is stale
+func (s *writeStats) bucketAt(now time.Time) *writeBucket {
+	epoch := now.UnixNano() / int64(s.window/statsBuckets)
+	bucket := &s.buckets[epoch%statsBuckets]
+	if bucket.epoch != epoch || bucket.columns == nil {
+		bucket.epoch = epoch
+		bucket.columns = make(map[string]uint64, 8)
+		bucket.chunks = make(map[commit.Chunk]uint64, 8)
+	}
+	return bucket
+}
+
+// record records a number of writes for a column and a chunk
+func (s *writeStats) record(column string, chunk commit.Chunk, writes uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	bucket := s.bucketAt(time.Now())
+	bucket.columns[column] += writes
+	bucket.chunks[chunk] += writes
+}
+
+// load aggregates the buckets which are within the window
+func (s *writeStats) load(top int) WriteStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	out := WriteStats{
+		Window:  s.window,
+		Columns: make(map[string]uint64, 8),
+	}
+
+	chunks := make(map[commit.Chunk]uint64, 8)
+	epoch := s.bucketAt(time.Now()).epoch
+	for _, bucket := range s.buckets {
+		if bucket.columns == nil || epoch-bucket.epoch >= statsBuckets {
+			continue // Outside of the window
+		}
+
+		for k, v := range bucket.columns {
+			out.Columns[k] += v
+		}
+		for k, v := range bucket.chunks {
+			chunks[k] += v
+		}
+	}
+
+	// Sort the chunks by the number of writes and keep the top ones
+	for chunk, writes := range chunks {
+		out.Chunks = append(out.Chunks, ChunkWrites{Chunk: chunk, Writes: writes})
+	}
+	sort.Slice(out.Chunks, func(i, j int) bool {
+		if out.Chunks[i].Writes != out.Chunks[j].Writes {
+			return out.Chunks[i].Writes > out.Chunks[j].Writes
+		}
+		return out.Chunks[i].Chunk < out.Chunks[j].Chunk
+	})
+	if len(out.Chunks) > top {
+		out.Chunks = out.Chunks[:top]
+	}
+	return out
+}
+
+// WriteStats returns the number of values written per column over the sliding window
+// configured with the Stats option, along with the specified number of chunks with
+// the most writes. This can be used to identify which columns cause the most index
+// maintenance. It returns false if the statistics are not enabled.
+func (c *Collection) WriteStats(top int) (WriteStats, bool) {
+	if c.stats == nil {
+		return WriteStats{}, false
+	}
+	return c.stats.load(top), true
+}
+
+// commitStats records the number of values written to each column of a chunk
+func (txn *Txn) commitStats(chunk commit.Chunk) {
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn {
+			continue
+		}
+
+		var writes uint64
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				writes++
+			}
+		})
+
+		if writes > 0 {
+			txn.owner.stats.record(u.Column, chunk, writes)
+		}
+	}
+}
+
+// --------------------------- Index Statistics ----------------------------
+
+// IndexStats represents the statistics of a bitmap index.
+type IndexStats struct {
+	Name        string    // The name of the index
+	Column      string    // The name of the column the index depends on
+	Cardinality int       // The number of rows in the index
+	Updated     time.Time // The time of the last change of the index, zero if never changed
+	Memory      int       // The approximate memory used by the bitmap, in bytes
+}
+
+// IndexStats returns the statistics of every bitmap index of the collection, sorted by
+// name. The cardinality can be compared with the number of rows in the collection in order
+// to estimate the selectivity of an index.
+func (c *Collection) IndexStats() []IndexStats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	out := make([]IndexStats, 0, 8)
+	c.cols.Range(func(column *column) {
+		index, ok := column.Column.(*columnIndex)
+		if !ok {
+			return
+		}
+
+		stats := IndexStats{
+			Name:        column.name,
+			Column:      index.name,
+			Cardinality: int(atomic.LoadInt64(&index.count)),
+			Memory:      cap(index.fill) * 8,
+		}
+		if updated := atomic.LoadInt64(&index.updated); updated > 0 {
+			stats.Updated = time.Unix(0, updated)
+		}
+		out = append(out, stats)
+	})
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// bySelectivity orders the indexes from the most to the least selective, according to their
+// cardinality. The columns which are not bitmap indexes are kept last, in the same order.
+func (txn *Txn) bySelectivity(columns []string) []string {
+	if len(columns) < 2 {
+		return columns
+	}
+
+	cardinality := make(map[string]int64, len(columns))
+	for _, name := range columns {
+		cardinality[name] = math.MaxInt64
+		if column, ok := txn.owner.cols.Load(name); ok {
+			if index, ok := column.Column.(*columnIndex); ok {
+				cardinality[name] = atomic.LoadInt64(&index.count)
+			}
+		}
+	}
+
+	ordered := make([]string, len(columns))
+	copy(ordered, columns)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return cardinality[ordered[i]] < cardinality[ordered[j]]
+	})
+	return ordered
+}
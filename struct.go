@@ -0,0 +1,230 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	typeTime  = reflect.TypeOf(time.Time{})
+	typeBytes = reflect.TypeOf([]byte(nil))
+)
+
+// structs caches the field mappings of the struct types
+var structs sync.Map
+
+// structField represents a mapping between a struct field and a column
+type structField struct {
+	index []int  // The index of the field in the struct
+	name  string // The name of the column
+	kind  string // The kind of the column, such as "enum" or "key"
+}
+
+// structOf returns the field mapping of a struct type. Exported fields are mapped to the
+// column with the same name, unless a `column:"name,kind"` tag is specified. Fields with
+// a "-" tag are ignored.
+func structOf(typ reflect.Type) ([]structField, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("column: unable to map %v, not a struct", typ)
+	}
+
+	if fields, ok := structs.Load(typ); ok {
+		return fields.([]structField), nil
+	}
+
+	fields := make([]structField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("column")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, kind, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, structField{
+			index: field.Index,
+			name:  name,
+			kind:  kind,
+		})
+	}
+
+	structs.Store(typ, fields)
+	return fields, nil
+}
+
+// columnOf creates a column for the type of a struct field
+func columnOf(typ reflect.Type, kind string) (Column, error) {
+	switch {
+	case kind == "enum" && typ.Kind() == reflect.String:
+		return ForEnum(), nil
+	case kind == "key" && typ.Kind() == reflect.String:
+		return ForKey(), nil
+	case kind != "":
+		return nil, fmt.Errorf("column: unsupported column kind '%s' for %v", kind, typ)
+	case typ == typeTime:
+		return ForTime(), nil
+	case typ == typeBytes:
+		return ForBytes(), nil
+	default:
+		return ForKind(typ.Kind())
+	}
+}
+
+// CreateColumnsOfStruct registers a column for every exported field of the struct type T.
+// The name of the column can be specified with a `column:"name"` tag, and string fields
+// can be stored as an enum or a primary key with `column:"name,enum"` and `column:"name,key"`
+// tags respectively. Existing columns are left as-is.
+func CreateColumnsOfStruct[T any](c *Collection) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	fields, err := structOf(typ)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if _, ok := c.cols.Load(f.name); ok {
+			continue
+		}
+
+		column, err := columnOf(typ.FieldByIndex(f.index).Type, f.kind)
+		if err != nil {
+			return err
+		}
+
+		if err := c.CreateColumn(f.name, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertStruct inserts the fields of a struct as a new row, and returns its index. If the
+// collection has a primary key, the value of the corresponding field is used as the key.
+func (txn *Txn) InsertStruct(v any) (index uint32, err error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	fields, err := structOf(value.Type())
+	if err != nil {
+		return 0, err
+	}
+
+	write := func(r Row) error {
+		index = r.Index()
+		for _, f := range fields {
+			if f.name == txn.owner.pkName {
+				continue
+			}
+
+			if err := setField(r, f.name, value.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("column: unable to insert '%s', %w", f.name, err)
+			}
+		}
+		return nil
+	}
+
+	if txn.owner.pk == nil {
+		return txn.Insert(write)
+	}
+
+	for _, f := range fields {
+		if f.name == txn.owner.pkName {
+			return index, txn.InsertKey(value.FieldByIndex(f.index).String(), write)
+		}
+	}
+	return 0, fmt.Errorf("column: unable to insert, %v has no '%s' key field", value.Type(), txn.owner.pkName)
+}
+
+// ScanStruct loads the values of a row into the fields of a struct. The fields whose
+// column does not contain a value are set to their zero value.
+func (txn *Txn) ScanStruct(idx uint32, dst any) error {
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return fmt.Errorf("column: unable to scan into %T, not a pointer", dst)
+	}
+
+	fields, err := structOf(value.Type())
+	if err != nil {
+		return err
+	}
+
+	txn.owner.lock.RLock()
+	exists := txn.owner.fill.Contains(idx)
+	txn.owner.lock.RUnlock()
+	if !exists {
+		return fmt.Errorf("column: unable to scan, row %d does not exist", idx)
+	}
+
+	value = value.Elem()
+	return txn.QueryAt(idx, func(r Row) error {
+		for _, f := range fields {
+			field := value.FieldByIndex(f.index)
+			v, ok := r.Any(f.name)
+			if !ok || v == nil {
+				field.Set(reflect.Zero(field.Type()))
+				continue
+			}
+
+			rv := reflect.ValueOf(v)
+			if !rv.Type().ConvertibleTo(field.Type()) {
+				return fmt.Errorf("column: unable to scan '%s' of type %T into %v", f.name, v, field.Type())
+			}
+			field.Set(rv.Convert(field.Type()))
+		}
+		return nil
+	})
+}
+
+// setField writes the value of a struct field into the column
+func setField(r Row, name string, v reflect.Value) error {
+	column := r.txn.Any(name)
+	switch v.Kind() {
+	case reflect.String:
+		return column.Set(v.String())
+	case reflect.Bool:
+		r.SetBool(name, v.Bool())
+		return nil
+	case reflect.Int:
+		return column.Set(int(v.Int()))
+	case reflect.Int8:
+		return column.Set(int8(v.Int()))
+	case reflect.Int16:
+		return column.Set(int16(v.Int()))
+	case reflect.Int32:
+		return column.Set(int32(v.Int()))
+	case reflect.Int64:
+		return column.Set(v.Int())
+	case reflect.Uint:
+		return column.Set(uint(v.Uint()))
+	case reflect.Uint8:
+		return column.Set(uint8(v.Uint()))
+	case reflect.Uint16:
+		return column.Set(uint16(v.Uint()))
+	case reflect.Uint32:
+		return column.Set(uint32(v.Uint()))
+	case reflect.Uint64:
+		return column.Set(v.Uint())
+	case reflect.Float32:
+		return column.Set(float32(v.Float()))
+	case reflect.Float64:
+		return column.Set(v.Float())
+	default:
+		if v.Type() == typeTime {
+			r.SetTime(name, v.Interface().(time.Time))
+			return nil
+		}
+		return column.Set(v.Interface())
+	}
+}
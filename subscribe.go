@@ -0,0 +1,333 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kelindar/column/commit"
+)
+
+// EventType represents the type of a change event.
+type EventType uint8
+
+// Various event types
+const (
+	EventInsert EventType = iota // A row was inserted
+	EventUpdate                  // One or more columns of a row were updated
+	EventDelete                  // A row was deleted
+)
+
+// String returns the name of the event type
+func (e EventType) String() string {
+	switch e {
+	case EventInsert:
+		return "insert"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	default:
+		return "invalid"
+	}
+}
+
+// Event represents a row-level change which was committed to the collection.
+type Event struct {
+	Type   EventType      // The type of the change
+	Index  uint32         // The index of the row
+	Values map[string]any // The values after the change, empty for deletes
+}
+
+// OverflowPolicy represents the behavior of a subscription when its buffer is full.
+type OverflowPolicy uint8
+
+// Various overflow policies
+const (
+	OverflowBlock OverflowPolicy = iota // The committer waits for the subscriber
+	OverflowDrop                        // The events which do not fit are dropped
+)
+
+// SubscribeOptions represents the options of a subscription.
+type SubscribeOptions struct {
+	Columns []string       // The columns to report, all of them if empty
+	Index   string         // The index the rows must belong to (optional)
+	Buffer  int            // The size of the event buffer, 1024 by default
+	Policy  OverflowPolicy // The behavior when the buffer is full
+}
+
+// subscription represents a single subscriber
+type subscription struct {
+	lock    sync.RWMutex        // The lock which prevents closing the channel while sending
+	ctx     context.Context     // The context of the subscription
+	events  chan Event          // The channel of events
+	policy  OverflowPolicy      // The behavior when the buffer is full
+	columns map[string]struct{} // The columns to report, all of them if empty
+	index   *column             // The index to match (optional)
+	closed  bool                // Whether the channel was closed
+}
+
+// pendingEvent represents an event which was committed and is about to be delivered.
+type pendingEvent struct {
+	sub   *subscription
+	event Event
+}
+
+// subscribers represents a list of subscriptions of a collection
+type subscribers struct {
+	lock  sync.RWMutex
+	count int32           // The number of subscriptions, for a lock-free check
+	list  []*subscription // The list of subscriptions
+}
+
+// Subscribe creates a subscription to the row-level changes committed to the collection.
+// The returned channel receives an event for every inserted, updated or deleted row and
+// is closed once the context is cancelled or the collection is closed. Updates are only
+// reported if one of the selected columns has changed. If an index is specified, inserts
+// and updates are reported only for the rows in the index, while deletes are always
+// reported since the row no longer belongs to any index. The events are delivered after
+// the transaction is committed, and with the blocking policy a slow subscriber delays
+// the transactions of the collection.
+func (c *Collection) Subscribe(ctx context.Context, opts SubscribeOptions) (<-chan Event, error) {
+	sub := &subscription{
+		ctx:    ctx,
+		policy: opts.Policy,
+	}
+
+	for _, name := range opts.Columns {
+		if _, ok := c.cols.Load(name); !ok {
+			return nil, fmt.Errorf("column: unable to subscribe, column '%v' does not exist", name)
+		}
+
+		if sub.columns == nil {
+			sub.columns = make(map[string]struct{}, len(opts.Columns))
+		}
+		sub.columns[name] = struct{}{}
+	}
+
+	if opts.Index != "" {
+		index, ok := c.cols.Load(opts.Index)
+		if !ok || !index.IsIndex() {
+			return nil, fmt.Errorf("column: unable to subscribe, index '%v' does not exist", opts.Index)
+		}
+		sub.index = index
+	}
+
+	if opts.Buffer <= 0 {
+		opts.Buffer = 1024
+	}
+
+	// Register the subscription
+	sub.events = make(chan Event, opts.Buffer)
+	c.subs.lock.Lock()
+	c.subs.list = append(c.subs.list, sub)
+	atomic.AddInt32(&c.subs.count, 1)
+	c.subs.lock.Unlock()
+
+	// Unregister once either the subscription or the collection is done
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.ctx.Done():
+		}
+		c.unsubscribe(sub)
+	}()
+	return sub.events, nil
+}
+
+// unsubscribe removes the subscription and closes its channel.
+func (c *Collection) unsubscribe(sub *subscription) {
+	c.subs.lock.Lock()
+	for i, v := range c.subs.list {
+		if v == sub {
+			c.subs.list = append(c.subs.list[:i], c.subs.list[i+1:]...)
+			atomic.AddInt32(&c.subs.count, -1)
+			break
+		}
+	}
+	c.subs.lock.Unlock()
+
+	// Wait for the events being sent, which are abandoned since the context is done
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+	sub.closed = true
+	close(sub.events)
+}
+
+// hasSubscribers checks whether the collection has any subscriptions.
+func (c *Collection) hasSubscribers() bool {
+	return atomic.LoadInt32(&c.subs.count) > 0
+}
+
+// --------------------------- Change Capture ----------------------------
+
+// rowChange represents the changes to a single row within a chunk.
+type rowChange struct {
+	index    uint32
+	inserted bool
+	deleted  bool
+	columns  []*column
+}
+
+// commitEvents captures the changes of a chunk as events for each of the subscriptions.
+// The chunk must be write-locked, and the events are delivered once the commit is done.
+func (txn *Txn) commitEvents(chunk commit.Chunk, markers *commit.Buffer) {
+	order := make([]*rowChange, 0, 16)
+	rows := make(map[uint32]*rowChange, 16)
+	changeOf := func(idx uint32) *rowChange {
+		if v, ok := rows[idx]; ok {
+			return v
+		}
+		v := &rowChange{index: idx}
+		rows[idx] = v
+		order = append(order, v)
+		return v
+	}
+
+	// Find the inserted and deleted rows
+	if markers != nil {
+		txn.reader.Range(markers, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				switch r.Type {
+				case commit.Insert:
+					changeOf(r.Index()).inserted = true
+				case commit.Delete:
+					changeOf(r.Index()).deleted = true
+				}
+			}
+		})
+	}
+
+	// Find the updated columns of each row
+	for _, u := range txn.updates {
+		if u.IsEmpty() || u.Column == rowColumn {
+			continue
+		}
+
+		column, ok := txn.owner.cols.Load(u.Column)
+		if !ok {
+			continue
+		}
+
+		txn.reader.Range(u, chunk, func(r *commit.Reader) {
+			for r.Next() {
+				change := changeOf(r.Index())
+				if n := len(change.columns); n == 0 || change.columns[n-1] != column {
+					change.columns = append(change.columns, column)
+				}
+			}
+		})
+	}
+
+	if len(order) == 0 {
+		return
+	}
+
+	// Resolve the final state of the rows and build the events for each subscription
+	txn.owner.subs.lock.RLock()
+	defer txn.owner.subs.lock.RUnlock()
+	txn.owner.lock.RLock()
+	defer txn.owner.lock.RUnlock()
+	for _, change := range order {
+		event := Event{Index: change.index}
+		switch {
+		case !txn.owner.fill.Contains(change.index):
+			if !change.deleted {
+				continue // Updated a row which does not exist
+			}
+			event.Type = EventDelete
+		case change.inserted:
+			event.Type = EventInsert
+		default:
+			event.Type = EventUpdate
+		}
+
+		for _, sub := range txn.owner.subs.list {
+			if e, ok := sub.project(txn.owner, change, event); ok {
+				txn.events = append(txn.events, pendingEvent{sub: sub, event: e})
+			}
+		}
+	}
+}
+
+// project builds the event for the subscription, returning false if the subscription
+// is not interested in the change.
+func (s *subscription) project(owner *Collection, change *rowChange, event Event) (Event, bool) {
+	if event.Type == EventDelete {
+		return event, true
+	}
+
+	if s.index != nil && !s.index.Contains(change.index) {
+		return event, false
+	}
+
+	event.Values = make(map[string]any, 4)
+	collect := func(column *column) {
+		if _, ok := s.columns[column.name]; len(s.columns) > 0 && !ok {
+			return
+		}
+		if v, ok := column.Value(change.index); ok {
+			event.Values[column.name] = v
+		}
+	}
+
+	switch event.Type {
+	case EventInsert:
+		owner.cols.Range(func(column *column) {
+			if !isComputed(column) {
+				collect(column)
+			}
+		})
+	default:
+		for _, column := range change.columns {
+			collect(column)
+		}
+		if len(event.Values) == 0 {
+			return event, false
+		}
+	}
+	return event, true
+}
+
+// publish delivers the pending events to the subscriptions, once the commit is done. The
+// list of subscriptions is not locked, so a slow subscriber does not prevent the others
+// from subscribing or unsubscribing.
+func (txn *Txn) publish() {
+	if len(txn.events) == 0 {
+		return
+	}
+
+	for i, pending := range txn.events {
+		txn.events[i] = pendingEvent{}
+		pending.sub.send(txn.owner.ctx, pending.event)
+	}
+	txn.events = txn.events[:0]
+}
+
+// send sends the event to the subscription, according to its overflow policy, unless
+// the subscription is closed.
+func (s *subscription) send(ctx context.Context, event Event) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return
+	}
+
+	if s.policy == OverflowDrop {
+		select {
+		case s.events <- event:
+		default:
+		}
+		return
+	}
+
+	select {
+	case s.events <- event:
+	case <-s.ctx.Done():
+	case <-ctx.Done():
+	}
+}
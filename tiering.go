@@ -0,0 +1,424 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/kelindar/iostream"
+)
+
+// TieringPolicy represents the policy of the hot/cold tiering of a collection. The chunks
+// which were not accessed for a while are spilled to disk, using the snapshot encoding,
+// and transparently reloaded when they are accessed again. The indexes are kept in memory,
+// so the queries return the same results whether the chunks are spilled or not.
+type TieringPolicy struct {
+	After    time.Duration // The duration without access after which a chunk is spilled, disabled if zero
+	Interval time.Duration // The interval between the checks of the idle chunks, After if zero
+	Dir      string        // The directory of the spilled chunks
+	OnError  func(error)   // The callback receiving the errors of the spills and reloads (optional)
+}
+
+// releaser represents a column whose chunks can be released from memory once spilled, and
+// allocated again before being reloaded.
+type releaser interface {
+	release(chunk commit.Chunk) int
+	reserve(chunk commit.Chunk)
+}
+
+// release releases the memory of a chunk and returns the approximate number of bytes freed.
+func (s chunks[T]) release(chunk commit.Chunk) (freed int) {
+	if int(chunk) >= len(s) || s[chunk].data == nil {
+		return 0
+	}
+
+	var zero T
+	freed = len(s[chunk].fill)*8 + len(s[chunk].data)*int(unsafe.Sizeof(zero))
+	s[chunk].fill = nil
+	s[chunk].data = nil
+	return
+}
+
+// reserve allocates a chunk which was previously released
+func (s chunks[T]) reserve(chunk commit.Chunk) {
+	if int(chunk) < len(s) && s[chunk].data == nil {
+		s[chunk].fill = make(bitmap.Bitmap, chunkSize/64)
+		s[chunk].data = make([]T, chunkSize)
+	}
+}
+
+// --------------------------- Tiering ----------------------------
+
+// tiering keeps track of the last access to every chunk and of the chunks spilled to disk
+type tiering struct {
+	lock   sync.RWMutex  // The lock to protect the list of chunks
+	policy TieringPolicy // The tiering policy
+	chunks []tierChunk   // The state of every chunk
+}
+
+// tierChunk represents the tiering state of a chunk
+type tierChunk struct {
+	access  int64 // The time of the last access, in unix nanoseconds
+	spilled int32 // Whether the chunk is spilled to disk
+	pinned  int32 // The number of writers which keep the chunk in memory
+}
+
+// newTiering creates a new tiering state for the policy
+func newTiering(policy TieringPolicy) *tiering {
+	if policy.Interval <= 0 {
+		policy.Interval = policy.After
+	}
+
+	return &tiering{
+		policy: policy,
+		chunks: make([]tierChunk, 0, 4),
+	}
+}
+
+// touch records an access to a chunk and returns whether it is spilled
+func (t *tiering) touch(chunk commit.Chunk) bool {
+	now := time.Now().UnixNano()
+	t.lock.RLock()
+	if int(chunk) < len(t.chunks) {
+		atomic.StoreInt64(&t.chunks[chunk].access, now)
+		spilled := atomic.LoadInt32(&t.chunks[chunk].spilled) == 1
+		t.lock.RUnlock()
+		return spilled
+	}
+	t.lock.RUnlock()
+
+	// First access to the chunk, grow the list of chunks
+	t.lock.Lock()
+	for int(chunk) >= len(t.chunks) {
+		t.chunks = append(t.chunks, tierChunk{})
+	}
+	t.chunks[chunk].access = now
+	t.lock.Unlock()
+	return false
+}
+
+// pin keeps a chunk in memory until it is unpinned
+func (t *tiering) pin(chunk commit.Chunk) {
+	t.touch(chunk)
+	t.lock.RLock()
+	atomic.AddInt32(&t.chunks[chunk].pinned, 1)
+	t.lock.RUnlock()
+}
+
+// unpin releases a chunk which was pinned, so that it can be spilled again
+func (t *tiering) unpin(chunk commit.Chunk) {
+	t.lock.RLock()
+	atomic.AddInt32(&t.chunks[chunk].pinned, -1)
+	t.lock.RUnlock()
+}
+
+// idle returns whether a chunk is in memory, is not pinned and was not accessed since
+// the specified time
+func (t *tiering) idle(chunk commit.Chunk, since int64) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return int(chunk) < len(t.chunks) &&
+		atomic.LoadInt32(&t.chunks[chunk].spilled) == 0 &&
+		atomic.LoadInt32(&t.chunks[chunk].pinned) == 0 &&
+		atomic.LoadInt64(&t.chunks[chunk].access) < since
+}
+
+// spilled returns the list of the chunks which are spilled to disk
+func (t *tiering) spilled() (out []commit.Chunk) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for i := range t.chunks {
+		if atomic.LoadInt32(&t.chunks[i].spilled) == 1 {
+			out = append(out, commit.Chunk(i))
+		}
+	}
+	return
+}
+
+// mark marks a chunk as spilled or not
+func (t *tiering) mark(chunk commit.Chunk, spilled bool) {
+	var v int32
+	if spilled {
+		v = 1
+	}
+
+	t.lock.RLock()
+	atomic.StoreInt32(&t.chunks[chunk].spilled, v)
+	t.lock.RUnlock()
+}
+
+// report reports an error to the callback of the policy, if any
+func (t *tiering) report(err error) {
+	if err != nil && t.policy.OnError != nil {
+		t.policy.OnError(err)
+	}
+}
+
+// --------------------------- Collection ----------------------------
+
+// tiers periodically spills the chunks which were not accessed for the duration of the
+// tiering policy, until the collection is closed.
+func (c *Collection) tiers(ctx context.Context) {
+	ticker := time.NewTicker(c.tier.policy.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			if _, err := c.Spill(); err != nil {
+				c.tier.report(err)
+			}
+		}
+	}
+}
+
+// Spill writes the chunks which were not accessed for the duration of the tiering policy
+// to disk and releases their memory, then returns the number of chunks spilled. This is
+// done periodically when a tiering policy is configured, but can also be called manually.
+func (c *Collection) Spill() (int, error) {
+	if c.tier == nil {
+		return 0, fmt.Errorf("column: unable to spill, no tiering policy is configured")
+	}
+
+	if c.IsFrozen() {
+		return 0, nil // Frozen collections are read without locks
+	}
+
+	if err := os.MkdirAll(c.tier.policy.Dir, 0755); err != nil {
+		return 0, err
+	}
+
+	spilled := 0
+	since := time.Now().Add(-c.tier.policy.After).UnixNano()
+	for chunk := commit.Chunk(0); int(chunk) < c.chunks(); chunk++ {
+		if !c.tier.idle(chunk, since) {
+			continue
+		}
+
+		// Check again under the lock, as the chunk may have been accessed meanwhile
+		c.slock.Lock(uint(chunk))
+		var err error
+		if c.tier.idle(chunk, since) && !c.IsFrozen() {
+			err = c.spillChunk(chunk)
+			spilled++
+		}
+		c.slock.Unlock(uint(chunk))
+		if err != nil {
+			return spilled - 1, err
+		}
+	}
+	return spilled, nil
+}
+
+// spillChunk writes the columns of a chunk, whose write lock must be held, into a file and
+// releases their memory.
+func (c *Collection) spillChunk(chunk commit.Chunk) error {
+	file, err := os.Create(c.spillPath(chunk))
+	if err != nil {
+		return err
+	}
+
+	// Write every column which can be released from memory
+	var released []releaser
+	buffer := c.txns.acquirePage("")
+	defer c.txns.releasePage(buffer)
+	writer := iostream.NewWriter(file)
+	if err := c.cols.RangeUntil(func(column *column) error {
+		target, ok := column.Column.(releaser)
+		if !ok || !column.Snapshot(chunk, buffer) {
+			return nil
+		}
+
+		released = append(released, target)
+		return writer.WriteSelf(buffer)
+	}); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	err = writer.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	// Only release the memory once the chunk is entirely written
+	for _, column := range released {
+		column.release(chunk)
+	}
+
+	c.tier.mark(chunk, true)
+	return nil
+}
+
+// loadChunk reloads a chunk, whose write lock must be held, from its file. The values are
+// only applied to the columns themselves, as the indexes were kept in memory. If the file
+// can not be read, the chunk remains spilled and its file is kept, so it can be retried.
+func (c *Collection) loadChunk(chunk commit.Chunk) error {
+	file, err := os.Open(c.spillPath(chunk))
+	if err != nil {
+		return fmt.Errorf("column: unable to reload chunk %d, %w", chunk, err)
+	}
+
+	c.reserveChunk(chunk)
+	err = c.applyChunk(chunk, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		c.releaseChunk(chunk)
+		return fmt.Errorf("column: unable to reload chunk %d, %w", chunk, err)
+	}
+
+	c.tier.mark(chunk, false)
+	os.Remove(file.Name())
+	return nil
+}
+
+// applyChunk applies the columns of a spilled chunk read from the source
+func (c *Collection) applyChunk(chunk commit.Chunk, file io.Reader) error {
+	buffer := c.txns.acquirePage("")
+	defer c.txns.releasePage(buffer)
+	reader := commit.NewReader()
+	src := iostream.NewReader(file)
+	for {
+		_, err := buffer.ReadFrom(src)
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if column, ok := c.cols.Load(buffer.Column); ok {
+			reader.Seek(buffer)
+			column.Apply(chunk, reader)
+		}
+	}
+}
+
+// reserveChunk allocates the memory of a chunk in every column which can be released
+func (c *Collection) reserveChunk(chunk commit.Chunk) {
+	c.cols.Range(func(column *column) {
+		if target, ok := column.Column.(releaser); ok {
+			target.reserve(chunk)
+		}
+	})
+}
+
+// releaseChunk releases the memory of a chunk in every column which can be released
+func (c *Collection) releaseChunk(chunk commit.Chunk) {
+	c.cols.Range(func(column *column) {
+		if target, ok := column.Column.(releaser); ok {
+			target.release(chunk)
+		}
+	})
+}
+
+// spillPath returns the path of the file of a spilled chunk
+func (c *Collection) spillPath(chunk commit.Chunk) string {
+	return filepath.Join(c.tier.policy.Dir, fmt.Sprintf("chunk-%x-%d.bin", c.source, chunk))
+}
+
+// readLockChunk acquires the read lock of a chunk, reloading it first if it was spilled.
+// If the chunk can not be reloaded, the read lock is still acquired and the error is
+// returned, while the chunk remains spilled and reads as empty.
+func (c *Collection) readLockChunk(chunk commit.Chunk) error {
+	c.slock.RLock(uint(chunk))
+	for c.tier != nil && c.tier.touch(chunk) {
+		c.slock.RUnlock(uint(chunk))
+		err := c.reload(chunk)
+		c.slock.RLock(uint(chunk))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinChunks reloads the spilled chunks which are about to be written, and keeps them in
+// memory until unpinChunks is called, so that the writes can be applied once the chunks
+// are locked. If a chunk can not be reloaded, the chunks are unpinned and the error is
+// returned while the writes can still be aborted, as the spilled values are kept.
+func (c *Collection) pinChunks(chunks bitmap.Bitmap) (err error) {
+	if c.tier == nil {
+		return nil
+	}
+
+	chunks.Range(func(x uint32) {
+		c.tier.pin(commit.Chunk(x))
+		if err == nil {
+			err = c.reload(commit.Chunk(x))
+		}
+	})
+
+	if err != nil {
+		c.unpinChunks(chunks)
+	}
+	return
+}
+
+// unpinChunks releases the chunks pinned by pinChunks
+func (c *Collection) unpinChunks(chunks bitmap.Bitmap) {
+	if c.tier == nil {
+		return
+	}
+
+	chunks.Range(func(x uint32) {
+		c.tier.unpin(commit.Chunk(x))
+	})
+}
+
+// reload reloads a chunk, if it was spilled
+func (c *Collection) reload(chunk commit.Chunk) (err error) {
+	c.slock.Lock(uint(chunk))
+	if c.tier.touch(chunk) {
+		err = c.loadChunk(chunk)
+		c.tier.report(err)
+	}
+	c.slock.Unlock(uint(chunk))
+	return
+}
+
+// reloadAll reloads all of the chunks which were spilled, before the operations which do
+// not lock the chunks individually, and returns the first error encountered.
+func (c *Collection) reloadAll() (err error) {
+	if c.tier == nil {
+		return nil
+	}
+
+	for _, chunk := range c.tier.spilled() {
+		if e := c.reload(chunk); err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// removeSpilled removes the files of the chunks which are spilled, once the collection
+// is closed.
+func (c *Collection) removeSpilled() {
+	if c.tier == nil {
+		return
+	}
+
+	for _, chunk := range c.tier.spilled() {
+		os.Remove(c.spillPath(chunk))
+	}
+}
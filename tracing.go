@@ -0,0 +1,95 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"io"
+)
+
+// Tracer represents a tracer which creates the spans for the queries, snapshots and
+// restores of a collection. It mirrors a subset of the OpenTelemetry tracing API, so
+// that an OpenTelemetry tracer can be adapted to it in a few lines.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span represents a single traced operation.
+type Span interface {
+	SetAttribute(key string, value any) // Sets an attribute of the span
+	RecordError(err error)              // Records an error which occurred during the span
+	End()                               // Completes the span
+}
+
+// startSpan starts a span for the transaction and carries it in the transaction context
+func (txn *Txn) startSpan(name string) Span {
+	if txn.owner.opts.Tracer == nil {
+		return nil
+	}
+
+	ctx, span := txn.owner.opts.Tracer.Start(txn.Context(), name)
+	txn.ctx = ctx
+	return span
+}
+
+// endSpan completes the span of the transaction, with the rows matched, the chunks
+// touched and the last commit ID.
+func (txn *Txn) endSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+
+	if txn.setup {
+		span.SetAttribute("column.rows_matched", txn.index.Count())
+	}
+
+	span.SetAttribute("column.chunks_touched", txn.touched)
+	if txn.commitID > 0 {
+		span.SetAttribute("column.commit_id", txn.commitID)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// SnapshotContext writes a collection snapshot into the underlying writer, similarly to
+// Snapshot, and traces it if a tracer is configured.
+func (c *Collection) SnapshotContext(ctx context.Context, dst io.Writer, opts ...SnapshotOption) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.opts.Tracer != nil {
+		var span Span
+		_, span = c.opts.Tracer.Start(ctx, "column.Snapshot")
+		defer c.endSpan(span, &err)
+	}
+	return c.Snapshot(dst, opts...)
+}
+
+// RestoreContext restores the collection from the underlying snapshot reader, similarly
+// to Restore, and traces it if a tracer is configured.
+func (c *Collection) RestoreContext(ctx context.Context, snapshot io.Reader, opts ...RestoreOption) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.opts.Tracer != nil {
+		var span Span
+		_, span = c.opts.Tracer.Start(ctx, "column.Restore")
+		defer c.endSpan(span, &err)
+	}
+	return c.Restore(snapshot, opts...)
+}
+
+// endSpan completes the span of a collection-wide operation
+func (c *Collection) endSpan(span Span, err *error) {
+	span.SetAttribute("column.chunks", c.chunks())
+	span.SetAttribute("column.rows", c.Count())
+	if *err != nil {
+		span.RecordError(*err)
+	}
+	span.End()
+}
@@ -4,18 +4,27 @@
 package column
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
+	"math/bits"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
+	"github.com/tidwall/btree"
 )
 
 var (
 	errNoKey         = errors.New("column: collection does not have a key column")
 	errUnkeyedInsert = errors.New("column: use InsertKey or UpsertKey methods instead")
+	errNoTTL         = errors.New("column: time-to-live is disabled for this collection")
+	errSavepoint     = errors.New("column: savepoint does not belong to the transaction")
 )
 
 // --------------------------- Pool of Transactions ----------------------------
@@ -53,6 +62,22 @@ func (p *txnPool) acquire(owner *Collection) *Txn {
 	txn.owner = owner
 	txn.logger = owner.logger
 	txn.setup = false
+	txn.consistent = false
+	txn.frozen = owner.isReadOnly()
+	txn.from = 0
+	txn.cacheSize = 0
+	txn.strict = owner.opts.Strict
+	txn.err = nil
+	txn.profile = owner.opts.QueryLogger != nil
+	txn.tracing = false
+	txn.steps = txn.steps[:0]
+	txn.touched = 0
+	txn.commitID = 0
+	txn.limited = false
+	txn.maxScan = 0
+	txn.scanned = 0
+	txn.cancel = nil
+	txn.ctx = owner.ctx
 	return txn
 }
 
@@ -78,15 +103,47 @@ func (p *txnPool) releasePage(buffer *commit.Buffer) {
 
 // Txn represents a transaction which supports filtering and projection.
 type Txn struct {
-	cursor  uint32           // The current cursor
-	setup   bool             // Whether the transaction was set up or not
-	owner   *Collection      // The target collection
-	index   bitmap.Bitmap    // The filtering index
-	dirty   bitmap.Bitmap    // The dirty chunks
-	updates []*commit.Buffer // The update buffers
-	columns []columnCache    // The column mapping
-	logger  commit.Logger    // The optional commit logger
-	reader  *commit.Reader   // The commit reader to re-use
+	cursor     uint32             // The current cursor
+	setup      bool               // Whether the transaction was set up or not
+	consistent bool               // Whether all of the chunks are read-locked
+	frozen     bool               // Whether the collection is read-only, so reads are not locked
+	strict     bool               // Whether filtering on unknown columns is an error
+	err        error              // The error recorded while composing the query
+	profile    bool               // Whether the filtering steps are profiled
+	tracing    bool               // Whether a filtering step is being profiled
+	steps      []Step             // The profiled filtering steps
+	touched    int                // The number of chunks touched by the last commit
+	commitID   uint64             // The ID of the last commit
+	limited    bool               // Whether the scans are limited by a timeout or a budget
+	maxScan    int                // The maximum number of rows to scan, unlimited if zero
+	scanned    int                // The number of rows scanned so far
+	cancel     context.CancelFunc // The cancellation of the timeout, if any
+	from       commit.Chunk       // The first chunk to consider, for pagination
+	cacheSize  int                // The number of predicate results to cache per filter
+	ctx        context.Context    // The context of the transaction
+	owner      *Collection        // The target collection
+	index      bitmap.Bitmap      // The filtering index
+	dirty      bitmap.Bitmap      // The dirty chunks
+	pinned     bitmap.Bitmap      // The chunks kept in memory until the transaction is reset
+	updates    []*commit.Buffer   // The update buffers
+	columns    []columnCache      // The column mapping
+	locked     bool               // Whether the dirty chunks are already write-locked
+	held       bitmap.Bitmap      // The shards write-locked until the commit is finished
+	expect     []expectedVersion  // The expected versions of the rows
+	deferred   []deferredUpdate   // The conditional updates, applied when committing
+	logger     commit.Logger      // The optional commit logger
+	reader     *commit.Reader     // The commit reader to re-use
+	events     []pendingEvent     // The change events to deliver to subscribers
+	staged     []stagedValue      // The values staged in typed columns
+}
+
+// Context returns the context of the transaction. This is the context given to
+// QueryContext, or the root context of the collection otherwise.
+func (txn *Txn) Context() context.Context {
+	if txn.ctx == nil {
+		return context.Background()
+	}
+	return txn.ctx
 }
 
 // Index returns the current index
@@ -100,8 +157,16 @@ func (txn *Txn) reset() {
 		txn.owner.txns.releasePage(txn.updates[i])
 	}
 
+	if len(txn.pinned) > 0 {
+		txn.owner.unpinChunks(txn.pinned)
+		txn.pinned.Clear()
+	}
+
+	txn.unstage()
 	txn.dirty.Clear()
 	txn.reader.Rewind()
+	txn.expect = txn.expect[:0]
+	txn.deferred = txn.deferred[:0]
 	txn.columns = txn.columns[:0]
 	txn.updates = txn.updates[:0]
 }
@@ -149,11 +214,56 @@ func (txn *Txn) columnAt(columnName string) (*column, bool) {
 	return column, true
 }
 
-// With applies a logical AND operation to the current query and the specified index.
+// Strict enables the strict mode for the transaction, in which filtering on a column which
+// does not exist is recorded as an error, instead of silently matching nothing.
+func (txn *Txn) Strict() *Txn {
+	txn.strict = true
+	return txn
+}
+
+// Err returns the first error recorded while composing the query, in strict mode. This
+// error is also returned by the query itself.
+func (txn *Txn) Err() error {
+	return txn.err
+}
+
+// filterAt loads the column used for filtering and, in strict mode, records an error if
+// it does not exist.
+func (txn *Txn) filterAt(columnName string) (*column, bool) {
+	column, ok := txn.columnAt(columnName)
+	if !ok && txn.strict && txn.err == nil {
+		txn.err = fmt.Errorf("column: unable to filter, column '%s' does not exist", columnName)
+	}
+	return column, ok
+}
+
+// indexAt loads the column used as a bitmap index. In strict mode, it records an error if
+// the column is not an index, a view or a boolean column, since the fill list of the other
+// columns would be used instead.
+func (txn *Txn) indexAt(columnName string) (*column, bool) {
+	column, ok := txn.filterAt(columnName)
+	if !ok || !txn.strict {
+		return column, ok
+	}
+
+	switch column.Column.(type) {
+	case *columnIndex, *columnView, *columnBool:
+		return column, true
+	default:
+		if txn.err == nil {
+			txn.err = fmt.Errorf("column: unable to filter, column '%s' is not an index", columnName)
+		}
+		return nil, false
+	}
+}
+
+// With applies a logical AND operation to the current query and the specified index. When
+// several indexes are specified, they are intersected from the most to the least selective.
 func (txn *Txn) With(columns ...string) *Txn {
+	defer txn.trace("With", columns...)()
 	txn.initialize()
-	for _, columnName := range columns {
-		if idx, ok := txn.columnAt(columnName); ok {
+	for _, columnName := range txn.bySelectivity(columns) {
+		if idx, ok := txn.indexAt(columnName); ok {
 			txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
 				dst.And(src)
 			})
@@ -164,11 +274,61 @@ func (txn *Txn) With(columns ...string) *Txn {
 	return txn
 }
 
+// WithBool filters down the rows for which the boolean data column is set to true. Unlike
+// With, the column must be a boolean column, otherwise nothing is matched.
+func (txn *Txn) WithBool(column string) *Txn {
+	defer txn.trace("WithBool", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if ok {
+		_, ok = c.Column.(*columnBool)
+		if !ok && txn.strict && txn.err == nil {
+			txn.err = fmt.Errorf("column: unable to filter, column '%s' is not a boolean", column)
+		}
+	}
+
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	txn.rangeReadPair(c, func(dst, src bitmap.Bitmap) {
+		dst.And(src)
+	})
+	return txn
+}
+
+// WithBoolFalse filters down the rows for which the boolean data column is explicitly set to
+// false. Unlike Without, the rows which have no value in the column are not matched.
+func (txn *Txn) WithBoolFalse(column string) *Txn {
+	defer txn.trace("WithBoolFalse", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	var source *columnBool
+	if ok {
+		source, ok = c.Column.(*columnBool)
+		if !ok && txn.strict && txn.err == nil {
+			txn.err = fmt.Errorf("column: unable to filter, column '%s' is not a boolean", column)
+		}
+	}
+
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		index.And(source.falses(chunk))
+	})
+	return txn
+}
+
 // Without applies a logical AND NOT operation to the current query and the specified index.
 func (txn *Txn) Without(columns ...string) *Txn {
+	defer txn.trace("Without", columns...)()
 	txn.initialize()
 	for _, columnName := range columns {
-		if idx, ok := txn.columnAt(columnName); ok {
+		if idx, ok := txn.indexAt(columnName); ok {
 			txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
 				dst.AndNot(src)
 			})
@@ -179,11 +339,12 @@ func (txn *Txn) Without(columns ...string) *Txn {
 
 // Union computes a union between the current query and the specified index.
 func (txn *Txn) Union(columns ...string) *Txn {
+	defer txn.trace("Union", columns...)()
 	first := !txn.setup
 	txn.initialize()
 
 	for _, columnName := range columns {
-		if idx, ok := txn.columnAt(columnName); ok {
+		if idx, ok := txn.indexAt(columnName); ok {
 			txn.rangeReadPair(idx, func(dst, src bitmap.Bitmap) {
 				if first {
 					dst.And(src)
@@ -204,10 +365,12 @@ func (txn *Txn) WithUnion(columns ...string) *Txn {
 		return txn.Union(columns...)
 	}
 
+	defer txn.trace("WithUnion", columns...)()
+
 	// allocate slice of column pointers
 	cols := make([]*column, 0)
 	for _, columnName := range columns {
-		if idx, ok := txn.columnAt(columnName); ok {
+		if idx, ok := txn.indexAt(columnName); ok {
 			cols = append(cols, idx)
 		}
 	}
@@ -220,8 +383,12 @@ func (txn *Txn) WithUnion(columns ...string) *Txn {
 	lock := txn.owner.slock
 
 	// range & lock over each available chunk
-	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
-		lock.RLock(uint(chunk))
+	for chunk := txn.from; chunk <= limit; chunk++ {
+		if txn.limited && !txn.admit(chunk) {
+			break
+		}
+
+		txn.readLock(lock, chunk)
 
 		// reset entire bitmap
 		for i := range tmpMap {
@@ -237,7 +404,7 @@ func (txn *Txn) WithUnion(columns ...string) *Txn {
 		idxMap := chunk.OfBitmap(txn.index)
 		idxMap.And(tmpMap)
 
-		lock.RUnlock(uint(chunk))
+		txn.readUnlock(lock, chunk)
 	}
 
 	return txn
@@ -246,8 +413,9 @@ func (txn *Txn) WithUnion(columns ...string) *Txn {
 // WithValue applies a filter predicate over values for a specific properties. It filters
 // down the items in the query.
 func (txn *Txn) WithValue(column string, predicate func(v interface{}) bool) *Txn {
+	defer txn.trace("WithValue", column)()
 	txn.initialize()
-	c, ok := txn.columnAt(column)
+	c, ok := txn.filterAt(column)
 	if !ok {
 		txn.index.Clear()
 		return txn
@@ -268,8 +436,9 @@ func (txn *Txn) WithValue(column string, predicate func(v interface{}) bool) *Tx
 // WithFloat filters down the values based on the specified predicate. The column for
 // this filter must be numerical and convertible to float64.
 func (txn *Txn) WithFloat(column string, predicate func(v float64) bool) *Txn {
+	defer txn.trace("WithFloat", column)()
 	txn.initialize()
-	c, ok := txn.columnAt(column)
+	c, ok := txn.filterAt(column)
 	if !ok || !c.IsNumeric() {
 		txn.index.Clear()
 		return txn
@@ -284,8 +453,9 @@ func (txn *Txn) WithFloat(column string, predicate func(v float64) bool) *Txn {
 // WithInt filters down the values based on the specified predicate. The column for
 // this filter must be numerical and convertible to int64.
 func (txn *Txn) WithInt(column string, predicate func(v int64) bool) *Txn {
+	defer txn.trace("WithInt", column)()
 	txn.initialize()
-	c, ok := txn.columnAt(column)
+	c, ok := txn.filterAt(column)
 	if !ok || !c.IsNumeric() {
 		txn.index.Clear()
 		return txn
@@ -300,8 +470,9 @@ func (txn *Txn) WithInt(column string, predicate func(v int64) bool) *Txn {
 // WithUint filters down the values based on the specified predicate. The column for
 // this filter must be numerical and convertible to uint64.
 func (txn *Txn) WithUint(column string, predicate func(v uint64) bool) *Txn {
+	defer txn.trace("WithUint", column)()
 	txn.initialize()
-	c, ok := txn.columnAt(column)
+	c, ok := txn.filterAt(column)
 	if !ok || !c.IsNumeric() {
 		txn.index.Clear()
 		return txn
@@ -313,22 +484,228 @@ func (txn *Txn) WithUint(column string, predicate func(v uint64) bool) *Txn {
 	return txn
 }
 
+// WithInt64Range filters down the values to the ones within the inclusive [from, to]
+// range. The column for this filter must be numerical and convertible to int64.
+func (txn *Txn) WithInt64Range(column string, from, to int64) *Txn {
+	defer txn.trace("WithInt64Range", column)()
+	return txn.withRange(column, func(c Numeric, chunk commit.Chunk, index bitmap.Bitmap) {
+		c.FilterRangeInt64(chunk, index, from, to)
+	})
+}
+
+// WithUint64Range filters down the values to the ones within the inclusive [from, to]
+// range. The column for this filter must be numerical and convertible to uint64.
+func (txn *Txn) WithUint64Range(column string, from, to uint64) *Txn {
+	defer txn.trace("WithUint64Range", column)()
+	return txn.withRange(column, func(c Numeric, chunk commit.Chunk, index bitmap.Bitmap) {
+		c.FilterRangeUint64(chunk, index, from, to)
+	})
+}
+
+// WithFloat64Range filters down the values to the ones within the inclusive [from, to]
+// range. The column for this filter must be numerical and convertible to float64.
+func (txn *Txn) WithFloat64Range(column string, from, to float64) *Txn {
+	defer txn.trace("WithFloat64Range", column)()
+	return txn.withRange(column, func(c Numeric, chunk commit.Chunk, index bitmap.Bitmap) {
+		c.FilterRangeFloat64(chunk, index, from, to)
+	})
+}
+
+// WithFloat64Above filters down the values to the ones strictly greater than the
+// specified value. The column for this filter must be numerical.
+func (txn *Txn) WithFloat64Above(column string, value float64) *Txn {
+	defer txn.trace("WithFloat64Above", column)()
+	return txn.WithFloat64Range(column, math.Nextafter(value, math.Inf(1)), math.Inf(1))
+}
+
+// WithFloat64Below filters down the values to the ones strictly lower than the
+// specified value. The column for this filter must be numerical.
+func (txn *Txn) WithFloat64Below(column string, value float64) *Txn {
+	defer txn.trace("WithFloat64Below", column)()
+	return txn.WithFloat64Range(column, math.Inf(-1), math.Nextafter(value, math.Inf(-1)))
+}
+
+// withRange applies a range filter on a numeric column
+func (txn *Txn) withRange(column string, fn func(Numeric, commit.Chunk, bitmap.Bitmap)) *Txn {
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok || !c.IsNumeric() {
+		txn.index.Clear()
+		return txn
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		fn(c.Column.(Numeric), chunk, index)
+	})
+	return txn
+}
+
+// WithStringEqual filters down the values to the ones equal to the specified string.
+// For enum columns the string is resolved once and compared by its dictionary location
+// rather than by value. The column for this filter must be textual.
+func (txn *Txn) WithStringEqual(column, value string) *Txn {
+	defer txn.trace("WithStringEqual", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok || !c.IsTextual() {
+		txn.index.Clear()
+		return txn
+	}
+
+	if enum, ok := c.Column.(*columnEnum); ok {
+		txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+			enum.filterEqual(chunk, index, value)
+		})
+		return txn
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		c.Column.(Textual).FilterString(chunk, index, func(v string) bool {
+			return v == value
+		})
+	})
+	return txn
+}
+
+// WithOneOf filters down the values to the ones equal to any of the specified strings.
+// For enum columns the strings are resolved once and compared by their dictionary location,
+// without requiring an index. The column for this filter must be textual.
+func (txn *Txn) WithOneOf(column string, values ...string) *Txn {
+	defer txn.trace("WithOneOf", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok || !c.IsTextual() {
+		txn.index.Clear()
+		return txn
+	}
+
+	if enum, ok := c.Column.(*columnEnum); ok {
+		txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+			enum.filterOneOf(chunk, index, values)
+		})
+		return txn
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		c.Column.(Textual).FilterString(chunk, index, func(v string) bool {
+			for _, value := range values {
+				if v == value {
+					return true
+				}
+			}
+			return false
+		})
+	})
+	return txn
+}
+
+// WithEnumID filters down the values to the ones with any of the specified dictionary
+// identifiers, as returned by the ID() or Lookup() methods of the enum accessor. The
+// column for this filter must be an enum.
+func (txn *Txn) WithEnumID(column string, ids ...uint32) *Txn {
+	defer txn.trace("WithEnumID", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	enum, ok := c.Column.(*columnEnum)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	var set bitmap.Bitmap
+	for _, id := range ids {
+		set.Set(id)
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		enum.filterIDs(chunk, index, set)
+	})
+	return txn
+}
+
 // WithString filters down the values based on the specified predicate. The column for
 // this filter must be a string.
 func (txn *Txn) WithString(column string, predicate func(v string) bool) *Txn {
+	defer txn.trace("WithString", column)()
 	txn.initialize()
-	c, ok := txn.columnAt(column)
+	c, ok := txn.filterAt(column)
 	if !ok || !c.IsTextual() {
 		txn.index.Clear()
 		return txn
 	}
 
+	enum, isEnum := c.Column.(*columnEnum)
 	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		if isEnum {
+			enum.filterCached(chunk, index, predicate, txn.cacheSize)
+			return
+		}
+
 		c.Column.(Textual).FilterString(chunk, index, predicate)
 	})
 	return txn
 }
 
+// WithCache sets the number of recently evaluated values for which the predicate result
+// is cached by the subsequent string filters on enum columns. By default only the last
+// value is cached, a larger cache helps when the data is skewed across a few values.
+func (txn *Txn) WithCache(size int) *Txn {
+	txn.cacheSize = size
+	return txn
+}
+
+// WithPrefix filters down the values to the ones starting with the specified prefix. If
+// a trigram index exists on the column, it is used to narrow down the candidates before
+// the values are checked, otherwise the column is scanned.
+func (txn *Txn) WithPrefix(column, prefix string) *Txn {
+	defer txn.trace("WithPrefix", column)()
+	txn.withTrigrams(column, prefix, true)
+	return txn.WithString(column, func(v string) bool {
+		return strings.HasPrefix(v, prefix)
+	})
+}
+
+// WithContains filters down the values to the ones containing the specified substring. If
+// a trigram index exists on the column, it is used to narrow down the candidates before
+// the values are checked, otherwise the column is scanned.
+func (txn *Txn) WithContains(column, substr string) *Txn {
+	defer txn.trace("WithContains", column)()
+	txn.withTrigrams(column, substr, false)
+	return txn.WithString(column, func(v string) bool {
+		return strings.Contains(v, substr)
+	})
+}
+
+// withTrigrams intersects the current query with the candidates of a trigram index
+// for a given column, if such index exists.
+func (txn *Txn) withTrigrams(column, pattern string, anchored bool) {
+	txn.initialize()
+	columns, ok := txn.owner.cols.LoadWithIndex(column)
+	if !ok {
+		return
+	}
+
+	for _, v := range columns[1:] {
+		index, ok := v.Column.(*columnTrigram)
+		if !ok {
+			continue
+		}
+
+		var candidates bitmap.Bitmap
+		if index.candidates(pattern, anchored, &candidates) {
+			txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+				index.And(chunk.OfBitmap(candidates))
+			})
+		}
+		return
+	}
+}
+
 // Count returns the number of objects matching the query
 func (txn *Txn) Count() int {
 	txn.initialize()
@@ -361,6 +738,106 @@ func (txn *Txn) Insert(fn func(Row) error) (uint32, error) {
 	return txn.insert(fn, 0)
 }
 
+// InsertObject adds an object to the collection and returns the allocated index. The
+// object is validated against the schema before anything is written, so an invalid
+// object (e.g. unknown column or mismatched type) does not leave a partial row.
+func (txn *Txn) InsertObject(obj map[string]any) (uint32, error) {
+	if err := txn.validate(obj); err != nil {
+		return 0, err
+	}
+
+	return txn.Insert(func(r Row) error {
+		return r.SetMany(obj)
+	})
+}
+
+// UpsertObject inserts or updates an object, keyed by the value of the specified primary
+// key column. The key is extracted from the object and the remaining values are set on
+// the row.
+func (txn *Txn) UpsertObject(obj map[string]any, keyColumn string) error {
+	if txn.owner.pk == nil {
+		return errNoKey
+	}
+
+	if keyColumn != txn.owner.pkName {
+		return fmt.Errorf("column: unable to upsert, '%s' is not the key column", keyColumn)
+	}
+
+	key, ok := obj[keyColumn].(string)
+	if !ok {
+		return fmt.Errorf("column: unable to upsert, key '%s' must be a string", keyColumn)
+	}
+
+	values := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if k != keyColumn {
+			values[k] = v
+		}
+	}
+
+	if err := txn.validate(values); err != nil {
+		return err
+	}
+
+	return txn.UpsertKey(key, func(r Row) error {
+		return r.SetMany(values)
+	})
+}
+
+// UpsertKeyMany inserts or updates a row with the specified primary key, merging the values
+// into the existing row. See Row.MergeMany for more details on how the values are merged.
+func (txn *Txn) UpsertKeyMany(key string, values map[string]any) error {
+	if err := txn.validate(values); err != nil {
+		return err
+	}
+
+	return txn.UpsertKey(key, func(r Row) error {
+		return r.MergeMany(values)
+	})
+}
+
+// validate checks whether the object can be written into the collection.
+func (txn *Txn) validate(obj map[string]any) error {
+	for k, v := range obj {
+		column, ok := txn.columnAt(k)
+		switch {
+		case !ok:
+			return fmt.Errorf("column: unable to set '%s', no such column", k)
+		case isComputed(column):
+			return fmt.Errorf("column: unable to set '%s', column is computed", k)
+		case !column.Accepts(v):
+			return fmt.Errorf("column: unable to set '%s', unsupported type %T", k, v)
+		}
+	}
+	return nil
+}
+
+// backfill writes the values returned by the function into the column, for every row
+// selected by the transaction.
+func (txn *Txn) backfill(columnName string, fn func(r Row) any) (err error) {
+	column, ok := txn.columnAt(columnName)
+	if !ok {
+		return fmt.Errorf("column: unable to backfill '%s', no such column", columnName)
+	}
+
+	buffer := txn.bufferFor(columnName)
+	txn.Range(func(idx uint32) {
+		value := fn(Row{txn})
+		switch {
+		case err != nil || value == nil:
+			return
+		case !column.Accepts(value):
+			err = fmt.Errorf("column: unable to set '%s', unsupported type %T", columnName, value)
+			return
+		}
+
+		if value, err = encodeValue(column, value); err == nil {
+			err = buffer.PutAny(commit.Put, idx, value)
+		}
+	})
+	return
+}
+
 // insert creates an insertion cursor for a given column and expiration time.
 func (txn *Txn) insert(fn func(Row) error, expireAt int64) (uint32, error) {
 
@@ -379,49 +856,274 @@ func (txn *Txn) insert(fn func(Row) error, expireAt int64) (uint32, error) {
 
 // --------------------------- Iteration ----------------------------
 
-// Range selects and iterates over result set. In each iteration step, the internal
-// transaction cursor is updated and can be used by various column accessors.
+// Range selects and iterates over result set, in the ascending order of the indexes of the
+// rows. In each iteration step, the internal transaction cursor is updated and can be used
+// by various column accessors. See RangeStable for other stable orders.
 func (txn *Txn) Range(fn func(idx uint32)) error {
 	txn.initialize()
+	prefetch := txn.owner.opts.Prefetch
 	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		if prefetch {
+			txn.prefetch(chunk + 1)
+		}
+
 		offset := chunk.Min()
 		index.Range(func(x uint32) {
 			txn.cursor = offset + x
 			fn(offset + x)
 		})
 	})
-	return nil
+	return txn.err
+}
+
+// prefetcher represents a column which can bring a chunk of data into memory ahead
+// of it being read.
+type prefetcher interface {
+	prefetch(chunk commit.Chunk) byte
+}
+
+// maxPrefetches is the maximum number of prefetches queued per collection
+const maxPrefetches = 4
+
+// prefetchTask represents a chunk of a column queued for prefetching
+type prefetchTask struct {
+	column *column      // The column to prefetch
+	chunk  commit.Chunk // The chunk to prefetch
+}
+
+// prefetch queues the chunk of every column used by this transaction for prefetching, so
+// that memory stalls on cold data (e.g. after a restore) overlap with the processing of the
+// current chunk. The columns are skipped if the queue of the collection is already full.
+func (txn *Txn) prefetch(chunk commit.Chunk) {
+	if _, ok := chunk.OfBitmap(txn.index).Min(); !ok {
+		return // Nothing will be read from this chunk
+	}
+
+	for _, c := range txn.columns {
+		if _, ok := c.col.Column.(prefetcher); !ok {
+			continue
+		}
+
+		select {
+		case txn.owner.prefetch <- prefetchTask{column: c.col, chunk: chunk}:
+		default:
+			return // Too many prefetches are already queued
+		}
+	}
+}
+
+// prefetches touches the chunks queued by the transactions, one at a time, until the
+// collection is closed.
+func (c *Collection) prefetches(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-c.prefetch:
+			c.slock.RLock(uint(task.chunk))
+
+			// The column lock prevents the chunks from being grown while they are read
+			task.column.lock.RLock()
+			task.column.Column.(prefetcher).prefetch(task.chunk)
+			task.column.lock.RUnlock()
+			c.slock.RUnlock(uint(task.chunk))
+		}
+	}
+}
+
+// After resumes a paginated query from the specified page token, previously returned
+// by RangePage. Every row up to and including the last visited one is removed from the
+// result set and the subsequent filters skip the chunks which precede it, so it should
+// be called before any other filter. An empty token starts from the beginning, while
+// an invalid token results in an empty result set.
+func (txn *Txn) After(token string) *Txn {
+	txn.initialize()
+	if token == "" {
+		return txn
+	}
+
+	last, ok := decodePageToken(token)
+	if !ok {
+		txn.index.Clear()
+		return txn
+	}
+
+	// Clear all of the blocks preceding the last visited offset
+	blkAt := int(last >> 6)
+	for i := 0; i < blkAt && i < len(txn.index); i++ {
+		txn.index[i] = 0
+	}
+
+	// Clear the bits of the block up to and including the last visited offset
+	if blkAt < len(txn.index) {
+		txn.index[blkAt] &= ^uint64(0) << ((last & 0x3f) + 1)
+	}
+
+	txn.from = commit.ChunkAt(last)
+	return txn
+}
+
+// RangePage iterates over at most limit rows of the result set, in the order of their
+// offsets. It returns an opaque token which can be provided to After in a subsequent
+// transaction in order to resume the iteration, or an empty token if there are no
+// more rows to iterate over. If the limit is not positive, no row is iterated over and
+// an empty token is returned.
+func (txn *Txn) RangePage(limit int, fn func(idx uint32)) (next string) {
+	if limit <= 0 {
+		return ""
+	}
+
+	txn.initialize()
+	count, last := 0, uint32(0)
+	until := commit.Chunk(len(txn.index) >> bitmapShift)
+	lock := txn.owner.slock
+
+	for chunk := txn.from; chunk <= until; chunk++ {
+		if txn.limited && !txn.admit(chunk) {
+			return ""
+		}
+
+		txn.readLock(lock, chunk)
+		offset := chunk.Min()
+		for blkAt, blk := range chunk.OfBitmap(txn.index) {
+			for ; blk != 0; blk &= blk - 1 {
+				if count == limit {
+					txn.readUnlock(lock, chunk)
+					return encodePageToken(last)
+				}
+
+				last = offset + uint32(blkAt<<6+bits.TrailingZeros64(blk))
+				txn.cursor = last
+				fn(last)
+				count++
+			}
+		}
+		txn.readUnlock(lock, chunk)
+	}
+	return ""
+}
+
+// encodePageToken encodes the last visited offset into an opaque page token
+func encodePageToken(last uint32) string {
+	return base64.RawURLEncoding.EncodeToString(binary.AppendUvarint(nil, uint64(last)))
+}
+
+// decodePageToken decodes the last visited offset from a page token
+func decodePageToken(token string) (uint32, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, false
+	}
+
+	last, n := binary.Uvarint(data)
+	if n != len(data) || last > math.MaxUint32 {
+		return 0, false
+	}
+	return uint32(last), true
 }
 
 // Ascend through a given SortedIndex and returns each offset
 // remaining in the transaction's index
 func (txn *Txn) Ascend(sortIndexName string, fn func(idx uint32)) error {
+	return txn.scanSorted(sortIndexName, fn, func(tree *btree.BTreeG[sortIndexItem], iter func(sortIndexItem) bool) {
+		tree.Scan(iter)
+	})
+}
+
+// Descend through a given SortedIndex, in descending order of the keys, and returns each
+// offset remaining in the transaction's index
+func (txn *Txn) Descend(sortIndexName string, fn func(idx uint32)) error {
+	return txn.scanSorted(sortIndexName, fn, func(tree *btree.BTreeG[sortIndexItem], iter func(sortIndexItem) bool) {
+		tree.Reverse(iter)
+	})
+}
+
+// AscendRange iterates through a given SortedIndex, in ascending order, over the keys which
+// are greater or equal to from and strictly less than to, and returns each offset remaining
+// in the transaction's index. The iteration seeks directly to the first key of the range.
+func (txn *Txn) AscendRange(sortIndexName, from, to string, fn func(idx uint32)) error {
+	return txn.scanSorted(sortIndexName, fn, func(tree *btree.BTreeG[sortIndexItem], iter func(sortIndexItem) bool) {
+		tree.Ascend(sortIndexItem{Key: from}, func(item sortIndexItem) bool {
+			return item.Key < to && iter(item)
+		})
+	})
+}
+
+// DescendRange iterates through a given SortedIndex, in descending order, over the keys
+// which are greater or equal to from and strictly less than to, and returns each offset
+// remaining in the transaction's index. The iteration seeks directly to the last key of the
+// range.
+func (txn *Txn) DescendRange(sortIndexName, from, to string, fn func(idx uint32)) error {
+	return txn.scanSorted(sortIndexName, fn, func(tree *btree.BTreeG[sortIndexItem], iter func(sortIndexItem) bool) {
+		tree.Descend(sortIndexItem{Key: to}, func(item sortIndexItem) bool {
+			switch {
+			case item.Key >= to:
+				return true // The upper bound is exclusive
+			case item.Key < from:
+				return false
+			default:
+				return iter(item)
+			}
+		})
+	})
+}
+
+// scanSorted scans a sorted index with the provided function and calls fn for each of the
+// offsets which remain in the transaction's index.
+func (txn *Txn) scanSorted(sortIndexName string, fn func(idx uint32), scan func(*btree.BTreeG[sortIndexItem], func(sortIndexItem) bool)) error {
 	txn.initialize()
-	txn.owner.lock.RLock() // protect against writes on btree
+	txn.owner.lock.RLock() // protect against inserts and deletes
 	defer txn.owner.lock.RUnlock()
-	// lock := txn.owner.slock
 
-	sortIndex, ok := txn.owner.cols.Load(sortIndexName)
-	if !ok {
-		return fmt.Errorf("column: no sorted index named '%v'", sortIndexName)
+	sortIndexCol, err := txn.sortIndexOf(sortIndexName)
+	if err != nil {
+		return err
 	}
 
-	// For each btree key, check if the offset is still in
-	// the txn's index & return if true
-	sortIndexCol, _ := sortIndex.Column.(*columnSortIndex)
-	sortIndexCol.btree.Scan(func(item sortIndexItem) bool {
+	// For each btree key, check if the offset is still in the txn's index & return if
+	// true. The scan works on a copy of the tree, so that the updates are not blocked.
+	scan(sortIndexCol.tree(), func(item sortIndexItem) bool {
 		if txn.index.Contains(item.Value) {
-			// chunk := commit.ChunkAt(item.Value)
-			// lock.RLock(uint(chunk))
 			txn.cursor = item.Value
 			fn(item.Value)
-			// lock.RUnlock(uint(chunk))
 		}
 		return true
 	})
 	return nil
 }
 
+// CountKey returns the number of rows remaining in the transaction's index which have
+// the specified key in a given sorted index.
+func (txn *Txn) CountKey(sortIndexName, key string) (int, error) {
+	txn.initialize()
+	sortIndex, err := txn.sortIndexOf(sortIndexName)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	sortIndex.rangeKey(key, func(idx uint32) {
+		if txn.index.Contains(idx) {
+			count++
+		}
+	})
+	return count, nil
+}
+
+// sortIndexOf loads a sorted index by its name
+func (txn *Txn) sortIndexOf(sortIndexName string) (*columnSortIndex, error) {
+	column, ok := txn.owner.cols.Load(sortIndexName)
+	if !ok {
+		return nil, fmt.Errorf("column: no sorted index named '%v'", sortIndexName)
+	}
+
+	sortIndex, ok := column.Column.(*columnSortIndex)
+	if !ok {
+		return nil, fmt.Errorf("column: '%v' is not a sorted index", sortIndexName)
+	}
+	return sortIndex, nil
+}
+
 // DeleteAll marks all of the items currently selected by this transaction for deletion. The
 // actual delete will take place once the transaction is committed.
 func (txn *Txn) DeleteAll() {
@@ -439,13 +1141,17 @@ func (txn *Txn) InsertKey(key string, fn func(Row) error) error {
 		return errNoKey
 	}
 
+	if err := checkKey(txn.owner.pk, key); err != nil {
+		return err
+	}
+
 	if idx, ok := txn.owner.pk.OffsetOf(key); ok {
 		return fmt.Errorf("column: key '%s' already exists at offset %d", key, idx)
 	}
 
 	// If not found, insert at a new index
 	idx, err := txn.insert(fn, 0)
-	txn.bufferFor(txn.owner.pk.name).PutString(commit.Put, idx, key)
+	txn.bufferFor(txn.owner.pkName).PutString(commit.Put, idx, key)
 	return err
 }
 
@@ -455,13 +1161,17 @@ func (txn *Txn) UpsertKey(key string, fn func(Row) error) error {
 		return errNoKey
 	}
 
+	if err := checkKey(txn.owner.pk, key); err != nil {
+		return err
+	}
+
 	if idx, ok := txn.owner.pk.OffsetOf(key); ok {
 		return txn.QueryAt(idx, fn)
 	}
 
 	// If not found, insert at a new index
 	idx, err := txn.insert(fn, 0)
-	txn.bufferFor(txn.owner.pk.name).PutString(commit.Put, idx, key)
+	txn.bufferFor(txn.owner.pkName).PutString(commit.Put, idx, key)
 	return err
 }
 
@@ -505,21 +1215,227 @@ func (txn *Txn) rollback() {
 	txn.reset()
 }
 
+// --------------------------- Savepoints ----------------------------
+
+// Savepoint represents a position in the pending updates of a transaction, to which
+// the transaction can be rolled back.
+type Savepoint struct {
+	buffers  []*commit.Buffer // The update buffers at the time of the savepoint
+	marks    []commit.Mark    // The position in each of the buffers
+	expect   int              // The number of expected versions
+	deferred int              // The number of conditional updates
+}
+
+// Savepoint returns the current position in the pending updates of the transaction. It
+// can be given to RollbackTo in order to undo the updates made after this point, while
+// the transaction is still in progress.
+func (txn *Txn) Savepoint() Savepoint {
+	sp := Savepoint{
+		buffers:  make([]*commit.Buffer, 0, len(txn.updates)),
+		marks:    make([]commit.Mark, 0, len(txn.updates)),
+		expect:   len(txn.expect),
+		deferred: len(txn.deferred),
+	}
+
+	sp.buffers = append(sp.buffers, txn.updates...)
+	sp.marks = txn.marks(sp.marks)
+	return sp
+}
+
+// RollbackTo discards the updates, inserts, deletes, conditional updates and expected
+// versions made after the savepoint, without aborting the transaction. The filters applied
+// on the transaction are not affected. The rows reserved by the discarded inserts are
+// released.
+func (txn *Txn) RollbackTo(sp Savepoint) error {
+	if len(sp.buffers) > len(txn.updates) || sp.expect > len(txn.expect) || sp.deferred > len(txn.deferred) {
+		return errSavepoint
+	}
+
+	for i, u := range sp.buffers {
+		if txn.updates[i] != u {
+			return errSavepoint
+		}
+	}
+
+	// Find the inserts which will be discarded
+	var inserts map[uint32]struct{}
+	if rows, ok := txn.findMarkers(); ok {
+		inserts = insertsOf(rows)
+	}
+
+	// Truncate the buffers back to the savepoint and clear the ones created since, along
+	// with the expected versions and conditional updates
+	txn.truncate(sp.marks)
+	txn.expect = txn.expect[:sp.expect]
+	txn.deferred = txn.deferred[:sp.deferred]
+
+	// Release the rows which are no longer inserted
+	if rows, ok := txn.findMarkers(); ok {
+		for idx := range insertsOf(rows) {
+			delete(inserts, idx)
+		}
+	}
+	for idx := range inserts {
+		txn.owner.free(idx)
+	}
+	return nil
+}
+
+// marks appends the current position in each of the update buffers to dst
+func (txn *Txn) marks(dst []commit.Mark) []commit.Mark {
+	for _, u := range txn.updates {
+		dst = append(dst, u.Mark())
+	}
+	return dst
+}
+
+// truncate truncates the update buffers back to the marks, and clears the buffers which
+// were created after the marks were taken.
+func (txn *Txn) truncate(marks []commit.Mark) {
+	for i, u := range txn.updates {
+		if i < len(marks) {
+			u.Truncate(marks[i])
+		} else {
+			u.Reset(u.Column)
+		}
+	}
+}
+
+// insertsOf returns the set of rows inserted in the buffer
+func insertsOf(buffer *commit.Buffer) map[uint32]struct{} {
+	out := make(map[uint32]struct{})
+	reader := commit.NewReader()
+	reader.Seek(buffer)
+	for reader.Next() {
+		if reader.Type == commit.Insert {
+			out[reader.Index()] = struct{}{}
+		}
+	}
+	return out
+}
+
 // Commit commits the transaction by applying all pending updates and deletes to
 // the collection. This operation is can be called several times for a transaction
 // in order to perform partial commits. If there's no pending updates/deletes, this
 // operation will result in a no-op.
-func (txn *Txn) commit() {
+func (txn *Txn) commit() error {
 	defer txn.reset()
 
-	// Mark the dirty chunks from the updates
+	// Reject the writes once the collection is frozen
+	if txn.modifies() {
+		if err := txn.owner.beginWrite(); err != nil {
+			txn.freeInserts()
+			txn.rollback()
+			return err
+		}
+		defer txn.owner.endWrite()
+	}
+
+	if err := txn.prepare(false); err != nil {
+		txn.freeInserts()
+		txn.rollback()
+		return err
+	}
+
+	if txn.owner.batch != nil && !txn.conditional() {
+		txn.owner.batch.commit(txn)
+		return nil
+	}
+
+	txn.apply()
+	txn.finish()
+	return nil
+}
+
+// prepare marks the dirty chunks, reloads them if they were spilled and checks the expected
+// versions of the rows. If the lock is requested or any versions are expected, the shards
+// of the dirty chunks remain write-locked until the transaction is finished.
+func (txn *Txn) prepare(lock bool) error {
+	txn.markDirty()
+
+	// Reload the spilled chunks while the transaction can still be rolled back
+	if err := txn.pin(); err != nil {
+		return err
+	}
+
+	// Check the expected versions and keep the chunks locked until they are committed
+	if lock || txn.conditional() {
+		txn.lockExpected()
+	}
+
+	if len(txn.expect) > 0 {
+		if err := txn.checkVersions(); err != nil {
+			txn.unlockExpected()
+			return err
+		}
+	}
+
+	// Apply the conditional updates, now that the rows can no longer change
+	if len(txn.deferred) > 0 {
+		if err := txn.commitDeferred(); err != nil {
+			txn.unlockExpected()
+			return err
+		}
+	}
+
+	// Validate the values, now that the conditional updates are resolved
+	if txn.locked {
+		if err := txn.checkValues(); err != nil {
+			txn.unlockExpected()
+			return err
+		}
+	}
+
+	// Increment the versions of the modified rows
+	if txn.owner.opts.Versioned {
+		txn.commitVersions()
+		txn.markDirty()
+	}
+	return nil
+}
+
+// markDirty marks the dirty chunks from the updates
+func (txn *Txn) markDirty() {
 	for _, u := range txn.updates {
 		u.RangeChunks(func(chunk commit.Chunk) {
 			txn.dirty.Set(uint32(chunk))
 		})
+
+		// Start cleaning up the expired rows once an expiration is set
+		if u.Column == expireColumn && !u.IsEmpty() {
+			txn.owner.startVacuum()
+		}
+	}
+}
+
+// pin reloads the spilled chunks which are modified by the transaction or which contain a
+// row with an expected version or a conditional update, and keeps them in memory until the
+// transaction is reset.
+func (txn *Txn) pin() error {
+	if txn.owner.tier == nil {
+		return nil
+	}
+
+	txn.pinned.Or(txn.dirty)
+	for _, e := range txn.expect {
+		txn.pinned.Set(uint32(commit.ChunkAt(e.index)))
+	}
+	for _, u := range txn.deferred {
+		txn.pinned.Set(uint32(commit.ChunkAt(u.index)))
+	}
+
+	if err := txn.owner.pinChunks(txn.pinned); err != nil {
+		txn.pinned.Clear()
+		return err
 	}
+	return nil
+}
+
+// apply applies the pending updates of a prepared transaction to the collection.
+func (txn *Txn) apply() {
 
 	// Grow the size of the fill list
+	txn.touched = txn.dirty.Count()
 	markers, changedRows := txn.findMarkers()
 	if last, ok := txn.dirty.Max(); ok {
 		txn.commitCapacity(commit.Chunk(last))
@@ -527,6 +1443,7 @@ func (txn *Txn) commit() {
 
 	// Commit chunk by chunk to reduce lock contentions
 	txn.rangeWrite(func(commitID uint64, chunk commit.Chunk, fill bitmap.Bitmap) {
+		txn.commitID = commitID
 		if changedRows {
 			txn.commitMarkers(chunk, fill, markers)
 		}
@@ -537,25 +1454,89 @@ func (txn *Txn) commit() {
 			return
 		}
 
+		// Refresh the materialized views for this chunk
+		txn.owner.cols.Range(func(column *column) {
+			txn.owner.refreshView(column, chunk)
+		})
+
+		// Invalidate the cached rows of this chunk
+		if txn.owner.hot != nil {
+			txn.owner.hot.invalidate(chunk)
+		}
+
+		// Record the write statistics
+		if txn.owner.stats != nil {
+			txn.commitStats(chunk)
+		}
+
+		// Count the inserted, updated and deleted rows
+		if txn.owner.opts.Metrics != nil {
+			txn.commitMetrics(chunk, markers)
+		}
+
+		// Capture the row-level changes for the subscribers
+		if txn.owner.hasSubscribers() {
+			txn.commitEvents(chunk, markers)
+		}
+
 		// If there is a pending snapshot, append commit into a temp log
-		if dst, ok := txn.owner.isSnapshotting(); ok {
-			dst.Append(commit.Commit{
-				ID:      commitID,
-				Chunk:   chunk,
-				Updates: txn.updates,
-			})
+		dst, snapshotting := txn.owner.isSnapshotting()
+		if !snapshotting && txn.logger == nil {
+			return
 		}
 
+		updates, release := txn.encodeStaged(chunk)
+		defer release()
+		change := commit.Commit{
+			ID:      commitID,
+			Prev:    txn.owner.chain(chunk, commitID),
+			Source:  txn.owner.source,
+			Chunk:   chunk,
+			Updates: updates,
+		}
+
+		if snapshotting {
+			dst.Append(change)
+		}
 		if txn.logger != nil {
-			txn.logger.Append(commit.Commit{
-				ID:      commitID,
-				Chunk:   chunk,
-				Updates: txn.updates,
-			})
+			txn.logger.Append(change)
 		}
 	})
 }
 
+// chain records the commit as the last one written into the commit log for the chunk,
+// and returns the previous one so that the consumers of the log can detect the gaps.
+func (c *Collection) chain(chunk commit.Chunk, commitID uint64) (prev uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for len(c.logged) <= int(chunk) {
+		c.logged = append(c.logged, 0)
+	}
+
+	prev = c.logged[chunk]
+	c.logged[chunk] = commitID
+	return
+}
+
+// finish releases the locks held by a prepared transaction and delivers the changes.
+func (txn *Txn) finish() {
+	if txn.locked {
+		txn.unlockExpected()
+	}
+
+	if txn.owner.opts.Metrics != nil {
+		txn.commitSize()
+	}
+
+	// Deliver the captured changes, now that the chunks are unlocked
+	txn.publish()
+
+	// Report the modified chunks, for the same reason
+	if !txn.owner.hooks.isEmpty() {
+		txn.owner.hooks.notifyDirty(txn.dirty)
+	}
+}
+
 // commitUpdates applies the pending updates to the collection.
 func (txn *Txn) commitUpdates(chunk commit.Chunk) (updated bool) {
 	for _, u := range txn.updates {
@@ -569,6 +1550,15 @@ func (txn *Txn) commitUpdates(chunk commit.Chunk) (updated bool) {
 			continue
 		}
 
+		// Capture the previous values for the audit triggers
+		for _, v := range columns[1:] {
+			if a, ok := v.Column.(auditor); ok {
+				txn.reader.Range(u, chunk, func(r *commit.Reader) {
+					a.capture(columns[0], r)
+				})
+			}
+		}
+
 		// Apply the updates on the column itself first. This may result in a modified
 		// buffer caused by merge updates, so we need to range our indexes separately.
 		updated = true
@@ -580,7 +1570,7 @@ func (txn *Txn) commitUpdates(chunk commit.Chunk) (updated bool) {
 		if len(columns) > 1 {
 			txn.reader.Range(u, chunk, func(r *commit.Reader) {
 				for _, v := range columns[1:] {
-					v.Apply(chunk, r)
+					v.ApplyContext(txn.Context(), chunk, r)
 				}
 			})
 		}
@@ -596,6 +1586,9 @@ func (txn *Txn) commitMarkers(chunk commit.Chunk, fill bitmap.Bitmap, buffer *co
 			switch r.Type {
 			case commit.Insert:
 				txn.owner.fill.Set(r.Index())
+				if txn.owner.evictor != nil {
+					atomic.StoreInt32(&txn.owner.evictor.pending, 1)
+				}
 			case commit.Delete:
 				txn.owner.fill.Remove(r.Index())
 			}
@@ -603,11 +1596,27 @@ func (txn *Txn) commitMarkers(chunk commit.Chunk, fill bitmap.Bitmap, buffer *co
 		txn.owner.lock.Unlock()
 	})
 
+	// Notify the hooks observing the inserted and deleted rows
+	if !txn.owner.hooks.isEmpty() {
+		txn.reader.Range(buffer, chunk, txn.owner.hooks.notify)
+	}
+
+	// Capture the values of the deleted rows for the audit triggers
+	txn.owner.cols.Range(func(column *column) {
+		if a, ok := column.Column.(auditor); ok {
+			if source, ok := txn.owner.cols.Load(a.Column()); ok {
+				txn.reader.Range(buffer, chunk, func(r *commit.Reader) {
+					a.capture(source, r)
+				})
+			}
+		}
+	})
+
 	// We also need to apply the delete operations on the column so it
 	// can remove unnecessary data.
 	txn.reader.Range(buffer, chunk, func(r *commit.Reader) {
 		txn.owner.cols.Range(func(column *column) {
-			column.Apply(chunk, r)
+			column.ApplyContext(txn.Context(), chunk, r)
 		})
 	})
 
@@ -618,23 +1627,7 @@ func (txn *Txn) commitMarkers(chunk commit.Chunk, fill bitmap.Bitmap, buffer *co
 
 // commitCapacity grows all columns until they reach the max index
 func (txn *Txn) commitCapacity(last commit.Chunk) {
-	txn.owner.lock.Lock()
-	defer txn.owner.lock.Unlock()
-	if len(txn.owner.commits) >= int(last+1) {
-		return
-	}
-
-	// Grow the commits array
-	for len(txn.owner.commits) < int(last+1) {
-		txn.owner.commits = append(txn.owner.commits, 0)
-	}
-
-	// Grow the fill list and all of the owner's columns
-	max := last.Max()
-	txn.owner.fill.Grow(max)
-	txn.owner.cols.Range(func(column *column) {
-		column.Grow(max)
-	})
+	txn.owner.grow(last)
 }
 
 // --------------------------- Buffer Lookups ----------------------------
@@ -0,0 +1,139 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math/bits"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+	"github.com/kelindar/simd"
+)
+
+// summable represents a numeric column which is able to count and sum its values matching
+// a predicate, in a single pass over a chunk.
+type summable interface {
+	sumFloat64(commit.Chunk, bitmap.Bitmap, func(v float64) bool) (int, float64)
+	sumInt64(commit.Chunk, bitmap.Bitmap, func(v int64) bool) (int, int64)
+	sumUint64(commit.Chunk, bitmap.Bitmap, func(v uint64) bool) (int, uint64)
+}
+
+// CountWhereFloat counts the selected rows whose value matches the predicate, without
+// filtering down the transaction. The column must be numerical and convertible to float64.
+func (txn *Txn) CountWhereFloat(column string, predicate func(v float64) bool) int {
+	count, _ := sumWhere(txn, column, func(c summable, chunk commit.Chunk, index bitmap.Bitmap) (int, float64) {
+		return c.sumFloat64(chunk, index, predicate)
+	})
+	return count
+}
+
+// CountWhereInt counts the selected rows whose value matches the predicate, without
+// filtering down the transaction. The column must be numerical and convertible to int64.
+func (txn *Txn) CountWhereInt(column string, predicate func(v int64) bool) int {
+	count, _ := sumWhere(txn, column, func(c summable, chunk commit.Chunk, index bitmap.Bitmap) (int, int64) {
+		return c.sumInt64(chunk, index, predicate)
+	})
+	return count
+}
+
+// CountWhereUint counts the selected rows whose value matches the predicate, without
+// filtering down the transaction. The column must be numerical and convertible to uint64.
+func (txn *Txn) CountWhereUint(column string, predicate func(v uint64) bool) int {
+	count, _ := sumWhere(txn, column, func(c summable, chunk commit.Chunk, index bitmap.Bitmap) (int, uint64) {
+		return c.sumUint64(chunk, index, predicate)
+	})
+	return count
+}
+
+// SumWhereFloat sums the values of the selected rows which match the predicate, without
+// filtering down the transaction. The column must be numerical and convertible to float64.
+func (txn *Txn) SumWhereFloat(column string, predicate func(v float64) bool) float64 {
+	_, sum := sumWhere(txn, column, func(c summable, chunk commit.Chunk, index bitmap.Bitmap) (int, float64) {
+		return c.sumFloat64(chunk, index, predicate)
+	})
+	return sum
+}
+
+// SumWhereInt sums the values of the selected rows which match the predicate, without
+// filtering down the transaction. The column must be numerical and convertible to int64.
+func (txn *Txn) SumWhereInt(column string, predicate func(v int64) bool) int64 {
+	_, sum := sumWhere(txn, column, func(c summable, chunk commit.Chunk, index bitmap.Bitmap) (int, int64) {
+		return c.sumInt64(chunk, index, predicate)
+	})
+	return sum
+}
+
+// SumWhereUint sums the values of the selected rows which match the predicate, without
+// filtering down the transaction. The column must be numerical and convertible to uint64.
+func (txn *Txn) SumWhereUint(column string, predicate func(v uint64) bool) uint64 {
+	_, sum := sumWhere(txn, column, func(c summable, chunk commit.Chunk, index bitmap.Bitmap) (int, uint64) {
+		return c.sumUint64(chunk, index, predicate)
+	})
+	return sum
+}
+
+// sumWhere counts and sums the matching values of a column, chunk by chunk
+func sumWhere[C simd.Number](txn *Txn, column string, fn func(summable, commit.Chunk, bitmap.Bitmap) (int, C)) (count int, sum C) {
+	defer txn.trace("SumWhere", column)()
+	txn.initialize()
+	c, ok := txn.filterAt(column)
+	if !ok {
+		return
+	}
+
+	source, ok := c.Column.(summable)
+	if !ok {
+		return
+	}
+
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		n, v := fn(source, chunk, index)
+		count += n
+		sum += v
+	})
+	return
+}
+
+// sumNumbers counts and sums the values of a chunk which are selected by the index and
+// match the predicate. Unlike the filters, the index is left unchanged.
+func sumNumbers[T, C simd.Number](column *numericColumn[T], chunk commit.Chunk, index bitmap.Bitmap, predicate func(C) bool) (count int, sum C) {
+	if int(chunk) >= len(column.chunks) {
+		return
+	}
+
+	fill, data := column.chunkAt(chunk)
+	for i, word := range index {
+		if i >= len(fill) {
+			break
+		}
+
+		for word &= fill[i]; word != 0; word &= word - 1 {
+			x := i<<6 + bits.TrailingZeros64(word)
+			if x >= len(data) {
+				break
+			}
+
+			if v := C(data[x]); predicate(v) {
+				count++
+				sum += v
+			}
+		}
+	}
+	return
+}
+
+// sumFloat64 counts and sums the values matching the predicate
+func (c *numericColumn[T]) sumFloat64(chunk commit.Chunk, index bitmap.Bitmap, predicate func(float64) bool) (int, float64) {
+	return sumNumbers(c, chunk, index, predicate)
+}
+
+// sumInt64 counts and sums the values matching the predicate
+func (c *numericColumn[T]) sumInt64(chunk commit.Chunk, index bitmap.Bitmap, predicate func(int64) bool) (int, int64) {
+	return sumNumbers(c, chunk, index, predicate)
+}
+
+// sumUint64 counts and sums the values matching the predicate
+func (c *numericColumn[T]) sumUint64(chunk commit.Chunk, index bitmap.Bitmap, predicate func(uint64) bool) (int, uint64) {
+	return sumNumbers(c, chunk, index, predicate)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kelindar/bitmap"
+)
+
+// commitBatch represents a group of transactions which are committed together, so that
+// many small commits acquire the locks of their chunks only once.
+type commitBatch struct {
+	lock   sync.Mutex    // The mutex protecting the queue
+	window time.Duration // The duration to wait for other commits to join the batch
+	queue  []*Txn        // The transactions waiting to be committed
+	done   chan struct{} // The channel closed once the queued transactions are applied
+}
+
+// commit adds the prepared transaction to the current batch and waits until it is applied.
+// The first transaction of a batch becomes its leader: it waits for the duration of the
+// window, then applies all of the queued transactions under a single set of locks.
+func (b *commitBatch) commit(txn *Txn) {
+	b.lock.Lock()
+	leader := len(b.queue) == 0
+	if leader {
+		b.done = make(chan struct{})
+	}
+
+	b.queue = append(b.queue, txn)
+	done := b.done
+	b.lock.Unlock()
+
+	// Wait for the leader to apply the batch
+	if !leader {
+		<-done
+		txn.finish()
+		return
+	}
+
+	time.Sleep(b.window)
+	b.lock.Lock()
+	queue := b.queue
+	b.queue = nil
+	b.lock.Unlock()
+
+	// Lock the shards modified by any of the transactions, in ascending order
+	var locked bitmap.Bitmap
+	for _, t := range queue {
+		t.dirty.Range(func(chunk uint32) {
+			locked.Set(chunk % shards)
+		})
+	}
+
+	locked.Range(func(shard uint32) {
+		txn.owner.slock.Lock(uint(shard))
+	})
+
+	// Apply the transactions in the order they were committed
+	for _, t := range queue {
+		t.locked = true
+		t.apply()
+		t.locked = false
+	}
+
+	locked.Range(func(shard uint32) {
+		txn.owner.slock.Unlock(uint(shard))
+	})
+
+	close(done)
+	txn.finish()
+}
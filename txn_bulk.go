@@ -0,0 +1,221 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Loader represents a write-optimized loader which is used to stage a large number of
+// rows into the collection. See Collection.BulkLoad for more details.
+type Loader struct {
+	txn    *Txn                // The underlying transaction, used for its buffers
+	loaded bitmap.Bitmap       // The chunks that were loaded, kept in memory until rebuilt
+	staged int                 // The number of rows staged but not yet flushed
+	rows   bitmap.Bitmap       // The rows staged but not yet flushed
+	keys   map[string]struct{} // The primary keys inserted by the loader
+	marks  []commit.Mark       // The position in the buffers before the current insert
+}
+
+// BulkLoad executes a write-optimized load of a large number of rows. Rather than going
+// through the regular commit path, rows are staged and written directly into the chunk
+// storage of each column, bypassing the indexes, triggers, the commit logger and the
+// snapshot recorder. Once the load is done, the indexes and the materialized views are
+// rebuilt for all of the loaded chunks and the fill counts are updated.
+//
+// Since nothing is logged, this is meant for initial loads and a snapshot should be taken
+// afterwards if durability is required. The load is not atomic: if fn returns an error,
+// the rows loaded until that point are kept and the error is returned. If a spilled chunk
+// can not be reloaded, the rows staged since the last flush are discarded instead.
+func (c *Collection) BulkLoad(fn func(loader *Loader) error) error {
+	if err := c.beginWrite(); err != nil {
+		return err
+	}
+
+	defer c.endWrite()
+	txn := c.txns.acquire(c)
+	loader := &Loader{
+		txn:  txn,
+		keys: make(map[string]struct{}),
+	}
+	defer c.txns.release(txn)
+
+	err := fn(loader)
+	if e := loader.flush(); err == nil {
+		err = e
+	}
+
+	loader.rebuild()
+	return err
+}
+
+// Insert stages a new row at a new offset.
+func (l *Loader) Insert(fn func(Row) error) (uint32, error) {
+	if l.txn.owner.pk != nil {
+		return 0, errUnkeyedInsert
+	}
+
+	return l.insert(fn)
+}
+
+// InsertKey stages a new row given its corresponding primary key.
+func (l *Loader) InsertKey(key string, fn func(Row) error) (uint32, error) {
+	if l.txn.owner.pk == nil {
+		return 0, errNoKey
+	}
+
+	if _, ok := l.keys[key]; ok {
+		return 0, fmt.Errorf("column: unable to set duplicate key '%s'", key)
+	}
+
+	if idx, ok := l.txn.owner.pk.OffsetOf(key); ok {
+		return 0, fmt.Errorf("column: key '%s' already exists at offset %d", key, idx)
+	}
+
+	idx, err := l.insert(func(r Row) error {
+		if err := r.txn.Key().Set(key); err != nil {
+			return err
+		}
+		return fn(r)
+	})
+	if err == nil {
+		l.keys[key] = struct{}{}
+	}
+	return idx, err
+}
+
+// InsertObject stages an object at a new offset.
+func (l *Loader) InsertObject(obj map[string]any) (uint32, error) {
+	if err := l.txn.validate(obj); err != nil {
+		return 0, err
+	}
+
+	return l.Insert(func(r Row) error {
+		return r.SetMany(obj)
+	})
+}
+
+// insert stages a new row and flushes the staged rows if necessary
+func (l *Loader) insert(fn func(Row) error) (uint32, error) {
+	idx := l.txn.owner.next()
+	l.txn.cursor = idx
+	l.marks = l.txn.marks(l.marks[:0])
+
+	// If there was an error, discard the values which might have been written. This can
+	// not be done with deletions, since a deletion in the buffer of a boolean column is a
+	// false value.
+	if err := fn(Row{l.txn}); err != nil {
+		l.txn.truncate(l.marks)
+		l.txn.owner.free(idx)
+		return idx, err
+	}
+
+	l.rows.Set(idx)
+	if l.staged++; l.staged >= chunkSize {
+		return idx, l.flush()
+	}
+	return idx, nil
+}
+
+// flush writes the staged rows directly into the columns, skipping their indexes. The
+// chunks are reloaded and kept in memory until they are rebuilt. If a spilled chunk can
+// not be reloaded, the staged rows are discarded and the error is returned.
+func (l *Loader) flush() error {
+	txn := l.txn
+	defer txn.reset()
+	defer l.rows.Clear()
+	l.staged = 0
+
+	var chunks bitmap.Bitmap
+	for _, u := range txn.updates {
+		u.RangeChunks(func(chunk commit.Chunk) {
+			txn.dirty.Set(uint32(chunk))
+			if !l.loaded.Contains(uint32(chunk)) {
+				chunks.Set(uint32(chunk))
+			}
+		})
+	}
+
+	// Forget the keys as well, since the keys of the rows flushed earlier are already
+	// found in the primary key
+	if err := txn.owner.pinChunks(chunks); err != nil {
+		l.rows.Range(txn.owner.free)
+		for key := range l.keys {
+			delete(l.keys, key)
+		}
+		return err
+	}
+
+	// Grow the columns so they can accomodate the staged rows
+	if last, ok := txn.dirty.Max(); ok {
+		txn.commitCapacity(commit.Chunk(last))
+	}
+
+	owner := txn.owner
+	txn.dirty.Range(func(x uint32) {
+		chunk := commit.Chunk(x)
+		owner.slock.Lock(uint(chunk))
+		for _, u := range txn.updates {
+			if column, ok := owner.cols.Load(u.Column); ok && !u.IsEmpty() {
+				txn.reader.Range(u, chunk, func(r *commit.Reader) {
+					column.Apply(chunk, r)
+				})
+			}
+		}
+
+		owner.lock.Lock()
+		owner.commits[chunk] = commit.Next()
+		owner.lock.Unlock()
+		owner.slock.Unlock(uint(chunk))
+	})
+
+	l.loaded.Or(txn.dirty)
+	return nil
+}
+
+// rebuild rebuilds the indexes and the views for all of the loaded chunks, releases them
+// so that they can be spilled again and updates the count.
+func (l *Loader) rebuild() {
+	owner := l.txn.owner
+	defer owner.unpinChunks(l.loaded)
+	buffer := commit.NewBuffer(chunkSize)
+	reader := commit.NewReader()
+
+	l.loaded.Range(func(x uint32) {
+		chunk := commit.Chunk(x)
+		owner.slock.Lock(uint(chunk))
+		owner.cols.Range(func(column *column) {
+			columns, _ := owner.cols.LoadWithIndex(column.name)
+			if len(columns) <= 1 || !column.Snapshot(chunk, buffer) {
+				return
+			}
+
+			for _, index := range columns[1:] {
+				if _, ok := index.Column.(*columnTrigger); !ok {
+					reader.Seek(buffer)
+					index.Apply(chunk, reader)
+				}
+			}
+		})
+
+		// Refresh the materialized views, now that the indexes of the chunk are rebuilt
+		owner.cols.Range(func(column *column) {
+			owner.refreshView(column, chunk)
+		})
+
+		// Invalidate the cached rows of this chunk
+		if owner.hot != nil {
+			owner.hot.invalidate(chunk)
+		}
+		owner.slock.Unlock(uint(chunk))
+	})
+
+	owner.lock.Lock()
+	atomic.StoreUint64(&owner.count, uint64(owner.fill.Count()))
+	owner.lock.Unlock()
+}
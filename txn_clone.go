@@ -0,0 +1,85 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+)
+
+// CloneRow copies all of the values of a row into a new row, and returns the index of the
+// new row. The primary key and the version of the row are not copied.
+func (txn *Txn) CloneRow(index uint32) (uint32, error) {
+	values, err := txn.valuesAt(index)
+	if err != nil {
+		return 0, err
+	}
+
+	return txn.Insert(func(r Row) error {
+		return r.SetMany(values)
+	})
+}
+
+// CloneKey copies all of the values of a row with the specified primary key into a new row
+// with the destination key. The destination key must not exist yet.
+func (txn *Txn) CloneKey(srcKey, dstKey string) error {
+	if txn.owner.pk == nil {
+		return errNoKey
+	}
+
+	index, ok := txn.owner.pk.OffsetOf(srcKey)
+	if !ok {
+		return fmt.Errorf("column: key '%s' was not found", srcKey)
+	}
+
+	if _, exists := txn.owner.pk.OffsetOf(dstKey); exists {
+		return fmt.Errorf("column: key '%s' already exists", dstKey)
+	}
+
+	values, err := txn.valuesAt(index)
+	if err != nil {
+		return err
+	}
+
+	return txn.InsertKey(dstKey, func(r Row) error {
+		return r.SetMany(values)
+	})
+}
+
+// CloneKey copies all of the values of a row with the specified primary key into a new
+// row with the destination key. The destination key must not exist yet.
+func (c *Collection) CloneKey(srcKey, dstKey string) error {
+	return c.Query(func(txn *Txn) error {
+		return txn.CloneKey(srcKey, dstKey)
+	})
+}
+
+// valuesAt reads all of the values of a row which can be copied into another row
+func (txn *Txn) valuesAt(index uint32) (map[string]any, error) {
+	txn.owner.lock.RLock()
+	exists := txn.owner.fill.Contains(index)
+	txn.owner.lock.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("column: row %d does not exist", index)
+	}
+
+	values := make(map[string]any, 8)
+	txn.QueryAt(index, func(r Row) error {
+		txn.owner.cols.Range(func(column *column) {
+			switch {
+			case isComputed(column):
+				return
+			case column.name == versionColumn || column.name == generationColumn:
+				return
+			case column.name == txn.owner.pkName:
+				return
+			}
+
+			if v, ok := column.Value(index); ok {
+				values[column.name] = v
+			}
+		})
+		return nil
+	})
+	return values, nil
+}
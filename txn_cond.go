@@ -0,0 +1,68 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"github.com/kelindar/column/commit"
+)
+
+// deferredUpdate represents a conditional update of a row, applied when committing
+type deferredUpdate struct {
+	index uint32                  // The index of the row to update
+	fn    func(Row) (bool, error) // The function updating the row
+}
+
+// UpdateAtIf updates a row conditionally. Unlike QueryAt, the function is not executed
+// immediately but when the transaction is committed, while the chunk of the row is locked
+// for writing, so the values it reads can not be modified concurrently until the updates
+// are applied. If the function returns false, the updates it made are discarded and the
+// rest of the transaction is committed. If it returns an error, the whole transaction is
+// rolled back and the error is returned by the query. The function must only update the
+// row at the specified index.
+func (txn *Txn) UpdateAtIf(index uint32, fn func(Row) (bool, error)) {
+	txn.deferred = append(txn.deferred, deferredUpdate{
+		index: index,
+		fn:    fn,
+	})
+}
+
+// conditional checks whether the transaction needs to check the current state of the
+// rows while their chunks are locked for writing.
+func (txn *Txn) conditional() bool {
+	return len(txn.expect) > 0 || len(txn.deferred) > 0 || txn.validated()
+}
+
+// commitDeferred executes the conditional updates, while the chunks are locked.
+func (txn *Txn) commitDeferred() error {
+	for _, u := range txn.deferred {
+		txn.cursor = u.index
+		savepoint := txn.Savepoint()
+		ok, err := u.fn(Row{txn})
+		switch {
+		case err != nil:
+			return err
+		case !ok:
+			txn.RollbackTo(savepoint)
+		default:
+			txn.dirty.Set(uint32(commit.ChunkAt(u.index)))
+		}
+	}
+	return nil
+}
+
+// SetIf stores a numeric value at a particular column of the row, only if the current
+// value satisfies the condition at the time the transaction is committed. If the row
+// does not have a value, the condition receives a zero value. See UpdateAtIf for more
+// details.
+func SetIf[T Number](r Row, columnName string, value T, condition func(current T) bool) {
+	r.txn.UpdateAtIf(r.Index(), func(r Row) (bool, error) {
+		current, _ := readNumber[T](r.txn, columnName)
+		if !condition(current) {
+			return false, nil
+		}
+
+		NumberOf[T](r.txn, columnName).Set(value)
+		return true, nil
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Copy copies the values of the source column into the destination column, for every row
+// selected by the transaction which has a value in the source column. An optional transform
+// function converts each value before it is written, and the rows for which it returns nil
+// are left unchanged. The values are read chunk by chunk and written on commit, and if a
+// value is not accepted by the destination column an error is returned, so that returning
+// it from the query rolls back the copy.
+func (txn *Txn) Copy(srcColumn, dstColumn string, transform ...func(v any) any) error {
+	defer txn.trace("Copy", srcColumn, dstColumn)()
+	source, ok := txn.columnAt(srcColumn)
+	if !ok {
+		return fmt.Errorf("column: unable to copy, column '%s' does not exist", srcColumn)
+	}
+
+	target, ok := txn.columnAt(dstColumn)
+	switch {
+	case !ok:
+		return fmt.Errorf("column: unable to copy, column '%s' does not exist", dstColumn)
+	case isComputed(target):
+		return fmt.Errorf("column: unable to copy into '%s', column is computed", dstColumn)
+	case txn.owner.isReserved(dstColumn):
+		return fmt.Errorf("column: unable to copy into '%s', column is reserved", dstColumn)
+	}
+
+	var err error
+	txn.initialize()
+	buffer := txn.bufferFor(dstColumn)
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		offset := chunk.Min()
+		index.Range(func(x uint32) {
+			if err == nil {
+				err = copyAt(source, target, buffer, offset+x, transform)
+			}
+		})
+	})
+	return err
+}
+
+// copyAt copies the value of a row and writes it into the buffer of the target column
+func copyAt(source, target *column, buffer *commit.Buffer, idx uint32, transform []func(any) any) error {
+	value, ok := source.Value(idx)
+	if !ok {
+		return nil
+	}
+
+	for _, fn := range transform {
+		if value = fn(value); value == nil {
+			return nil
+		}
+	}
+
+	if !target.Accepts(value) {
+		return fmt.Errorf("column: unable to copy into '%s', value %v of row %d is not accepted by %s",
+			target.name, value, idx, typeName(target.Column))
+	}
+
+	encoded, err := encodeValue(target, value)
+	if err != nil {
+		return err
+	}
+
+	return buffer.PutAny(commit.Put, idx, encoded)
+}
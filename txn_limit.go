@@ -0,0 +1,77 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kelindar/column/commit"
+)
+
+// ErrScanLimit is returned when a transaction scans more rows than allowed.
+var ErrScanLimit = errors.New("column: transaction exceeded the maximum number of scanned rows")
+
+// QueryOption represents an option of a query, such as its limits.
+type QueryOption func(*Txn)
+
+// WithTimeout aborts the transaction if it takes longer than the specified duration. The
+// filters and iterations stop matching rows once the deadline is exceeded, and the query
+// is rolled back and returns context.DeadlineExceeded.
+func WithTimeout(timeout time.Duration) QueryOption {
+	return func(txn *Txn) {
+		txn.ctx, txn.cancel = context.WithTimeout(txn.Context(), timeout)
+		txn.limited = true
+	}
+}
+
+// WithMaxScanned aborts the transaction if its filters and iterations scan more than the
+// specified number of rows. The query is rolled back and returns ErrScanLimit.
+func WithMaxScanned(rows int) QueryOption {
+	return func(txn *Txn) {
+		txn.maxScan = rows
+		txn.limited = true
+	}
+}
+
+// admit checks whether the chunk can be scanned within the limits of the transaction,
+// and aborts the transaction otherwise.
+func (txn *Txn) admit(chunk commit.Chunk) bool {
+	if err := txn.expired(); err != nil {
+		txn.abort(err)
+		return false
+	}
+
+	if txn.maxScan > 0 {
+		txn.scanned += chunk.OfBitmap(txn.index).Count()
+		if txn.scanned > txn.maxScan {
+			txn.abort(ErrScanLimit)
+			return false
+		}
+	}
+	return true
+}
+
+// expired returns the error of the context of the transaction. Since the context is only
+// cancelled once its timer has fired, the deadline is also compared with the current time
+// so that the transaction can not run past it.
+func (txn *Txn) expired() error {
+	if err := txn.ctx.Err(); err != nil {
+		return err
+	}
+
+	if deadline, ok := txn.ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// abort records the error and clears the index, so that nothing is matched anymore
+func (txn *Txn) abort(err error) {
+	if txn.err == nil {
+		txn.err = err
+	}
+	txn.index.Clear()
+}
@@ -6,6 +6,7 @@ package column
 import (
 	"github.com/kelindar/bitmap"
 	"github.com/kelindar/column/commit"
+	"github.com/kelindar/smutex"
 )
 
 const (
@@ -13,6 +14,7 @@ const (
 	bitmapSize  = 1 << bitmapShift
 	chunkShift  = 14 // 16K
 	chunkSize   = 1 << chunkShift
+	pageSize    = 4096 // The memory page size, used for prefetching
 )
 
 // initialize ensures that the transaction is pre-initialized with the snapshot
@@ -38,9 +40,13 @@ func (txn *Txn) QueryAt(index uint32, f func(Row) error) (err error) {
 	txn.cursor = index
 
 	chunk := commit.ChunkAt(index)
-	lock.RLock(uint(chunk))
+	if txn.owner.evictor != nil {
+		txn.owner.evictor.touch(index)
+	}
+
+	txn.readLock(lock, chunk)
 	err = f(Row{txn})
-	lock.RUnlock(uint(chunk))
+	txn.readUnlock(lock, chunk)
 	return err
 }
 
@@ -52,10 +58,14 @@ func (txn *Txn) rangeRead(f func(chunk commit.Chunk, index bitmap.Bitmap)) {
 	limit := commit.Chunk(len(txn.index) >> bitmapShift)
 	lock := txn.owner.slock
 
-	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
-		lock.RLock(uint(chunk))
+	for chunk := txn.from; chunk <= limit; chunk++ {
+		if txn.limited && !txn.admit(chunk) {
+			return
+		}
+
+		txn.readLock(lock, chunk)
 		f(chunk, chunk.OfBitmap(txn.index))
-		lock.RUnlock(uint(chunk))
+		txn.readUnlock(lock, chunk)
 	}
 }
 
@@ -66,9 +76,32 @@ func (txn *Txn) rangeReadPair(column *column, f func(a, b bitmap.Bitmap)) {
 	lock := txn.owner.slock
 
 	// Iterate through all of the chunks and acquire appropriate shard locks.
-	for chunk := commit.Chunk(0); chunk <= limit; chunk++ {
-		lock.RLock(uint(chunk))
+	for chunk := txn.from; chunk <= limit; chunk++ {
+		if txn.limited && !txn.admit(chunk) {
+			return
+		}
+
+		txn.readLock(lock, chunk)
 		f(chunk.OfBitmap(txn.index), column.Index(chunk))
+		txn.readUnlock(lock, chunk)
+	}
+}
+
+// readLock acquires a read lock for a chunk, unless the transaction is consistent
+// in which case all of the shards are already read-locked, or the collection is frozen
+// in which case the chunks can no longer be modified.
+func (txn *Txn) readLock(lock *smutex.SMutex128, chunk commit.Chunk) {
+	if !txn.consistent && !txn.frozen {
+		if err := txn.owner.readLockChunk(chunk); err != nil {
+			txn.abort(err)
+		}
+	}
+}
+
+// readUnlock releases a read lock for a chunk, unless the transaction is consistent or
+// the collection is frozen.
+func (txn *Txn) readUnlock(lock *smutex.SMutex128, chunk commit.Chunk) {
+	if !txn.consistent && !txn.frozen {
 		lock.RUnlock(uint(chunk))
 	}
 }
@@ -79,8 +112,13 @@ func (txn *Txn) rangeWrite(fn func(commitID uint64, chunk commit.Chunk, fill bit
 	lock := txn.owner.slock
 	txn.dirty.Range(func(x uint32) {
 		chunk := commit.Chunk(x)
+		if !txn.locked {
+			lock.Lock(uint(chunk))
+		}
+
+		// The commit ID is taken while holding the lock, so a commit with a smaller ID
+		// is always visible to a reader which acquires the lock after it.
 		commitID := commit.Next()
-		lock.Lock(uint(chunk))
 
 		// Compute the fill and set the last commit ID
 		txn.owner.lock.RLock()
@@ -90,6 +128,69 @@ func (txn *Txn) rangeWrite(fn func(commitID uint64, chunk commit.Chunk, fill bit
 
 		// Call the delegate
 		fn(commitID, chunk, fill)
-		lock.Unlock(uint(chunk))
+		if !txn.locked {
+			lock.Unlock(uint(chunk))
+		}
 	})
 }
+
+// --------------------------- Consistent View ---------------------------
+
+// shards is the number of shards of the collection's sharded mutex
+const shards = 128
+
+// readLockAll acquires read locks on every shard, preventing any commit from being
+// applied until readUnlockAll is called. The spilled chunks are reloaded first, and
+// again if some were spilled before the locks were acquired. The locks are acquired
+// even if a chunk can not be reloaded, in which case the error is returned.
+func (c *Collection) readLockAll() error {
+	for {
+		err := c.reloadAll()
+		for shard := uint(0); shard < shards; shard++ {
+			c.slock.RLock(shard)
+		}
+
+		if err != nil || c.tier == nil || len(c.tier.spilled()) == 0 {
+			return err
+		}
+
+		c.readUnlockAll()
+	}
+}
+
+// writeLockAll acquires the write locks of all of the shards, in ascending order. The
+// spilled chunks are reloaded once the locks are acquired. The locks are acquired even if
+// a chunk can not be reloaded, in which case the chunk remains spilled and the error is
+// returned.
+func (c *Collection) writeLockAll() (err error) {
+	for shard := uint(0); shard < shards; shard++ {
+		c.slock.Lock(shard)
+	}
+
+	if c.tier == nil {
+		return nil
+	}
+
+	for _, chunk := range c.tier.spilled() {
+		if e := c.loadChunk(chunk); err == nil {
+			err = e
+		}
+	}
+
+	c.tier.report(err)
+	return
+}
+
+// writeUnlockAll releases the write locks acquired by writeLockAll.
+func (c *Collection) writeUnlockAll() {
+	for shard := uint(0); shard < shards; shard++ {
+		c.slock.Unlock(shard)
+	}
+}
+
+// readUnlockAll releases the read locks acquired by readLockAll.
+func (c *Collection) readUnlockAll() {
+	for shard := uint(0); shard < shards; shard++ {
+		c.slock.RUnlock(shard)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// Order represents the order in which RangeStable iterates over the result set.
+type Order uint8
+
+// Various stable iteration orders
+const (
+	OrderByIndex Order = iota // Ascending order of the indexes of the rows
+	OrderByKey                // Ascending order of the primary keys of the rows
+)
+
+// RangeStable iterates over the result set in a stable order, which only depends on the
+// rows selected and not on the layout of the collection. Ordered by index, the rows are
+// visited from the lowest index to the highest one. Note that the indexes of the deleted
+// rows are reused by the subsequent inserts, so this is not the order of insertion. Ordered
+// by primary key, the keys of the result set are loaded and sorted before the iteration,
+// which requires the collection to have a primary key column.
+func (txn *Txn) RangeStable(order Order, fn func(idx uint32)) error {
+	switch order {
+	case OrderByIndex:
+		return txn.Range(fn)
+	case OrderByKey:
+		return txn.rangeByKey(fn)
+	default:
+		return fmt.Errorf("column: unable to range, unsupported order %d", order)
+	}
+}
+
+// rangeByKey iterates over the result set in the ascending order of the primary keys
+func (txn *Txn) rangeByKey(fn func(idx uint32)) error {
+	if txn.owner.pk == nil {
+		return errNoKey
+	}
+
+	type entry struct {
+		key string
+		idx uint32
+	}
+
+	txn.initialize()
+	entries := make([]entry, 0, txn.index.Count())
+	txn.rangeRead(func(chunk commit.Chunk, index bitmap.Bitmap) {
+		offset := chunk.Min()
+		index.Range(func(x uint32) {
+			if key, ok := txn.owner.pk.LoadString(offset + x); ok {
+				entries = append(entries, entry{key: key, idx: offset + x})
+			}
+		})
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	// Visit every row while holding the read lock of its chunk, same as Range
+	lock := txn.owner.slock
+	for _, e := range entries {
+		chunk := commit.ChunkAt(e.idx)
+		txn.readLock(lock, chunk)
+		txn.cursor = e.idx
+		fn(e.idx)
+		txn.readUnlock(lock, chunk)
+	}
+	return txn.err
+}
+
+// RangeReverse iterates over the result set in the descending order of the indexes of the
+// rows, from the highest one down. When the rows are appended in time order, this visits
+// the latest rows first.
+func (txn *Txn) RangeReverse(fn func(idx uint32)) error {
+	txn.initialize()
+	lock := txn.owner.slock
+	for chunk := int(len(txn.index) >> bitmapShift); chunk >= int(txn.from); chunk-- {
+		if txn.limited && !txn.admit(commit.Chunk(chunk)) {
+			return txn.err
+		}
+
+		txn.readLock(lock, commit.Chunk(chunk))
+		offset := commit.Chunk(chunk).Min()
+		index := commit.Chunk(chunk).OfBitmap(txn.index)
+		for blkAt := len(index) - 1; blkAt >= 0; blkAt-- {
+			for blk := index[blkAt]; blk != 0; {
+				x := 63 - bits.LeadingZeros64(blk)
+				blk &^= 1 << x
+
+				txn.cursor = offset + uint32(blkAt<<6+x)
+				fn(txn.cursor)
+			}
+		}
+		txn.readUnlock(lock, commit.Chunk(chunk))
+	}
+	return txn.err
+}
+
+// RangeWindow iterates over at most count rows of the result set, in the ascending order of
+// the indexes of the rows, starting with the row at the specified position of the result
+// set. The rows preceding the window are skipped by counting the bits of the result set, so
+// the chunks before the window are neither locked nor read. For example, the latest n rows
+// of a collection whose rows are appended in time order are given by a window starting at
+// txn.Count() - n.
+func (txn *Txn) RangeWindow(start, count int, fn func(idx uint32)) error {
+	if start < 0 || count < 0 {
+		return fmt.Errorf("column: unable to range, invalid window (%d, %d)", start, count)
+	}
+
+	txn.initialize()
+	lock := txn.owner.slock
+	until := commit.Chunk(len(txn.index) >> bitmapShift)
+	for chunk := txn.from; chunk <= until && count > 0; chunk++ {
+		index := chunk.OfBitmap(txn.index)
+		if n := index.Count(); n <= start {
+			start -= n
+			continue // Skip the entire chunk
+		}
+
+		if txn.limited && !txn.admit(chunk) {
+			return txn.err
+		}
+
+		txn.readLock(lock, chunk)
+		offset := chunk.Min()
+		for blkAt := 0; blkAt < len(index) && count > 0; blkAt++ {
+			blk := index[blkAt]
+			if n := bits.OnesCount64(blk); n <= start {
+				start -= n
+				continue // Skip the entire block
+			}
+
+			for ; blk != 0 && count > 0; blk &= blk - 1 {
+				if start > 0 {
+					start--
+					continue
+				}
+
+				txn.cursor = offset + uint32(blkAt<<6+bits.TrailingZeros64(blk))
+				fn(txn.cursor)
+				count--
+			}
+		}
+		txn.readUnlock(lock, chunk)
+	}
+	return txn.err
+}
@@ -5,7 +5,9 @@ package column
 
 import (
 	"encoding"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/kelindar/column/commit"
 )
@@ -229,22 +231,185 @@ func (r Row) MergeRecord(columnName string, delta encoding.BinaryMarshaler) erro
 	return r.txn.Record(columnName).Merge(delta)
 }
 
+// --------------------------- Bytes ----------------------------
+
+// Bytes loads a binary value at a particular column
+func (r Row) Bytes(columnName string) ([]byte, bool) {
+	return rdBytes(readerFor[*columnBytes](r.txn, columnName)).Get()
+}
+
+// SetBytes stores a binary value at a particular column
+func (r Row) SetBytes(columnName string, value []byte) error {
+	return r.txn.Bytes(columnName).Set(value)
+}
+
+// --------------------------- JSON ----------------------------
+
+// JSON loads a raw JSON document at a particular column
+func (r Row) JSON(columnName string) (json.RawMessage, bool) {
+	return rdJSON(readerFor[*columnJSON](r.txn, columnName)).Get()
+}
+
+// SetJSON encodes a value as JSON and stores it at a particular column
+func (r Row) SetJSON(columnName string, value any) error {
+	return r.txn.JSON(columnName).Set(value)
+}
+
+// --------------------------- Slices ----------------------------
+
+// Slice loads a set of values at a particular column
+func (r Row) Slice(columnName string) ([]string, bool) {
+	return rdSlice(readerFor[*columnSlice](r.txn, columnName)).Get()
+}
+
+// SetSlice replaces a set of values at a particular column
+func (r Row) SetSlice(columnName string, values []string) {
+	r.txn.Slice(columnName).Set(values)
+}
+
+// AddToSet atomically adds a value to the set at a particular column
+func (r Row) AddToSet(columnName string, value string) {
+	r.txn.Slice(columnName).Add(value)
+}
+
+// RemoveFromSet atomically removes a value from the set at a particular column
+func (r Row) RemoveFromSet(columnName string, value string) {
+	r.txn.Slice(columnName).Remove(value)
+}
+
+// --------------------------- Vectors ----------------------------
+
+// Vector loads a copy of the vector at a particular column
+func (r Row) Vector(columnName string) ([]float32, bool) {
+	return rdVector(readerFor[*columnVector](r.txn, columnName)).Get()
+}
+
+// SetVector stores a vector at a particular column
+func (r Row) SetVector(columnName string, value []float32) {
+	r.txn.Vector(columnName).Set(value)
+}
+
+// --------------------------- UUID ----------------------------
+
+// UUID loads a UUID value at a particular column
+func (r Row) UUID(columnName string) ([16]byte, bool) {
+	return readUUIDOf(r.txn, columnName).Get()
+}
+
+// SetUUID stores a UUID value at a particular column
+func (r Row) SetUUID(columnName string, value [16]byte) {
+	r.txn.UUID(columnName).Set(value)
+}
+
+// --------------------------- Time ----------------------------
+
+// Time loads a time value at a particular column
+func (r Row) Time(columnName string) (time.Time, bool) {
+	return rdTime(readerFor[*columnTime](r.txn, columnName)).Get()
+}
+
+// SetTime stores a time value at a particular column
+func (r Row) SetTime(columnName string, value time.Time) {
+	r.txn.Time(columnName).Set(value)
+}
+
+// --------------------------- Points ----------------------------
+
+// Point loads a geographical point at a particular column
+func (r Row) Point(columnName string) (lat, lon float64, ok bool) {
+	return rdPoint(readerFor[*columnPoint](r.txn, columnName)).Get()
+}
+
+// SetPoint stores a geographical point at a particular column
+func (r Row) SetPoint(columnName string, lat, lon float64) {
+	r.txn.Point(columnName).Set(lat, lon)
+}
+
 // --------------------------- Map ----------------------------
 
 // SetMany stores a set of columns for a given map
 func (r Row) SetMany(value map[string]any) error {
+	return r.putMany(value, false)
+}
+
+// MergeMany merges a set of columns for a given map. The values of the numeric columns and
+// of the string columns are merged using their merge function, which can be specified with
+// WithMerge when creating the column, while the values of other columns are simply stored.
+func (r Row) MergeMany(value map[string]any) error {
+	return r.putMany(value, true)
+}
+
+// putMany stores or merges a set of columns for a given map
+func (r Row) putMany(value map[string]any, merge bool) error {
 	for k, v := range value {
-		if _, ok := r.txn.columnAt(k); !ok {
+		column, ok := r.txn.columnAt(k)
+		if !ok {
 			return fmt.Errorf("unable to set '%s', no such column", k)
 		}
 
-		if err := r.txn.bufferFor(k).PutAny(commit.Put, r.txn.cursor, v); err != nil {
+		// Hand the value over as-is, if the column keeps it in its native form
+		if s, ok := column.Column.(stager); ok && s.stage(r.txn, k, v) {
+			continue
+		}
+
+		v, err := encodeValue(column, v)
+		if err != nil {
+			return err
+		}
+
+		op := commit.Put
+		if merge && isMergeable(column) {
+			op = commit.Merge
+		}
+
+		if err := r.txn.bufferFor(k).PutAny(op, r.txn.cursor, v); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// encodeValue converts a value into a form which can be written into the commit buffer
+// of the column.
+func encodeValue(column *column, v any) (any, error) {
+
+	// Columns which require a specific encoding convert the value themselves
+	if enc, ok := column.Column.(encoder); ok {
+		encoded, err := enc.encode(v)
+		if err != nil {
+			return nil, err
+		}
+		v = encoded
+	}
+
+	// Convert the values which are not natively supported by the buffer
+	switch x := v.(type) {
+	case time.Time:
+		if column.IsTime() {
+			v = x.UnixNano() // Stored as nanoseconds rather than binary encoding
+		}
+	case [16]byte:
+		v = x[:]
+	case []string:
+		v = encodeSlice(x)
+	case json.RawMessage:
+		v = []byte(x)
+	}
+	return v, nil
+}
+
+// isMergeable checks whether the column supports merging of values
+func isMergeable(column *column) bool {
+	switch column.Column.(type) {
+	case *columnString:
+		return true
+	case *columnTime:
+		return false
+	default:
+		return column.IsNumeric()
+	}
+}
+
 // --------------------------- Others ----------------------------
 
 // Bool loads a bool value at a particular column
@@ -266,3 +431,24 @@ func (r Row) Any(columnName string) (any, bool) {
 func (r Row) SetAny(columnName string, value interface{}) {
 	r.txn.Any(columnName).Set(value)
 }
+
+// --------------------------- Generic ----------------------------
+
+// Get loads a value of type T at a particular column of the row. If the column does not
+// contain a value or the value is of a different type, this returns false.
+func Get[T any](r Row, columnName string) (value T, ok bool) {
+	if v, found := r.Any(columnName); found {
+		value, ok = v.(T)
+	}
+	return
+}
+
+// Set stores a value of type T at a particular column of the row
+func Set[T any](r Row, columnName string, value T) {
+	r.txn.Any(columnName).Set(value)
+}
+
+// Merge atomically merges a delta into a numeric value at a particular column of the row
+func Merge[T Number](r Row, columnName string, delta T) {
+	NumberOf[T](r.txn, columnName).Merge(delta)
+}
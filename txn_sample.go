@@ -0,0 +1,60 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"math/rand"
+)
+
+// Sample filters down the rows to a uniform random subset of at most n of the currently
+// selected rows. This allows expensive computations to run on a statistically meaningful
+// sample of a large selection.
+func (txn *Txn) Sample(n int) *Txn {
+	defer txn.trace("Sample")()
+	txn.initialize()
+	switch {
+	case n <= 0:
+		txn.index.Clear()
+		return txn
+	case txn.index.Count() <= n:
+		return txn
+	}
+
+	// Select the rows using a reservoir sampling, so that every row has an equal
+	// probability of being part of the sample.
+	seen, reservoir := 0, make([]uint32, 0, n)
+	txn.index.Range(func(idx uint32) {
+		switch {
+		case seen < n:
+			reservoir = append(reservoir, idx)
+		default:
+			if at := rand.Intn(seen + 1); at < n {
+				reservoir[at] = idx
+			}
+		}
+		seen++
+	})
+
+	txn.index.Clear()
+	for _, idx := range reservoir {
+		txn.index.Set(idx)
+	}
+	return txn
+}
+
+// SampleFraction filters down the rows to a random subset of the currently selected rows,
+// where each row is kept independently with the probability p (0 <= p <= 1).
+func (txn *Txn) SampleFraction(p float64) *Txn {
+	defer txn.trace("SampleFraction")()
+	txn.initialize()
+	switch {
+	case p <= 0:
+		txn.index.Clear()
+	case p < 1:
+		txn.index.Filter(func(uint32) bool {
+			return rand.Float64() < p
+		})
+	}
+	return txn
+}
@@ -0,0 +1,100 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kelindar/column/commit"
+)
+
+// RowView represents a copy of the values of a single row. Unlike the column accessors,
+// it does not depend on the transaction cursor and remains valid once the transaction
+// has ended.
+type RowView struct {
+	Index  uint32         // The index of the row
+	Values map[string]any // The values of the row, keyed by column name
+}
+
+// Get returns the value of the specified column, if present in the row.
+func (v RowView) Get(columnName string) (any, bool) {
+	value, ok := v.Values[columnName]
+	return value, ok
+}
+
+// Stream copies the selected columns of every row in the result set into a RowView and
+// sends it to the sink. If no columns are specified, all of the non-computed columns are
+// copied. The rows of each chunk are copied while the chunk is read-locked, but are sent
+// after the lock is released, so a slow consumer applies backpressure without blocking
+// writers. Streaming stops with the context error as soon as the context is cancelled.
+// The sink is not closed by this function.
+func (txn *Txn) Stream(ctx context.Context, sink chan<- RowView, columns ...string) error {
+	txn.initialize()
+	targets, err := txn.streamColumns(columns)
+	if err != nil {
+		return err
+	}
+
+	limit := commit.Chunk(len(txn.index) >> bitmapShift)
+	lock := txn.owner.slock
+	batch := make([]RowView, 0, 64)
+	for chunk := txn.from; chunk <= limit; chunk++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Copy the values of the chunk while holding the read lock
+		batch = batch[:0]
+		offset := chunk.Min()
+		txn.readLock(lock, chunk)
+		chunk.OfBitmap(txn.index).Range(func(x uint32) {
+			row := RowView{
+				Index:  offset + x,
+				Values: make(map[string]any, len(targets)),
+			}
+
+			for _, c := range targets {
+				if v, ok := c.Value(row.Index); ok {
+					row.Values[c.name] = copyValue(v)
+				}
+			}
+			batch = append(batch, row)
+		})
+		txn.readUnlock(lock, chunk)
+
+		// Push the rows to the sink, waiting for the consumer if necessary
+		for _, row := range batch {
+			select {
+			case sink <- row:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// streamColumns resolves the columns to be copied by Stream.
+func (txn *Txn) streamColumns(names []string) ([]*column, error) {
+	if len(names) == 0 {
+		out := make([]*column, 0, 8)
+		txn.owner.cols.Range(func(column *column) {
+			if !isComputed(column) {
+				out = append(out, column)
+			}
+		})
+		return out, nil
+	}
+
+	out := make([]*column, 0, len(names))
+	for _, name := range names {
+		column, ok := txn.columnAt(name)
+		if !ok {
+			return nil, fmt.Errorf("column: unable to stream '%s', no such column", name)
+		}
+		out = append(out, column)
+	}
+	return out, nil
+}
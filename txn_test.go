@@ -4,10 +4,15 @@
 package column
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kelindar/column/commit"
 	"github.com/kelindar/xxrand"
@@ -154,6 +159,72 @@ func TestCount(t *testing.T) {
 	})
 }
 
+func TestWithRange(t *testing.T) {
+	players := loadPlayers(500)
+	count := func(fn func(txn *Txn) *Txn) (n int) {
+		players.Query(func(txn *Txn) error {
+			n = fn(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	// Range filters must agree with the equivalent predicates
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn {
+			return txn.WithInt("age", func(v int64) bool { return v >= 30 && v <= 40 })
+		}),
+		count(func(txn *Txn) *Txn { return txn.WithInt64Range("age", 30, 40) }),
+	)
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn {
+			return txn.WithUint("age", func(v uint64) bool { return v >= 30 && v <= 40 })
+		}),
+		count(func(txn *Txn) *Txn { return txn.WithUint64Range("age", 30, 40) }),
+	)
+	assert.Equal(t, 222, count(func(txn *Txn) *Txn { return txn.WithFloat64Above("balance", 2500) }))
+	assert.Equal(t, 278, count(func(txn *Txn) *Txn { return txn.WithFloat64Below("balance", 2500.0000001) }))
+	assert.Equal(t, 500, count(func(txn *Txn) *Txn {
+		return txn.WithFloat64Range("balance", math.Inf(-1), math.Inf(1))
+	}))
+
+	// Combined with an index
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn {
+			return txn.With("human").WithFloat("balance", func(v float64) bool { return v > 2500 })
+		}),
+		count(func(txn *Txn) *Txn { return txn.With("human").WithFloat64Above("balance", 2500) }),
+	)
+
+	// Equality on enums and strings
+	assert.Equal(t, 138, count(func(txn *Txn) *Txn { return txn.WithStringEqual("race", "human") }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithStringEqual("race", "goblin") }))
+	assert.Equal(t, 500, count(func(txn *Txn) *Txn { return txn.WithString("name", func(string) bool { return true }) }))
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn {
+			return txn.WithString("name", func(v string) bool { return v == "Roman Atachiants" })
+		}),
+		count(func(txn *Txn) *Txn { return txn.WithStringEqual("name", "Roman Atachiants") }),
+	)
+
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn { return txn.WithUnion("elf", "dwarf") }),
+		count(func(txn *Txn) *Txn { return txn.WithOneOf("race", "elf", "dwarf", "goblin") }),
+	)
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithOneOf("race", "goblin") }))
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn { return txn.WithStringEqual("name", "Roman Atachiants") }),
+		count(func(txn *Txn) *Txn { return txn.WithOneOf("name", "Roman Atachiants", "Merlin") }),
+	)
+
+	// Invalid columns
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithOneOf("age", "30") }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithInt64Range("invalid", 0, 100) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithInt64Range("name", 0, 100) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithStringEqual("age", "30") }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithStringEqual("invalid", "30") }))
+}
+
 func TestIndexInvalid(t *testing.T) {
 	players := loadPlayers(500)
 	players.Query(func(txn *Txn) error {
@@ -335,6 +406,81 @@ func TestSortIndex(t *testing.T) {
 	assert.Equal(t, "rob", res[2])
 }
 
+func TestSortIndexDuplicates(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.CreateSortIndex("sorted_names", "name")
+	c.CreateIndex("even", "name", func(r Reader) bool {
+		return r.Index()%2 == 0
+	})
+
+	// Insert many rows sharing only a few keys
+	names := []string{"bob", "alice", "carol"}
+	for i := 0; i < 3000; i++ {
+		name := names[i%len(names)]
+		c.Insert(func(r Row) error {
+			r.SetString("name", name)
+			return nil
+		})
+	}
+
+	// Every row must be kept, ordered by key then by offset
+	var order []uint32
+	var keys []string
+	c.Query(func(txn *Txn) error {
+		name := txn.String("name")
+		return txn.Ascend("sorted_names", func(idx uint32) {
+			v, _ := name.Get()
+			keys = append(keys, v)
+			order = append(order, idx)
+		})
+	})
+
+	assert.Len(t, order, 3000)
+	assert.Equal(t, []uint32{1, 4, 7}, order[:3])
+	assert.Equal(t, "alice", keys[999])
+	assert.Equal(t, "bob", keys[1000])
+	assert.Equal(t, uint32(0), order[1000])
+
+	c.Query(func(txn *Txn) error {
+		n, err := txn.CountKey("sorted_names", "bob")
+		assert.NoError(t, err)
+		assert.Equal(t, 1000, n)
+
+		n, err = txn.CountKey("sorted_names", "dave")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		_, err = txn.CountKey("invalid", "bob")
+		assert.Error(t, err)
+		_, err = txn.CountKey("name", "bob")
+		assert.Error(t, err)
+		return nil
+	})
+
+	// The count respects the current filter
+	c.Query(func(txn *Txn) error {
+		n, err := txn.With("even").CountKey("sorted_names", "bob")
+		assert.NoError(t, err)
+		assert.Equal(t, 500, n)
+		return nil
+	})
+
+	// Updating or deleting a duplicate only affects that row
+	c.QueryAt(0, func(r Row) error {
+		r.SetString("name", "alice")
+		return nil
+	})
+	c.DeleteAt(3)
+	c.Query(func(txn *Txn) error {
+		bob, _ := txn.CountKey("sorted_names", "bob")
+		alice, _ := txn.CountKey("sorted_names", "alice")
+		assert.Equal(t, 998, bob)
+		assert.Equal(t, 1001, alice)
+		return nil
+	})
+}
+
 func TestSortIndexLoad(t *testing.T) {
 
 	players := loadPlayers(500)
@@ -363,6 +509,107 @@ func TestSortIndexLoad(t *testing.T) {
 
 }
 
+func TestSortIndexRange(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.CreateSortIndex("sorted_names", "name")
+	c.CreateIndex("even", "name", func(r Reader) bool {
+		return r.Index()%2 == 0
+	})
+
+	for _, name := range []string{"dave", "alice", "carol", "bob", "alice", "erin"} {
+		c.Insert(func(r Row) error {
+			r.SetString("name", name)
+			return nil
+		})
+	}
+
+	// Collects the keys in the order they are visited
+	var out []string
+	collect := func(txn *Txn) func(uint32) {
+		out = out[:0]
+		name := txn.String("name")
+		return func(idx uint32) {
+			v, _ := name.Get()
+			out = append(out, fmt.Sprintf("%s:%d", v, idx))
+		}
+	}
+
+	c.Query(func(txn *Txn) error {
+		assert.NoError(t, txn.Descend("sorted_names", collect(txn)))
+		assert.Equal(t, []string{"erin:5", "dave:0", "carol:2", "bob:3", "alice:4", "alice:1"}, out)
+		assert.NoError(t, txn.AscendRange("sorted_names", "alice", "d", collect(txn)))
+		assert.Equal(t, []string{"alice:1", "alice:4", "bob:3", "carol:2"}, out)
+		assert.NoError(t, txn.DescendRange("sorted_names", "b", "erin", collect(txn)))
+		assert.Equal(t, []string{"dave:0", "carol:2", "bob:3"}, out)
+		assert.NoError(t, txn.AscendRange("sorted_names", "x", "z", collect(txn)))
+		assert.Empty(t, out)
+		return nil
+	})
+
+	// The ranges respect the current filter
+	c.Query(func(txn *Txn) error {
+		txn = txn.With("even")
+		assert.NoError(t, txn.DescendRange("sorted_names", "a", "d", collect(txn)))
+		assert.Equal(t, []string{"carol:2", "alice:4"}, out)
+		assert.Error(t, txn.Descend("invalid", collect(txn)))
+		assert.Error(t, txn.AscendRange("name", "a", "b", collect(txn)))
+		return nil
+	})
+}
+
+func TestSortIndexNumeric(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("balance", ForFloat64())
+	c.CreateColumn("level", ForInt())
+	c.CreateSortIndex("by_balance", "balance")
+	c.CreateSortIndex("by_level", "level")
+
+	for i, v := range []float64{10, -2.5, 300, 0, -100, 2, 10} {
+		c.Insert(func(r Row) error {
+			r.SetFloat64("balance", v)
+			r.SetInt("level", int(v)*(i%2*2-1))
+			return nil
+		})
+	}
+
+	// Merges are reflected with the final value
+	c.QueryAt(3, func(r Row) error {
+		r.MergeFloat64("balance", -1000)
+		return nil
+	})
+
+	c.Query(func(txn *Txn) error {
+		var balances []float64
+		balance := txn.Float64("balance")
+		assert.NoError(t, txn.Ascend("by_balance", func(idx uint32) {
+			v, _ := balance.Get()
+			balances = append(balances, v)
+		}))
+		assert.Equal(t, []float64{-1000, -100, -2.5, 2, 10, 10, 300}, balances)
+
+		var levels []int
+		level := txn.Int("level")
+		assert.NoError(t, txn.Descend("by_level", func(idx uint32) {
+			v, _ := level.Get()
+			levels = append(levels, v)
+		}))
+		assert.Equal(t, []int{100, 2, 0, -2, -10, -10, -300}, levels)
+
+		balances = balances[:0]
+		assert.NoError(t, txn.AscendRange("by_balance", SortKey(-5.0), SortKey(10.0), func(idx uint32) {
+			v, _ := balance.Get()
+			balances = append(balances, v)
+		}))
+		assert.Equal(t, []float64{-2.5, 2}, balances)
+
+		count, err := txn.CountKey("by_balance", SortKey(10.0))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		return nil
+	})
+}
+
 func TestSortIndexChunks(t *testing.T) {
 	N := 100_000
 	obj := map[string]any{
@@ -703,6 +950,35 @@ func TestUpsertKey(t *testing.T) {
 	assert.Equal(t, 1, count)
 }
 
+func TestUpsertObject(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("key", ForKey())
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("age", ForInt())
+
+	obj := map[string]any{"key": "1", "name": "Roman", "age": 35}
+	assert.NoError(t, c.UpsertObject(obj, "key"))
+	assert.NoError(t, c.UpsertObject(map[string]any{"key": "1", "age": 36}, "key"))
+	assert.NoError(t, c.UpsertObject(map[string]any{"key": "2", "name": "Merlin"}, "key"))
+	assert.Equal(t, 2, c.Count())
+	assert.Len(t, obj, 3)
+
+	assert.NoError(t, c.QueryKey("1", func(r Row) error {
+		name, _ := r.String("name")
+		age, _ := r.Int("age")
+		assert.Equal(t, "Roman", name)
+		assert.Equal(t, 36, age)
+		return nil
+	}))
+
+	// Invalid objects
+	assert.Error(t, c.UpsertObject(map[string]any{"key": 1}, "key"))
+	assert.Error(t, c.UpsertObject(map[string]any{"name": "Roman"}, "name"))
+	assert.Error(t, c.UpsertObject(map[string]any{"key": "3", "age": "old"}, "key"))
+	assert.Error(t, NewCollection().UpsertObject(obj, "key"))
+	assert.Equal(t, 2, c.Count())
+}
+
 func TestUpsertKeyNoColumn(t *testing.T) {
 	c := NewCollection()
 	c.CreateColumn("key", ForKey())
@@ -1153,3 +1429,1624 @@ func TestSetManyErr(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestTrigramIndex(t *testing.T) {
+	players := loadPlayers(500)
+	assert.Error(t, players.CreateTrigramIndex("", ""))
+	assert.Error(t, players.CreateTrigramIndex("x", "age"))
+	assert.Error(t, players.CreateTrigramIndex("x", "nonexistent"))
+
+	// Count without the index first
+	var prefix, contains int
+	players.Query(func(txn *Txn) error {
+		prefix = txn.WithPrefix("name", "Ro").Count()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		contains = txn.WithContains("name", "er").Count()
+		return nil
+	})
+
+	// Now with the index, the results should be the same
+	assert.NoError(t, players.CreateTrigramIndex("name_trigram", "name"))
+	assert.Error(t, players.CreateTrigramIndex("name_trigram", "name"))
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, prefix, txn.WithPrefix("name", "Ro").Count())
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, contains, txn.WithContains("name", "er").Count())
+		return nil
+	})
+
+	// Short patterns can't use the index, fall back to a scan
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.WithContains("name", "").Count())
+		return nil
+	})
+
+	// Updates and deletes should be reflected in the index
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		r.SetString("name", "Zyxwvu")
+		return nil
+	}))
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithPrefix("name", "Zyx").Count())
+		assert.Equal(t, 1, txn.WithContains("name", "xwv").Count())
+		return nil
+	})
+
+	assert.True(t, players.DeleteAt(0))
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithContains("name", "xwv").Count())
+		return nil
+	})
+}
+
+func TestRangePage(t *testing.T) {
+	players := loadPlayers(60000)
+
+	// Collect the full result set first
+	var expect []uint32
+	players.Query(func(txn *Txn) error {
+		return txn.With("human", "mage").Range(func(idx uint32) {
+			expect = append(expect, idx)
+		})
+	})
+
+	// Go page by page and make sure we get the same results
+	var actual []uint32
+	var token string
+	for pages := 0; ; pages++ {
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			token = txn.After(token).With("human", "mage").RangePage(1000, func(idx uint32) {
+				actual = append(actual, idx)
+			})
+			return nil
+		}))
+
+		if token == "" {
+			assert.Equal(t, len(expect)/1000, pages)
+			break
+		}
+	}
+	assert.Equal(t, expect, actual)
+
+	// A limit which is not positive iterates over nothing
+	for _, limit := range []int{0, -1} {
+		assert.NoError(t, players.Query(func(txn *Txn) error {
+			assert.Equal(t, "", txn.RangePage(limit, func(idx uint32) {
+				t.Fatal("unexpected row")
+			}))
+			return nil
+		}))
+	}
+
+	// The pages are scanned within the limits of the query
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, "", txn.RangePage(1000, func(idx uint32) {}))
+		return nil
+	}, WithMaxScanned(10)), ErrScanLimit)
+
+	// Invalid token
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.After("!!").Count())
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.After("_____w").Count())
+		return nil
+	})
+
+	// Token of the last offset
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.After(encodePageToken(59999)).Count())
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.After(encodePageToken(59998)).Count())
+		return nil
+	})
+}
+
+func TestRangeStable(t *testing.T) {
+	players := NewCollection()
+	players.CreateColumn("name", ForKey())
+	players.CreateColumn("age", ForInt())
+	for i, name := range []string{"carol", "alice", "dave", "bob"} {
+		players.InsertKey(name, func(r Row) error {
+			r.SetInt("age", 20+i)
+			return nil
+		})
+	}
+
+	// The index of a deleted row is reused by the next insert
+	players.DeleteKey("alice")
+	players.InsertKey("eve", func(r Row) error {
+		r.SetInt("age", 30)
+		return nil
+	})
+
+	collect := func(order Order) (names []string, err error) {
+		err = players.Query(func(txn *Txn) error {
+			key := txn.Key()
+			return txn.RangeStable(order, func(idx uint32) {
+				name, _ := key.Get()
+				names = append(names, name)
+			})
+		})
+		return
+	}
+
+	byIndex, err := collect(OrderByIndex)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"carol", "eve", "dave", "bob"}, byIndex)
+
+	byKey, err := collect(OrderByKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob", "carol", "dave", "eve"}, byKey)
+
+	_, err = collect(Order(99))
+	assert.Error(t, err)
+
+	// Ordering by key requires a primary key
+	assert.ErrorIs(t, loadPlayers(10).Query(func(txn *Txn) error {
+		return txn.RangeStable(OrderByKey, func(idx uint32) {})
+	}), errNoKey)
+}
+
+func TestRangeReverse(t *testing.T) {
+	players := loadPlayers(60000)
+
+	var expect, actual []uint32
+	players.Query(func(txn *Txn) error {
+		return txn.With("human", "mage").Range(func(idx uint32) {
+			expect = append([]uint32{idx}, expect...)
+		})
+	})
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		age := txn.Int("age")
+		return txn.With("human", "mage").RangeReverse(func(idx uint32) {
+			_, ok := age.Get()
+			assert.True(t, ok)
+			actual = append(actual, idx)
+		})
+	}))
+	assert.Equal(t, expect, actual)
+}
+
+func TestRangeWindow(t *testing.T) {
+	players := loadPlayers(60000)
+
+	var expect []uint32
+	players.Query(func(txn *Txn) error {
+		return txn.With("human", "mage").Range(func(idx uint32) {
+			expect = append(expect, idx)
+		})
+	})
+
+	window := func(start, count int) (out []uint32, err error) {
+		err = players.Query(func(txn *Txn) error {
+			return txn.With("human", "mage").RangeWindow(start, count, func(idx uint32) {
+				out = append(out, idx)
+			})
+		})
+		return
+	}
+
+	n := len(expect)
+	for _, tc := range [][2]int{{0, 10}, {1, 63}, {63, 65}, {2000, 1000}, {n - 10, 10}, {n - 5, 100}} {
+		actual, err := window(tc[0], tc[1])
+		assert.NoError(t, err)
+		end := tc[0] + tc[1]
+		if end > n {
+			end = n
+		}
+		assert.Equal(t, expect[tc[0]:end], actual, "window %v", tc)
+	}
+
+	actual, err := window(n, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, actual)
+
+	_, err = window(-1, 10)
+	assert.Error(t, err)
+}
+
+func TestStream(t *testing.T) {
+	players := loadPlayers(50000)
+	defer players.Close()
+
+	// Stream all of the humans through a buffered sink
+	sink := make(chan RowView, 16)
+	done := make(chan int)
+	go func() {
+		count := 0
+		for row := range sink {
+			race, ok := row.Get("race")
+			if ok && race == "human" && len(row.Values) == 2 {
+				count++
+			}
+		}
+		done <- count
+	}()
+
+	var expect int
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn = txn.With("human")
+		expect = txn.Count()
+		return txn.Stream(context.Background(), sink, "race", "age")
+	}))
+	close(sink)
+	assert.Equal(t, expect, <-done)
+	assert.NotZero(t, expect)
+
+	// Unknown column
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		return txn.Stream(context.Background(), make(chan RowView), "invalid")
+	}))
+}
+
+func TestStreamCancel(t *testing.T) {
+	players := loadPlayers(50000)
+	defer players.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := make(chan RowView)
+	go func() {
+		for i := 0; i < 10; i++ {
+			<-sink
+		}
+		cancel()
+	}()
+
+	err := players.Query(func(txn *Txn) error {
+		return txn.Stream(ctx, sink)
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Writes are not blocked while the consumer is slow
+	assert.NoError(t, players.QueryAt(0, func(r Row) error {
+		r.SetFloat64("balance", 1)
+		return nil
+	}))
+}
+
+func TestStreamBytes(t *testing.T) {
+	coll := NewCollection()
+	defer coll.Close()
+	assert.NoError(t, coll.CreateColumn("data", ForBytes()))
+	_, err := coll.Insert(func(r Row) error {
+		return r.SetBytes("data", []byte("hello"))
+	})
+	assert.NoError(t, err)
+
+	sink := make(chan RowView, 1)
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		return txn.Stream(context.Background(), sink)
+	}))
+
+	// The streamed row does not share the value stored in the column
+	row := <-sink
+	data, _ := row.Get("data")
+	data.([]byte)[0] = 'j'
+	assert.NoError(t, coll.QueryAt(0, func(r Row) error {
+		v, _ := r.Bytes("data")
+		assert.Equal(t, []byte("hello"), v)
+		return nil
+	}))
+}
+
+func TestSavepoint(t *testing.T) {
+	players := newEmpty(100)
+	defer players.Close()
+	assert.NoError(t, players.CreateColumn("counter", ForInt64()))
+
+	idx, _ := players.Insert(func(r Row) error {
+		r.SetString("name", "Roman")
+		r.SetInt64("counter", 1)
+		return nil
+	})
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		name := txn.String("name")
+		counter := txn.Int64("counter")
+		assert.NoError(t, txn.QueryAt(idx, func(r Row) error {
+			name.Set("Merlin")
+			return nil
+		}))
+
+		// Make a few changes after the savepoint, including inserts and deletes
+		sp := txn.Savepoint()
+		assert.NoError(t, txn.QueryAt(idx, func(r Row) error {
+			counter.Merge(10)
+			name.Set("Gandalf")
+			return nil
+		}))
+		for i := 0; i < 3; i++ {
+			txn.Insert(func(r Row) error {
+				r.SetString("name", "Sauron")
+				return nil
+			})
+		}
+		txn.DeleteAt(idx)
+		assert.Equal(t, 4, players.Count())
+
+		// Roll back the changes made since the savepoint
+		assert.NoError(t, txn.RollbackTo(sp))
+		assert.Equal(t, 1, players.Count())
+		assert.Error(t, txn.RollbackTo(Savepoint{buffers: []*commit.Buffer{commit.NewBuffer(0)}}))
+
+		// Keep writing after the rollback
+		return txn.QueryAt(idx, func(r Row) error {
+			counter.Merge(2)
+			return nil
+		})
+	}))
+
+	assert.Equal(t, 1, players.Count())
+	assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+		name, _ := r.String("name")
+		counter, _ := r.Int64("counter")
+		assert.Equal(t, "Merlin", name)
+		assert.Equal(t, int64(3), counter)
+		return nil
+	}))
+}
+
+func TestSavepointConditional(t *testing.T) {
+	players := NewCollection(Options{Versioned: true})
+	players.CreateColumn("balance", ForFloat64())
+	idx, _ := players.Insert(func(r Row) error {
+		r.SetFloat64("balance", 100)
+		return nil
+	})
+
+	// Conditional updates made after the savepoint are discarded
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		sp := txn.Savepoint()
+		assert.NoError(t, txn.QueryAt(idx, func(r Row) error {
+			SetIf(r, "balance", 99.0, func(v float64) bool { return true })
+			return nil
+		}))
+		return txn.RollbackTo(sp)
+	}))
+
+	balance, _ := ReadNumber[float64](players, idx, "balance")
+	assert.Equal(t, 100.0, balance)
+
+	// Expected versions made after the savepoint are discarded
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		sp := txn.Savepoint()
+		assert.NoError(t, txn.QueryAtVersion(idx, 1, func(r Row) error {
+			return nil
+		}))
+		assert.NoError(t, txn.RollbackTo(sp))
+
+		done := make(chan struct{})
+		go func() {
+			players.QueryAt(idx, func(r Row) error {
+				r.SetFloat64("balance", 50)
+				return nil
+			})
+			close(done)
+		}()
+		<-done
+		return nil
+	}))
+
+	balance, _ = ReadNumber[float64](players, idx, "balance")
+	assert.Equal(t, 50.0, balance)
+}
+
+func TestRowVersion(t *testing.T) {
+	players := NewCollection(Options{Versioned: true})
+	players.CreateColumn("name", ForString())
+	players.CreateColumn("balance", ForFloat64())
+
+	idx, err := players.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Read the current version of the row
+	version := func() (v uint64) {
+		assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+			v, _ = r.Version()
+			return nil
+		}))
+		return
+	}
+	assert.Equal(t, uint64(1), version())
+
+	// Update with the expected version succeeds and increments it
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.QueryAtVersion(idx, 1, func(r Row) error {
+			r.SetFloat64("balance", 10)
+			r.SetString("name", "Gandalf")
+			return nil
+		})
+	}))
+	assert.Equal(t, uint64(2), version())
+
+	// Update with a stale version fails
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		return txn.QueryAtVersion(idx, 1, func(r Row) error {
+			r.SetFloat64("balance", 20)
+			return nil
+		})
+	}), ErrVersionConflict)
+	assert.Equal(t, uint64(2), version())
+
+	// Row modified after the check, but before the commit
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		if err := txn.QueryAtVersion(idx, 2, func(r Row) error {
+			r.SetFloat64("balance", 30)
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		done := make(chan struct{})
+		go func() {
+			players.QueryAt(idx, func(r Row) error {
+				r.SetString("name", "Sauron")
+				return nil
+			})
+			close(done)
+		}()
+		<-done
+		return nil
+	}), ErrVersionConflict)
+	assert.Equal(t, uint64(3), version())
+
+	assert.NoError(t, players.QueryAt(idx, func(r Row) error {
+		balance, _ := r.Float64("balance")
+		name, _ := r.String("name")
+		assert.Equal(t, float64(10), balance)
+		assert.Equal(t, "Sauron", name)
+		return nil
+	}))
+}
+
+func TestRowID(t *testing.T) {
+	players := NewCollection(Options{Generations: true})
+	players.CreateColumn("name", ForString())
+	insert := func(name string) (id RowID) {
+		_, err := players.Insert(func(r Row) error {
+			r.SetString("name", name)
+			id, _ = r.RowID()
+			return nil
+		})
+		assert.NoError(t, err)
+		return
+	}
+
+	merlin := insert("Merlin")
+	assert.NoError(t, players.QueryByRowID(merlin, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Merlin", name)
+		return nil
+	}))
+
+	// The index is re-used, but the old identifier is stale
+	assert.True(t, players.DeleteAt(merlin.Index()))
+	assert.ErrorIs(t, players.QueryByRowID(merlin, func(r Row) error { return nil }), ErrStale)
+	gandalf := insert("Gandalf")
+	assert.Equal(t, merlin.Index(), gandalf.Index())
+	assert.Equal(t, merlin.Generation()+1, gandalf.Generation())
+	assert.ErrorIs(t, players.QueryByRowID(merlin, func(r Row) error { return nil }), ErrStale)
+	assert.NoError(t, players.QueryByRowID(gandalf, func(r Row) error {
+		name, _ := r.String("name")
+		assert.Equal(t, "Gandalf", name)
+		return nil
+	}))
+
+	// The generations are not exposed as values of the rows
+	assert.Error(t, players.CreateColumn("generation", ForUint32()))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("generation").Count())
+		return nil
+	}))
+
+	// The generations are restored from a snapshot
+	buffer := bytes.NewBuffer(nil)
+	assert.NoError(t, players.Snapshot(buffer))
+	output := NewCollection(Options{Generations: true})
+	output.CreateColumn("name", ForString())
+	assert.NoError(t, output.Restore(buffer))
+	assert.ErrorIs(t, output.QueryByRowID(merlin, func(r Row) error { return nil }), ErrStale)
+	assert.NoError(t, output.QueryByRowID(gandalf, func(r Row) error { return nil }))
+
+	// Without generations, the row identifiers are not available
+	assert.Error(t, newEmpty(10).QueryByRowID(gandalf, func(r Row) error { return nil }))
+}
+
+func TestRowVersionDisabled(t *testing.T) {
+	players := newEmpty(10)
+	idx, _ := players.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		return txn.QueryAtVersion(idx, 0, func(r Row) error {
+			_, ok := r.Version()
+			assert.False(t, ok)
+			return nil
+		})
+	}))
+}
+
+func TestStrict(t *testing.T) {
+	players := loadPlayers(500)
+
+	// By default, unknown columns silently match nothing
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.With("humann").Count())
+		assert.NoError(t, txn.Err())
+		return nil
+	}))
+
+	// In strict mode, the first unknown column is reported
+	err := players.Query(func(txn *Txn) error {
+		txn.Strict().With("human").Without("elff").WithValue("agee", func(v any) bool {
+			return true
+		})
+		assert.Error(t, txn.Err())
+		return nil
+	})
+	assert.ErrorContains(t, err, "elff")
+
+	// Strict mode can be enabled for the entire collection
+	strict := NewCollection(Options{Strict: true})
+	strict.CreateColumn("name", ForString())
+	assert.Error(t, strict.Query(func(txn *Txn) error {
+		txn.Union("missing").Count()
+		return nil
+	}))
+	assert.NoError(t, strict.Query(func(txn *Txn) error {
+		txn.WithString("name", func(v string) bool { return true }).Count()
+		return nil
+	}))
+
+	// The filters of the specialized columns also report unknown columns
+	for _, filter := range []func(txn *Txn) *Txn{
+		func(txn *Txn) *Txn { return txn.WithJSONPath("missing", "a.b", func(v any) bool { return true }) },
+		func(txn *Txn) *Txn { return txn.WithMember("missing", "a") },
+		func(txn *Txn) *Txn { return txn.WithTimeRange("missing", time.Unix(0, 0), time.Now()) },
+		func(txn *Txn) *Txn { return txn.WithUUID("missing", [16]byte{1}) },
+		func(txn *Txn) *Txn { return txn.WithinRadius("missing", 48.85, 2.35, 1000) },
+		func(txn *Txn) *Txn { return txn.WithinBox("missing", 48, 2, 49, 3) },
+	} {
+		assert.ErrorContains(t, strict.Query(func(txn *Txn) error {
+			filter(txn).Count()
+			return nil
+		}), "missing")
+	}
+}
+
+func TestWithBool(t *testing.T) {
+	players := loadPlayers(500)
+	var active int
+	players.Query(func(txn *Txn) error {
+		active = txn.WithValue("active", func(v any) bool { return v.(bool) }).Count()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		assert.NotZero(t, active)
+		assert.Equal(t, active, txn.WithBool("active").Count())
+		return nil
+	})
+
+	// Other types of columns are rejected
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithBool("name").Count())
+		return nil
+	}))
+	assert.ErrorContains(t, players.Query(func(txn *Txn) error {
+		txn.Strict().WithBool("name")
+		return nil
+	}), "not a boolean")
+
+	// In strict mode, only the indexes and boolean columns can be used with With
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.Strict().With("human", "active").Without("old").Count()
+		return nil
+	}))
+	assert.ErrorContains(t, players.Query(func(txn *Txn) error {
+		txn.Strict().With("name").Count()
+		return nil
+	}), "not an index")
+}
+
+func TestBoolFalse(t *testing.T) {
+	coll := NewCollection()
+	coll.CreateColumn("name", ForString())
+	coll.CreateColumn("active", ForBool())
+	for i := 0; i < 300; i++ {
+		coll.Insert(func(r Row) error {
+			r.SetString("name", "x")
+			switch i % 3 {
+			case 0:
+				r.SetBool("active", true)
+			case 1:
+				r.SetBool("active", false)
+			}
+			return nil
+		})
+	}
+
+	// False is distinct from missing
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 100, txn.WithBool("active").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 100, txn.WithBoolFalse("active").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 2, txn.DistinctCount("active"))
+		assert.Equal(t, 200, txn.Without("active").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 1, txn.WithBool("active").DistinctCount("active"))
+		return nil
+	})
+
+	// Deleted rows are neither true nor false
+	coll.Query(func(txn *Txn) error {
+		return txn.WithBoolFalse("active").Range(func(idx uint32) {
+			if idx < 30 {
+				txn.DeleteAt(idx)
+			}
+		})
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 90, txn.WithBoolFalse("active").Count())
+		return nil
+	})
+
+	// Set all of the selected rows
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		txn.WithBoolFalse("active").Bool("active").SetAll(true)
+		return nil
+	}))
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 0, txn.WithBoolFalse("active").Count())
+		return nil
+	})
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 190, txn.WithBool("active").Count())
+		return nil
+	})
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		txn.Bool("active").SetAll(false)
+		return nil
+	}))
+	coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 290, txn.WithBoolFalse("active").Count())
+		return nil
+	})
+
+	// Other types of columns are rejected
+	assert.ErrorContains(t, coll.Query(func(txn *Txn) error {
+		txn.Strict().WithBoolFalse("name")
+		return nil
+	}), "not a boolean")
+}
+
+func TestExplain(t *testing.T) {
+	players := loadPlayers(500)
+	players.Query(func(txn *Txn) error {
+		txn.With("human").WithString("class", func(v string) bool {
+			return v == "mage"
+		})
+
+		// Nothing is recorded until profiling is enabled
+		assert.Empty(t, txn.Explain().Steps)
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		txn.Profile().With("human").WithFloat64Above("balance", 3000).Count()
+
+		plan := txn.Explain()
+		assert.Len(t, plan.Steps, 2)
+		assert.Equal(t, []string{"human"}, plan.Indexes())
+		assert.Equal(t, "With", plan.Steps[0].Op)
+		assert.Equal(t, 500, plan.Steps[0].Examined)
+		assert.Equal(t, plan.Steps[0].Matched, plan.Steps[1].Examined)
+		assert.Equal(t, "WithFloat64Above", plan.Steps[1].Op)
+		assert.False(t, plan.Steps[1].Indexed)
+		assert.NotZero(t, plan.Steps[1].Chunks)
+		assert.Contains(t, plan.String(), "WithFloat64Above(balance) scan")
+		return nil
+	})
+}
+
+func TestQueryLogger(t *testing.T) {
+	var plans []Plan
+	players := NewCollection(Options{
+		QueryLogger: func(p Plan) { plans = append(plans, p) },
+		SlowQuery:   time.Nanosecond,
+	})
+	players.CreateColumn("name", ForString())
+	players.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		txn.WithString("name", func(v string) bool { return v == "Merlin" }).Count()
+		return nil
+	})
+
+	assert.Len(t, plans, 2)
+	assert.Empty(t, plans[0].Steps)
+	assert.Len(t, plans[1].Steps, 1)
+	assert.Equal(t, 1, plans[1].Steps[0].Matched)
+	assert.Greater(t, plans[1].Duration, time.Duration(0))
+}
+
+func TestQueryLimits(t *testing.T) {
+	players := loadPlayers(500)
+
+	// The budget is enough for the query
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.With("human").Range(func(idx uint32) {})
+	}, WithMaxScanned(1000)))
+
+	// The budget is exceeded by the second filter
+	var matched int
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		matched = txn.WithValue("age", func(v any) bool { return true }).
+			WithValue("age", func(v any) bool { return true }).Count()
+		return nil
+	}, WithMaxScanned(600)), ErrScanLimit)
+	assert.Equal(t, 0, matched)
+
+	// Updates of an aborted transaction are rolled back
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}, WithMaxScanned(10)), ErrScanLimit)
+	assert.Equal(t, 500, players.Count())
+
+	// The transaction takes longer than allowed
+	assert.ErrorIs(t, players.Query(func(txn *Txn) error {
+		time.Sleep(5 * time.Millisecond)
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}, WithTimeout(time.Millisecond)), context.DeadlineExceeded)
+	assert.Equal(t, 500, players.Count())
+
+	// The deadline is exceeded, even if the context was not cancelled yet
+	late := lateContext{context.Background()}
+	assert.ErrorIs(t, players.QueryContext(late, func(txn *Txn) error {
+		return txn.Range(func(idx uint32) {
+			txn.DeleteAt(idx)
+		})
+	}, WithTimeout(time.Hour)), context.DeadlineExceeded)
+	assert.Equal(t, 500, players.Count())
+}
+
+// lateContext represents a context whose deadline has passed, but which is not cancelled
+type lateContext struct {
+	context.Context
+}
+
+func (lateContext) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Second), true
+}
+
+func TestNumberOf(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("hp", ForInt16())
+	c.CreateColumn("balance", ForFloat64())
+
+	idx, err := c.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		Set[int16](r, "hp", 100)
+		Set(r, "balance", 10.5)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Update using the generic accessors
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		Merge[int16](r, "hp", 20)
+		return nil
+	}))
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		balance := NumberOf[float64](txn, "balance")
+		return txn.Range(func(idx uint32) {
+			balance.Merge(1.5)
+		})
+	}))
+
+	// Read back the values
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		hp, ok := Get[int16](r, "hp")
+		assert.True(t, ok)
+		assert.Equal(t, int16(120), hp)
+
+		balance, ok := Get[float64](r, "balance")
+		assert.True(t, ok)
+		assert.Equal(t, 12.0, balance)
+
+		name, ok := Get[string](r, "name")
+		assert.True(t, ok)
+		assert.Equal(t, "Merlin", name)
+
+		_, ok = Get[int](r, "hp")
+		assert.False(t, ok)
+		return nil
+	}))
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		assert.Equal(t, int16(120), NumberOf[int16](txn, "hp").Sum())
+		assert.Panics(t, func() {
+			NumberOf[int](txn, "hp")
+		})
+		return nil
+	}))
+}
+
+func TestQuantile(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("latency", ForFloat64())
+	for i := 1; i <= 100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetFloat64("latency", float64(i))
+			return nil
+		})
+	}
+
+	// A row without a value should be ignored
+	c.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		latency := txn.Float64("latency")
+
+		median, ok := latency.Median()
+		assert.True(t, ok)
+		assert.Equal(t, 50.5, median)
+
+		p95, ok := latency.Quantile(0.95)
+		assert.True(t, ok)
+		assert.InDelta(t, 95.05, p95, 1e-9)
+
+		p0, _ := latency.Quantile(0)
+		p100, _ := latency.Quantile(1)
+		assert.Equal(t, 1.0, p0)
+		assert.Equal(t, 100.0, p100)
+
+		_, ok = latency.Quantile(1.5)
+		assert.False(t, ok)
+
+		bins := latency.Histogram(4)
+		assert.Len(t, bins, 4)
+		for _, bin := range bins {
+			assert.Equal(t, 25, bin.Count)
+		}
+		assert.Equal(t, 1.0, bins[0].Min)
+		assert.Equal(t, 100.0, bins[3].Max)
+		assert.Nil(t, latency.Histogram(0))
+		return nil
+	}))
+
+	// Empty selection
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		latency := txn.Float64("latency")
+		txn.WithValue("latency", func(v any) bool { return false })
+
+		_, ok := latency.Median()
+		assert.False(t, ok)
+		assert.Nil(t, latency.Histogram(10))
+		return nil
+	}))
+
+	// NaN and infinite values are skipped
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		c.Insert(func(r Row) error {
+			r.SetFloat64("latency", v)
+			return nil
+		})
+	}
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		latency := txn.Float64("latency")
+		median, ok := latency.Median()
+		assert.True(t, ok)
+		assert.Equal(t, 50.5, median)
+
+		bins := latency.Histogram(4)
+		assert.Len(t, bins, 4)
+		for _, bin := range bins {
+			assert.Equal(t, 25, bin.Count)
+		}
+		return nil
+	}))
+
+	// Only non-finite values
+	nan := NewCollection()
+	nan.CreateColumn("latency", ForFloat64())
+	nan.Insert(func(r Row) error {
+		r.SetFloat64("latency", math.NaN())
+		return nil
+	})
+	assert.NoError(t, nan.Query(func(txn *Txn) error {
+		_, ok := txn.Float64("latency").Quantile(0.5)
+		assert.False(t, ok)
+		assert.Nil(t, txn.Float64("latency").Histogram(4))
+		return nil
+	}))
+}
+
+func TestCopy(t *testing.T) {
+	players := loadPlayers(500)
+	players.CreateColumn("age2", ForInt())
+	players.CreateColumn("score", ForFloat64())
+
+	// Copy the values of the selected rows only
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.With("human").Copy("age", "age2")
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, txn.With("human").Int("age").Sum(), txn.Int("age2").Sum())
+		return nil
+	}))
+
+	// Transform the values, skipping the ones for which nil is returned
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.Copy("age", "score", func(v any) any {
+			if v.(int) < 30 {
+				return nil
+			}
+			return float64(v.(int)) / 2
+		})
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		expect := float64(txn.WithInt("age", func(v int64) bool { return v >= 30 }).Int("age").Sum()) / 2
+		assert.Equal(t, expect, txn.Float64("score").Sum())
+		assert.Equal(t, txn.Count(), txn.With("score").Count())
+		return nil
+	}))
+
+	// A value which is not accepted rolls back the copy
+	assert.Error(t, players.Query(func(txn *Txn) error {
+		txn.Float64("score").UpdateAll(func(float64) float64 { return 0 })
+		return txn.Copy("age", "score")
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.NotZero(t, txn.Float64("score").Sum())
+		assert.Error(t, txn.Copy("invalid", "score"))
+		assert.Error(t, txn.Copy("age", "invalid"))
+		assert.Error(t, txn.Copy("age", "human"))
+		return nil
+	}))
+}
+
+func TestUpdateAll(t *testing.T) {
+	players := loadPlayers(500)
+
+	var total, human float64
+	var age int
+	players.Query(func(txn *Txn) error {
+		age = txn.Int("age").Sum()
+		total = txn.Float64("balance").Sum()
+		human = txn.With("human").Float64("balance").Sum()
+		return nil
+	})
+
+	// Only the selected rows are updated
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.With("human").Float64("balance").ScaleAll(2)
+		return nil
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.InDelta(t, total+human, txn.Float64("balance").Sum(), 1e-6)
+		return nil
+	}))
+
+	// The rows without a value are left as is
+	players.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.Int("age").AddAll(1)
+		NumberOf[float64](txn, "balance").UpdateAll(func(v float64) float64 {
+			return 0
+		})
+		return nil
+	}))
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 0.0, txn.Float64("balance").Sum())
+		assert.Equal(t, age+500, txn.Int("age").Sum())
+		return nil
+	}))
+
+	assert.NoError(t, players.QueryAt(500, func(r Row) error {
+		_, ok := r.Int("age")
+		assert.False(t, ok)
+		return nil
+	}))
+
+	// The deltas are merged, so they add up within a transaction
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.Int("age").AddAll(1)
+		NumberOf[int](txn, "age").AddAll(1)
+		return nil
+	}))
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, age+1500, txn.Int("age").Sum())
+		return nil
+	}))
+}
+
+func TestAggregateWhere(t *testing.T) {
+	players := loadPlayers(500)
+
+	// Compute the expected results by filtering down the transactions
+	var count, countOld int
+	var sum float64
+	var sumAge int64
+	players.Query(func(txn *Txn) error {
+		txn.With("human").WithFloat("balance", func(v float64) bool { return v > 3000 })
+		count, sum = txn.Count(), txn.Float64("balance").Sum()
+		return nil
+	})
+	players.Query(func(txn *Txn) error {
+		txn.WithInt("age", func(v int64) bool { return v >= 30 })
+		countOld, sumAge = txn.Count(), int64(txn.Int("age").Sum())
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		human := txn.With("human")
+		total := human.Count()
+		assert.Equal(t, count, human.CountWhereFloat("balance", func(v float64) bool { return v > 3000 }))
+		assert.InDelta(t, sum, human.SumWhereFloat("balance", func(v float64) bool { return v > 3000 }), 1e-6)
+		assert.Equal(t, total, human.Count())
+		return nil
+	})
+
+	players.Query(func(txn *Txn) error {
+		assert.Equal(t, countOld, txn.CountWhereInt("age", func(v int64) bool { return v >= 30 }))
+		assert.Equal(t, sumAge, txn.SumWhereInt("age", func(v int64) bool { return v >= 30 }))
+		assert.Equal(t, countOld, txn.CountWhereUint("age", func(v uint64) bool { return v >= 30 }))
+		assert.Equal(t, uint64(sumAge), txn.SumWhereUint("age", func(v uint64) bool { return v >= 30 }))
+		assert.Equal(t, 500, txn.Count())
+
+		// Columns which are missing or not numeric do not match anything
+		assert.Equal(t, 0, txn.CountWhereFloat("invalid", func(v float64) bool { return true }))
+		assert.Equal(t, 0.0, txn.SumWhereFloat("name", func(v float64) bool { return true }))
+		return nil
+	})
+}
+
+func TestIntegerAggregates(t *testing.T) {
+	const large = 1<<60 + 1
+	c := NewCollection()
+	c.CreateColumn("signed", ForInt64())
+	c.CreateColumn("unsigned", ForUint64())
+	for _, v := range []int64{large, 3, -2} {
+		c.Insert(func(r Row) error {
+			r.SetInt64("signed", v)
+			r.SetUint64("unsigned", uint64(v+2))
+			return nil
+		})
+	}
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		signed := txn.Int64("signed")
+		assert.Equal(t, int64(large+1), signed.Sum())
+		assert.Equal(t, float64(large+1)/3, signed.Avg())
+
+		min, _ := signed.Min()
+		max, _ := signed.Max()
+		assert.Equal(t, int64(-2), min)
+		assert.Equal(t, int64(large), max)
+
+		unsigned := txn.Uint64("unsigned")
+		assert.Equal(t, uint64(large+7), unsigned.Sum())
+		min2, _ := unsigned.Min()
+		assert.Equal(t, uint64(0), min2)
+		return nil
+	}))
+
+	// The average of an empty selection is undefined
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		txn.WithValue("signed", func(v any) bool { return false })
+		assert.True(t, math.IsNaN(txn.Int64("signed").Avg()))
+		return nil
+	}))
+}
+
+func TestNumericSlice(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("name", ForString())
+	c.CreateColumn("balance", ForFloat64())
+	c.CreateColumn("age", ForInt32())
+	c.CreateIndex("even", "age", func(r Reader) bool {
+		return r.Int()%2 == 0
+	})
+
+	for i := 0; i < ChunkSize+100; i++ {
+		c.Insert(func(r Row) error {
+			r.SetFloat64("balance", float64(i))
+			r.SetInt32("age", int32(i))
+			return nil
+		})
+	}
+
+	// A row without a value should be ignored
+	c.Insert(func(r Row) error {
+		r.SetString("name", "Merlin")
+		return nil
+	})
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		balances := txn.Float64("balance").Slice(nil)
+		assert.Len(t, balances, ChunkSize+100)
+		for i, v := range balances {
+			assert.Equal(t, float64(i), v)
+		}
+
+		// The values are appended into the destination
+		ages := txn.With("even").Int32("age").Slice([]int32{-1})
+		assert.Len(t, ages, (ChunkSize+100)/2+1)
+		assert.Equal(t, []int32{-1, 0, 2, 4}, ages[:4])
+		assert.Equal(t, int32(ChunkSize+98), ages[len(ages)-1])
+		return nil
+	}))
+}
+
+func TestDistinct(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.ElementsMatch(t, []string{"mage", "rogue", "fighter"}, txn.Enum("class").Distinct())
+		assert.ElementsMatch(t, []string{"mage", "rogue", "fighter"}, txn.Enum("class").Values())
+		assert.Equal(t, 3, txn.DistinctCount("class"))
+		assert.Equal(t, 2, txn.DistinctCount("active"))
+		assert.Equal(t, 0, txn.DistinctCount("invalid"))
+
+		// Approximate count of a non-enum column
+		assert.InDelta(t, 500, txn.DistinctCount("serial"), 10)
+		return nil
+	}))
+
+	// Distinct values within a selection
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.With("mage")
+		assert.Equal(t, []string{"mage"}, txn.Enum("class").Distinct())
+		assert.Len(t, txn.Enum("class").Values(), 3)
+		assert.Equal(t, 1, txn.DistinctCount("class"))
+		assert.InDelta(t, txn.Count(), txn.DistinctCount("serial"), 5)
+		return nil
+	}))
+}
+
+func TestFacet(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		facets := txn.Facet(map[string][]string{
+			"race":    nil,
+			"class":   {"mage", "druid"},
+			"name":    {"Roberta Kaufman"},
+			"invalid": nil,
+		})
+
+		assert.Len(t, facets, 3)
+		assert.Len(t, facets["race"], 4)
+		for race, count := range facets["race"] {
+			assert.Equal(t, countOf(txn, "race", race), count)
+		}
+		assert.Equal(t, map[string]int{
+			"mage":  countOf(txn, "class", "mage"),
+			"druid": 0,
+		}, facets["class"])
+		assert.Len(t, facets["name"], 1)
+		return nil
+	}))
+
+	// Facets within a selection
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		txn.With("human", "mage")
+		facets := txn.Facet(map[string][]string{"race": nil, "class": nil})
+		assert.Equal(t, map[string]int{"human": txn.Count()}, facets["race"])
+		assert.Equal(t, map[string]int{"mage": txn.Count()}, facets["class"])
+		return nil
+	}))
+}
+
+// countOf counts the rows with the specified value within a separate transaction
+func countOf(txn *Txn, column, value string) (count int) {
+	txn.owner.Query(func(other *Txn) error {
+		count = other.WithValue(column, func(v any) bool {
+			return v == value
+		}).Count()
+		return nil
+	})
+	return
+}
+
+func TestSample(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		mages := txn.With("mage").Count()
+		assert.Equal(t, 50, txn.Sample(50).Count())
+
+		// The sample must be a subset of the selection
+		class := txn.Enum("class")
+		txn.Range(func(idx uint32) {
+			v, _ := class.Get()
+			assert.Equal(t, "mage", v)
+		})
+
+		assert.Equal(t, 50, txn.Sample(mages).Count())
+		assert.Equal(t, 0, txn.Sample(0).Count())
+		return nil
+	}))
+
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Equal(t, 500, txn.SampleFraction(1).Count())
+		assert.InDelta(t, 250, txn.SampleFraction(0.5).Count(), 60)
+		assert.Equal(t, 0, txn.SampleFraction(0).Count())
+		return nil
+	}))
+}
+
+func TestJoin(t *testing.T) {
+	guilds := NewCollection()
+	guilds.CreateColumn("name", ForKey())
+	guilds.CreateColumn("region", ForEnum())
+	for _, v := range [][2]string{{"knights", "eu"}, {"wizards", "us"}} {
+		guilds.InsertKey(v[0], func(r Row) error {
+			r.SetEnum("region", v[1])
+			return nil
+		})
+	}
+
+	players := NewCollection()
+	players.CreateColumn("name", ForString())
+	players.CreateColumn("guild", ForEnum())
+	players.CreateColumn("age", ForInt())
+	for _, v := range [][2]string{{"Arthur", "knights"}, {"Merlin", "wizards"}, {"Robin", "outlaws"}} {
+		players.Insert(func(r Row) error {
+			r.SetString("name", v[0])
+			r.SetEnum("guild", v[1])
+			return nil
+		})
+	}
+
+	// Join the players with their guilds
+	regions := map[string]string{}
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		return txn.Join(guilds, "guild", "name").Range(func(left, right Row) {
+			name, _ := left.String("name")
+			region, _ := right.Enum("region")
+			regions[name] = region
+		})
+	}))
+	assert.Equal(t, map[string]string{"Arthur": "eu", "Merlin": "us"}, regions)
+
+	// Invalid joins
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		assert.Error(t, txn.Join(guilds, "invalid", "name").Range(func(left, right Row) {}))
+		assert.Error(t, txn.Join(guilds, "guild", "region").Range(func(left, right Row) {}))
+		assert.Error(t, txn.Join(guilds, "age", "name").Range(func(left, right Row) {}))
+		return nil
+	}))
+}
+
+func TestAtomic(t *testing.T) {
+	orders := NewCollection()
+	orders.CreateColumn("item", ForString())
+	orders.CreateColumn("quantity", ForInt())
+
+	inventory := NewCollection(Options{Versioned: true})
+	inventory.CreateColumn("item", ForKey())
+	inventory.CreateColumn("stock", ForInt())
+	assert.NoError(t, inventory.InsertKey("sword", func(r Row) error {
+		r.SetInt("stock", 10)
+		return nil
+	}))
+
+	// Place an order, atomically decrementing the stock
+	order := func(quantity int, version uint64) error {
+		return Atomic(func(tx *MultiTxn) error {
+			if _, err := tx.On(orders).Insert(func(r Row) error {
+				r.SetString("item", "sword")
+				r.SetInt("quantity", quantity)
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			assert.Same(t, tx.On(inventory), tx.On(inventory))
+			idx, _ := inventory.pk.OffsetOf("sword")
+			return tx.On(inventory).QueryAtVersion(idx, version, func(r Row) error {
+				if stock, _ := r.Int("stock"); stock < quantity {
+					return fmt.Errorf("not enough stock")
+				}
+
+				r.MergeInt("stock", -quantity)
+				return nil
+			})
+		})
+	}
+
+	stock := func() (v int) {
+		inventory.QueryKey("sword", func(r Row) error {
+			v, _ = r.Int("stock")
+			return nil
+		})
+		return
+	}
+
+	assert.NoError(t, order(3, 1))
+	assert.Equal(t, 1, orders.Count())
+	assert.Equal(t, 7, stock())
+
+	// Error in the transaction, nothing is committed
+	assert.Error(t, order(20, 2))
+	assert.Equal(t, 1, orders.Count())
+	assert.Equal(t, 7, stock())
+
+	// Version conflict, nothing is committed
+	assert.ErrorIs(t, order(1, 1), ErrVersionConflict)
+	assert.Equal(t, 1, orders.Count())
+	assert.Equal(t, 7, stock())
+}
+
+func TestCommitCoalesce(t *testing.T) {
+	c := NewCollection(Options{CommitCoalesce: time.Millisecond})
+	c.CreateColumn("balance", ForInt64())
+	for i := 0; i < 10; i++ {
+		c.Insert(func(r Row) error {
+			r.SetInt64("balance", 0)
+			return nil
+		})
+	}
+
+	// A single commit is visible once the query returns
+	assert.NoError(t, c.QueryAt(0, func(r Row) error {
+		r.MergeInt64("balance", 1)
+		return nil
+	}))
+	assert.NoError(t, c.QueryAt(0, func(r Row) error {
+		v, _ := r.Int64("balance")
+		assert.Equal(t, int64(1), v)
+		return nil
+	}))
+
+	// Many concurrent commits to nearby rows
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.QueryAt(uint32(j%10), func(r Row) error {
+					r.MergeInt64("balance", 1)
+					return nil
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.NoError(t, c.Query(func(txn *Txn) error {
+		assert.Equal(t, int64(401), txn.Int64("balance").Sum())
+		return nil
+	}))
+}
+
+func TestUpdateAtIf(t *testing.T) {
+	c := NewCollection()
+	c.CreateColumn("stock", ForInt64())
+	c.CreateColumn("balance", ForFloat64())
+	idx, _ := c.Insert(func(r Row) error {
+		r.SetInt64("stock", 10)
+		r.SetFloat64("balance", 100)
+		return nil
+	})
+
+	// Concurrently decrement the stock, as long as it is positive
+	var wg sync.WaitGroup
+	var sold int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Query(func(txn *Txn) error {
+				txn.UpdateAtIf(idx, func(r Row) (bool, error) {
+					if stock, _ := r.Int64("stock"); stock <= 0 {
+						return false, nil
+					}
+
+					r.MergeInt64("stock", -1)
+					atomic.AddInt64(&sold, 1)
+					return true, nil
+				})
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	stock, _ := ReadNumber[int64](c, idx, "stock")
+	assert.Equal(t, int64(0), stock)
+	assert.Equal(t, int64(10), sold)
+
+	// Conditional set of a single value
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		SetIf(r, "balance", 50.0, func(v float64) bool { return v > 500 })
+		r.SetInt64("stock", 5)
+		return nil
+	}))
+
+	balance, _ := ReadNumber[float64](c, idx, "balance")
+	stock, _ = ReadNumber[int64](c, idx, "stock")
+	assert.Equal(t, 100.0, balance)
+	assert.Equal(t, int64(5), stock)
+
+	assert.NoError(t, c.QueryAt(idx, func(r Row) error {
+		SetIf(r, "balance", 50.0, func(v float64) bool { return v == 100 })
+		return nil
+	}))
+	balance, _ = ReadNumber[float64](c, idx, "balance")
+	assert.Equal(t, 50.0, balance)
+
+	// An error rolls back the whole transaction
+	assert.Error(t, c.Query(func(txn *Txn) error {
+		txn.QueryAt(idx, func(r Row) error {
+			r.SetInt64("stock", 1)
+			return nil
+		})
+		txn.UpdateAtIf(idx, func(r Row) (bool, error) {
+			return false, fmt.Errorf("error")
+		})
+		return nil
+	}))
+	stock, _ = ReadNumber[int64](c, idx, "stock")
+	assert.Equal(t, int64(5), stock)
+}
+
+func TestCloneRow(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		idx, err := txn.CloneRow(20)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(500), idx)
+
+		_, err = txn.CloneRow(100000)
+		assert.Error(t, err)
+		return nil
+	}))
+
+	// Every column must have been copied
+	src, _ := players.ReadAt(20, "name", "class", "race", "age", "balance", "active")
+	dst, _ := players.ReadAt(500, "name", "class", "race", "age", "balance", "active")
+	assert.Equal(t, src, dst)
+	assert.Equal(t, 501, players.Count())
+
+	// The indexes must have been updated for the new row
+	srcIndex, _ := players.ReadAt(20, "human", "mage", "old")
+	dstIndex, _ := players.ReadAt(500, "human", "mage", "old")
+	assert.Equal(t, srcIndex, dstIndex)
+
+	// Clone by primary key
+	c := NewCollection()
+	c.CreateColumn("name", ForKey())
+	c.CreateColumn("tags", ForSlice())
+	c.CreateColumn("joined", ForTime())
+	now := time.Unix(1000, 0)
+	c.InsertKey("merlin", func(r Row) error {
+		r.SetSlice("tags", []string{"wizard"})
+		r.SetTime("joined", now)
+		return nil
+	})
+
+	assert.NoError(t, c.CloneKey("merlin", "gandalf"))
+	assert.Error(t, c.CloneKey("merlin", "gandalf"))
+	assert.Error(t, c.CloneKey("arthur", "lancelot"))
+	assert.Error(t, players.CloneKey("a", "b"))
+	assert.NoError(t, c.QueryKey("gandalf", func(r Row) error {
+		tags, _ := r.Slice("tags")
+		joined, _ := r.Time("joined")
+		assert.Equal(t, []string{"wizard"}, tags)
+		assert.True(t, now.Equal(joined))
+		return nil
+	}))
+}
+
+func TestEnumOf(t *testing.T) {
+	coll := NewCollection()
+	assert.NoError(t, coll.CreateColumn("race", ForEnumOf("human", "elf", "dwarf", "orc")))
+	for _, race := range []string{"elf", "dwarf", "elf", "orc"} {
+		_, err := coll.Insert(func(r Row) error {
+			r.SetEnum("race", race)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// Writing a value outside of the vocabulary rolls back the transaction
+	_, err := coll.Insert(func(r Row) error {
+		r.SetEnum("race", "elv")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrUnknownValue)
+	assert.Equal(t, 4, coll.Count())
+
+	vocabulary, ok := coll.Vocabulary("race")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"human", "elf", "dwarf", "orc"}, vocabulary)
+	_, ok = coll.Vocabulary("invalid")
+	assert.False(t, ok)
+
+	assert.NoError(t, coll.Query(func(txn *Txn) error {
+		assert.Equal(t, 3, txn.WithOneOf("race", "elf", "dwarf").Count())
+		return nil
+	}))
+}
+
+func TestEnumID(t *testing.T) {
+	players := loadPlayers(500)
+	assert.NoError(t, players.Query(func(txn *Txn) error {
+		race := txn.Enum("race")
+		elf, ok := race.Lookup("elf")
+		assert.True(t, ok)
+		_, ok = race.Lookup("goblin")
+		assert.False(t, ok)
+
+		// The identifiers map back to the values of the dictionary
+		values := race.Values()
+		assert.Equal(t, "elf", values[elf])
+		assert.NoError(t, txn.Range(func(idx uint32) {
+			id, ok := race.ID()
+			assert.True(t, ok)
+			value, _ := race.Get()
+			assert.Equal(t, value, values[id])
+		}))
+		return nil
+	}))
+
+	count := func(fn func(txn *Txn) *Txn) (n int) {
+		players.Query(func(txn *Txn) error {
+			n = fn(txn).Count()
+			return nil
+		})
+		return
+	}
+
+	assert.Equal(t,
+		count(func(txn *Txn) *Txn { return txn.WithOneOf("race", "elf", "orc") }),
+		count(func(txn *Txn) *Txn {
+			race := txn.Enum("race")
+			elf, _ := race.Lookup("elf")
+			orc, _ := race.Lookup("orc")
+			return txn.WithEnumID("race", elf, orc)
+		}),
+	)
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithEnumID("race") }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithEnumID("name", 0) }))
+	assert.Equal(t, 0, count(func(txn *Txn) *Txn { return txn.WithEnumID("invalid", 0) }))
+}
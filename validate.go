@@ -0,0 +1,144 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"fmt"
+
+	"github.com/kelindar/column/commit"
+)
+
+// WithValidator sets an optional validation function of the values of a column. The values
+// are validated when the transaction is committed, once the merges are resolved, and an
+// invalid value causes the entire transaction to be rolled back with an error wrapping the
+// one returned by the function.
+func WithValidator[T any](fn func(v T) error) func(*option[T]) {
+	return func(v *option[T]) {
+		v.Validate = fn
+	}
+}
+
+// validator represents a column which validates the values written into it
+type validator interface {
+	validates() bool
+	validate(r *commit.Reader) error
+}
+
+// validates returns whether a validation function or a check of the merges is configured
+func (o *option[T]) validates() bool {
+	return o.Validate != nil || o.Check != nil
+}
+
+// check validates the values written by the operations of the reader. The deltas are
+// merged into the current values, loaded with the load function, before being validated.
+func (o *option[T]) check(r *commit.Reader, read func(*commit.Reader) T, load func(uint32) (T, bool)) error {
+	var pending map[uint32]T
+	for r.Next() {
+		var value T
+		idx := r.Index()
+		switch r.Type {
+		case commit.Put:
+			value = read(r)
+		case commit.Merge:
+			value = read(r)
+			if o.Merge == nil {
+				break
+			}
+
+			current, ok := pending[idx]
+			if !ok {
+				current, _ = load(idx)
+			}
+
+			if o.Check != nil {
+				if err := o.Check(current, value); err != nil {
+					return fmt.Errorf("invalid merge at row %d: %w", idx, err)
+				}
+			}
+			value = o.Merge(current, value)
+		case commit.Delete:
+			delete(pending, idx)
+			continue
+		default:
+			continue
+		}
+
+		if o.Validate != nil {
+			if err := o.Validate(value); err != nil {
+				return fmt.Errorf("invalid value at row %d: %w", idx, err)
+			}
+		}
+
+		// Keep the value, in case a delta is merged into it later
+		if pending == nil {
+			pending = make(map[uint32]T, 4)
+		}
+		pending[idx] = value
+	}
+	return nil
+}
+
+// validate validates the values written into the column
+func (c *numericColumn[T]) validate(r *commit.Reader) error {
+	return c.check(r, decodeNumber[T], c.load)
+}
+
+// validate validates the values written into the column
+func (c *columnString) validate(r *commit.Reader) error {
+	return c.check(r, (*commit.Reader).String, c.LoadString)
+}
+
+// validate validates the values written into the column
+func (c *columnEnum) validate(r *commit.Reader) error {
+	return c.check(r, (*commit.Reader).String, c.LoadString)
+}
+
+// validate validates the values written into the column
+func (c *columnBytes) validate(r *commit.Reader) error {
+	return c.check(r, (*commit.Reader).Bytes, c.LoadBytes)
+}
+
+// --------------------------- Transaction ----------------------------
+
+// validated checks whether the transaction writes into a column with a validator
+func (txn *Txn) validated() bool {
+	for _, u := range txn.updates {
+		if column, ok := txn.columnAt(u.Column); ok && !u.IsEmpty() {
+			if v, ok := column.Column.(validator); ok && v.validates() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkValues validates the values written into the columns with a validator, while
+// the chunks are locked so that the deltas can be merged into the current values.
+func (txn *Txn) checkValues() error {
+	for _, u := range txn.updates {
+		column, ok := txn.columnAt(u.Column)
+		if !ok {
+			continue
+		}
+
+		v, ok := column.Column.(validator)
+		if !ok || !v.validates() {
+			continue
+		}
+
+		var err error
+		u.RangeChunks(func(chunk commit.Chunk) {
+			txn.reader.Range(u, chunk, func(r *commit.Reader) {
+				if err == nil {
+					err = v.validate(r)
+				}
+			})
+		})
+
+		if err != nil {
+			return fmt.Errorf("column: unable to set '%s', %w", u.Column, err)
+		}
+	}
+	return nil
+}
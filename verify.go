@@ -0,0 +1,231 @@
+// Copyright (c) Roman Atachiants and contributors. All rights reserved.
+// Licensed under the MIT license. See LICENSE file in the project root for details.
+
+package column
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"reflect"
+
+	"github.com/kelindar/bitmap"
+	"github.com/kelindar/column/commit"
+)
+
+// VerifyOptions represents the options for comparing two collections.
+type VerifyOptions struct {
+	Context context.Context // The context which can be used to interrupt the comparison
+	Columns []string        // The columns to compare, all of the non-computed ones if empty
+	Limit   int             // The maximum number of differences to report, 100 by default
+}
+
+// DiffReport represents the result of the comparison of two collections.
+type DiffReport struct {
+	Chunks      int          // The number of chunks compared
+	Rows        int          // The number of rows compared
+	Differences []Difference // The first differences found
+	Truncated   bool         // Whether there are more differences than the limit
+}
+
+// Equal returns whether no difference was found.
+func (r DiffReport) Equal() bool {
+	return len(r.Differences) == 0
+}
+
+// Difference represents a value which differs between the primary and the replica. The
+// column is empty if the row exists in only one of the collections.
+type Difference struct {
+	Column  string // The column in which the values differ
+	Index   uint32 // The index of the row
+	Primary any    // The value in the primary, or nil if not present
+	Replica any    // The value in the replica, or nil if not present
+}
+
+// String returns a human-readable representation of the difference
+func (d Difference) String() string {
+	if d.Column == "" {
+		return fmt.Sprintf("row %d: exists in primary=%v, replica=%v", d.Index, d.Primary, d.Replica)
+	}
+	return fmt.Sprintf("column '%s' (row %d): primary=%v, replica=%v", d.Column, d.Index, d.Primary, d.Replica)
+}
+
+// Verify compares the rows and the values of two collections, chunk by chunk. The rows of
+// a chunk of the primary are copied under its read lock, and then compared with the chunk
+// of the replica under the read lock of the replica, so the locks of both collections are
+// never held at once. It reports the first differences found, which can be used to prove
+// that a replica has converged with its primary. The columns to compare must exist in
+// both of the collections.
+func Verify(primary, replica *Collection, opts VerifyOptions) (report DiffReport, err error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 100
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+
+	pairs, err := verifyColumns(primary, replica, opts.Columns)
+	if err != nil {
+		return report, err
+	}
+
+	chunks := primary.chunks()
+	if n := replica.chunks(); n > chunks {
+		chunks = n
+	}
+
+	var copied chunkCopy
+	for chunk := commit.Chunk(0); int(chunk) < chunks; chunk++ {
+		if err := opts.Context.Err(); err != nil {
+			return report, err
+		}
+
+		report.Chunks++
+		err := primary.readLockChunk(chunk)
+		copied.load(primary, pairs, chunk)
+		primary.slock.RUnlock(uint(chunk))
+		if err != nil {
+			return report, err
+		}
+
+		err = replica.readLockChunk(chunk)
+		done := report.verifyChunk(&copied, replica, pairs, chunk, opts.Limit)
+		replica.slock.RUnlock(uint(chunk))
+		if err != nil {
+			return report, err
+		}
+		if done {
+			report.Truncated = true
+			return report, nil
+		}
+	}
+	return report, nil
+}
+
+// verifyColumns resolves the pairs of columns to compare
+func verifyColumns(primary, replica *Collection, names []string) ([][2]*column, error) {
+	if len(names) == 0 {
+		primary.cols.Range(func(column *column) {
+			if !isComputed(column) {
+				names = append(names, column.name)
+			}
+		})
+	}
+
+	pairs := make([][2]*column, 0, len(names))
+	for _, name := range names {
+		p, ok := primary.cols.Load(name)
+		if !ok {
+			return nil, fmt.Errorf("column: unable to verify, column '%s' does not exist in primary", name)
+		}
+
+		r, ok := replica.cols.Load(name)
+		if !ok {
+			return nil, fmt.Errorf("column: unable to verify, column '%s' does not exist in replica", name)
+		}
+		pairs = append(pairs, [2]*column{p, r})
+	}
+	return pairs, nil
+}
+
+// chunkCopy represents a copy of the rows of a chunk of the primary
+type chunkCopy struct {
+	fill   bitmap.Bitmap // The rows of the chunk
+	values []verifyValue // The values of the rows, for every column of every row
+}
+
+// verifyValue represents a value of a row copied from the primary
+type verifyValue struct {
+	value any  // The value of the row
+	ok    bool // Whether the row has a value
+}
+
+// load copies the rows of a chunk of the collection. The caller must hold the read lock
+// of the chunk.
+func (c *chunkCopy) load(owner *Collection, pairs [][2]*column, chunk commit.Chunk) {
+	owner.lock.RLock()
+	chunk.OfBitmap(owner.fill).Clone(&c.fill)
+	owner.lock.RUnlock()
+
+	offset := chunk.Min()
+	c.values = c.values[:0]
+	c.fill.Range(func(x uint32) {
+		for _, pair := range pairs {
+			v, ok := pair[0].Value(offset + x)
+			c.values = append(c.values, verifyValue{value: copyValue(v), ok: ok})
+		}
+	})
+}
+
+// verifyChunk compares a chunk copied from the primary with the chunk of the replica, and
+// returns whether the limit of differences was exceeded. The caller must hold the read
+// lock of the chunk of the replica.
+func (r *DiffReport) verifyChunk(copied *chunkCopy, replica *Collection, pairs [][2]*column, chunk commit.Chunk, limit int) bool {
+	replica.lock.RLock()
+	p, q := copied.fill, chunk.OfBitmap(replica.fill).Clone(nil)
+	replica.lock.RUnlock()
+
+	// Compare the fill lists first
+	offset := chunk.Min()
+	for i := 0; i < len(p) || i < len(q); i++ {
+		var x, y uint64
+		if i < len(p) {
+			x = p[i]
+		}
+		if i < len(q) {
+			y = q[i]
+		}
+
+		for diff := x ^ y; diff != 0; diff &= diff - 1 {
+			bit := uint32(i<<6) + uint32(bits.TrailingZeros64(diff))
+			if r.add(Difference{
+				Index:   offset + bit,
+				Primary: x&(1<<(bit&0x3f)) != 0,
+				Replica: y&(1<<(bit&0x3f)) != 0,
+			}, limit) {
+				return true
+			}
+		}
+	}
+
+	// Compare the values of the rows present in both collections
+	done, at := false, 0
+	p.Range(func(x uint32) {
+		values := copied.values[at : at+len(pairs)]
+		at += len(pairs)
+		if done || !q.Contains(x) {
+			return
+		}
+
+		r.Rows++
+		idx := offset + x
+		for i, pair := range pairs {
+			v1, ok1 := values[i].value, values[i].ok
+			v2, ok2 := pair[1].Value(idx)
+			if ok1 == ok2 && reflect.DeepEqual(v1, v2) {
+				continue
+			}
+
+			if done = r.add(Difference{
+				Column:  pair[0].name,
+				Index:   idx,
+				Primary: v1,
+				Replica: copyValue(v2),
+			}, limit); done {
+				return
+			}
+		}
+	})
+	return done
+}
+
+// add adds a difference to the report, unless the limit was already reached, in which case
+// it returns true as there are more differences than the limit
+func (r *DiffReport) add(d Difference, limit int) bool {
+	if len(r.Differences) >= limit {
+		return true
+	}
+
+	r.Differences = append(r.Differences, d)
+	return false
+}